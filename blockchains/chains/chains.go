@@ -0,0 +1,70 @@
+// Package chains is the registry of the blockchains supported by the
+// benchmark. A chain is a client interface, used by the secondaries to send
+// the transactions, and a workload generator, used by the primary to create
+// them, registered under the name given in the chain configuration.
+//
+// The chains of this repository are registered when the client interfaces and
+// workload generators are initialised. Chains maintained out of the tree
+// register themselves when their package is imported:
+//
+//	func init() {
+//		chains.Register("mychain",
+//			func() clientinterfaces.BlockchainInterface { return &MyInterface{} },
+//			func() workloadgenerators.WorkloadGenerator { return &MyGenerator{} })
+//	}
+//
+// and are added to the benchmark with a blank import in package main.
+package chains
+
+import (
+	"diablo-benchmark/blockchains/clientinterfaces"
+	"diablo-benchmark/blockchains/workloadgenerators"
+	"sort"
+)
+
+// Chain describes a registered chain
+type Chain struct {
+	Name         string // Name of the chain in the chain configuration
+	HasInterface bool   // Is a client interface registered for the chain?
+	HasGenerator bool   // Is a workload generator registered for the chain?
+}
+
+// Complete returns true if the chain can be benchmarked, it needs both a
+// client interface and a workload generator.
+func (c Chain) Complete() bool {
+	return c.HasInterface && c.HasGenerator
+}
+
+// Register registers the client interface and workload generator of a chain
+// under its name. It panics if a chain is already registered with this name.
+func Register(name string, newInterface func() clientinterfaces.BlockchainInterface, newGenerator func() workloadgenerators.WorkloadGenerator) {
+	clientinterfaces.RegisterBlockchainInterface(name, newInterface)
+	workloadgenerators.RegisterWorkloadGenerator(name, newGenerator)
+}
+
+// List returns the registered chains sorted by name
+func List() []Chain {
+	byName := make(map[string]*Chain)
+	get := func(name string) *Chain {
+		if _, ok := byName[name]; !ok {
+			byName[name] = &Chain{Name: name}
+		}
+		return byName[name]
+	}
+
+	for _, name := range clientinterfaces.BlockchainInterfaces() {
+		get(name).HasInterface = true
+	}
+
+	for _, name := range workloadgenerators.WorkloadGenerators() {
+		get(name).HasGenerator = true
+	}
+
+	list := make([]Chain, 0, len(byName))
+	for _, c := range byName {
+		list = append(list, *c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return list
+}
@@ -0,0 +1,47 @@
+package chains
+
+import (
+	"diablo-benchmark/blockchains/clientinterfaces"
+	"diablo-benchmark/blockchains/workloadgenerators"
+	"diablo-benchmark/core/configs"
+	"testing"
+)
+
+func TestBuiltinChains(t *testing.T) {
+	found := make(map[string]bool)
+	for _, c := range List() {
+		if !c.Complete() {
+			t.Errorf("chain %s is not complete: %+v", c.Name, c)
+		}
+		found[c.Name] = true
+	}
+
+	for _, name := range []string{"ethereum", "fabric", "mock", "quorum"} {
+		if !found[name] {
+			t.Errorf("chain %s is not registered", name)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("test-chain",
+		func() clientinterfaces.BlockchainInterface { return &clientinterfaces.MockInterface{} },
+		func() workloadgenerators.WorkloadGenerator { return &workloadgenerators.MockWorkloadGenerator{} })
+
+	config := &configs.ChainConfig{Name: "test-chain"}
+	if _, err := clientinterfaces.GetBlockchainInterface(config); err != nil {
+		t.Errorf("registered interface not found: %s", err.Error())
+	}
+	if _, err := workloadgenerators.GetWorkloadGenerator(config); err != nil {
+		t.Errorf("registered generator not found: %s", err.Error())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected registering a chain twice to panic")
+		}
+	}()
+	Register("test-chain",
+		func() clientinterfaces.BlockchainInterface { return &clientinterfaces.MockInterface{} },
+		func() workloadgenerators.WorkloadGenerator { return &workloadgenerators.MockWorkloadGenerator{} })
+}
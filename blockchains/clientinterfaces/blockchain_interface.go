@@ -7,26 +7,108 @@
 package clientinterfaces
 
 import (
+	"context"
 	"diablo-benchmark/blockchains/workloadgenerators"
 	"diablo-benchmark/core/configs"
 	"diablo-benchmark/core/results"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // GenericInterface provides the required fields of the blockchain interface so that
 // All information can be accessed
 type GenericInterface struct {
-	Nodes     []string // List of node "address:port" strings
-	TotalTx   int      // Total nuimber of transactions
-	NumTxDone uint64   // The number of completed transactions
-	NumTxSent uint64   // Number of transactions sent
-	Success   uint64   // Number of successful transactions
-	Fail      uint64   // Number of failed transactions
-	Window    int      // Window to measure throughput
+	Nodes     []string      // List of node "address:port" strings
+	TotalTx   int           // Total nuimber of transactions
+	NumTxDone uint64        // The number of completed transactions
+	NumTxSent uint64        // Number of transactions sent
+	Success   uint64        // Number of successful transactions
+	Fail      uint64        // Number of failed transactions
+	Window    int           // Window to measure throughput
+	doneCh    chan struct{} // Signalled when transactions are done
+	doneOnce  sync.Once     // Creates the done channel on first use
+	intended  int64         // Scheduled send time of the transaction being sent (unix ns), 0 if not scheduled
 }
 
 // GetTxDone returns the number of transactions completed
 func (gi *GenericInterface) GetTxDone() uint64 {
-	return gi.NumTxDone
+	return atomic.LoadUint64(&gi.NumTxDone)
+}
+
+// GetTxFailed returns the number of transactions that failed, they are
+// counted in the completed transactions
+func (gi *GenericInterface) GetTxFailed() uint64 {
+	return atomic.LoadUint64(&gi.Fail)
+}
+
+// AddTxDone adds n transactions to the number of completed (committed or
+// failed) transactions, and signals the workers waiting for them.
+func (gi *GenericInterface) AddTxDone(n uint64) {
+	if n == 0 {
+		return
+	}
+
+	atomic.AddUint64(&gi.NumTxDone, n)
+
+	select {
+	case gi.doneChannel() <- struct{}{}:
+	default:
+		// A signal is already pending
+	}
+}
+
+// TxDoneNotify returns the channel signalled when transactions are done
+func (gi *GenericInterface) TxDoneNotify() <-chan struct{} {
+	return gi.doneChannel()
+}
+
+// doneChannel returns the done channel, creating it if needed
+func (gi *GenericInterface) doneChannel() chan struct{} {
+	gi.doneOnce.Do(func() {
+		gi.doneCh = make(chan struct{}, 1)
+	})
+	return gi.doneCh
+}
+
+// SetIntendedSendTime sets the time at which the next transaction sent was
+// scheduled to be sent, from its interval in the workload and its position in
+// it. A zero time means the transaction was not scheduled.
+func (gi *GenericInterface) SetIntendedSendTime(t time.Time) {
+	if t.IsZero() {
+		atomic.StoreInt64(&gi.intended, 0)
+		return
+	}
+
+	atomic.StoreInt64(&gi.intended, t.UnixNano())
+}
+
+// IntendedSendTime returns the time at which the transaction being sent was
+// scheduled to be sent, or the given send time if it was not scheduled or
+// is sent early.
+func (gi *GenericInterface) IntendedSendTime(sent time.Time) time.Time {
+	ns := atomic.LoadInt64(&gi.intended)
+	if ns == 0 {
+		return sent
+	}
+
+	if intended := time.Unix(0, ns); intended.Before(sent) {
+		return intended
+	}
+
+	return sent
+}
+
+// responseLatency returns the latency of a committed transaction in
+// milliseconds measured from its intended send time rather than from the time
+// it was sent, so that the delays of the client are included. Without an
+// intended time, this is the service latency.
+func responseLatency(intended time.Time, sent time.Time, committed time.Time) float64 {
+	if intended.IsZero() || intended.After(sent) {
+		intended = sent
+	}
+
+	return float64(committed.Sub(intended).Milliseconds())
 }
 
 // SetWindow sets the window attribute of transactions
@@ -70,8 +152,9 @@ type BlockchainInterface interface {
 	// SendRawTransactions sends the raw transaction bytes to the blockchain
 	// It is safe to assume that these bytes will be formatted correctly according to the chosen blockchain.
 	// The transactions are generated through the workload to relieve the signing and encoding during timed
-	// benchmarks
-	SendRawTransaction(tx interface{}) error
+	// benchmarks. The transactions still in flight when the context is
+	// cancelled are stopped when the chain allows it.
+	SendRawTransaction(ctx context.Context, tx interface{}) error
 
 	// SecureRead reads the value from the chain, this requires the client to connect to _multiple_ nodes and asks
 	// for the value. This ensures that the value read is "secure" - the same value must be returned
@@ -89,10 +172,27 @@ type BlockchainInterface interface {
 	// This is already implemented with the GenericInterface
 	GetTxDone() uint64
 
+	// GetTxFailed returns the number of completed transactions that failed.
+	// This is already implemented with the GenericInterface
+	GetTxFailed() uint64
+
 	// ParseBlocksForTransactions retrieves block information from start to end index and
 	// is used as a post-benchmark check to learn about the block and transactions.
 	ParseBlocksForTransactions(startNumber uint64, endNumber uint64) error
 
+	// TxDoneNotify returns a channel signalled when transactions are done, after
+	// GetTxDone has increased. It is used to send transactions in closed loop.
+	// This is already implemented with the GenericInterface, implementations
+	// must count the done transactions with AddTxDone.
+	TxDoneNotify() <-chan struct{}
+
+	// SetIntendedSendTime sets the time at which the next transaction given to
+	// SendRawTransaction was scheduled to be sent, to measure the response
+	// latency including the delays of the client (coordinated omission).
+	// This is already implemented with the GenericInterface, implementations
+	// read it with IntendedSendTime when the transaction is given to them.
+	SetIntendedSendTime(t time.Time)
+
 	// SetWindow sets the transaction window for the generic interface.
 	// This is to be used for the throughput over time calculations.
 	SetWindow(window int)
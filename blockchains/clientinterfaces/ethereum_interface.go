@@ -5,41 +5,134 @@ package clientinterfaces
 
 import (
 	"context"
+	"diablo-benchmark/blockchains/types"
 	"diablo-benchmark/blockchains/workloadgenerators"
 	"diablo-benchmark/core/configs"
 	"diablo-benchmark/core/results"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"go.uber.org/zap"
 )
 
+// Confirmation modes of the Ethereum interface, set with "confirmation" in the
+// extra section of the chain configuration.
+const (
+	// EthereumConfirmBlock marks a transaction done as soon as its hash is seen in a block
+	EthereumConfirmBlock = "block"
+	// EthereumConfirmReceipt fetches the receipts of the transactions seen in a block
+	// and only counts the ones that did not revert as successful.
+	EthereumConfirmReceipt = "receipt"
+)
+
+// secureReadTimeout bounds the time to wait for the answer of a node to a secure read
+const secureReadTimeout = 5 * time.Second
+
+// defaultReceiptBatchSize is the number of receipts requested in one batch
+// if "receiptBatchSize" is not given in the chain configuration.
+const defaultReceiptBatchSize = 100
+
+// receiptRetries is the number of attempts to fetch a batch of receipts, the
+// delay between the attempts starts at receiptRetryDelay and doubles.
+const (
+	receiptRetries    = 5
+	receiptRetryDelay = 100 * time.Millisecond
+)
+
+// revertSelector is the function selector of "Error(string)" used to encode revert reasons
+var revertSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+
+// ethereumSendFunc sends a signed transaction to the chain
+type ethereumSendFunc func(ctx context.Context, tx *ethtypes.Transaction) error
+
 // EthereumInterface is the the Ethereum implementation of the clientinterface
 // Provides functionality to interaact with the Ethereum blockchain
 type EthereumInterface struct {
-	PrimaryNode      *ethclient.Client      // The primary node connected for this client.
-	SecondaryNodes   []*ethclient.Client    // The other node information (for secure reads etc.)
-	SubscribeDone    chan bool              // Event channel that will unsub from events
-	TransactionInfo  map[string][]time.Time // Transaction information
-	HandlersStarted  bool                   // Have the handlers been initiated?
-	StartTime        time.Time              // Start time of the benchmark
-	ThroughputTicker *time.Ticker           // Ticker for throughput (1s)
-	Throughputs      []float64              // Throughput over time with 1 second intervals
+	PrimaryNode      *ethclient.Client                 // The primary node connected for this client.
+	PrimaryRPC       *rpc.Client                       // RPC connection of the primary node, used for batch requests
+	SecondaryNodes   []*ethclient.Client               // The other node information (for secure reads etc.)
+	SecureReadQuorum int                               // Number of matching answers required by a secure read, 0 for t+1
+	SubscribeDone    chan bool                         // Event channel that will unsub from events
+	TransactionInfo  map[string][]time.Time            // Transaction information
+	Receipts         map[string]*types.EthereumReceipt // Receipt information of the committed transactions (receipt mode)
+	ConfirmationMode string                            // How transactions are confirmed, "block" or "receipt"
+	ReceiptBatchSize int                               // Number of receipts fetched per batch request
+	HandlersStarted  bool                              // Have the handlers been initiated?
+	StartTime        time.Time                         // Start time of the benchmark
+	ThroughputTicker *time.Ticker                      // Ticker for throughput (1s)
+	Throughputs      []float64                         // Throughput over time with 1 second intervals
+	sentTxs          map[string]*ethtypes.Transaction  // Sent transactions, used to replay the reverted ones (receipt mode)
+	failedSends      map[string]bool                   // Transactions that could not be sent
+	intendedTimes    map[string]time.Time              // Scheduled send time of the transactions, for the response latency
+	failedReads      map[string]bool                   // Secure reads that did not reach the quorum
+	nodeAddrs        []string                          // Address of the primary then the secondary nodes, in order of connection
+	numReads         uint64                            // Number of secure reads sent, used to identify them
+	sendTx           ethereumSendFunc                  // Sends a signed transaction, nil to send it to the primary node
+	txLock           sync.Mutex                        // Protects the transaction information
 	GenericInterface
 }
 
+func init() {
+	RegisterBlockchainInterface("ethereum", func() BlockchainInterface { return &EthereumInterface{} })
+}
+
 // Init initialises the list of nodes
 func (e *EthereumInterface) Init(chainConfig *configs.ChainConfig) {
 	e.Nodes = chainConfig.Nodes
 	e.TransactionInfo = make(map[string][]time.Time, 0)
+	e.Receipts = make(map[string]*types.EthereumReceipt, 0)
+	e.sentTxs = make(map[string]*ethtypes.Transaction, 0)
+	e.failedSends = make(map[string]bool, 0)
+	e.intendedTimes = make(map[string]time.Time, 0)
+	e.failedReads = make(map[string]bool, 0)
 	e.SubscribeDone = make(chan bool)
 	e.HandlersStarted = false
 	e.NumTxDone = 0
+	e.ConfirmationMode = EthereumConfirmBlock
+	e.ReceiptBatchSize = defaultReceiptBatchSize
+
+	extra, err := getExtraMap(chainConfig)
+	if err != nil {
+		zap.L().Warn("ignoring extra chain configuration", zap.Error(err))
+		return
+	}
+
+	mode, err := getExtraString(extra, "confirmation", EthereumConfirmBlock)
+	if err != nil || (mode != EthereumConfirmBlock && mode != EthereumConfirmReceipt) {
+		zap.L().Warn("unknown confirmation mode, confirming with blocks",
+			zap.String("confirmation", mode))
+		mode = EthereumConfirmBlock
+	}
+	e.ConfirmationMode = mode
+
+	batchSize, err := getExtraNumber(extra, "receiptBatchSize", defaultReceiptBatchSize)
+	if err != nil || batchSize < 1 {
+		zap.L().Warn("invalid receipt batch size, using default",
+			zap.Int("receiptBatchSize", defaultReceiptBatchSize))
+		batchSize = defaultReceiptBatchSize
+	}
+	e.ReceiptBatchSize = int(batchSize)
+
+	quorum, err := getExtraNumber(extra, "secureReadQuorum", 0)
+	if err != nil || quorum < 0 || int(quorum) > len(chainConfig.Nodes) {
+		zap.L().Warn("invalid secure read quorum, using t+1",
+			zap.Int("nodes", len(chainConfig.Nodes)))
+		quorum = 0
+	}
+	e.SecureReadQuorum = int(quorum)
 }
 
 // Cleanup formats results and unsubscribes from the blockchain
@@ -53,37 +146,66 @@ func (e *EthereumInterface) Cleanup() results.Results {
 	}
 
 	txLatencies := make([]float64, 0)
+	responseLatencies := make([]float64, 0)
 	var avgLatency float64
+	var avgResponseLatency float64
 
 	var endTime time.Time
 
 	success := uint(0)
-	fails := uint(e.Fail)
-
-	for _, v := range e.TransactionInfo {
+	reverted := uint(0)
+	dropped := uint(0)
+	sendFailed := uint(0)
+	readFailed := uint(0)
+	gasUsed := uint64(0)
+	revertReasons := make(map[string]uint)
+
+	e.txLock.Lock()
+	for hash, v := range e.TransactionInfo {
 		if len(v) > 1 {
 			txLatency := v[1].Sub(v[0]).Milliseconds()
 			txLatencies = append(txLatencies, float64(txLatency))
 			avgLatency += float64(txLatency)
+			responseLat := responseLatency(e.intendedTimes[hash], v[0], v[1])
+			responseLatencies = append(responseLatencies, responseLat)
+			avgResponseLatency += responseLat
 			if v[1].After(endTime) {
 				endTime = v[1]
 			}
 
 			success++
+		} else if e.failedSends[hash] {
+			sendFailed++
+		} else if e.failedReads[hash] {
+			readFailed++
+		} else if r, ok := e.Receipts[hash]; ok && r.Status == ethtypes.ReceiptStatusFailed {
+			reverted++
+			revertReasons[r.RevertReason]++
 		} else {
-			fails++
+			dropped++
 		}
 	}
 
+	for _, r := range e.Receipts {
+		gasUsed += r.GasUsed
+	}
+	e.txLock.Unlock()
+
+	fails := sendFailed + readFailed + reverted + dropped
+
 	zap.L().Debug("Statistics being returned",
 		zap.Uint("success", success),
-		zap.Uint("fail", fails))
+		zap.Uint("fail", fails),
+		zap.Uint("reverted", reverted),
+		zap.Uint("dropped", dropped),
+		zap.Uint("sendFailed", sendFailed))
 
 	// Calculate the throughput and latencies
 	var throughput float64
 	if len(txLatencies) > 0 {
 		throughput = (float64(e.NumTxDone) - float64(e.Fail)) / (endTime.Sub(e.StartTime).Seconds())
 		avgLatency = avgLatency / float64(len(txLatencies))
+		avgResponseLatency = avgResponseLatency / float64(len(responseLatencies))
 	} else {
 		avgLatency = 0
 		throughput = 0
@@ -106,12 +228,19 @@ func (e *EthereumInterface) Cleanup() results.Results {
 	)
 
 	return results.Results{
-		TxLatencies:       txLatencies,
-		AverageLatency:    avgLatency,
-		Throughput:        averageThroughput,
-		ThroughputSeconds: calculatedThroughputSeconds,
-		Success:           success,
-		Fail:              fails,
+		TxLatencies:            txLatencies,
+		AverageLatency:         avgLatency,
+		ResponseLatencies:      responseLatencies,
+		AverageResponseLatency: avgResponseLatency,
+		Throughput:             averageThroughput,
+		ThroughputSeconds:      calculatedThroughputSeconds,
+		Success:                success,
+		Fail:                   fails,
+		Reverted:               reverted,
+		Dropped:                dropped,
+		SendFailed:             sendFailed,
+		GasUsed:                gasUsed,
+		RevertReasons:          revertReasons,
 	}
 }
 
@@ -143,6 +272,13 @@ func (e *EthereumInterface) ParseWorkload(workload workloadgenerators.WorkerThre
 	for _, v := range workload {
 		intervalTxs := make([]interface{}, 0)
 		for _, txBytes := range v {
+			// Reads are not signed transactions, they are marked by the generator
+			var read types.EthereumRead
+			if err := json.Unmarshal(txBytes, &read); err == nil && read.SecureRead {
+				intervalTxs = append(intervalTxs, &read)
+				continue
+			}
+
 			t := ethtypes.Transaction{}
 			err := t.UnmarshalJSON(txBytes)
 			if err != nil {
@@ -169,7 +305,14 @@ func (e *EthereumInterface) parseBlocksForTransactions(blockNumber *big.Int) {
 	}
 
 	tNow := time.Now()
+
+	if e.ConfirmationMode == EthereumConfirmReceipt {
+		e.confirmWithReceipts(block, tNow)
+		return
+	}
+
 	var tAdd uint64
+	e.txLock.Lock()
 	for _, v := range block.Transactions() {
 		tHash := v.Hash().String()
 		if _, ok := e.TransactionInfo[tHash]; ok {
@@ -177,8 +320,166 @@ func (e *EthereumInterface) parseBlocksForTransactions(blockNumber *big.Int) {
 			tAdd++
 		}
 	}
+	e.txLock.Unlock()
+
+	e.AddTxDone(tAdd)
+}
+
+// confirmWithReceipts fetches, in batches, the receipts of the transactions
+// of this client included in the block. Successful transactions are marked
+// committed at the given time, reverted ones are marked failed and their
+// revert reason is recorded.
+func (e *EthereumInterface) confirmWithReceipts(block *ethtypes.Block, tNow time.Time) {
+	hashes := make([]common.Hash, 0)
+	e.txLock.Lock()
+	for _, v := range block.Transactions() {
+		if info, ok := e.TransactionInfo[v.Hash().String()]; ok && len(info) == 1 {
+			hashes = append(hashes, v.Hash())
+		}
+	}
+	e.txLock.Unlock()
+
+	for start := 0; start < len(hashes); start += e.ReceiptBatchSize {
+		end := start + e.ReceiptBatchSize
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+
+		batch := make([]rpc.BatchElem, end-start)
+		receipts := make([]*ethtypes.Receipt, end-start)
+		for i, h := range hashes[start:end] {
+			batch[i] = rpc.BatchElem{
+				Method: "eth_getTransactionReceipt",
+				Args:   []interface{}{h},
+				Result: &receipts[i],
+			}
+		}
+
+		if err := e.fetchReceipts(block.NumberU64(), batch); err != nil {
+			zap.L().Error("failed to fetch receipts, the transactions stay pending",
+				zap.Uint64("block", block.NumberU64()),
+				zap.Int("transactions", len(batch)),
+				zap.Error(err))
+			continue
+		}
+
+		for i, elem := range batch {
+			tHash := hashes[start+i].String()
+			if elem.Error != nil || receipts[i] == nil {
+				zap.L().Debug("missing receipt",
+					zap.String("hash", tHash),
+					zap.Error(elem.Error))
+				continue
+			}
+
+			receipt := &types.EthereumReceipt{
+				Hash:        tHash,
+				BlockNumber: block.NumberU64(),
+				Status:      receipts[i].Status,
+				GasUsed:     receipts[i].GasUsed,
+			}
+
+			if receipt.Status == ethtypes.ReceiptStatusFailed {
+				receipt.RevertReason = e.revertReason(tHash, block.Number())
+			}
+
+			e.txLock.Lock()
+			e.Receipts[tHash] = receipt
+			if receipt.Status == ethtypes.ReceiptStatusSuccessful {
+				e.TransactionInfo[tHash] = append(e.TransactionInfo[tHash], tNow)
+			}
+			e.txLock.Unlock()
+
+			if receipt.Status == ethtypes.ReceiptStatusSuccessful {
+				atomic.AddUint64(&e.Success, 1)
+			} else {
+				atomic.AddUint64(&e.Fail, 1)
+			}
+			e.AddTxDone(1)
+		}
+	}
+}
+
+// fetchReceipts requests the batch of receipts, retrying with backoff when the
+// request fails so that its transactions are not reported as dropped.
+func (e *EthereumInterface) fetchReceipts(block uint64, batch []rpc.BatchElem) error {
+	delay := receiptRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := e.PrimaryRPC.BatchCallContext(context.Background(), batch)
+		if err == nil || attempt == receiptRetries {
+			return err
+		}
+
+		zap.L().Warn("failed to fetch receipts, retrying",
+			zap.Uint64("block", block),
+			zap.Int("attempt", attempt),
+			zap.Error(err))
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// revertReason replays the reverted transaction as a call on the state of the
+// parent block to retrieve the reason given by the contract. This is best
+// effort: transactions earlier in the same block are not taken into account.
+func (e *EthereumInterface) revertReason(hash string, blockNumber *big.Int) string {
+	e.txLock.Lock()
+	tx, ok := e.sentTxs[hash]
+	e.txLock.Unlock()
+	if !ok {
+		return ""
+	}
+
+	var signer ethtypes.Signer = ethtypes.HomesteadSigner{}
+	if tx.Protected() {
+		signer = ethtypes.NewEIP155Signer(tx.ChainId())
+	}
+
+	from, err := ethtypes.Sender(signer, tx)
+	if err != nil {
+		return ""
+	}
+
+	msg := ethereum.CallMsg{
+		From:     from,
+		To:       tx.To(),
+		Gas:      tx.Gas(),
+		GasPrice: tx.GasPrice(),
+		Value:    tx.Value(),
+		Data:     tx.Data(),
+	}
+
+	parent := new(big.Int).Sub(blockNumber, big.NewInt(1))
+	out, err := e.PrimaryNode.CallContract(context.Background(), msg, parent)
+	if err != nil {
+		// Nodes return the revert data alongside the error
+		if de, ok := err.(rpc.DataError); ok {
+			if data, ok := de.ErrorData().(string); ok {
+				if b, decErr := hex.DecodeString(strings.TrimPrefix(data, "0x")); decErr == nil {
+					if reason := decodeRevertReason(b); reason != "" {
+						return reason
+					}
+				}
+			}
+		}
+		return err.Error()
+	}
 
-	atomic.AddUint64(&e.NumTxDone, tAdd)
+	return decodeRevertReason(out)
+}
+
+// decodeRevertReason decodes the ABI encoded "Error(string)" returned by a reverted call
+func decodeRevertReason(data []byte) string {
+	if len(data) < 4+64 || string(data[:4]) != string(revertSelector) {
+		return ""
+	}
+
+	length := binary.BigEndian.Uint64(data[4+56 : 4+64])
+	if uint64(len(data)) < 4+64+length {
+		return ""
+	}
+
+	return string(data[4+64 : 4+64+length])
 }
 
 // EventHandler subscribes to the blocks and handles the incoming information about the transactions
@@ -217,11 +518,13 @@ func (e *EthereumInterface) ParseBlocksForTransactions(startNumber uint64, endNu
 			return err
 		}
 
+		e.txLock.Lock()
 		for _, v := range b.TransactionHashes {
 			if _, ok := e.TransactionInfo[v]; ok {
 				e.TransactionInfo[v] = append(e.TransactionInfo[v], time.Unix(int64(b.Timestamp), 0))
 			}
 		}
+		e.txLock.Unlock()
 	}
 
 	return nil
@@ -235,15 +538,17 @@ func (e *EthereumInterface) ConnectOne(id int) error {
 		return errors.New("invalid client ID")
 	}
 
-	// Connect to the node
-	c, err := ethclient.Dial(fmt.Sprintf("ws://%s", e.Nodes[id]))
+	// Connect to the node, keeping the RPC client for batch requests
+	r, err := rpc.Dial(fmt.Sprintf("ws://%s", e.Nodes[id]))
 
 	// If there's an error, raise it.
 	if err != nil {
 		return err
 	}
 
-	e.PrimaryNode = c
+	e.PrimaryRPC = r
+	e.PrimaryNode = ethclient.NewClient(r)
+	e.nodeAddrs = []string{e.Nodes[id]}
 
 	if !e.HandlersStarted {
 		go e.EventHandler()
@@ -276,6 +581,7 @@ func (e *EthereumInterface) ConnectAll(primaryID int) error {
 			}
 
 			e.SecondaryNodes = append(e.SecondaryNodes, c)
+			e.nodeAddrs = append(e.nodeAddrs, node)
 		}
 	}
 
@@ -287,7 +593,7 @@ func (e *EthereumInterface) DeploySmartContract(tx interface{}) (interface{}, er
 	txSigned := tx.(*ethtypes.Transaction)
 	timeoutCTX, _ := context.WithTimeout(context.Background(), 5*time.Second)
 
-	err := e.PrimaryNode.SendTransaction(timeoutCTX, txSigned)
+	err := e.send(timeoutCTX, txSigned)
 
 	if err != nil {
 		return nil, err
@@ -304,41 +610,187 @@ func (e *EthereumInterface) DeploySmartContract(tx interface{}) (interface{}, er
 	return r.ContractAddress, nil
 }
 
-func (e *EthereumInterface) _sendTx(txSigned ethtypes.Transaction) {
-	// timoutCTX, _ := context.WithTimeout(context.Background(), 5*time.Second)
+// send sends the signed transaction with the send function of the chain, by
+// default to the primary node.
+func (e *EthereumInterface) send(ctx context.Context, tx *ethtypes.Transaction) error {
+	if e.sendTx != nil {
+		return e.sendTx(ctx, tx)
+	}
+
+	return e.PrimaryNode.SendTransaction(ctx, tx)
+}
 
-	err := e.PrimaryNode.SendTransaction(context.Background(), &txSigned)
+func (e *EthereumInterface) _sendTx(ctx context.Context, txSigned ethtypes.Transaction, intended time.Time) {
+	err := e.send(ctx, &txSigned)
 
 	// The transaction failed - this could be if it was reproposed, or, just failed.
 	// We need to make sure that if it was re-proposed it doesn't count as a "success" on this node.
+	tHash := txSigned.Hash().String()
+
+	e.txLock.Lock()
 	if err != nil {
 		zap.L().Debug("Err",
 			zap.Error(err),
 		)
+		e.failedSends[tHash] = true
 		atomic.AddUint64(&e.Fail, 1)
-		atomic.AddUint64(&e.NumTxDone, 1)
+		e.AddTxDone(1)
+	} else if e.ConfirmationMode == EthereumConfirmReceipt {
+		e.sentTxs[tHash] = &txSigned
 	}
 
-	e.TransactionInfo[txSigned.Hash().String()] = []time.Time{time.Now()}
+	e.TransactionInfo[tHash] = []time.Time{time.Now()}
+	e.intendedTimes[tHash] = intended
+	e.txLock.Unlock()
 	atomic.AddUint64(&e.NumTxSent, 1)
 }
 
+// _secureRead performs the read and records its latency like a transaction
+func (e *EthereumInterface) _secureRead(ctx context.Context, read *types.EthereumRead, intended time.Time) {
+	id := fmt.Sprintf("read-%d", atomic.AddUint64(&e.numReads, 1))
+	tStart := time.Now()
+
+	e.txLock.Lock()
+	e.TransactionInfo[id] = []time.Time{tStart}
+	e.intendedTimes[id] = intended
+	e.txLock.Unlock()
+	atomic.AddUint64(&e.NumTxSent, 1)
+
+	data, err := hex.DecodeString(strings.TrimPrefix(read.Data, "0x"))
+	if err == nil {
+		_, err = e.secureRead(ctx, read.To, data)
+	}
+
+	e.txLock.Lock()
+	if err != nil {
+		zap.L().Debug("Err",
+			zap.Error(err),
+		)
+		e.failedReads[id] = true
+	} else {
+		e.TransactionInfo[id] = append(e.TransactionInfo[id], time.Now())
+	}
+	e.txLock.Unlock()
+
+	if err != nil {
+		atomic.AddUint64(&e.Fail, 1)
+	} else {
+		atomic.AddUint64(&e.Success, 1)
+	}
+	e.AddTxDone(1)
+}
+
 // SendRawTransaction sends a raw transaction to the blockchain node.
 // It assumes that the transaction is the correct type
 // and has already been signed and is ready to send into the network.
-func (e *EthereumInterface) SendRawTransaction(tx interface{}) error {
+// Reads of the contract are performed with a secure read instead.
+func (e *EthereumInterface) SendRawTransaction(ctx context.Context, tx interface{}) error {
 	// NOTE: type conversion might be slow, there might be a better way to send this.
-	txSigned := tx.(*ethtypes.Transaction)
-	go e._sendTx(*txSigned)
+	intended := e.IntendedSendTime(time.Now())
+	switch t := tx.(type) {
+	case *ethtypes.Transaction:
+		go e._sendTx(ctx, *t, intended)
+	case *types.EthereumRead:
+		go e._secureRead(ctx, t, intended)
+	default:
+		return fmt.Errorf("invalid transaction type for ethereum: %T", tx)
+	}
 
 	return nil
 }
 
+// secureReadQuorum returns the number of matching answers required, by
+// default t+1 where t is the number of faulty nodes tolerated out of the
+// connected nodes (n >= 3t+1).
+func (e *EthereumInterface) secureReadQuorum() int {
+	if e.SecureReadQuorum > 0 {
+		return e.SecureReadQuorum
+	}
+
+	n := 1 + len(e.SecondaryNodes)
+	return (n-1)/3 + 1
+}
+
 // SecureRead will implement a "secure read" - will read a value from all connected nodes to ensure that the
-// value is the same.
+// value is the same. The callFunc is the address of the contract and callPrams the encoded call data
+// (function selector and arguments). The call is sent to the primary and all secondary nodes, the
+// returned bytes are given once the quorum of nodes agree on them.
 func (e *EthereumInterface) SecureRead(callFunc string, callPrams []byte) (interface{}, error) {
-	// TODO implement
-	return nil, nil
+	return e.secureRead(context.Background(), callFunc, callPrams)
+}
+
+// secureRead performs the secure read, the calls stop when the context is cancelled
+func (e *EthereumInterface) secureRead(ctx context.Context, callFunc string, callPrams []byte) (interface{}, error) {
+	clients := append([]*ethclient.Client{e.PrimaryNode}, e.SecondaryNodes...)
+	quorum := e.secureReadQuorum()
+
+	if quorum > len(clients) {
+		return nil, fmt.Errorf("secure read requires %d nodes, %d connected", quorum, len(clients))
+	}
+
+	to := common.HexToAddress(callFunc)
+	msg := ethereum.CallMsg{To: &to, Data: callPrams}
+
+	type answer struct {
+		node int
+		out  []byte
+		err  error
+	}
+
+	answers := make(chan answer, len(clients))
+	for i, c := range clients {
+		go func(i int, c *ethclient.Client) {
+			callCtx, cancel := context.WithTimeout(ctx, secureReadTimeout)
+			defer cancel()
+			out, err := c.CallContract(callCtx, msg, nil)
+			answers <- answer{node: i, out: out, err: err}
+		}(i, c)
+	}
+
+	// Return as soon as enough nodes agree, keep the other answers for the error
+	counts := make(map[string]int)
+	received := make([]answer, 0, len(clients))
+	for range clients {
+		a := <-answers
+		received = append(received, a)
+		if a.err != nil {
+			continue
+		}
+
+		key := hex.EncodeToString(a.out)
+		counts[key]++
+		if counts[key] >= quorum {
+			return a.out, nil
+		}
+	}
+
+	// The nodes disagreeing are the ones not giving the most common answer
+	majority, majorityCount := "", 0
+	for k, c := range counts {
+		if c > majorityCount {
+			majority, majorityCount = k, c
+		}
+	}
+
+	disagreeing := make([]string, 0, len(received))
+	for _, a := range received {
+		if a.err != nil {
+			disagreeing = append(disagreeing, fmt.Sprintf("%s (error: %s)", e.nodeAddr(a.node), a.err.Error()))
+		} else if hex.EncodeToString(a.out) != majority {
+			disagreeing = append(disagreeing, fmt.Sprintf("%s (0x%x)", e.nodeAddr(a.node), a.out))
+		}
+	}
+
+	return nil, fmt.Errorf("secure read did not reach a quorum of %d (%d agreeing), disagreeing nodes: %s",
+		quorum, majorityCount, strings.Join(disagreeing, ", "))
+}
+
+// nodeAddr returns the address of the connected node at the given index
+func (e *EthereumInterface) nodeAddr(idx int) string {
+	if idx < len(e.nodeAddrs) {
+		return e.nodeAddrs[idx]
+	}
+	return fmt.Sprintf("node %d", idx)
 }
 
 // GetBlockByNumber will request the block information by passing it the height number.
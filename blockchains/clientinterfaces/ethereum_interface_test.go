@@ -0,0 +1,242 @@
+package clientinterfaces
+
+import (
+	"diablo-benchmark/blockchains/types"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// stubEthRPC starts a JSON-RPC server answering each request, alone or in a
+// batch, with the "result" or "error" member returned by answer. The whole
+// HTTP request fails if answer returns an empty string.
+func stubEthRPC(t *testing.T, answer func(method string, params []json.RawMessage) string) (*rpc.Client, string) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		type request struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var reqs []request
+		batch := strings.HasPrefix(strings.TrimSpace(string(body)), "[")
+		if batch {
+			err = json.Unmarshal(body, &reqs)
+		} else {
+			reqs = make([]request, 1)
+			err = json.Unmarshal(body, &reqs[0])
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		responses := make([]string, 0, len(reqs))
+		for _, req := range reqs {
+			member := answer(req.Method, req.Params)
+			if member == "" {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			responses = append(responses, `{"jsonrpc":"2.0","id":`+string(req.ID)+`,`+member+`}`)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if batch {
+			w.Write([]byte("[" + strings.Join(responses, ",") + "]"))
+		} else {
+			w.Write([]byte(responses[0]))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := rpc.DialHTTP(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to dial stub node: %s", err.Error())
+	}
+
+	return c, srv.Listener.Addr().String()
+}
+
+// stubEthNode starts a JSON-RPC server answering every eth_call with the given result
+func stubEthNode(t *testing.T, result string) (*ethclient.Client, string) {
+	c, addr := stubEthRPC(t, func(method string, params []json.RawMessage) string {
+		if method != "eth_call" {
+			return `"error":{"code":-32601,"message":"method not found"}`
+		}
+		return `"result":"` + result + `"`
+	})
+
+	return ethclient.NewClient(c), addr
+}
+
+// stubEthInterface returns an interface connected to nodes answering the given results
+func stubEthInterface(t *testing.T, results ...string) *EthereumInterface {
+	e := &EthereumInterface{}
+	for i, r := range results {
+		c, addr := stubEthNode(t, r)
+		if i == 0 {
+			e.PrimaryNode = c
+		} else {
+			e.SecondaryNodes = append(e.SecondaryNodes, c)
+		}
+		e.nodeAddrs = append(e.nodeAddrs, addr)
+	}
+
+	return e
+}
+
+func TestEthereumSecureReadQuorum(t *testing.T) {
+	// 4 nodes tolerate 1 faulty node, 2 matching answers are required
+	e := stubEthInterface(t, "0x01", "0x02", "0x01", "0x03")
+
+	v, err := e.SecureRead("0x0000000000000000000000000000000000000001", []byte{0x12, 0x34, 0x56, 0x78})
+	if err != nil {
+		t.Fatalf("secure read failed: %s", err.Error())
+	}
+
+	if out := v.([]byte); len(out) != 1 || out[0] != 0x01 {
+		t.Errorf("expected 0x01, got %x", out)
+	}
+}
+
+func TestEthereumSecureReadDisagreement(t *testing.T) {
+	e := stubEthInterface(t, "0x01", "0x02", "0x03", "0x04")
+
+	_, err := e.SecureRead("0x0000000000000000000000000000000000000001", nil)
+	if err == nil {
+		t.Fatalf("expected the secure read to fail without a quorum")
+	}
+
+	// Without a common answer, all nodes but the one giving the reported majority disagree
+	if n := strings.Count(err.Error(), "127.0.0.1"); n != 3 {
+		t.Errorf("expected 3 disagreeing nodes in the error, got %d: %s", n, err.Error())
+	}
+
+	e.SecureReadQuorum = 1
+	if _, err := e.SecureRead("0x0000000000000000000000000000000000000001", nil); err != nil {
+		t.Errorf("expected a quorum of 1 to succeed: %s", err.Error())
+	}
+}
+
+// revertData is the ABI encoding of Error("nope")
+const revertData = "08c379a0" +
+	"0000000000000000000000000000000000000000000000000000000000000020" +
+	"0000000000000000000000000000000000000000000000000000000000000004" +
+	"6e6f706500000000000000000000000000000000000000000000000000000000"
+
+func TestDecodeRevertReason(t *testing.T) {
+	data, _ := hex.DecodeString(revertData)
+
+	tests := []struct {
+		data     []byte
+		expected string
+	}{
+		{data, "nope"},
+		{data[:4+64], ""}, // truncated reason
+		{data[:4+32], ""}, // no length
+		{append([]byte{0, 0, 0, 0}, data[4:]...), ""}, // other selector
+		{nil, ""},
+	}
+
+	for i, test := range tests {
+		if reason := decodeRevertReason(test.data); reason != test.expected {
+			t.Errorf("%d: expected the reason %q, got %q", i, test.expected, reason)
+		}
+	}
+}
+
+func TestEthereumConfirmWithReceipts(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	to := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	var txs []*ethtypes.Transaction
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		tx, err := ethtypes.SignTx(ethtypes.NewTransaction(nonce, to, big.NewInt(0), 21000, big.NewInt(1), nil), ethtypes.HomesteadSigner{}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+	}
+	reverted := txs[1].Hash().String()
+
+	// The first batch request fails, the receipts are then fetched again
+	batches := 0
+	c, _ := stubEthRPC(t, func(method string, params []json.RawMessage) string {
+		switch method {
+		case "eth_getTransactionReceipt":
+			var hash string
+			json.Unmarshal(params[0], &hash)
+			if hash == txs[0].Hash().String() {
+				batches++
+				if batches == 1 {
+					return ""
+				}
+			}
+
+			status := "0x1"
+			if hash == reverted {
+				status = "0x0"
+			}
+			return `"result":{"status":"` + status + `","cumulativeGasUsed":"0x5208","gasUsed":"0x5208","logs":[],` +
+				`"logsBloom":"0x` + strings.Repeat("00", 256) + `","transactionHash":"` + hash + `"}`
+		case "eth_call":
+			return `"error":{"code":3,"message":"execution reverted","data":"0x` + revertData + `"}`
+		default:
+			return `"error":{"code":-32601,"message":"method not found"}`
+		}
+	})
+
+	e := &EthereumInterface{
+		PrimaryNode:      ethclient.NewClient(c),
+		PrimaryRPC:       c,
+		TransactionInfo:  make(map[string][]time.Time),
+		Receipts:         make(map[string]*types.EthereumReceipt),
+		ConfirmationMode: EthereumConfirmReceipt,
+		ReceiptBatchSize: 2,
+		sentTxs:          make(map[string]*ethtypes.Transaction),
+	}
+	for _, tx := range txs {
+		e.TransactionInfo[tx.Hash().String()] = []time.Time{time.Now()}
+		e.sentTxs[tx.Hash().String()] = tx
+	}
+
+	block := ethtypes.NewBlock(&ethtypes.Header{Number: big.NewInt(5)}, txs, nil, nil)
+	e.confirmWithReceipts(block, time.Now())
+
+	if batches != 2 {
+		t.Errorf("expected the failed batch to be fetched again, got %d requests", batches)
+	}
+	if e.Success != 2 || e.Fail != 1 || e.NumTxDone != 3 {
+		t.Errorf("expected 2 successes and 1 failure, got %d and %d (%d done)", e.Success, e.Fail, e.NumTxDone)
+	}
+	if receipt, ok := e.Receipts[reverted]; !ok || receipt.RevertReason != "nope" {
+		t.Errorf("expected the revert reason of the reverted transaction, got %+v", receipt)
+	}
+	for _, tx := range []*ethtypes.Transaction{txs[0], txs[2]} {
+		if len(e.TransactionInfo[tx.Hash().String()]) != 2 {
+			t.Errorf("expected %s to be committed", tx.Hash().String())
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package clientinterfaces
+
+import (
+	"diablo-benchmark/blockchains/types"
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/core"
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
+)
+
+// fabricConfigBackend wraps the connection profile to add what the Fabric
+// gateway would add: the user of the chain configuration as an embedded user
+// of the organisation, the default channel peers, and the localhost mappings
+// of the discovered peers when running against a local network.
+type fabricConfigBackend struct {
+	backend       core.ConfigBackend                               // Backend of the connection profile
+	organizations map[string]interface{}                           // Organisations including the embedded user
+	matchers      map[string][]map[string]string                   // Entity matchers mapping the peers to localhost
+	channels      map[string]map[string]map[string]map[string]bool // Default channel configuration if none is given
+}
+
+// newFabricConfigProvider returns the configuration of the SDK from the
+// connection profile at the given path, with the user embedded in it.
+func newFabricConfigProvider(ccpPath string, user types.FabricUser, localhost bool) core.ConfigProvider {
+	return func() ([]core.ConfigBackend, error) {
+		backends, err := config.FromFile(filepath.Clean(ccpPath))()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(backends) != 1 {
+			return nil, errors.New("invalid connection profile")
+		}
+
+		return []core.ConfigBackend{newFabricConfigBackend(backends[0], user, localhost)}, nil
+	}
+}
+
+// newFabricConfigBackend creates the backend embedding the user in the organisation of the client
+func newFabricConfigBackend(backend core.ConfigBackend, user types.FabricUser, localhost bool) *fabricConfigBackend {
+	b := &fabricConfigBackend{backend: backend}
+
+	org := ""
+	if v, ok := backend.Lookup("client.organization"); ok {
+		org, _ = v.(string)
+	}
+
+	// Copy the organisations, the backend is shared by the SDK
+	b.organizations = make(map[string]interface{})
+	if v, ok := backend.Lookup("organizations"); ok {
+		if orgs, ok := v.(map[string]interface{}); ok {
+			for k, o := range orgs {
+				b.organizations[k] = o
+			}
+		}
+	}
+
+	for k, o := range b.organizations {
+		if !strings.EqualFold(k, org) {
+			continue
+		}
+
+		orgConfig := make(map[string]interface{})
+		if m, ok := o.(map[string]interface{}); ok {
+			for field, v := range m {
+				orgConfig[field] = v
+			}
+		}
+
+		// The SDK looks the embedded users up by lower case name
+		orgConfig["users"] = map[string]interface{}{
+			strings.ToLower(user.Label): map[string]interface{}{
+				"cert": map[string]interface{}{"pem": user.Cert},
+				"key":  map[string]interface{}{"pem": user.Key},
+			},
+		}
+		b.organizations[k] = orgConfig
+	}
+
+	if localhost {
+		mapping := map[string]string{
+			"pattern":                             "([^:]+):(\\d+)",
+			"urlSubstitutionExp":                  "localhost:${2}",
+			"sslTargetOverrideUrlSubstitutionExp": "${1}",
+			"mappedHost":                          "${1}",
+		}
+		b.matchers = map[string][]map[string]string{
+			"peer":    {mapping},
+			"orderer": {mapping},
+		}
+	}
+
+	if _, ok := backend.Lookup("channels"); !ok {
+		b.channels = defaultFabricChannels(backend, org)
+	}
+
+	return b
+}
+
+// defaultFabricChannels uses the peers of the organisation for all roles on every channel
+func defaultFabricChannels(backend core.ConfigBackend, org string) map[string]map[string]map[string]map[string]bool {
+	value, ok := backend.Lookup("organizations." + org + ".peers")
+	if !ok {
+		return nil
+	}
+
+	peerList, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	roles := map[string]bool{
+		"endorsingPeer":  true,
+		"chaincodeQuery": true,
+		"ledgerQuery":    true,
+		"eventSource":    true,
+	}
+
+	peers := make(map[string]map[string]bool)
+	for _, p := range peerList {
+		if name, ok := p.(string); ok {
+			peers[name] = roles
+		}
+	}
+
+	return map[string]map[string]map[string]map[string]bool{
+		"_default": {"peers": peers},
+	}
+}
+
+// Lookup returns the value of the key, overridden by the additions to the connection profile
+func (b *fabricConfigBackend) Lookup(key string) (interface{}, bool) {
+	switch {
+	case key == "organizations":
+		return b.organizations, true
+	case key == "entityMatchers" && b.matchers != nil:
+		return b.matchers, true
+	case key == "channels" && b.channels != nil:
+		return b.channels, true
+	}
+
+	return b.backend.Lookup(key)
+}
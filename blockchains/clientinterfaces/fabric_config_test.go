@@ -0,0 +1,114 @@
+package clientinterfaces
+
+import (
+	"diablo-benchmark/blockchains/types"
+	"reflect"
+	"testing"
+)
+
+// fakeConfigBackend is a connection profile given as its keys
+type fakeConfigBackend map[string]interface{}
+
+// Lookup returns the value of the key
+func (b fakeConfigBackend) Lookup(key string) (interface{}, bool) {
+	v, ok := b[key]
+	return v, ok
+}
+
+// testFabricProfile returns a connection profile of two organisations, the
+// client being in Org1
+func testFabricProfile() fakeConfigBackend {
+	org1 := map[string]interface{}{"mspid": "Org1MSP", "peers": []interface{}{"peer0.org1.example.com"}}
+	org2 := map[string]interface{}{"mspid": "Org2MSP"}
+
+	return fakeConfigBackend{
+		"client.organization":          "org1",
+		"organizations":                map[string]interface{}{"Org1": org1, "Org2": org2},
+		"organizations.org1.peers":     org1["peers"],
+		"peers.peer0.org1.example.com": map[string]interface{}{"url": "grpcs://peer0.org1.example.com:7051"},
+	}
+}
+
+func TestFabricConfigBackendOrganizations(t *testing.T) {
+	profile := testFabricProfile()
+	user := types.FabricUser{Label: "User1", MspID: "Org1MSP", Cert: "cert", Key: "key"}
+	b := newFabricConfigBackend(profile, user, false)
+
+	v, ok := b.Lookup("organizations")
+	if !ok {
+		t.Fatalf("expected the organisations")
+	}
+	orgs := v.(map[string]interface{})
+
+	// The user is embedded in the organisation of the client, by lower case name
+	org1 := orgs["Org1"].(map[string]interface{})
+	expected := map[string]interface{}{
+		"user1": map[string]interface{}{
+			"cert": map[string]interface{}{"pem": "cert"},
+			"key":  map[string]interface{}{"pem": "key"},
+		},
+	}
+	if !reflect.DeepEqual(org1["users"], expected) {
+		t.Errorf("expected the embedded user %v, got %v", expected, org1["users"])
+	}
+	if org1["mspid"] != "Org1MSP" {
+		t.Errorf("expected the other fields of the organisation to be kept, got %v", org1)
+	}
+
+	// The other organisations and the connection profile are left as they are
+	if _, ok := orgs["Org2"].(map[string]interface{})["users"]; ok {
+		t.Errorf("expected no user in the other organisation")
+	}
+	if _, ok := profile["organizations"].(map[string]interface{})["Org1"].(map[string]interface{})["users"]; ok {
+		t.Errorf("expected the connection profile not to be modified")
+	}
+
+	// The other keys are the ones of the connection profile
+	if v, ok := b.Lookup("client.organization"); !ok || v != "org1" {
+		t.Errorf("expected the organisation of the client, got %v", v)
+	}
+}
+
+func TestFabricConfigBackendEntityMatchers(t *testing.T) {
+	b := newFabricConfigBackend(testFabricProfile(), types.FabricUser{Label: "User1"}, false)
+	if _, ok := b.Lookup("entityMatchers"); ok {
+		t.Errorf("expected no entity matchers without localhost")
+	}
+
+	b = newFabricConfigBackend(testFabricProfile(), types.FabricUser{Label: "User1"}, true)
+	v, ok := b.Lookup("entityMatchers")
+	if !ok {
+		t.Fatalf("expected the localhost entity matchers")
+	}
+
+	matchers := v.(map[string][]map[string]string)
+	for _, entity := range []string{"peer", "orderer"} {
+		if len(matchers[entity]) != 1 || matchers[entity][0]["urlSubstitutionExp"] != "localhost:${2}" {
+			t.Errorf("expected the %s to be mapped to localhost, got %v", entity, matchers[entity])
+		}
+	}
+}
+
+func TestFabricConfigBackendChannels(t *testing.T) {
+	b := newFabricConfigBackend(testFabricProfile(), types.FabricUser{Label: "User1"}, false)
+
+	v, ok := b.Lookup("channels")
+	if !ok {
+		t.Fatalf("expected the default channel")
+	}
+	channels := v.(map[string]map[string]map[string]map[string]bool)
+	roles := channels["_default"]["peers"]["peer0.org1.example.com"]
+	for _, role := range []string{"endorsingPeer", "chaincodeQuery", "ledgerQuery", "eventSource"} {
+		if !roles[role] {
+			t.Errorf("expected the peer of the organisation to be %s, got %v", role, roles)
+		}
+	}
+
+	// The channels of the connection profile are kept
+	profile := testFabricProfile()
+	profile["channels"] = map[string]interface{}{"mychannel": map[string]interface{}{}}
+	b = newFabricConfigBackend(profile, types.FabricUser{Label: "User1"}, false)
+	if v, ok := b.Lookup("channels"); !ok || !reflect.DeepEqual(v, profile["channels"]) {
+		t.Errorf("expected the channels of the connection profile, got %v", v)
+	}
+}
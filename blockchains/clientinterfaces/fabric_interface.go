@@ -1,39 +1,71 @@
 package clientinterfaces
 
 import (
+	"context"
+	"crypto/sha256"
 	"diablo-benchmark/blockchains/types"
 	"diablo-benchmark/blockchains/workloadgenerators"
 	"diablo-benchmark/core/configs"
 	"diablo-benchmark/core/results"
+	"encoding/asn1"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
 	"os"
-	"path/filepath"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel/invoke"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
 	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
 	"go.uber.org/zap"
 )
 
+// fabricValid is the validation code of the transactions committed successfully
+const fabricValid = "VALID"
+
 //FabricInterface is the Hyperledger Fabric implementation of the clientinterface
 // Provides functionality to communicate with the Fabric blockchain
 type FabricInterface struct {
+	SDK           *fabsdk.FabricSDK             // SDK configured from the connection profile, shared by the clients below
 	Gateway       *gateway.Gateway              // Gateway manages the network interaction on behalf of the application
-	Wallet        *gateway.Wallet               // Wallet containing user identity configured for the gateway
 	Network       *gateway.Network              // Network object originating from gateway
-	Contract      *gateway.Contract             // The smart contract we will be interacting with (only supporting one contract workload for now)
+	Contract      *gateway.Contract             // The smart contract of the chaincode of the chain configuration
+	Channel       *channel.Client               // Channel client submitting the transactions, to record their phases
+	Ledger        *ledger.Client                // Ledger client to query the blocks of the channel
+	ChaincodeID   string                        // Name of the chaincode the transactions are sent to
 	ccpPath       string                        // connection-profile path to configure the gateway
 	commitChannel chan *types.FabricCommitEvent // channel where we continuously listen to commit events to register throughput
 
-	TransactionInfo  map[uint64][]time.Time // Transaction information (used for throughput calculation)
-	StartTime        time.Time              // Start time of the benchmark
-	ThroughputTicker *time.Ticker           // Ticker for throughput (1s)
-	Throughputs      []float64              // Throughput over time with 1 second intervals
+	contractLock sync.Mutex                   // Protects the contracts, created when the transactions are sent
+	contracts    map[string]*gateway.Contract // Smart contract of each chaincode invoked by the transactions
+
+	txLock           sync.Mutex                 // Protects the transaction information, written by the SDK handlers
+	TransactionInfo  map[uint64][]time.Time     // Transaction information (used for throughput calculation)
+	intendedTimes    map[uint64]time.Time       // Scheduled send time of the transactions, for the response latency
+	Transactions     map[uint64]*types.FabricTX // Transactions sent, with the time of their phases
+	fabricIDs        map[string]uint64          // Fabric transaction ID to the ID of the transaction in the workload
+	StartTime        time.Time                  // Start time of the benchmark
+	ThroughputTicker *time.Ticker               // Ticker for throughput (1s)
+	Throughputs      []float64                  // Throughput over time with 1 second intervals
 	GenericInterface
 }
 
+func init() {
+	RegisterBlockchainInterface("fabric", func() BlockchainInterface { return &FabricInterface{} })
+}
+
 // Init initializes the wallet, gateway, network and map of contracts available in the network
 func (f *FabricInterface) Init(chainConfig *configs.ChainConfig) {
 	f.Nodes = chainConfig.Nodes
@@ -46,52 +78,60 @@ func (f *FabricInterface) Init(chainConfig *configs.ChainConfig) {
 	}
 	f.NumTxDone = 0
 	f.TransactionInfo = make(map[uint64][]time.Time, 0)
+	f.intendedTimes = make(map[uint64]time.Time, 0)
+	f.Transactions = make(map[uint64]*types.FabricTX, 0)
+	f.fabricIDs = make(map[string]uint64, 0)
 
 	err := os.Setenv("DISCOVERY_AS_LOCALHOST", mapConfig["localHost"].(string))
 	if err != nil {
 		zap.L().Warn("Error setting DISCOVERY_AS_LOCALHOST environemnt variable: " + err.Error())
 	}
 
-	wallet, err := gateway.NewFileSystemWallet("wallet")
-	if err != nil {
-		zap.L().Warn("Failed to create wallet" + err.Error())
-	}
+	f.ccpPath = mapConfig["ccpPath"].(string)
+	channelName := mapConfig["channelName"].(string)
+	f.ChaincodeID = mapConfig["contractName"].(string)
 
-	if !wallet.Exists(user.Label) {
-		err = f.populateWallet(wallet, user)
-		if err != nil {
-			zap.L().Warn("Failed to populate wallet" + err.Error())
-		}
+	// The user is embedded in the connection profile so that the gateway,
+	// the channel and the ledger clients share the same SDK and identity.
+	localhost := strings.ToUpper(mapConfig["localHost"].(string)) == "TRUE"
+	f.SDK, err = fabsdk.New(newFabricConfigProvider(f.ccpPath, user, localhost))
+	if err != nil {
+		zap.L().Warn("Failed to create the Fabric SDK" + err.Error())
+		return
 	}
 
-
-	ccpPath := mapConfig["ccpPath"].(string)
-
 	f.Gateway, err = gateway.Connect(
-		gateway.WithConfig(config.FromFile(filepath.Clean(ccpPath))),
-		gateway.WithIdentity(wallet, user.Label))
+		gateway.WithSDK(f.SDK),
+		gateway.WithUser(user.Label))
 
 	if err != nil {
 		zap.L().Warn("Failed to connect to gateway" + err.Error())
+		return
 	}
 
-	f.Network, err = f.Gateway.GetNetwork(mapConfig["channelName"].(string))
+	f.Network, err = f.Gateway.GetNetwork(channelName)
 
 	if err != nil {
 		zap.L().Warn("Failed to get network" + err.Error())
+		return
 	}
 
-	contract := f.Network.GetContract(mapConfig["contractName"].(string))
+	contract := f.Network.GetContract(f.ChaincodeID)
 
 	f.Contract = contract
-}
+	f.contracts = map[string]*gateway.Contract{f.ChaincodeID: contract}
+
+	channelContext := f.SDK.ChannelContext(channelName, fabsdk.WithUser(user.Label))
 
-// Called when the wallet hasn't been instantiated yet
-// Creates the wallet/identity of the gateway peer we connect to
-func (f *FabricInterface) populateWallet(wallet *gateway.Wallet, user types.FabricUser) error {
-	identity := gateway.NewX509Identity(user.MspID, user.Cert, user.Key)
+	f.Channel, err = channel.New(channelContext)
+	if err != nil {
+		zap.L().Warn("Failed to create channel client" + err.Error())
+	}
 
-	return wallet.Put(user.Label, identity)
+	f.Ledger, err = ledger.New(channelContext)
+	if err != nil {
+		zap.L().Warn("Failed to create ledger client" + err.Error())
+	}
 }
 
 // Cleanup Finishes up and performs any post-benchmark operations.
@@ -102,24 +142,33 @@ func (f *FabricInterface) Cleanup() results.Results {
 	f.ThroughputTicker.Stop()
 
 	txLatencies := make([]float64, 0)
+	responseLatencies := make([]float64, 0)
 	var avgLatency float64
+	var avgResponseLatency float64
 
 	var endTime time.Time
 
-	for _, v := range f.TransactionInfo {
+	f.txLock.Lock()
+	for id, v := range f.TransactionInfo {
 		if len(v) > 1 {
 			txLatency := v[1].Sub(v[0]).Milliseconds()
 			txLatencies = append(txLatencies, float64(txLatency))
 			avgLatency += float64(txLatency)
+			responseLat := responseLatency(f.intendedTimes[id], v[0], v[1])
+			responseLatencies = append(responseLatencies, responseLat)
+			avgResponseLatency += responseLat
 			if v[1].After(endTime) {
 				endTime = v[1]
 			}
 		}
 	}
+	f.txLock.Unlock()
 
 	success := uint(f.Success)
 	fails := uint(f.Fail)
 
+	phaseLatencies, validationCodes := f.phaseStatistics()
+
 	zap.L().Debug("Statistics being returned",
 		zap.Uint("success", success),
 		zap.Uint("fail", fails))
@@ -129,6 +178,7 @@ func (f *FabricInterface) Cleanup() results.Results {
 	if len(txLatencies) > 0 {
 		throughput = float64(f.NumTxDone) - float64(f.Fail)/(endTime.Sub(f.StartTime).Seconds())
 		avgLatency = avgLatency / float64(len(txLatencies))
+		avgResponseLatency = avgResponseLatency / float64(len(responseLatencies))
 	} else {
 		avgLatency = 0
 		throughput = 0
@@ -151,15 +201,57 @@ func (f *FabricInterface) Cleanup() results.Results {
 	)
 
 	return results.Results{
-		TxLatencies:       txLatencies,
-		AverageLatency:    avgLatency,
-		Throughput:        averageThroughput,
-		ThroughputSeconds: calculatedThroughputSeconds,
-		Success:           success,
-		Fail:              fails,
+		TxLatencies:            txLatencies,
+		AverageLatency:         avgLatency,
+		ResponseLatencies:      responseLatencies,
+		AverageResponseLatency: avgResponseLatency,
+		Throughput:             averageThroughput,
+		ThroughputSeconds:      calculatedThroughputSeconds,
+		Success:                success,
+		Fail:                   fails,
+		PhaseLatencies:         phaseLatencies,
+		ValidationCodes:        validationCodes,
 	}
 }
 
+// phaseStatistics returns the average latency of each phase of the committed
+// transactions in milliseconds: endorsement (from submission), ordering (from
+// endorsement) and commit (from ordering), as well as the number of
+// transactions per validation code.
+func (f *FabricInterface) phaseStatistics() (map[string]float64, map[string]uint) {
+	f.txLock.Lock()
+	defer f.txLock.Unlock()
+
+	phaseLatencies := make(map[string]float64)
+	validationCodes := make(map[string]uint)
+	committed := 0
+
+	for _, tx := range f.Transactions {
+		if tx.ValidationCode != "" {
+			validationCodes[tx.ValidationCode]++
+		}
+
+		if tx.CommittedTime.IsZero() || tx.OrderedTime.IsZero() || tx.EndorsedTime.IsZero() {
+			continue
+		}
+
+		phaseLatencies["endorse"] += float64(tx.EndorsedTime.Sub(tx.SubmitTime).Milliseconds())
+		phaseLatencies["order"] += float64(tx.OrderedTime.Sub(tx.EndorsedTime).Milliseconds())
+		phaseLatencies["commit"] += float64(tx.CommittedTime.Sub(tx.OrderedTime).Milliseconds())
+		committed++
+	}
+
+	if committed == 0 {
+		return nil, validationCodes
+	}
+
+	for k := range phaseLatencies {
+		phaseLatencies[k] = phaseLatencies[k] / float64(committed)
+	}
+
+	return phaseLatencies, validationCodes
+}
+
 // throughputSeconds calculates the throughput over time, to show dynamic
 func (f *FabricInterface) throughputSeconds() {
 	f.ThroughputTicker = time.NewTicker(time.Duration(f.Window) * time.Second)
@@ -189,6 +281,10 @@ func (f *FabricInterface) listenForCommits() {
 			zap.L().Debug("CommitChannel",
 				zap.Uint64("ID", ID))
 			// transaction failed, incrementing number of done and failed transactions
+			f.txLock.Lock()
+			if tx, ok := f.Transactions[ID]; ok && commit.ValidationCode != "" {
+				tx.ValidationCode = commit.ValidationCode
+			}
 			if !commit.Valid {
 				atomic.AddUint64(&f.Fail, 1)
 			} else {
@@ -196,8 +292,9 @@ func (f *FabricInterface) listenForCommits() {
 				f.TransactionInfo[ID] = append(f.TransactionInfo[ID], commit.CommitTime)
 				atomic.AddUint64(&f.Success, 1)
 			}
+			f.txLock.Unlock()
 
-			atomic.AddUint64(&f.NumTxDone, 1)
+			f.AddTxDone(1)
 		}
 	}
 
@@ -263,38 +360,63 @@ func (f *FabricInterface) DeploySmartContract(tx interface{}) (interface{}, erro
 }
 
 // SendRawTransaction sends the transaction by the gateway
-func (f *FabricInterface) SendRawTransaction(tx interface{}) error {
+func (f *FabricInterface) SendRawTransaction(ctx context.Context, tx interface{}) error {
 	transaction := tx.(*types.FabricTX)
 
 	zap.L().Debug("Submitting TX",
 		zap.Uint64("ID", transaction.ID))
 
 	// making note of the time we send the transaction
-	f.TransactionInfo[transaction.ID] = []time.Time{time.Now()}
+	tNow := time.Now()
+	f.txLock.Lock()
+	f.TransactionInfo[transaction.ID] = []time.Time{tNow}
+	f.intendedTimes[transaction.ID] = f.IntendedSendTime(tNow)
+	transaction.SubmitTime = tNow
+	f.Transactions[transaction.ID] = transaction
+	f.txLock.Unlock()
 	atomic.AddUint64(&f.NumTxSent, 1)
 
 	if transaction.FunctionType == "write" {
-		//submitTransaction does everything under the hood for us.
-		// Rather than interacting with a single peer, the SDK will send the submitTransaction proposal
-		//to every required organization’s peer in the blockchain network based on the chaincode’s endorsement policy.
-		//Each of these peers will execute the requested smart contract using this proposal, to generate a transaction response
-		//which it endorses (signs) and returns to the SDK. The SDK collects all the endorsed transaction responses into
-		//a single transaction, which it then submits to the orderer. The orderer collects and sequences transactions from various application clients into a block of transactions.
-		//These blocks are distributed to every peer in the network, where every transaction is validated and committed.
-		//Finally, the SDK is notified via an event, allowing it to return control to the application.
+		// The SDK sends the proposal to every required organization’s peer in the blockchain network
+		// based on the chaincode’s endorsement policy. Each of these peers will execute the requested
+		// smart contract using this proposal, to generate a transaction response which it endorses (signs)
+		// and returns to the SDK. The phase handler then collects all the endorsed transaction responses into
+		// a single transaction, which it submits to the orderer. The orderer collects and sequences transactions
+		// from various application clients into a block of transactions. These blocks are distributed to every
+		// peer in the network, where every transaction is validated and committed. Finally, the SDK is notified
+		// via an event with the validation code of the transaction.
+		// Failed transactions are not retried, so that conflicts appear in the results.
 		go func() {
-			_, err := f.Contract.SubmitTransaction(transaction.FunctionName, transaction.Args...)
-			time := time.Now()
+			args := make([][]byte, len(transaction.Args))
+			for i, v := range transaction.Args {
+				args[i] = []byte(v)
+			}
+
+			_, err := f.Channel.InvokeHandler(
+				newFabricPhaseHandler(f, transaction),
+				channel.Request{ChaincodeID: f.chaincodeID(transaction), Fcn: transaction.FunctionName, Args: args},
+				channel.WithParentContext(ctx),
+			)
 
 			if err != nil {
 				zap.L().Debug("TX got an error",
 					zap.Error(err))
 			}
-			valid := err == nil
+
+			f.txLock.Lock()
+			commitTime := transaction.CommittedTime
+			validationCode := transaction.ValidationCode
+			f.txLock.Unlock()
+
+			if commitTime.IsZero() {
+				commitTime = time.Now()
+			}
+
 			commit := types.FabricCommitEvent{
-				Valid:      valid,
-				ID:         transaction.ID,
-				CommitTime: time,
+				Valid:          err == nil && validationCode == fabricValid,
+				ID:             transaction.ID,
+				CommitTime:     commitTime,
+				ValidationCode: validationCode,
 			}
 			f.commitChannel <- &commit
 		}()
@@ -302,7 +424,7 @@ func (f *FabricInterface) SendRawTransaction(tx interface{}) error {
 	} else {
 		//EvaluteTransaction is much less expensive and only queries one peer for its world state
 		go func() {
-			_, err := f.Contract.EvaluateTransaction(transaction.FunctionName, transaction.Args...)
+			_, err := f.contract(transaction).EvaluateTransaction(transaction.FunctionName, transaction.Args...)
 			time := time.Now()
 			valid := err == nil
 			commit := types.FabricCommitEvent{
@@ -318,6 +440,36 @@ func (f *FabricInterface) SendRawTransaction(tx interface{}) error {
 
 }
 
+// chaincodeID returns the name of the chaincode invoked by the transaction
+func (f *FabricInterface) chaincodeID(transaction *types.FabricTX) string {
+	if transaction.Contract == "" {
+		return f.ChaincodeID
+	}
+
+	return transaction.Contract
+}
+
+// contract returns the smart contract of the chaincode invoked by the
+// transaction, the contracts of the other chaincodes are got from the network
+// the first time they are invoked.
+func (f *FabricInterface) contract(transaction *types.FabricTX) *gateway.Contract {
+	name := f.chaincodeID(transaction)
+
+	f.contractLock.Lock()
+	defer f.contractLock.Unlock()
+
+	contract, ok := f.contracts[name]
+	if !ok {
+		contract = f.Network.GetContract(name)
+		if f.contracts == nil {
+			f.contracts = make(map[string]*gateway.Contract)
+		}
+		f.contracts[name] = contract
+	}
+
+	return contract
+}
+
 // SecureRead reads the value from the chain
 // (NOT NEEDED IN FABRIC) SecureRead is useful in permissionless blockchains where transaction
 // validation is not always clear but transactions are always clearly rejected or commited in Hyperledger Fabric
@@ -325,31 +477,265 @@ func (f *FabricInterface) SecureRead(callFunc string, callParams []byte) (interf
 	return nil, nil
 }
 
-// GetBlockByNumber retrieves the block information at the given index
+// fabricBlockTx is a transaction of a block read from the ledger
+type fabricBlockTx struct {
+	TxID           string    // Fabric transaction ID
+	Timestamp      time.Time // Time the transaction was proposed by the client
+	ValidationCode string    // Validation code given by the committing peers
+}
+
+// queryBlock reads the block at the given index and decodes its transactions
+func (f *FabricInterface) queryBlock(index uint64) (*common.Block, []fabricBlockTx, error) {
+	if f.Ledger == nil {
+		return nil, nil, errors.New("ledger client not initialised")
+	}
+
+	b, err := f.Ledger.QueryBlock(index)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txs, err := fabricBlockTxs(b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b, txs, nil
+}
+
+// fabricBlockTxs decodes the transactions of the block with their validation code
+func fabricBlockTxs(b *common.Block) ([]fabricBlockTx, error) {
+	if b.Header == nil || b.Data == nil {
+		return nil, errors.New("invalid block returned")
+	}
+
+	// The validation codes are set by the committing peers in the metadata
+	var filter []byte
+	if b.Metadata != nil && len(b.Metadata.Metadata) > int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		filter = b.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+
+	txs := make([]fabricBlockTx, 0, len(b.Data.Data))
+	for i, data := range b.Data.Data {
+		env := &common.Envelope{}
+		if err := proto.Unmarshal(data, env); err != nil {
+			return nil, err
+		}
+
+		payload := &common.Payload{}
+		if err := proto.Unmarshal(env.Payload, payload); err != nil {
+			return nil, err
+		}
+
+		if payload.Header == nil {
+			return nil, errors.New("transaction without header in block")
+		}
+
+		chdr := &common.ChannelHeader{}
+		if err := proto.Unmarshal(payload.Header.ChannelHeader, chdr); err != nil {
+			return nil, err
+		}
+
+		tx := fabricBlockTx{TxID: chdr.TxId}
+		if chdr.Timestamp != nil {
+			tx.Timestamp = time.Unix(chdr.Timestamp.Seconds, int64(chdr.Timestamp.Nanos))
+		}
+		if i < len(filter) {
+			tx.ValidationCode = peer.TxValidationCode(filter[i]).String()
+		}
+
+		txs = append(txs, tx)
+	}
+
+	return txs, nil
+}
+
+// fabricBlockHash computes the hash of the block header, as done by the peers
+func fabricBlockHash(header *common.BlockHeader) (string, error) {
+	asn1Header := struct {
+		Number       *big.Int
+		PreviousHash []byte
+		DataHash     []byte
+	}{
+		Number:       new(big.Int).SetUint64(header.Number),
+		PreviousHash: header.PreviousHash,
+		DataHash:     header.DataHash,
+	}
+
+	b, err := asn1.Marshal(asn1Header)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// GetBlockByNumber retrieves the block information at the given index.
+// Fabric blocks have no timestamp, the latest proposal time of their
+// transactions is used instead.
 func (f *FabricInterface) GetBlockByNumber(index uint64) (GenericBlock, error) {
+	b, txs, err := f.queryBlock(index)
+	if err != nil {
+		return GenericBlock{}, err
+	}
+
+	hash, err := fabricBlockHash(b.Header)
+	if err != nil {
+		return GenericBlock{}, err
+	}
+
+	var timestamp time.Time
+	txList := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		txList = append(txList, tx.TxID)
+		if tx.Timestamp.After(timestamp) {
+			timestamp = tx.Timestamp
+		}
+	}
+
+	var unixTime uint64
+	if !timestamp.IsZero() {
+		unixTime = uint64(timestamp.Unix())
+	}
+
 	return GenericBlock{
-		Hash:              "",
-		Index:             0,
-		Timestamp:         0,
-		TransactionNumber: 0,
-		TransactionHashes: nil,
+		Hash:              hash,
+		Index:             b.Header.Number,
+		Timestamp:         unixTime,
+		TransactionNumber: len(txs),
+		TransactionHashes: txList,
 	}, nil
 }
 
-// GetBlockHeight returns the current height of the chain
+// GetBlockHeight returns the index of the latest block of the channel
 func (f *FabricInterface) GetBlockHeight() (uint64, error) {
-	return 0, nil
+	if f.Ledger == nil {
+		return 0, errors.New("ledger client not initialised")
+	}
+
+	info, err := f.Ledger.QueryInfo()
+	if err != nil {
+		return 0, err
+	}
+
+	if info.BCI == nil || info.BCI.Height == 0 {
+		return 0, errors.New("empty blockchain info returned")
+	}
+
+	return info.BCI.Height - 1, nil
 }
 
 // ParseBlocksForTransactions retrieves block information from start to end index and
 // is used as a post-benchmark check to learn about the block and transactions.
-// (NOT NEEDED IN FABRIC) As transactions are confirmed to be validated whenever we submit a transaction
+// It records the validation code of the transactions sent by this client, and
+// the block number of the ones whose commit event was missed.
 func (f *FabricInterface) ParseBlocksForTransactions(startNumber uint64, endNumber uint64) error {
+	for i := startNumber; i <= endNumber; i++ {
+		_, txs, err := f.queryBlock(i)
+		if err != nil {
+			return err
+		}
+
+		f.txLock.Lock()
+		for _, blockTx := range txs {
+			id, ok := f.fabricIDs[blockTx.TxID]
+			if !ok {
+				continue
+			}
+
+			tx := f.Transactions[id]
+			if tx.ValidationCode == "" {
+				tx.ValidationCode = blockTx.ValidationCode
+				tx.BlockNumber = i
+			}
+		}
+		f.txLock.Unlock()
+	}
+
 	return nil
 }
 
 // Close the connection to the blockchain node
 func (f *FabricInterface) Close() {
-	f.Gateway.Close()
+	// Closing the gateway closes the SDK it was created with
+	if f.Gateway != nil {
+		f.Gateway.Close()
+	} else if f.SDK != nil {
+		f.SDK.Close()
+	}
 	close(f.commitChannel)
 }
+
+// fabricPhaseHandler submits the endorsed transaction to the orderer and waits
+// for its commit event, recording the time of each phase in the transaction.
+// It replaces the commit handler of the SDK, which hides the phases.
+type fabricPhaseHandler struct {
+	f  *FabricInterface // Interface holding the lock of the transaction information
+	tx *types.FabricTX  // Transaction in which the phases are recorded
+}
+
+// newFabricPhaseHandler returns the chain of handlers endorsing then submitting the transaction
+func newFabricPhaseHandler(f *FabricInterface, tx *types.FabricTX) invoke.Handler {
+	return invoke.NewSelectAndEndorseHandler(
+		invoke.NewEndorsementValidationHandler(
+			invoke.NewSignatureValidationHandler(&fabricPhaseHandler{f: f, tx: tx}),
+		),
+	)
+}
+
+// Handle is called once the endorsements are collected and validated
+func (h *fabricPhaseHandler) Handle(requestContext *invoke.RequestContext, clientContext *invoke.ClientContext) {
+	txnID := string(requestContext.Response.TransactionID)
+
+	h.f.txLock.Lock()
+	h.tx.EndorsedTime = time.Now()
+	h.tx.TxID = txnID
+	h.f.fabricIDs[txnID] = h.tx.ID
+	h.f.txLock.Unlock()
+
+	reg, statusNotifier, err := clientContext.EventService.RegisterTxStatusEvent(txnID)
+	if err != nil {
+		requestContext.Error = fmt.Errorf("error registering for TxStatus event: %s", err.Error())
+		return
+	}
+	defer clientContext.EventService.Unregister(reg)
+
+	tx, err := clientContext.Transactor.CreateTransaction(fab.TransactionRequest{
+		Proposal:          requestContext.Response.Proposal,
+		ProposalResponses: requestContext.Response.Responses,
+	})
+	if err != nil {
+		requestContext.Error = fmt.Errorf("CreateTransaction failed: %s", err.Error())
+		return
+	}
+
+	if _, err := clientContext.Transactor.SendTransaction(tx); err != nil {
+		requestContext.Error = fmt.Errorf("SendTransaction failed: %s", err.Error())
+		return
+	}
+
+	h.f.txLock.Lock()
+	h.tx.OrderedTime = time.Now()
+	h.f.txLock.Unlock()
+
+	select {
+	case txStatus := <-statusNotifier:
+		code := txStatus.TxValidationCode.String()
+
+		h.f.txLock.Lock()
+		h.tx.CommittedTime = time.Now()
+		h.tx.ValidationCode = code
+		h.tx.BlockNumber = txStatus.BlockNumber
+		h.f.txLock.Unlock()
+
+		requestContext.Response.TxValidationCode = txStatus.TxValidationCode
+		if code != fabricValid {
+			requestContext.Error = status.New(status.EventServerStatus, int32(txStatus.TxValidationCode),
+				"received invalid transaction", nil)
+		}
+	case <-requestContext.Ctx.Done():
+		requestContext.Error = status.New(status.ClientStatus, status.Timeout.ToInt32(),
+			"Execute didn't receive block event", nil)
+	}
+}
@@ -0,0 +1,225 @@
+package clientinterfaces
+
+import (
+	"bytes"
+	"context"
+	"diablo-benchmark/blockchains/types"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel/invoke"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+)
+
+func TestFabricPhaseStatistics(t *testing.T) {
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	f := &FabricInterface{Transactions: map[uint64]*types.FabricTX{
+		// Committed: 10ms to endorse, 20ms to order, 30ms to commit
+		0: {SubmitTime: at(0), EndorsedTime: at(10), OrderedTime: at(30), CommittedTime: at(60), ValidationCode: fabricValid},
+		// Committed: 30ms to endorse, 40ms to order, 50ms to commit
+		1: {SubmitTime: at(0), EndorsedTime: at(30), OrderedTime: at(70), CommittedTime: at(120), ValidationCode: "MVCC_READ_CONFLICT"},
+		// Never ordered, only its validation code is counted
+		2: {SubmitTime: at(0), EndorsedTime: at(5), ValidationCode: "ENDORSEMENT_POLICY_FAILURE"},
+		// Never endorsed
+		3: {SubmitTime: at(0)},
+	}}
+
+	phases, codes := f.phaseStatistics()
+
+	expected := map[string]float64{"endorse": 20, "order": 30, "commit": 40}
+	for phase, latency := range expected {
+		if phases[phase] != latency {
+			t.Errorf("expected an average %s latency of %.0fms, got %.0fms", phase, latency, phases[phase])
+		}
+	}
+
+	for code, n := range map[string]uint{fabricValid: 1, "MVCC_READ_CONFLICT": 1, "ENDORSEMENT_POLICY_FAILURE": 1} {
+		if codes[code] != n {
+			t.Errorf("expected %d transactions with the code %s, got %d", n, code, codes[code])
+		}
+	}
+	if len(codes) != 3 {
+		t.Errorf("expected 3 validation codes, got %v", codes)
+	}
+
+	// Without committed transactions there are no phases
+	f.Transactions = map[uint64]*types.FabricTX{0: {SubmitTime: at(0)}}
+	if phases, _ := f.phaseStatistics(); phases != nil {
+		t.Errorf("expected no phase latencies, got %v", phases)
+	}
+}
+
+// testFabricEnvelope returns the encoded envelope of the transaction
+func testFabricEnvelope(t *testing.T, txID string, seconds int64) []byte {
+	chdr, err := proto.Marshal(&common.ChannelHeader{TxId: txID, Timestamp: &timestamp.Timestamp{Seconds: seconds}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := proto.Marshal(&common.Payload{Header: &common.Header{ChannelHeader: chdr}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := proto.Marshal(&common.Envelope{Payload: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return env
+}
+
+func TestFabricBlockTxs(t *testing.T) {
+	metadata := make([][]byte, common.BlockMetadataIndex_TRANSACTIONS_FILTER+1)
+	metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = []byte{byte(peer.TxValidationCode_VALID), byte(peer.TxValidationCode_MVCC_READ_CONFLICT)}
+
+	block := &common.Block{
+		Header:   &common.BlockHeader{Number: 3},
+		Data:     &common.BlockData{Data: [][]byte{testFabricEnvelope(t, "tx1", 100), testFabricEnvelope(t, "tx2", 200)}},
+		Metadata: &common.BlockMetadata{Metadata: metadata},
+	}
+
+	txs, err := fabricBlockTxs(block)
+	if err != nil {
+		t.Fatalf("failed to decode the block: %s", err.Error())
+	}
+
+	expected := []fabricBlockTx{
+		{TxID: "tx1", Timestamp: time.Unix(100, 0), ValidationCode: fabricValid},
+		{TxID: "tx2", Timestamp: time.Unix(200, 0), ValidationCode: "MVCC_READ_CONFLICT"},
+	}
+	if len(txs) != len(expected) {
+		t.Fatalf("expected %d transactions, got %d", len(expected), len(txs))
+	}
+	for i := range expected {
+		if txs[i].TxID != expected[i].TxID || !txs[i].Timestamp.Equal(expected[i].Timestamp) || txs[i].ValidationCode != expected[i].ValidationCode {
+			t.Errorf("expected the transaction %+v, got %+v", expected[i], txs[i])
+		}
+	}
+
+	// Blocks read before validation have no filter
+	block.Metadata = nil
+	if txs, err := fabricBlockTxs(block); err != nil || txs[0].ValidationCode != "" {
+		t.Errorf("expected no validation code without metadata, got %+v (%v)", txs, err)
+	}
+
+	if _, err := fabricBlockTxs(&common.Block{Header: block.Header}); err == nil {
+		t.Errorf("expected a block without data to fail")
+	}
+}
+
+func TestFabricBlockHash(t *testing.T) {
+	// SHA-256 of the DER encoding of the header, computed independently
+	tests := []struct {
+		header   *common.BlockHeader
+		expected string
+	}{
+		{
+			header:   &common.BlockHeader{Number: 7, PreviousHash: bytes.Repeat([]byte{0x01}, 32), DataHash: bytes.Repeat([]byte{0x02}, 32)},
+			expected: "c92add9f96e6c75a12f0073035cbab86771ace7180349e4e6944a354787fa561",
+		},
+		{
+			// The number is a positive integer, 128 needs a leading zero byte
+			header:   &common.BlockHeader{Number: 128},
+			expected: "2fc899e6fa44ba5e41f8476f1f3955b5794feee49c827d251dcf3196e3d8af71",
+		},
+	}
+
+	for _, test := range tests {
+		hash, err := fabricBlockHash(test.header)
+		if err != nil {
+			t.Fatalf("failed to hash block %d: %s", test.header.Number, err.Error())
+		}
+		if hash != test.expected {
+			t.Errorf("block %d: expected the hash %s, got %s", test.header.Number, test.expected, hash)
+		}
+	}
+}
+
+// fakeTransactor accepts the transactions sent to the orderer
+type fakeTransactor struct {
+	fab.Transactor
+	sendErr error
+}
+
+// CreateTransaction returns an empty transaction
+func (t *fakeTransactor) CreateTransaction(request fab.TransactionRequest) (*fab.Transaction, error) {
+	return &fab.Transaction{Proposal: request.Proposal}, nil
+}
+
+// SendTransaction fails with the error of the transactor, if any
+func (t *fakeTransactor) SendTransaction(tx *fab.Transaction) (*fab.TransactionResponse, error) {
+	return &fab.TransactionResponse{}, t.sendErr
+}
+
+// fakeEventService sends the status event given, if any, once registered
+type fakeEventService struct {
+	fab.EventService
+	status       *fab.TxStatusEvent
+	unregistered bool
+}
+
+// RegisterTxStatusEvent returns a channel with the status event of the service
+func (s *fakeEventService) RegisterTxStatusEvent(txID string) (fab.Registration, <-chan *fab.TxStatusEvent, error) {
+	events := make(chan *fab.TxStatusEvent, 1)
+	if s.status != nil {
+		events <- s.status
+	}
+	return txID, events, nil
+}
+
+// Unregister records the end of the registration
+func (s *fakeEventService) Unregister(reg fab.Registration) {
+	s.unregistered = true
+}
+
+func TestFabricPhaseHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   *fab.TxStatusEvent
+		sendErr  error
+		code     string
+		ordered  bool
+		expected bool // Whether the request succeeds
+	}{
+		{"valid", &fab.TxStatusEvent{TxValidationCode: peer.TxValidationCode_VALID, BlockNumber: 4}, nil, fabricValid, true, true},
+		{"invalid", &fab.TxStatusEvent{TxValidationCode: peer.TxValidationCode_MVCC_READ_CONFLICT, BlockNumber: 4}, nil, "MVCC_READ_CONFLICT", true, false},
+		{"not ordered", nil, errors.New("orderer unavailable"), "", false, false},
+		{"no event", nil, nil, "", true, false},
+	}
+
+	for _, test := range tests {
+		f := &FabricInterface{fabricIDs: make(map[string]uint64)}
+		tx := &types.FabricTX{ID: 9, SubmitTime: time.Now()}
+		events := &fakeEventService{status: test.status}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		request := &invoke.RequestContext{Ctx: ctx, Response: invoke.Response{TransactionID: "fabric-tx"}}
+		(&fabricPhaseHandler{f: f, tx: tx}).Handle(request, &invoke.ClientContext{
+			Transactor:   &fakeTransactor{sendErr: test.sendErr},
+			EventService: events,
+		})
+		cancel()
+
+		if (request.Error == nil) != test.expected {
+			t.Errorf("%s: expected the success of the request to be %t, got the error %v", test.name, test.expected, request.Error)
+		}
+		if tx.TxID != "fabric-tx" || f.fabricIDs["fabric-tx"] != 9 || tx.EndorsedTime.IsZero() {
+			t.Errorf("%s: expected the endorsement to be recorded, got %+v", test.name, tx)
+		}
+		if tx.OrderedTime.IsZero() == test.ordered {
+			t.Errorf("%s: expected the ordering to be recorded: %t", test.name, test.ordered)
+		}
+		if tx.ValidationCode != test.code || (test.code != "" && (tx.CommittedTime.IsZero() || tx.BlockNumber != 4)) {
+			t.Errorf("%s: expected the commit with the code %q, got %+v", test.name, test.code, tx)
+		}
+		if !events.unregistered {
+			t.Errorf("%s: expected the status event to be unregistered", test.name)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package clientinterfaces
+
+import (
+	"context"
+	"diablo-benchmark/blockchains/types"
+	"diablo-benchmark/blockchains/workloadgenerators"
+	"diablo-benchmark/core/configs"
+	"diablo-benchmark/core/results"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// MockInterface is the client interface of the in-memory mock blockchain.
+// It sends the transactions to a simulated ledger running in the secondary so
+// that the full benchmark pipeline can be run without any blockchain node.
+type MockInterface struct {
+	chainConfig      *configs.ChainConfig   // Chain configuration, identifies the shared ledger
+	ledger           *mockLedger            // Simulated ledger shared by the clients of the secondary
+	subscription     int                    // Subscription to the new blocks of the ledger
+	txLock           sync.Mutex             // Protects the transaction information
+	TransactionInfo  map[uint64][]time.Time // Transaction information [send, commit]
+	intendedTimes    map[uint64]time.Time   // Scheduled send time of the transactions, for the response latency
+	HandlersStarted  bool                   // Have the handlers been initiated?
+	StartTime        time.Time              // Start time of the benchmark
+	ThroughputTicker *time.Ticker           // Ticker for throughput (1s)
+	Throughputs      []float64              // Throughput over time with 1 second intervals
+	stopThroughput   chan bool              // Stops the throughput calculation
+	GenericInterface
+}
+
+func init() {
+	RegisterBlockchainInterface("mock", func() BlockchainInterface { return &MockInterface{} })
+}
+
+// Init parses the mock configuration and attaches to the simulated ledger
+func (m *MockInterface) Init(chainConfig *configs.ChainConfig) {
+	m.Nodes = chainConfig.Nodes
+	m.chainConfig = chainConfig
+	m.TransactionInfo = make(map[uint64][]time.Time, 0)
+	m.intendedTimes = make(map[uint64]time.Time, 0)
+	m.stopThroughput = make(chan bool)
+	m.HandlersStarted = false
+	m.NumTxDone = 0
+
+	mockConfig, err := ParseMockChainConfig(chainConfig)
+	if err != nil {
+		zap.L().Warn("invalid mock configuration, using defaults",
+			zap.Error(err))
+		mockConfig, _ = ParseMockChainConfig(&configs.ChainConfig{})
+	}
+
+	m.ledger = acquireMockLedger(chainConfig, mockConfig)
+}
+
+// Cleanup formats results and unsubscribes from the ledger
+func (m *MockInterface) Cleanup() results.Results {
+	// Stop the ticker
+	if m.ThroughputTicker != nil {
+		m.ThroughputTicker.Stop()
+		m.stopThroughput <- true
+		m.ThroughputTicker = nil
+	}
+
+	if m.HandlersStarted {
+		m.ledger.unsubscribe(m.subscription)
+		m.HandlersStarted = false
+	}
+
+	m.txLock.Lock()
+	defer m.txLock.Unlock()
+
+	txLatencies := make([]float64, 0)
+	responseLatencies := make([]float64, 0)
+	var avgLatency float64
+	var avgResponseLatency float64
+
+	success := uint(0)
+	fails := uint(m.Fail)
+
+	for id, v := range m.TransactionInfo {
+		if len(v) > 1 {
+			txLatency := v[1].Sub(v[0]).Milliseconds()
+			txLatencies = append(txLatencies, float64(txLatency))
+			avgLatency += float64(txLatency)
+			responseLat := responseLatency(m.intendedTimes[id], v[0], v[1])
+			responseLatencies = append(responseLatencies, responseLat)
+			avgResponseLatency += responseLat
+			success++
+		}
+	}
+
+	zap.L().Debug("Statistics being returned",
+		zap.Uint("success", success),
+		zap.Uint("fail", fails))
+
+	if len(txLatencies) > 0 {
+		avgLatency = avgLatency / float64(len(txLatencies))
+		avgResponseLatency = avgResponseLatency / float64(len(responseLatencies))
+	}
+
+	averageThroughput := float64(0)
+	calculatedThroughputSeconds := make([]float64, 0)
+	if len(m.Throughputs) > 0 {
+		calculatedThroughputSeconds = append(calculatedThroughputSeconds, m.Throughputs[0])
+		averageThroughput = m.Throughputs[0]
+		for i := 1; i < len(m.Throughputs); i++ {
+			calculatedThroughputSeconds = append(calculatedThroughputSeconds, m.Throughputs[i]-m.Throughputs[i-1])
+			averageThroughput += m.Throughputs[i] - m.Throughputs[i-1]
+		}
+		averageThroughput = averageThroughput / float64(len(m.Throughputs))
+	}
+
+	zap.L().Debug("Results being returned",
+		zap.Float64("avg throughput", averageThroughput),
+		zap.Float64("latency", avgLatency),
+		zap.String("ThroughputWindow", fmt.Sprintf("%v", calculatedThroughputSeconds)),
+	)
+
+	return results.Results{
+		TxLatencies:            txLatencies,
+		AverageLatency:         avgLatency,
+		ResponseLatencies:      responseLatencies,
+		AverageResponseLatency: avgResponseLatency,
+		Throughput:             averageThroughput,
+		ThroughputSeconds:      calculatedThroughputSeconds,
+		Success:                success,
+		Fail:                   fails,
+	}
+}
+
+// throughputSeconds calculates the throughput over time, to show dynamic
+func (m *MockInterface) throughputSeconds() {
+	for {
+		select {
+		case <-m.stopThroughput:
+			return
+		case <-m.ThroughputTicker.C:
+			m.Throughputs = append(m.Throughputs, float64(atomic.LoadUint64(&m.NumTxDone)-atomic.LoadUint64(&m.Fail)))
+		}
+	}
+}
+
+// Start sets up the start time and starts the periodic checking of the
+// throughput.
+func (m *MockInterface) Start() {
+	m.StartTime = time.Now()
+	m.ThroughputTicker = time.NewTicker(time.Duration(m.Window) * time.Second)
+	go m.throughputSeconds()
+}
+
+// ParseWorkload parses the workload and converts into the type for the benchmark.
+func (m *MockInterface) ParseWorkload(workload workloadgenerators.WorkerThreadWorkload) ([][]interface{}, error) {
+	parsedWorkload := make([][]interface{}, 0)
+
+	for _, v := range workload {
+		intervalTxs := make([]interface{}, 0)
+		for _, txBytes := range v {
+			var t types.MockTX
+			err := json.Unmarshal(txBytes, &t)
+			if err != nil {
+				return nil, err
+			}
+
+			intervalTxs = append(intervalTxs, &t)
+		}
+		parsedWorkload = append(parsedWorkload, intervalTxs)
+	}
+
+	m.TotalTx = len(parsedWorkload)
+
+	return parsedWorkload, nil
+}
+
+// handleBlock marks the transactions of this client included in the block as committed
+func (m *MockInterface) handleBlock(block types.MockBlock) {
+	tNow := time.Now()
+	var tAdd uint64
+
+	m.txLock.Lock()
+	for _, id := range block.Transactions {
+		if v, ok := m.TransactionInfo[id]; ok && len(v) == 1 {
+			m.TransactionInfo[id] = append(v, tNow)
+			tAdd++
+		}
+	}
+	m.txLock.Unlock()
+
+	atomic.AddUint64(&m.Success, tAdd)
+	m.AddTxDone(tAdd)
+}
+
+// ConnectOne subscribes to the blocks of the ledger, the mock chain has
+// no nodes to connect to.
+func (m *MockInterface) ConnectOne(id int) error {
+	if m.ledger == nil {
+		return errors.New("mock interface not initialised")
+	}
+
+	if !m.HandlersStarted {
+		m.subscription = m.ledger.subscribe(m.handleBlock)
+		m.HandlersStarted = true
+	}
+
+	return nil
+}
+
+// ConnectAll connects to the ledger
+func (m *MockInterface) ConnectAll(primaryID int) error {
+	return m.ConnectOne(primaryID)
+}
+
+// DeploySmartContract registers the contract in the ledger and returns its address
+func (m *MockInterface) DeploySmartContract(tx interface{}) (interface{}, error) {
+	transaction, ok := tx.(*types.MockTX)
+	if !ok {
+		return nil, errors.New("invalid transaction type for mock chain")
+	}
+
+	return m.ledger.deploy(transaction), nil
+}
+
+// SendRawTransaction submits the transaction to the simulated ledger. Writes
+// are committed when included in a block, reads are answered once their
+// simulated latency has passed.
+func (m *MockInterface) SendRawTransaction(ctx context.Context, tx interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	transaction := tx.(*types.MockTX)
+	tNow := time.Now()
+
+	m.txLock.Lock()
+	m.TransactionInfo[transaction.ID] = []time.Time{tNow}
+	m.intendedTimes[transaction.ID] = m.IntendedSendTime(tNow)
+	m.txLock.Unlock()
+	atomic.AddUint64(&m.NumTxSent, 1)
+
+	if transaction.FunctionType == "read" {
+		time.AfterFunc(m.ledger.config.commitLatency(transaction.ID), func() {
+			m.ledger.read(transaction.FunctionName)
+			m.handleBlock(types.MockBlock{Transactions: []uint64{transaction.ID}})
+		})
+		return nil
+	}
+
+	err := m.ledger.submit(transaction, tNow)
+	if err != nil {
+		zap.L().Debug("Err",
+			zap.Error(err),
+		)
+		atomic.AddUint64(&m.Fail, 1)
+		m.AddTxDone(1)
+	}
+
+	return nil
+}
+
+// SecureRead returns the number of committed invocations of the function,
+// all clients share the same ledger so the value is always consistent.
+func (m *MockInterface) SecureRead(callFunc string, callParams []byte) (interface{}, error) {
+	return m.ledger.read(callFunc), nil
+}
+
+// GetBlockByNumber retrieves the block information at the given index
+func (m *MockInterface) GetBlockByNumber(index uint64) (GenericBlock, error) {
+	b, err := m.ledger.block(index)
+	if err != nil {
+		return GenericBlock{}, err
+	}
+
+	txList := make([]string, 0, len(b.Transactions))
+	for _, id := range b.Transactions {
+		txList = append(txList, fmt.Sprintf("%d", id))
+	}
+
+	return GenericBlock{
+		Hash:              b.Hash,
+		Index:             b.Index,
+		Timestamp:         uint64(b.Timestamp),
+		TransactionNumber: len(b.Transactions),
+		TransactionHashes: txList,
+	}, nil
+}
+
+// GetBlockHeight returns the current height of the chain
+func (m *MockInterface) GetBlockHeight() (uint64, error) {
+	return m.ledger.height(), nil
+}
+
+// ParseBlocksForTransactions goes through the blocks between the start and end
+// index and records the block time as commit time of the transactions that
+// have not been seen committed yet.
+func (m *MockInterface) ParseBlocksForTransactions(startNumber uint64, endNumber uint64) error {
+	for i := startNumber; i <= endNumber; i++ {
+		b, err := m.ledger.block(i)
+		if err != nil {
+			return err
+		}
+
+		m.txLock.Lock()
+		for _, id := range b.Transactions {
+			if v, ok := m.TransactionInfo[id]; ok && len(v) == 1 {
+				m.TransactionInfo[id] = append(v, time.Unix(0, b.Timestamp))
+			}
+		}
+		m.txLock.Unlock()
+	}
+
+	return nil
+}
+
+// Close detaches from the ledger
+func (m *MockInterface) Close() {
+	if m.HandlersStarted {
+		m.ledger.unsubscribe(m.subscription)
+		m.HandlersStarted = false
+	}
+
+	releaseMockLedger(m.chainConfig)
+}
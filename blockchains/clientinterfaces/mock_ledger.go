@@ -0,0 +1,423 @@
+package clientinterfaces
+
+import (
+	"crypto/sha256"
+	"diablo-benchmark/blockchains/types"
+	"diablo-benchmark/core/configs"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default values of the simulated ledger if they are not provided in the
+// "extra" section of the chain configuration.
+const (
+	mockDefaultBlockTime = 1000 // Milliseconds between blocks
+	mockDefaultBlockSize = 1000 // Maximum number of transactions per block
+	mockDefaultLatency   = 100  // Mean commit latency in milliseconds
+)
+
+// Supported commit latency distributions of the mock ledger
+const (
+	MockLatencyConstant    = "constant"
+	MockLatencyUniform     = "uniform"
+	MockLatencyNormal      = "normal"
+	MockLatencyExponential = "exponential"
+)
+
+// MockChainConfig holds the parameters of the simulated ledger, these are read
+// from the first entry of the "extra" field in the chain configuration:
+//
+//	extra:
+//	  - blockTime: 500        # ms between blocks
+//	    blockSize: 200        # max transactions per block
+//	    latency: "normal"     # constant | uniform | normal | exponential
+//	    latencyMean: 150      # ms
+//	    latencyStddev: 30     # ms (normal)
+//	    latencyMin: 50        # ms (uniform / lower bound)
+//	    latencyMax: 300       # ms (uniform / upper bound)
+//	    failureRate: 0.01     # probability [0,1] a transaction is rejected
+//	    seed: 42              # seed for deterministic runs
+type MockChainConfig struct {
+	BlockTime     time.Duration // Time between two blocks
+	BlockSize     int           // Maximum number of transactions per block
+	Latency       string        // Name of the commit latency distribution
+	LatencyMean   float64       // Mean of the commit latency (ms)
+	LatencyStddev float64       // Standard deviation of the commit latency (ms)
+	LatencyMin    float64       // Minimum commit latency (ms)
+	LatencyMax    float64       // Maximum commit latency (ms)
+	FailureRate   float64       // Probability that a transaction fails
+	Seed          uint64        // Seed used to derive the behaviour of every transaction
+}
+
+// ParseMockChainConfig reads the mock ledger parameters from the chain configuration.
+// Missing values are replaced with defaults.
+func ParseMockChainConfig(chainConfig *configs.ChainConfig) (*MockChainConfig, error) {
+	extra, err := getExtraMap(chainConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	blockTime, err := getExtraNumber(extra, "blockTime", mockDefaultBlockTime)
+	if err != nil {
+		return nil, err
+	}
+	blockSize, err := getExtraNumber(extra, "blockSize", mockDefaultBlockSize)
+	if err != nil {
+		return nil, err
+	}
+	mean, err := getExtraNumber(extra, "latencyMean", mockDefaultLatency)
+	if err != nil {
+		return nil, err
+	}
+	stddev, err := getExtraNumber(extra, "latencyStddev", 0)
+	if err != nil {
+		return nil, err
+	}
+	min, err := getExtraNumber(extra, "latencyMin", 0)
+	if err != nil {
+		return nil, err
+	}
+	max, err := getExtraNumber(extra, "latencyMax", mean*2)
+	if err != nil {
+		return nil, err
+	}
+	failureRate, err := getExtraNumber(extra, "failureRate", 0)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := getExtraNumber(extra, "seed", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	distribution, err := getExtraString(extra, "latency", MockLatencyConstant)
+	if err != nil {
+		return nil, err
+	}
+
+	switch distribution {
+	case MockLatencyConstant, MockLatencyUniform, MockLatencyNormal, MockLatencyExponential:
+	default:
+		return nil, fmt.Errorf("mock config: unknown latency distribution %s", distribution)
+	}
+
+	if blockTime <= 0 {
+		return nil, errors.New("mock config: blockTime must be greater than 0")
+	}
+
+	if blockSize <= 0 {
+		return nil, errors.New("mock config: blockSize must be greater than 0")
+	}
+
+	if failureRate < 0 || failureRate > 1 {
+		return nil, errors.New("mock config: failureRate must be between 0 and 1")
+	}
+
+	if min > max {
+		return nil, errors.New("mock config: latencyMin cannot be greater than latencyMax")
+	}
+
+	return &MockChainConfig{
+		BlockTime:     time.Duration(blockTime) * time.Millisecond,
+		BlockSize:     int(blockSize),
+		Latency:       distribution,
+		LatencyMean:   mean,
+		LatencyStddev: stddev,
+		LatencyMin:    min,
+		LatencyMax:    max,
+		FailureRate:   failureRate,
+		Seed:          uint64(seed),
+	}, nil
+}
+
+// splitmix64 is a small, fast hash used to derive the random behaviour of a
+// transaction from the seed and its ID. Deriving (rather than drawing from a
+// shared source) keeps the behaviour of every transaction identical between
+// runs regardless of the scheduling of the worker threads.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// uniform returns a deterministic float in (0, 1) for the transaction and salt
+func (c *MockChainConfig) uniform(txID uint64, salt uint64) float64 {
+	h := splitmix64(c.Seed ^ splitmix64(txID) ^ splitmix64(salt))
+	// 53 bits of precision, shifted away from 0 so logarithms are defined
+	return (float64(h>>11) + 0.5) / (1 << 53)
+}
+
+// fails returns whether the transaction is rejected by the ledger
+func (c *MockChainConfig) fails(txID uint64) bool {
+	return c.uniform(txID, 1) < c.FailureRate
+}
+
+// commitLatency samples the commit latency of the transaction from the
+// configured distribution.
+func (c *MockChainConfig) commitLatency(txID uint64) time.Duration {
+	var ms float64
+
+	switch c.Latency {
+	case MockLatencyUniform:
+		ms = c.LatencyMin + c.uniform(txID, 2)*(c.LatencyMax-c.LatencyMin)
+	case MockLatencyNormal:
+		// Box-Muller transform
+		u1 := c.uniform(txID, 2)
+		u2 := c.uniform(txID, 3)
+		z := math.Sqrt(-2*math.Log(u1)) * math.Cos(2*math.Pi*u2)
+		ms = c.LatencyMean + z*c.LatencyStddev
+	case MockLatencyExponential:
+		ms = -math.Log(c.uniform(txID, 2)) * c.LatencyMean
+	default:
+		ms = c.LatencyMean
+	}
+
+	if c.Latency != MockLatencyConstant {
+		ms = math.Max(ms, c.LatencyMin)
+		if c.LatencyMax > 0 {
+			ms = math.Min(ms, c.LatencyMax)
+		}
+	}
+
+	if ms < 0 {
+		ms = 0
+	}
+
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// pendingMockTX is a transaction waiting in the mock mempool
+type pendingMockTX struct {
+	tx    *types.MockTX
+	ready time.Time // Earliest time the transaction can be included in a block
+}
+
+// mockLedger is the in-process simulated blockchain. All the client
+// interfaces on a secondary created from the same chain configuration share
+// one ledger so that the blocks they observe are consistent.
+type mockLedger struct {
+	config      *MockChainConfig
+	lock        sync.Mutex
+	pending     []pendingMockTX                     // Transactions waiting to be included
+	blocks      []types.MockBlock                   // The chain, blocks[0] is the genesis
+	state       map[string]uint64                   // Number of committed invocations per function
+	contracts   map[string]bool                     // Deployed contract addresses
+	subscribers map[int]func(block types.MockBlock) // Callbacks invoked for each new block
+	nextSubID   int
+	refs        int
+	stopCh      chan bool
+}
+
+var (
+	mockLedgersLock sync.Mutex
+	mockLedgers     = make(map[*configs.ChainConfig]*mockLedger)
+)
+
+// acquireMockLedger returns the ledger shared by the chain configuration,
+// creating and starting it if it does not exist yet.
+func acquireMockLedger(chainConfig *configs.ChainConfig, mockConfig *MockChainConfig) *mockLedger {
+	mockLedgersLock.Lock()
+	defer mockLedgersLock.Unlock()
+
+	if l, ok := mockLedgers[chainConfig]; ok {
+		l.refs++
+		return l
+	}
+
+	genesis := types.MockBlock{
+		Index:     0,
+		Hash:      hashMockBlock(0, "", nil),
+		Timestamp: time.Now().UnixNano(),
+	}
+
+	l := &mockLedger{
+		config:      mockConfig,
+		blocks:      []types.MockBlock{genesis},
+		state:       make(map[string]uint64),
+		contracts:   make(map[string]bool),
+		subscribers: make(map[int]func(block types.MockBlock)),
+		refs:        1,
+		stopCh:      make(chan bool),
+	}
+	mockLedgers[chainConfig] = l
+
+	go l.produceBlocks()
+
+	return l
+}
+
+// releaseMockLedger drops a reference to the ledger, stopping it once nobody
+// uses it anymore.
+func releaseMockLedger(chainConfig *configs.ChainConfig) {
+	mockLedgersLock.Lock()
+	defer mockLedgersLock.Unlock()
+
+	l, ok := mockLedgers[chainConfig]
+	if !ok {
+		return
+	}
+
+	l.refs--
+	if l.refs <= 0 {
+		close(l.stopCh)
+		delete(mockLedgers, chainConfig)
+	}
+}
+
+// hashMockBlock computes a unique hash for the block content
+func hashMockBlock(index uint64, parent string, txs []uint64) string {
+	h := sha256.New()
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, index)
+	h.Write(buf)
+	h.Write([]byte(parent))
+	for _, id := range txs {
+		binary.BigEndian.PutUint64(buf, id)
+		h.Write(buf)
+	}
+	return "0x" + hex.EncodeToString(h.Sum(nil))
+}
+
+// produceBlocks creates a block every block time until the ledger is stopped
+func (l *mockLedger) produceBlocks() {
+	ticker := time.NewTicker(l.config.BlockTime)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stopCh:
+			return
+		case now := <-ticker.C:
+			l.createBlock(now)
+		}
+	}
+}
+
+// createBlock moves the ready transactions from the mempool into a new block
+// and notifies the subscribers.
+func (l *mockLedger) createBlock(now time.Time) {
+	l.lock.Lock()
+
+	// Oldest ready transactions first, ties broken by ID for stable blocks
+	sort.SliceStable(l.pending, func(i, j int) bool {
+		if l.pending[i].ready.Equal(l.pending[j].ready) {
+			return l.pending[i].tx.ID < l.pending[j].tx.ID
+		}
+		return l.pending[i].ready.Before(l.pending[j].ready)
+	})
+
+	included := make([]uint64, 0)
+	remaining := make([]pendingMockTX, 0, len(l.pending))
+	for _, p := range l.pending {
+		if len(included) < l.config.BlockSize && !p.ready.After(now) {
+			included = append(included, p.tx.ID)
+			l.state[p.tx.FunctionName]++
+			continue
+		}
+		remaining = append(remaining, p)
+	}
+	l.pending = remaining
+
+	parent := l.blocks[len(l.blocks)-1]
+	block := types.MockBlock{
+		Index:        parent.Index + 1,
+		Hash:         hashMockBlock(parent.Index+1, parent.Hash, included),
+		Timestamp:    now.UnixNano(),
+		Transactions: included,
+	}
+	l.blocks = append(l.blocks, block)
+
+	subscribers := make([]func(block types.MockBlock), 0, len(l.subscribers))
+	for _, s := range l.subscribers {
+		subscribers = append(subscribers, s)
+	}
+	l.lock.Unlock()
+
+	for _, s := range subscribers {
+		s(block)
+	}
+}
+
+// subscribe registers a callback for every new block, returns the subscription id
+func (l *mockLedger) subscribe(callback func(block types.MockBlock)) int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	id := l.nextSubID
+	l.nextSubID++
+	l.subscribers[id] = callback
+
+	return id
+}
+
+// unsubscribe removes the block callback
+func (l *mockLedger) unsubscribe(id int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.subscribers, id)
+}
+
+// submit adds the transaction to the mempool, or returns an error if the
+// transaction is (deterministically) chosen to fail.
+func (l *mockLedger) submit(tx *types.MockTX, now time.Time) error {
+	if l.config.fails(tx.ID) {
+		return fmt.Errorf("mock ledger rejected transaction %d", tx.ID)
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.pending = append(l.pending, pendingMockTX{
+		tx:    tx,
+		ready: now.Add(l.config.commitLatency(tx.ID)),
+	})
+
+	return nil
+}
+
+// deploy registers a contract and returns its address
+func (l *mockLedger) deploy(tx *types.MockTX) string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	address := fmt.Sprintf("0x%040x", tx.ID)
+	l.contracts[address] = true
+
+	return address
+}
+
+// read returns the number of committed invocations of the function
+func (l *mockLedger) read(function string) uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.state[function]
+}
+
+// block returns the block at the given height
+func (l *mockLedger) block(index uint64) (types.MockBlock, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if index >= uint64(len(l.blocks)) {
+		return types.MockBlock{}, fmt.Errorf("block %d does not exist", index)
+	}
+
+	return l.blocks[index], nil
+}
+
+// height returns the index of the latest block
+func (l *mockLedger) height() uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.blocks[len(l.blocks)-1].Index
+}
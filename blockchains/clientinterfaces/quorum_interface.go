@@ -0,0 +1,157 @@
+package clientinterfaces
+
+import (
+	"context"
+	"diablo-benchmark/blockchains/workloadgenerators"
+	"diablo-benchmark/core/configs"
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"go.uber.org/zap"
+)
+
+// Consensus protocols of Quorum, set with "consensus" in the extra section of
+// the chain configuration.
+const (
+	// QuorumConsensusRaft is the Raft consensus, blocks are final once created
+	// and their timestamp is given in nanoseconds.
+	QuorumConsensusRaft = "raft"
+	// QuorumConsensusIstanbul is the Istanbul BFT consensus, blocks are final
+	// once created and their timestamp is given in seconds.
+	QuorumConsensusIstanbul = "istanbul"
+)
+
+// QuorumInterface is the Quorum implementation of the clientinterface. Quorum
+// is an Ethereum client, the interface relies on the Ethereum implementation.
+// Both Raft and Istanbul give immediate finality, so a transaction is
+// committed as soon as it is seen in a block. Private transactions, marked by
+// the Quorum workload generator, are sent to the recipients given by
+// "privateFor" in the chain configuration.
+type QuorumInterface struct {
+	Consensus  string   // Consensus protocol of the network, "raft" or "istanbul"
+	PrivateFor []string // Tessera public keys of the recipients of the private transactions
+	EthereumInterface
+}
+
+func init() {
+	RegisterBlockchainInterface("quorum", func() BlockchainInterface { return &QuorumInterface{} })
+}
+
+// quorumBlock is the part of a block returned by "eth_getBlockByNumber" used by the benchmark
+type quorumBlock struct {
+	Hash         string         `json:"hash"`
+	Number       hexutil.Uint64 `json:"number"`
+	Timestamp    hexutil.Uint64 `json:"timestamp"`
+	Transactions []string       `json:"transactions"`
+}
+
+// Init initialises the Ethereum interface, the consensus and the recipients of private transactions
+func (q *QuorumInterface) Init(chainConfig *configs.ChainConfig) {
+	q.EthereumInterface.Init(chainConfig)
+	q.Consensus = QuorumConsensusRaft
+	q.sendTx = q.sendQuorumTx
+
+	extra, err := getExtraMap(chainConfig)
+	if err != nil {
+		zap.L().Warn("ignoring extra chain configuration", zap.Error(err))
+		return
+	}
+
+	consensus, err := getExtraString(extra, "consensus", QuorumConsensusRaft)
+	if err != nil || (consensus != QuorumConsensusRaft && consensus != QuorumConsensusIstanbul) {
+		zap.L().Warn("unknown consensus, using raft",
+			zap.String("consensus", consensus))
+		consensus = QuorumConsensusRaft
+	}
+	q.Consensus = consensus
+
+	// The generator fails the run on the same error
+	privateFor, _, err := workloadgenerators.QuorumPrivacyConfig(chainConfig)
+	if err != nil {
+		zap.L().Error("invalid private transactions configuration", zap.Error(err))
+		return
+	}
+	q.PrivateFor = privateFor
+}
+
+// sendQuorumTx sends the private transactions with their recipients, and the
+// others like Ethereum transactions.
+func (q *QuorumInterface) sendQuorumTx(ctx context.Context, tx *ethtypes.Transaction) error {
+	if !workloadgenerators.IsQuorumPrivateTx(tx) {
+		return q.PrimaryNode.SendTransaction(ctx, tx)
+	}
+
+	if len(q.PrivateFor) == 0 {
+		return errors.New("private transaction without privateFor in the chain config")
+	}
+
+	return workloadgenerators.SendQuorumPrivateTx(ctx, q.PrimaryRPC, tx, q.PrivateFor)
+}
+
+// blockTime converts the timestamp of a block, given in nanoseconds by Raft
+func (q *QuorumInterface) blockTime(timestamp uint64) time.Time {
+	if q.Consensus == QuorumConsensusRaft {
+		return time.Unix(0, int64(timestamp))
+	}
+
+	return time.Unix(int64(timestamp), 0)
+}
+
+// getBlock requests the block with only the hashes of its transactions, the
+// transactions themselves and the header are not needed.
+func (q *QuorumInterface) getBlock(index uint64) (*quorumBlock, error) {
+	var b *quorumBlock
+	err := q.PrimaryRPC.CallContext(context.Background(), &b, "eth_getBlockByNumber", hexutil.EncodeUint64(index), false)
+	if err != nil {
+		return nil, err
+	}
+
+	if b == nil {
+		return nil, ethereum.NotFound
+	}
+
+	return b, nil
+}
+
+// GetBlockByNumber will request the block information by passing it the height number.
+// The timestamp of the block is given in seconds whatever the consensus.
+func (q *QuorumInterface) GetBlockByNumber(index uint64) (block GenericBlock, error error) {
+	b, err := q.getBlock(index)
+	if err != nil {
+		return GenericBlock{}, err
+	}
+
+	return GenericBlock{
+		Hash:              b.Hash,
+		Index:             uint64(b.Number),
+		Timestamp:         uint64(q.blockTime(uint64(b.Timestamp)).Unix()),
+		TransactionNumber: len(b.Transactions),
+		TransactionHashes: b.Transactions,
+	}, nil
+}
+
+// ParseBlocksForTransactions Goes through all the blocks between start and end index, and
+// marks the transactions committed at the time of the block that includes them.
+func (q *QuorumInterface) ParseBlocksForTransactions(startNumber uint64, endNumber uint64) error {
+	for i := startNumber; i <= endNumber; i++ {
+		b, err := q.getBlock(i)
+		if err != nil {
+			return err
+		}
+
+		tBlock := q.blockTime(uint64(b.Timestamp))
+
+		q.txLock.Lock()
+		for _, v := range b.Transactions {
+			if _, ok := q.TransactionInfo[v]; ok {
+				q.TransactionInfo[v] = append(q.TransactionInfo[v], tBlock)
+			}
+		}
+		q.txLock.Unlock()
+	}
+
+	return nil
+}
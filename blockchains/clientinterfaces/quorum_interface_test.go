@@ -0,0 +1,207 @@
+package clientinterfaces
+
+import (
+	"context"
+	"diablo-benchmark/blockchains/workloadgenerators"
+	"diablo-benchmark/core/configs"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// stubQuorumNode emulates a Raft Quorum node and its Tessera node
+type stubQuorumNode struct {
+	blockTime  time.Time               // Time of the block returned by eth_getBlockByNumber
+	blockTxs   []string                // Transactions of the block returned by eth_getBlockByNumber
+	payloads   map[string][]byte       // Payloads stored in Tessera by hash
+	privateTxs []*ethtypes.Transaction // Private transactions received
+	privateFor [][]string              // Recipients of the private transactions received
+}
+
+// start starts the JSON-RPC and Tessera server of the node
+func (s *stubQuorumNode) start(t *testing.T) *httptest.Server {
+	s.payloads = make(map[string][]byte)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.URL.Path == "/storeraw" {
+			var req struct {
+				Payload string `json:"payload"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			payload, _ := base64.StdEncoding.DecodeString(req.Payload)
+			key := crypto.Keccak512(payload)
+			s.payloads[string(key)] = payload
+			json.NewEncoder(w).Encode(map[string]string{"key": base64.StdEncoding.EncodeToString(key)})
+			return
+		}
+
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var result interface{}
+		switch req.Method {
+		case "eth_getBlockByNumber":
+			// Raft gives the timestamp of the blocks in nanoseconds
+			result = map[string]interface{}{
+				"hash":         "0x01",
+				"number":       "0x1",
+				"timestamp":    hexutil.EncodeUint64(uint64(s.blockTime.UnixNano())),
+				"transactions": s.blockTxs,
+			}
+		case "eth_sendRawPrivateTransaction":
+			var raw hexutil.Bytes
+			var args struct {
+				PrivateFor []string `json:"privateFor"`
+			}
+			json.Unmarshal(req.Params[0], &raw)
+			json.Unmarshal(req.Params[1], &args)
+
+			tx := new(ethtypes.Transaction)
+			if err := rlp.DecodeBytes(raw, tx); err != nil {
+				t.Errorf("invalid private transaction: %s", err.Error())
+			}
+			s.privateTxs = append(s.privateTxs, tx)
+			s.privateFor = append(s.privateFor, args.PrivateFor)
+			result = tx.Hash().String()
+		default:
+			w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(req.ID) + `,"error":{"code":-32601,"message":"method not found"}}`))
+			return
+		}
+
+		out, _ := json.Marshal(result)
+		w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(req.ID) + `,"result":` + string(out) + `}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// stubQuorumInterface returns an interface initialised with the chain
+// configuration and connected to the stub node
+func stubQuorumInterface(t *testing.T, srv *httptest.Server, chainConfig *configs.ChainConfig) *QuorumInterface {
+	c, err := rpc.DialHTTP(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to dial stub node: %s", err.Error())
+	}
+
+	q := &QuorumInterface{}
+	q.Init(chainConfig)
+	q.PrimaryRPC = c
+	q.PrimaryNode = ethclient.NewClient(c)
+
+	return q
+}
+
+func TestQuorumRaftTimestamps(t *testing.T) {
+	start := time.Now()
+	node := &stubQuorumNode{
+		blockTime: start.Add(1500 * time.Millisecond),
+		blockTxs:  []string{"0xaa"},
+	}
+	srv := node.start(t)
+
+	q := stubQuorumInterface(t, srv, &configs.ChainConfig{Name: "quorum"})
+	q.TransactionInfo["0xaa"] = []time.Time{start}
+
+	if err := q.ParseBlocksForTransactions(1, 1); err != nil {
+		t.Fatalf("failed to parse blocks: %s", err.Error())
+	}
+
+	info := q.TransactionInfo["0xaa"]
+	if len(info) != 2 {
+		t.Fatalf("expected the transaction to be committed, got %v", info)
+	}
+
+	if latency := info[1].Sub(info[0]); latency != 1500*time.Millisecond {
+		t.Errorf("expected a latency of 1.5s, got %s", latency)
+	}
+
+	b, err := q.GetBlockByNumber(1)
+	if err != nil {
+		t.Fatalf("failed to get block: %s", err.Error())
+	}
+
+	if b.Timestamp != uint64(node.blockTime.Unix()) {
+		t.Errorf("expected the block timestamp in seconds %d, got %d", node.blockTime.Unix(), b.Timestamp)
+	}
+}
+
+func TestQuorumPrivateTransaction(t *testing.T) {
+	node := &stubQuorumNode{}
+	srv := node.start(t)
+
+	chainConfig := &configs.ChainConfig{
+		Name: "quorum",
+		Extra: []interface{}{map[string]interface{}{
+			"privateFor": []interface{}{"QfeDAys9MPDs2XHExtc84jKGHxZg/aj52DTh0vtA3Xc="},
+			"tessera":    srv.URL,
+		}},
+	}
+
+	// Create the private transaction with the generator
+	wg := (&workloadgenerators.QuorumWorkloadGenerator{}).NewGenerator(chainConfig, nil)
+	g := wg.(*workloadgenerators.QuorumWorkloadGenerator)
+	g.Nonces = make(map[string]uint64)
+	g.ChainID = big.NewInt(10)
+	g.SuggestedGasPrice = big.NewInt(0)
+
+	priv, _ := crypto.GenerateKey()
+	payload := []byte{0x12, 0x34, 0x56, 0x78}
+	txBytes, err := g.CreateSignedTransaction(crypto.FromECDSA(priv), "0x0000000000000000000000000000000000000001", big.NewInt(0), payload)
+	if err != nil {
+		t.Fatalf("failed to create transaction: %s", err.Error())
+	}
+
+	var tx ethtypes.Transaction
+	if err := tx.UnmarshalJSON(txBytes); err != nil {
+		t.Fatalf("failed to decode transaction: %s", err.Error())
+	}
+
+	if !workloadgenerators.IsQuorumPrivateTx(&tx) {
+		v, _, _ := tx.RawSignatureValues()
+		t.Fatalf("expected a private transaction, got v=%s", v.String())
+	}
+
+	if stored := node.payloads[string(tx.Data())]; string(stored) != string(payload) {
+		t.Errorf("expected the payload to be replaced by its hash in tessera, got data %x", tx.Data())
+	}
+
+	// Send it with the interface
+	q := stubQuorumInterface(t, srv, chainConfig)
+	if err := q.send(context.Background(), &tx); err != nil {
+		t.Fatalf("failed to send private transaction: %s", err.Error())
+	}
+
+	if len(node.privateTxs) != 1 {
+		t.Fatalf("expected 1 private transaction, got %d", len(node.privateTxs))
+	}
+
+	if node.privateTxs[0].Hash() != tx.Hash() {
+		t.Errorf("expected transaction %s, got %s", tx.Hash().String(), node.privateTxs[0].Hash().String())
+	}
+
+	if fmt.Sprint(node.privateFor[0]) != fmt.Sprint(q.PrivateFor) {
+		t.Errorf("expected privateFor %v, got %v", q.PrivateFor, node.privateFor[0])
+	}
+}
@@ -3,19 +3,111 @@ package clientinterfaces
 import (
 	"diablo-benchmark/core/configs"
 	"errors"
+	"fmt"
+	"sort"
+	"sync"
 )
 
+// interfaces maps the name of the chains to the constructor of their interface
+var (
+	interfaces     = make(map[string]func() BlockchainInterface)
+	interfacesLock sync.RWMutex
+)
+
+// RegisterBlockchainInterface makes the interface of a chain available under
+// the given name, the name of the chain in the chain configuration. It is
+// called by the chains when their package is initialised, registering the
+// same name twice or a nil constructor panics.
+func RegisterBlockchainInterface(name string, newInterface func() BlockchainInterface) {
+	interfacesLock.Lock()
+	defer interfacesLock.Unlock()
+
+	if newInterface == nil {
+		panic("clientinterfaces: nil constructor for blockchain interface " + name)
+	}
+
+	if _, ok := interfaces[name]; ok {
+		panic("clientinterfaces: blockchain interface registered twice for " + name)
+	}
+
+	interfaces[name] = newInterface
+}
+
+// BlockchainInterfaces returns the sorted names of the registered interfaces
+func BlockchainInterfaces() []string {
+	interfacesLock.RLock()
+	defer interfacesLock.RUnlock()
+
+	names := make([]string, 0, len(interfaces))
+	for name := range interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
 // GetBlockchainInterface maps the name of the blockchain in the config with the interface to implement.
 // Is used by the clients to select the correct chain configuration
 func GetBlockchainInterface(config *configs.ChainConfig) (BlockchainInterface, error) {
-	switch config.Name {
-	case "ethereum":
-		bci := EthereumInterface{}
-		return &bci, nil
-	case "fabric":
-		bci := FabricInterface{}
-		return &bci,nil
-	default:
+	interfacesLock.RLock()
+	newInterface, ok := interfaces[config.Name]
+	interfacesLock.RUnlock()
+
+	if !ok {
 		return nil, errors.New("unsupported blockchain in chain config")
 	}
+
+	return newInterface(), nil
+}
+
+// getExtraMap returns the first entry of the "extra" section of the chain
+// configuration, where the chain specific parameters are defined.
+func getExtraMap(chainConfig *configs.ChainConfig) (map[string]interface{}, error) {
+	if len(chainConfig.Extra) == 0 {
+		return make(map[string]interface{}), nil
+	}
+
+	m, ok := chainConfig.Extra[0].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("chain config: extra must be a map of parameters")
+	}
+
+	return m, nil
+}
+
+// getExtraNumber reads a numeric value from the yaml-decoded extra map
+func getExtraNumber(extra map[string]interface{}, key string, def float64) (float64, error) {
+	v, ok := extra[key]
+	if !ok {
+		return def, nil
+	}
+
+	switch n := v.(type) {
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	default:
+		return 0, fmt.Errorf("chain config: %s must be a number, got %v", key, v)
+	}
+}
+
+// getExtraString reads a string value from the yaml-decoded extra map
+func getExtraString(extra map[string]interface{}, key string, def string) (string, error) {
+	v, ok := extra[key]
+	if !ok {
+		return def, nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("chain config: %s must be a string, got %v", key, v)
+	}
+
+	return s, nil
 }
@@ -0,0 +1,20 @@
+package types
+
+// EthereumReceipt is the outcome of a committed Ethereum transaction,
+// as reported by its receipt.
+type EthereumReceipt struct {
+	Hash         string `json:"hash"`                    // Transaction hash
+	BlockNumber  uint64 `json:"block_number"`            // Block the transaction was included in
+	Status       uint64 `json:"status"`                  // 1 if the transaction succeeded, 0 if it reverted
+	GasUsed      uint64 `json:"gas_used"`                // Gas used by the transaction
+	RevertReason string `json:"revert_reason,omitempty"` // Reason given by the contract if it reverted
+}
+
+// EthereumRead is a contract call of a "read" function. It is not signed nor
+// sent as a transaction, it is answered by SecureRead with an "eth_call" on
+// multiple nodes.
+type EthereumRead struct {
+	SecureRead bool   `json:"secure_read"` // Always true, distinguishes reads from signed transactions
+	To         string `json:"to"`          // Address of the contract
+	Data       string `json:"data"`        // Hex encoded call data (function selector and arguments)
+}
@@ -5,10 +5,20 @@ import "time"
 //FabricTX represents all the necessary information for an
 // Hyperledger Fabric transaction
 type FabricTX struct {
-	ID           uint64   `json:"id"`            // id used in the client interface to keep track of the transaction and register departure and arrival time
-	FunctionName string   `json:"function_name"` // name of the function to be invoked in the chaincode/smart contract
-	FunctionType string   `json:"function_type"` // "write" or "read", indicates whether we query or submit, it is given in the benchmark config of the workload (ftype in bench.go)
-	Args         []string `json:"args"`          // arguments to invoke the chaincode
+	ID           uint64   `json:"id"`                 // id used in the client interface to keep track of the transaction and register departure and arrival time
+	Contract     string   `json:"contract,omitempty"` // name of the chaincode invoked, the chaincode of the chain configuration if empty
+	FunctionName string   `json:"function_name"`      // name of the function to be invoked in the chaincode/smart contract
+	FunctionType string   `json:"function_type"`      // "write" or "read", indicates whether we query or submit, it is given in the benchmark config of the workload (ftype in bench.go)
+	Args         []string `json:"args"`               // arguments to invoke the chaincode
+
+	// Phases of the transaction, recorded by the client interface when it is sent
+	TxID           string    `json:"-"` // Fabric transaction ID, known once the proposal is created
+	SubmitTime     time.Time `json:"-"` // time the proposal was sent to the endorsing peers
+	EndorsedTime   time.Time `json:"-"` // time all the endorsements were collected
+	OrderedTime    time.Time `json:"-"` // time the ordering service accepted the transaction
+	CommittedTime  time.Time `json:"-"` // time the commit event of the transaction was received
+	ValidationCode string    `json:"-"` // validation code given by the committing peer (e.g. VALID, MVCC_READ_CONFLICT)
+	BlockNumber    uint64    `json:"-"` // block in which the transaction was committed
 }
 
 //FabricUser represents a user/account which is the identity by
@@ -23,7 +33,8 @@ type FabricUser struct {
 //FabricCommitEvent represents a commit event which we construct after having submitted a transaction
 // and received the answer (valid or not)
 type FabricCommitEvent struct {
-	Valid bool
-	ID     uint64 // the ID used in client to keep track of the transaction and register throughput
-	CommitTime time.Time // the time the transaction was committed
+	Valid          bool
+	ID             uint64    // the ID used in client to keep track of the transaction and register throughput
+	CommitTime     time.Time // the time the transaction was committed
+	ValidationCode string    // validation code of the transaction, empty for queries and failed submissions
 }
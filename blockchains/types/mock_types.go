@@ -0,0 +1,22 @@
+package types
+
+// MockTX represents a transaction for the in-memory mock blockchain.
+// It carries just enough information for the simulated ledger to apply it
+// and for the client interface to keep track of its latency.
+type MockTX struct {
+	ID           uint64   `json:"id"`            // Unique id of the transaction, used to track it and seed its simulated behaviour
+	From         string   `json:"from"`          // Sending account
+	To           string   `json:"to"`            // Receiving account or contract address
+	Value        string   `json:"value"`         // Value transferred with the transaction
+	FunctionName string   `json:"function_name"` // Contract function invoked (empty for simple transfers)
+	FunctionType string   `json:"function_type"` // "read" or "write"
+	Args         []string `json:"args"`          // Arguments of the contract function
+}
+
+// MockBlock is a block produced by the simulated ledger
+type MockBlock struct {
+	Index        uint64   // Height of the block
+	Hash         string   // Unique identifier of the block
+	Timestamp    int64    // Unix timestamp (nanoseconds) of the block creation
+	Transactions []uint64 // The IDs of the transactions included in the block
+}
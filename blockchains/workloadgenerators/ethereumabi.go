@@ -0,0 +1,362 @@
+package workloadgenerators
+
+import (
+	"bytes"
+	"diablo-benchmark/core/configs"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/compiler"
+)
+
+// parseContractABI parses the ABI of the compiled contract
+func parseContractABI(contract *compiler.Contract) (abi.ABI, error) {
+	var definition []byte
+	switch d := contract.Info.AbiDefinition.(type) {
+	case nil:
+		return abi.ABI{}, errors.New("compiled contract has no ABI")
+	case string:
+		// Some compilers give the ABI as a JSON string
+		definition = []byte(d)
+	default:
+		var err error
+		if definition, err = json.Marshal(d); err != nil {
+			return abi.ABI{}, err
+		}
+	}
+
+	parsed, err := abi.JSON(bytes.NewReader(definition))
+	if err != nil {
+		return abi.ABI{}, fmt.Errorf("invalid contract ABI: %w", err)
+	}
+
+	return parsed, nil
+}
+
+// intTypes matches the integer types of a type, e.g. both in (uint,int8[])
+var intTypes = regexp.MustCompile(`u?int[0-9]*`)
+
+// canonicalType returns the type as written in the function signatures, e.g.
+// uint256 for uint.
+func canonicalType(t string) string {
+	t = strings.ReplaceAll(t, " ", "")
+	return intTypes.ReplaceAllStringFunc(t, func(intType string) string {
+		if intType == "uint" || intType == "int" {
+			return intType + "256"
+		}
+		return intType
+	})
+}
+
+// findMethod returns the method of the ABI called with the name and the types
+// of the params. The name can be given with its signature, e.g.
+// storeVal(uint32), the types of the params select between overloaded
+// functions.
+func findMethod(contractABI abi.ABI, functionName string, contractParams []configs.ContractParam) (abi.Method, error) {
+	name := functionName
+	if i := strings.Index(name, "("); i >= 0 {
+		name = name[:i]
+	}
+
+	types := make([]string, 0, len(contractParams))
+	for _, p := range contractParams {
+		types = append(types, canonicalType(p.Type))
+	}
+	signature := fmt.Sprintf("%s(%s)", name, strings.Join(types, ","))
+
+	var candidates []abi.Method
+	for _, m := range contractABI.Methods {
+		if m.RawName != name {
+			continue
+		}
+		if m.Sig == signature {
+			return m, nil
+		}
+		candidates = append(candidates, m)
+	}
+
+	switch len(candidates) {
+	case 0:
+		var available []string
+		for _, m := range contractABI.Methods {
+			available = append(available, m.Sig)
+		}
+		return abi.Method{}, fmt.Errorf("contract does not contain function %s, available: %s", name, strings.Join(available, ", "))
+	case 1:
+		if len(contractParams) == 0 && len(candidates[0].Inputs) > 0 {
+			return abi.Method{}, fmt.Errorf("function %s requires params, none given", candidates[0].Sig)
+		}
+		return abi.Method{}, fmt.Errorf("params of %s do not match the function %s", signature, candidates[0].Sig)
+	default:
+		var overloads []string
+		for _, m := range candidates {
+			overloads = append(overloads, m.Sig)
+		}
+		return abi.Method{}, fmt.Errorf("params of %s do not match any of the functions %s", signature, strings.Join(overloads, ", "))
+	}
+}
+
+// abiGoType returns the Go type the ABI packs for the type
+func abiGoType(t abi.Type) (reflect.Type, error) {
+	switch t.T {
+	case abi.IntTy, abi.UintTy:
+		unsigned := t.T == abi.UintTy
+		switch t.Size {
+		case 8:
+			if unsigned {
+				return reflect.TypeOf(uint8(0)), nil
+			}
+			return reflect.TypeOf(int8(0)), nil
+		case 16:
+			if unsigned {
+				return reflect.TypeOf(uint16(0)), nil
+			}
+			return reflect.TypeOf(int16(0)), nil
+		case 32:
+			if unsigned {
+				return reflect.TypeOf(uint32(0)), nil
+			}
+			return reflect.TypeOf(int32(0)), nil
+		case 64:
+			if unsigned {
+				return reflect.TypeOf(uint64(0)), nil
+			}
+			return reflect.TypeOf(int64(0)), nil
+		}
+		return reflect.TypeOf(&big.Int{}), nil
+	case abi.BoolTy:
+		return reflect.TypeOf(false), nil
+	case abi.StringTy:
+		return reflect.TypeOf(""), nil
+	case abi.AddressTy:
+		return reflect.TypeOf(common.Address{}), nil
+	case abi.BytesTy:
+		return reflect.TypeOf([]byte{}), nil
+	case abi.FixedBytesTy:
+		return reflect.ArrayOf(t.Size, reflect.TypeOf(byte(0))), nil
+	case abi.SliceTy, abi.ArrayTy:
+		elem, err := abiGoType(*t.Elem)
+		if err != nil {
+			return nil, err
+		}
+		if t.T == abi.SliceTy {
+			return reflect.SliceOf(elem), nil
+		}
+		return reflect.ArrayOf(t.Size, elem), nil
+	case abi.TupleTy:
+		return t.TupleType, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t.String())
+	}
+}
+
+// parseABIInt parses a decimal or 0x prefixed hexadecimal integer
+func parseABIInt(value string) (*big.Int, bool) {
+	if len(value) > 2 && (value[:2] == "0x" || value[:2] == "0X") {
+		return new(big.Int).SetString(value[2:], 16)
+	}
+	if len(value) > 3 && (value[:3] == "-0x" || value[:3] == "-0X") {
+		n, ok := new(big.Int).SetString(value[3:], 16)
+		if !ok {
+			return nil, false
+		}
+		return n.Neg(n), true
+	}
+
+	return new(big.Int).SetString(value, 10)
+}
+
+// parseABIBytes parses 0x prefixed hexadecimal bytes, other values are taken
+// as the bytes of the text.
+func parseABIBytes(value string) ([]byte, error) {
+	if len(value) >= 2 && (value[:2] == "0x" || value[:2] == "0X") {
+		return hex.DecodeString(value[2:])
+	}
+
+	return []byte(value), nil
+}
+
+// splitABIList splits the JSON list of the values of an array or a tuple,
+// the values that are JSON strings are unquoted.
+func splitABIList(value string) ([]string, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, fmt.Errorf("expected a JSON list, got %q", value)
+	}
+
+	values := make([]string, 0, len(raw))
+	for _, r := range raw {
+		var s string
+		if err := json.Unmarshal(r, &s); err == nil {
+			values = append(values, s)
+			continue
+		}
+		values = append(values, string(bytes.TrimSpace(r)))
+	}
+
+	return values, nil
+}
+
+// abiValue converts the value given in the configuration to the Go value
+// packed for the type. Numbers are decimal or 0x prefixed hexadecimal, bytes
+// are 0x prefixed hexadecimal or text, arrays and tuples are JSON lists.
+func abiValue(t abi.Type, value string) (reflect.Value, error) {
+	goType, err := abiGoType(t)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	switch t.T {
+	case abi.IntTy, abi.UintTy:
+		n, ok := parseABIInt(strings.TrimSpace(value))
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%q is not an integer", value)
+		}
+
+		// Check the value fits in the type
+		if t.T == abi.UintTy {
+			if n.Sign() < 0 || n.BitLen() > t.Size {
+				return reflect.Value{}, fmt.Errorf("%s is out of range for %s", n.String(), t.String())
+			}
+		} else {
+			limit := new(big.Int).Lsh(big.NewInt(1), uint(t.Size-1))
+			if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+				return reflect.Value{}, fmt.Errorf("%s is out of range for %s", n.String(), t.String())
+			}
+		}
+
+		if goType.Kind() == reflect.Ptr {
+			return reflect.ValueOf(n), nil
+		}
+		v := reflect.New(goType).Elem()
+		if t.T == abi.UintTy {
+			v.SetUint(n.Uint64())
+		} else {
+			v.SetInt(n.Int64())
+		}
+		return v, nil
+	case abi.BoolTy:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%q is not a bool", value)
+		}
+		return reflect.ValueOf(b), nil
+	case abi.StringTy:
+		return reflect.ValueOf(value), nil
+	case abi.AddressTy:
+		if !common.IsHexAddress(value) {
+			return reflect.Value{}, fmt.Errorf("%q is not an address", value)
+		}
+		return reflect.ValueOf(common.HexToAddress(value)), nil
+	case abi.BytesTy:
+		b, err := parseABIBytes(value)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%q is not hexadecimal bytes: %w", value, err)
+		}
+		return reflect.ValueOf(b), nil
+	case abi.FixedBytesTy:
+		b, err := parseABIBytes(value)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%q is not hexadecimal bytes: %w", value, err)
+		}
+		if len(b) > t.Size {
+			return reflect.Value{}, fmt.Errorf("%d bytes do not fit in %s", len(b), t.String())
+		}
+		// The bytes are left aligned
+		v := reflect.New(goType).Elem()
+		reflect.Copy(v, reflect.ValueOf(b))
+		return v, nil
+	case abi.SliceTy, abi.ArrayTy:
+		values, err := splitABIList(value)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if t.T == abi.ArrayTy && len(values) != t.Size {
+			return reflect.Value{}, fmt.Errorf("%s requires %d values, got %d", t.String(), t.Size, len(values))
+		}
+
+		var v reflect.Value
+		if t.T == abi.SliceTy {
+			v = reflect.MakeSlice(goType, len(values), len(values))
+		} else {
+			v = reflect.New(goType).Elem()
+		}
+		for i, elem := range values {
+			ev, err := abiValue(*t.Elem, elem)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %w", i, err)
+			}
+			v.Index(i).Set(ev)
+		}
+		return v, nil
+	case abi.TupleTy:
+		values, err := splitABIList(value)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if len(values) != len(t.TupleElems) {
+			return reflect.Value{}, fmt.Errorf("%s requires %d values, got %d", t.String(), len(t.TupleElems), len(values))
+		}
+
+		v := reflect.New(goType).Elem()
+		for i, elem := range values {
+			ev, err := abiValue(*t.TupleElems[i], elem)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: %w", t.TupleRawNames[i], err)
+			}
+			v.Field(i).Set(ev)
+		}
+		return v, nil
+	default:
+		return reflect.Value{}, fmt.Errorf("unsupported type %s", t.String())
+	}
+}
+
+// packArgs encodes the params of the configuration for the arguments of the
+// function with the signature
+func packArgs(args abi.Arguments, signature string, contractParams []configs.ContractParam) ([]byte, error) {
+	if len(contractParams) != len(args) {
+		return nil, fmt.Errorf("%s takes %d params, got %d", signature, len(args), len(contractParams))
+	}
+
+	values := make([]interface{}, 0, len(contractParams))
+	for i, p := range contractParams {
+		v, err := abiValue(args[i].Type, p.Value)
+		if err != nil {
+			return nil, fmt.Errorf("param %d (%s) of %s: %w", i, args[i].Type.String(), signature, err)
+		}
+		values = append(values, v.Interface())
+	}
+
+	packed, err := args.Pack(values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the params of %s: %w", signature, err)
+	}
+
+	return packed, nil
+}
+
+// packCall encodes the call of the method with the params of the configuration
+func packCall(method abi.Method, contractParams []configs.ContractParam) ([]byte, error) {
+	packed, err := packArgs(method.Inputs, "function "+method.Sig, contractParams)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append([]byte{}, method.ID...), packed...), nil
+}
+
+// packConstructor encodes the params of the constructor of the contract, they
+// are appended to its bytecode to deploy it
+func packConstructor(contractABI abi.ABI, contractParams []configs.ContractParam) ([]byte, error) {
+	return packArgs(contractABI.Constructor.Inputs, "constructor", contractParams)
+}
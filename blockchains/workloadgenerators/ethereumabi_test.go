@@ -0,0 +1,139 @@
+package workloadgenerators
+
+import (
+	"diablo-benchmark/core/configs"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/compiler"
+)
+
+// testABI is the ABI of the examples of the Solidity ABI specification, with
+// an overloaded function and a tuple
+const testABI = `[
+	{"type": "function", "name": "baz", "inputs": [{"name": "x", "type": "uint32"}, {"name": "y", "type": "bool"}], "outputs": [{"name": "r", "type": "bool"}]},
+	{"type": "function", "name": "bar", "inputs": [{"name": "xy", "type": "bytes3[2]"}], "outputs": []},
+	{"type": "function", "name": "sam", "inputs": [{"name": "a", "type": "bytes"}, {"name": "b", "type": "bool"}, {"name": "c", "type": "uint256[]"}], "outputs": []},
+	{"type": "function", "name": "set", "inputs": [{"name": "v", "type": "uint256"}], "outputs": []},
+	{"type": "function", "name": "set", "inputs": [{"name": "v", "type": "int8"}, {"name": "to", "type": "address"}], "outputs": []},
+	{"type": "function", "name": "put", "inputs": [{"name": "p", "type": "tuple", "components": [{"name": "id", "type": "uint64"}, {"name": "name", "type": "string"}]}], "outputs": []}
+]`
+
+// testABIGenerator returns a generator with a compiled contract of the ABI
+func testABIGenerator(t *testing.T) *EthereumWorkloadGenerator {
+	var definition interface{}
+	if err := json.Unmarshal([]byte(testABI), &definition); err != nil {
+		t.Fatalf("invalid test ABI: %s", err.Error())
+	}
+
+	return &EthereumWorkloadGenerator{
+		CompiledContract: &compiler.Contract{Info: compiler.ContractInfo{AbiDefinition: definition}},
+	}
+}
+
+func TestEncodeCallData(t *testing.T) {
+	e := testABIGenerator(t)
+
+	tests := []struct {
+		function string
+		params   []configs.ContractParam
+		expected string
+	}{
+		{
+			function: "baz",
+			params:   []configs.ContractParam{{Type: "uint32", Value: "69"}, {Type: "bool", Value: "true"}},
+			expected: "cdcd77c0" +
+				"0000000000000000000000000000000000000000000000000000000000000045" +
+				"0000000000000000000000000000000000000000000000000000000000000001",
+		},
+		{
+			function: "bar",
+			params:   []configs.ContractParam{{Type: "bytes3[2]", Value: `["abc", "def"]`}},
+			expected: "fce353f6" +
+				"6162630000000000000000000000000000000000000000000000000000000000" +
+				"6465660000000000000000000000000000000000000000000000000000000000",
+		},
+		{
+			function: "sam",
+			params: []configs.ContractParam{
+				{Type: "bytes", Value: "dave"},
+				{Type: "bool", Value: "true"},
+				{Type: "uint256[]", Value: "[1, 2, 3]"},
+			},
+			expected: "a5643bf2" +
+				"0000000000000000000000000000000000000000000000000000000000000060" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"00000000000000000000000000000000000000000000000000000000000000a0" +
+				"0000000000000000000000000000000000000000000000000000000000000004" +
+				"6461766500000000000000000000000000000000000000000000000000000000" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000000000000000000000000000000000000000000003",
+		},
+		{
+			// The overload is selected by the types of the params
+			function: "set",
+			params:   []configs.ContractParam{{Type: "int8", Value: "-1"}, {Type: "address", Value: "0x0000000000000000000000000000000000000001"}},
+			expected: "87812025" +
+				"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff" +
+				"0000000000000000000000000000000000000000000000000000000000000001",
+		},
+		{
+			function: "set(uint)",
+			params:   []configs.ContractParam{{Type: "uint", Value: "0x10"}},
+			expected: "60fe47b1" +
+				"0000000000000000000000000000000000000000000000000000000000000010",
+		},
+	}
+
+	for _, test := range tests {
+		data, err := e.encodeCallData(test.function, test.params)
+		if err != nil {
+			t.Errorf("failed to encode %s: %s", test.function, err.Error())
+			continue
+		}
+
+		if got := hex.EncodeToString(data); got != test.expected {
+			t.Errorf("wrong encoding of %s:\nexpected %s\ngot      %s", test.function, test.expected, got)
+		}
+	}
+
+	// Tuples are JSON lists of their fields
+	data, err := e.encodeCallData("put", []configs.ContractParam{{Type: "(uint64,string)", Value: `[7, "seven"]`}})
+	if err != nil {
+		t.Fatalf("failed to encode the tuple: %s", err.Error())
+	}
+	if len(data) != 4+5*32 {
+		t.Errorf("expected the selector and 5 words for the tuple, got %d bytes", len(data))
+	}
+}
+
+func TestEncodeCallDataErrors(t *testing.T) {
+	e := testABIGenerator(t)
+
+	tests := []struct {
+		function string
+		params   []configs.ContractParam
+		err      string
+	}{
+		{"missing", nil, "does not contain function missing"},
+		{"baz", []configs.ContractParam{{Type: "uint64", Value: "1"}, {Type: "bool", Value: "true"}}, "do not match the function baz(uint32,bool)"},
+		{"baz", nil, "requires params"},
+		{"baz", []configs.ContractParam{{Type: "uint32", Value: "4294967296"}, {Type: "bool", Value: "true"}}, "out of range for uint32"},
+		{"baz", []configs.ContractParam{{Type: "uint32", Value: "1"}, {Type: "bool", Value: "yes"}}, "param 1 (bool)"},
+		{"set", []configs.ContractParam{{Type: "string", Value: "1"}}, "do not match any of the functions"},
+		{"set", []configs.ContractParam{{Type: "int8", Value: "128"}, {Type: "address", Value: "0x01"}}, "out of range for int8"},
+		{"bar", []configs.ContractParam{{Type: "bytes3[2]", Value: `["abc"]`}}, "requires 2 values"},
+		{"bar", []configs.ContractParam{{Type: "bytes3[2]", Value: `["abcd", "def"]`}}, "do not fit in bytes3"},
+	}
+
+	for _, test := range tests {
+		_, err := e.encodeCallData(test.function, test.params)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("expected the error %q encoding %s %v, got %v", test.err, test.function, test.params, err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package workloadgenerators
+
+import (
+	"diablo-benchmark/core/configs"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/compiler"
+	"go.uber.org/zap"
+)
+
+// contractArtifact is the JSON artifact of a compiled contract. Hardhat and
+// Truffle give the bytecode as a string, Foundry as an object.
+type contractArtifact struct {
+	ContractName string          `json:"contractName"`
+	ABI          json.RawMessage `json:"abi"`
+	Bytecode     json.RawMessage `json:"bytecode"`
+}
+
+// loadContract returns the compiled contract of the configuration. Solidity
+// files are compiled with solc, JSON artifacts and .bin bytecode are taken as
+// they are.
+func loadContract(info configs.ContractInfo) (*compiler.Contract, error) {
+	if _, err := os.Stat(info.Path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("contract does not exist: %s", info.Path)
+		}
+		return nil, err
+	}
+
+	switch filepath.Ext(info.Path) {
+	case ".json":
+		return loadContractArtifact(info)
+	case ".bin":
+		return loadContractBytecode(info)
+	default:
+		return compileContract(info)
+	}
+}
+
+// compileContract compiles the Solidity file and returns the contract with the
+// name of the configuration, or the first one if no name is given.
+func compileContract(info configs.ContractInfo) (*compiler.Contract, error) {
+	// TODO: check the 'solc' string
+	contracts, err := compiler.CompileSolidity("", info.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(contracts) == 0 {
+		return nil, fmt.Errorf("no contracts to compile")
+	}
+
+	if info.Name == "" {
+		for k, v := range contracts {
+			zap.L().Warn("Name not provided, compiling first contract",
+				zap.String("contract", k),
+			)
+			return v, nil
+		}
+	}
+
+	for k, v := range contracts {
+		s := strings.Split(k, ":")
+		if s[len(s)-1] == info.Name {
+			return v, nil
+		}
+	}
+
+	zap.L().Error(fmt.Sprintf("Failed to find contract %v in %v", info.Name, contracts))
+	return nil, fmt.Errorf("failed to find contract in compiled")
+}
+
+// loadContractArtifact reads the bytecode and the ABI of the JSON artifact
+func loadContractArtifact(info configs.ContractInfo) (*compiler.Contract, error) {
+	data, err := ioutil.ReadFile(info.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var artifact contractArtifact
+	if err := json.Unmarshal(data, &artifact); err != nil {
+		return nil, fmt.Errorf("invalid contract artifact %s: %w", info.Path, err)
+	}
+
+	if info.Name != "" && artifact.ContractName != "" && artifact.ContractName != info.Name {
+		return nil, fmt.Errorf("artifact %s is the contract %s, not %s", info.Path, artifact.ContractName, info.Name)
+	}
+	if len(artifact.ABI) == 0 {
+		return nil, fmt.Errorf("artifact %s has no ABI", info.Path)
+	}
+
+	// The bytecode is either a string or an object with the string
+	var bytecode string
+	if err := json.Unmarshal(artifact.Bytecode, &bytecode); err != nil {
+		var object struct {
+			Object string `json:"object"`
+		}
+		if err := json.Unmarshal(artifact.Bytecode, &object); err != nil {
+			return nil, fmt.Errorf("artifact %s has no bytecode", info.Path)
+		}
+		bytecode = object.Object
+	}
+
+	return newPrecompiledContract(info.Path, bytecode, artifact.ABI)
+}
+
+// loadContractBytecode reads the .bin bytecode and its ABI
+func loadContractBytecode(info configs.ContractInfo) (*compiler.Contract, error) {
+	bytecode, err := ioutil.ReadFile(info.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	definition, err := ioutil.ReadFile(info.ABIPath())
+	if err != nil {
+		return nil, fmt.Errorf("ABI of the contract bytecode %s: %w", info.Path, err)
+	}
+
+	return newPrecompiledContract(info.Path, string(bytecode), definition)
+}
+
+// newPrecompiledContract returns the contract of the hexadecimal bytecode and
+// the JSON ABI
+func newPrecompiledContract(path string, bytecode string, definition []byte) (*compiler.Contract, error) {
+	bytecode = strings.TrimPrefix(strings.TrimSpace(bytecode), "0x")
+	if bytecode == "" {
+		return nil, fmt.Errorf("contract %s has no bytecode, it may be abstract", path)
+	}
+	// Unlinked libraries are left as __$...$__ placeholders
+	if strings.Contains(bytecode, "__") {
+		return nil, fmt.Errorf("bytecode of %s has unlinked libraries", path)
+	}
+	if _, err := hex.DecodeString(bytecode); err != nil {
+		return nil, fmt.Errorf("invalid bytecode of %s: %w", path, err)
+	}
+
+	abiDefinition, err := parseABIDefinition(path, definition)
+	if err != nil {
+		return nil, err
+	}
+
+	return &compiler.Contract{
+		Code: "0x" + bytecode,
+		Info: compiler.ContractInfo{AbiDefinition: abiDefinition},
+	}, nil
+}
+
+// parseABIDefinition parses the JSON ABI of the contract
+func parseABIDefinition(path string, definition []byte) (interface{}, error) {
+	var abiDefinition interface{}
+	if err := json.Unmarshal(definition, &abiDefinition); err != nil {
+		return nil, fmt.Errorf("invalid ABI of %s: %w", path, err)
+	}
+	if _, ok := abiDefinition.([]interface{}); !ok {
+		return nil, fmt.Errorf("the ABI of %s is not a JSON list", path)
+	}
+
+	return abiDefinition, nil
+}
+
+// loadDeployedContract returns the contract called at the address of the
+// configuration. Its ABI is read from the ABI file, or from the contract
+// path; only the ABI is used, the contract is not deployed.
+func loadDeployedContract(info configs.ContractInfo) (*compiler.Contract, error) {
+	if info.Path != "" {
+		return loadContract(info)
+	}
+
+	definition, err := ioutil.ReadFile(info.ABI)
+	if err != nil {
+		return nil, fmt.Errorf("ABI of the contract at %s: %w", info.Address, err)
+	}
+	abiDefinition, err := parseABIDefinition(info.ABI, definition)
+	if err != nil {
+		return nil, err
+	}
+
+	return &compiler.Contract{Info: compiler.ContractInfo{AbiDefinition: abiDefinition}}, nil
+}
+
+// deployData returns the bytecode of the contract followed by the params of
+// its constructor
+func deployData(contract *compiler.Contract, contractParams []configs.ContractParam) ([]byte, error) {
+	bytecode, err := hex.DecodeString(strings.TrimPrefix(contract.Code, "0x"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Contracts without params are deployed without parsing their ABI
+	if len(contractParams) == 0 && contract.Info.AbiDefinition == nil {
+		return bytecode, nil
+	}
+
+	contractABI, err := parseContractABI(contract)
+	if err != nil {
+		return nil, err
+	}
+	params, err := packConstructor(contractABI, contractParams)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(bytecode, params...), nil
+}
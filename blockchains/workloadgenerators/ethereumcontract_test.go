@@ -0,0 +1,314 @@
+package workloadgenerators
+
+import (
+	"context"
+	"diablo-benchmark/core/configs"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testConstructorABI is the ABI of a contract with a constructor taking a
+// number and an address
+const testConstructorABI = `[
+	{"type": "constructor", "inputs": [{"name": "supply", "type": "uint256"}, {"name": "owner", "type": "address"}]},
+	{"type": "function", "name": "set", "inputs": [{"name": "v", "type": "uint256"}], "outputs": []}
+]`
+
+// testConstructorParams are the params of the constructor and their encoding
+var (
+	testConstructorParams = []configs.ContractParam{
+		{Type: "uint256", Value: "1000"},
+		{Type: "address", Value: "0x00000000000000000000000000000000000000aa"},
+	}
+	testConstructorEncoded = "00000000000000000000000000000000000000000000000000000000000003e8" +
+		"00000000000000000000000000000000000000000000000000000000000000aa"
+)
+
+// writeContractFiles writes the files in a new directory and returns it
+func writeContractFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "diablo-contract")
+	if err != nil {
+		t.Fatalf("failed to create directory: %s", err.Error())
+	}
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write %s: %s", name, err.Error())
+		}
+	}
+
+	return dir
+}
+
+func TestLoadContract(t *testing.T) {
+	dir := writeContractFiles(t, map[string]string{
+		"hardhat.json": `{"contractName": "Token", "abi": ` + testConstructorABI + `, "bytecode": "0x6080"}`,
+		"foundry.json": `{"abi": ` + testConstructorABI + `, "bytecode": {"object": "0x6080"}}`,
+		"Token.bin":    "6080\n",
+		"Token.abi":    testConstructorABI,
+		"Other.bin":    "0x6080",
+		"other.json":   testConstructorABI,
+	})
+	defer os.RemoveAll(dir)
+
+	tests := []configs.ContractInfo{
+		{Path: filepath.Join(dir, "hardhat.json"), Name: "Token"},
+		{Path: filepath.Join(dir, "foundry.json")},
+		{Path: filepath.Join(dir, "Token.bin")},
+		{Path: filepath.Join(dir, "Other.bin"), ABI: filepath.Join(dir, "other.json")},
+	}
+
+	for _, info := range tests {
+		info.Params = testConstructorParams
+
+		contract, err := loadContract(info)
+		if err != nil {
+			t.Errorf("failed to load %s: %s", filepath.Base(info.Path), err.Error())
+			continue
+		}
+
+		data, err := deployData(contract, info.Params)
+		if err != nil {
+			t.Errorf("failed to encode the deployment of %s: %s", filepath.Base(info.Path), err.Error())
+			continue
+		}
+		if hex.EncodeToString(data) != "6080"+testConstructorEncoded {
+			t.Errorf("expected the deployment of %s to be the bytecode and the params, got %x", filepath.Base(info.Path), data)
+		}
+	}
+}
+
+func TestLoadContractErrors(t *testing.T) {
+	dir := writeContractFiles(t, map[string]string{
+		"Token.json":    `{"contractName": "Token", "abi": ` + testConstructorABI + `, "bytecode": "0x6080"}`,
+		"Abstract.json": `{"contractName": "Abstract", "abi": [], "bytecode": "0x"}`,
+		"Linked.json":   `{"contractName": "Linked", "abi": [], "bytecode": "0x6080__$aa$__6080"}`,
+		"NoABI.bin":     "6080",
+	})
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		info     configs.ContractInfo
+		expected string
+	}{
+		{configs.ContractInfo{Path: filepath.Join(dir, "Missing.json")}, "does not exist"},
+		{configs.ContractInfo{Path: filepath.Join(dir, "Token.json"), Name: "Other"}, "is the contract Token, not Other"},
+		{configs.ContractInfo{Path: filepath.Join(dir, "Abstract.json")}, "has no bytecode"},
+		{configs.ContractInfo{Path: filepath.Join(dir, "Linked.json")}, "unlinked libraries"},
+		{configs.ContractInfo{Path: filepath.Join(dir, "NoABI.bin")}, "ABI of the contract bytecode"},
+	}
+
+	for _, test := range tests {
+		_, err := loadContract(test.info)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("expected loading %s to fail with %q, got %v", filepath.Base(test.info.Path), test.expected, err)
+		}
+	}
+
+	// The params must match the constructor
+	contract, err := loadContract(configs.ContractInfo{Path: filepath.Join(dir, "Token.json")})
+	if err != nil {
+		t.Fatalf("failed to load contract: %s", err.Error())
+	}
+	if _, err := deployData(contract, testConstructorParams[:1]); err == nil || !strings.Contains(err.Error(), "constructor takes 2 params, got 1") {
+		t.Errorf("expected the missing constructor param to fail, got %v", err)
+	}
+}
+
+func TestCreateContractDeployTXArtifact(t *testing.T) {
+	dir := writeContractFiles(t, map[string]string{
+		"Token.json": `{"contractName": "Token", "abi": ` + testConstructorABI + `, "bytecode": "0x6080"}`,
+	})
+	defer os.RemoveAll(dir)
+
+	priv, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	chainConfig := &configs.ChainConfig{Name: "ethereum", Keys: []configs.ChainKey{{
+		PrivateKey: crypto.FromECDSA(priv),
+		Address:    crypto.PubkeyToAddress(priv.PublicKey).String(),
+	}}}
+	benchConfig := &configs.BenchConfig{
+		Secondaries: 1,
+		Threads:     1,
+		TxInfo:      configs.BenchInfo{TxType: configs.TxTypeContract, Intervals: configs.TPSIntervals{0: 1}},
+		ContractInfo: configs.ContractInfo{
+			Path:   filepath.Join(dir, "Token.json"),
+			Params: testConstructorParams,
+		},
+	}
+
+	// The contract is deployed without solc
+	e := testEthereumGenerator(chainConfig, benchConfig)
+	txBytes, err := e.CreateContractDeployTX(chainConfig.Keys[0].PrivateKey, benchConfig.ContractInfo.Path)
+	if err != nil {
+		t.Fatalf("failed to create the deployment: %s", err.Error())
+	}
+
+	var tx ethtypes.Transaction
+	if err := tx.UnmarshalJSON(txBytes); err != nil {
+		t.Fatalf("failed to decode transaction: %s", err.Error())
+	}
+	if tx.To() != nil || hex.EncodeToString(tx.Data()) != "6080"+testConstructorEncoded {
+		t.Errorf("expected a contract creation with the params, got %x", tx.Data())
+	}
+
+	// The calls are encoded with the ABI of the artifact
+	if _, err := e.encodeCallData("set", []configs.ContractParam{{Type: "uint256", Value: "1"}}); err != nil {
+		t.Errorf("failed to encode a call of the deployed contract: %s", err.Error())
+	}
+}
+
+func TestAttachDeployedContract(t *testing.T) {
+	dir := writeContractFiles(t, map[string]string{"Token.abi": testConstructorABI})
+	defer os.RemoveAll(dir)
+
+	priv, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	chainConfig := &configs.ChainConfig{Name: "ethereum", Keys: []configs.ChainKey{{
+		PrivateKey: crypto.FromECDSA(priv),
+		Address:    crypto.PubkeyToAddress(priv.PublicKey).String(),
+	}}}
+	address := common.HexToAddress("0x00000000000000000000000000000000000000cc")
+	benchConfig := &configs.BenchConfig{
+		Secondaries: 1,
+		Threads:     1,
+		TxInfo:      configs.BenchInfo{TxType: configs.TxTypeContract, Intervals: configs.TPSIntervals{0: 4, 1: 4}},
+		ContractInfo: configs.ContractInfo{
+			ABI:     filepath.Join(dir, "Token.abi"),
+			Address: address.String(),
+			Functions: []configs.ContractFunction{
+				{Name: "set", Ratio: 100, Params: []configs.ContractParam{{Type: "uint256", Value: "1"}}},
+			},
+		},
+	}
+
+	newGenerator := func(code []byte) *EthereumWorkloadGenerator {
+		e := testEthereumGenerator(chainConfig, benchConfig)
+		e.getCode = func(ctx context.Context, a common.Address) ([]byte, error) {
+			if a != address {
+				t.Errorf("expected the code of %s, got %s", address.String(), a.String())
+			}
+			return code, nil
+		}
+		e.sendTx = func(ctx context.Context, tx *ethtypes.Transaction) error {
+			t.Errorf("expected the contract not to be deployed")
+			return nil
+		}
+		return e
+	}
+
+	workload, err := newGenerator([]byte{0x60, 0x80}).GenerateWorkload()
+	if err != nil {
+		t.Fatalf("failed to generate workload: %s", err.Error())
+	}
+
+	// The calls go to the deployed contract, from the first nonce
+	var nonces, expected []uint64
+	for _, txs := range workload[0][0] {
+		for _, txBytes := range txs {
+			var tx ethtypes.Transaction
+			if err := tx.UnmarshalJSON(txBytes); err != nil {
+				t.Fatalf("failed to decode transaction: %s", err.Error())
+			}
+			if tx.To() == nil || *tx.To() != address {
+				t.Errorf("expected a call of %s, got %v", address.String(), tx.To())
+			}
+			expected = append(expected, uint64(len(nonces)))
+			nonces = append(nonces, tx.Nonce())
+		}
+	}
+	if len(nonces) == 0 || !reflect.DeepEqual(nonces, expected) {
+		t.Errorf("expected the nonces %v, got %v", expected, nonces)
+	}
+
+	// There must be a contract at the address
+	if _, err := newGenerator(nil).GenerateWorkload(); err == nil || !strings.Contains(err.Error(), "no contract deployed at") {
+		t.Errorf("expected attaching to an empty address to fail, got %v", err)
+	}
+}
+
+func TestMultipleContracts(t *testing.T) {
+	dir := writeContractFiles(t, map[string]string{
+		"Token.abi":   testConstructorABI,
+		"Counter.abi": `[{"type": "function", "name": "inc", "inputs": [], "outputs": []}]`,
+	})
+	defer os.RemoveAll(dir)
+
+	priv, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	chainConfig := &configs.ChainConfig{Name: "ethereum", Keys: []configs.ChainKey{{
+		PrivateKey: crypto.FromECDSA(priv),
+		Address:    crypto.PubkeyToAddress(priv.PublicKey).String(),
+	}}}
+	token := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	counter := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	benchConfig := &configs.BenchConfig{
+		Secondaries: 1,
+		Threads:     1,
+		TxInfo:      configs.BenchInfo{TxType: configs.TxTypeContract, Intervals: configs.TPSIntervals{0: 4, 1: 4}},
+		Contracts: []configs.ContractInfo{
+			{
+				Ratio:   75,
+				ABI:     filepath.Join(dir, "Token.abi"),
+				Address: token.String(),
+				Functions: []configs.ContractFunction{
+					{Name: "set", Ratio: 100, Params: []configs.ContractParam{{Type: "uint256", Value: "1"}}},
+				},
+			},
+			{
+				Ratio:     25,
+				ABI:       filepath.Join(dir, "Counter.abi"),
+				Address:   counter.String(),
+				Functions: []configs.ContractFunction{{Name: "inc", Ratio: 100}},
+			},
+		},
+	}
+
+	e := testEthereumGenerator(chainConfig, benchConfig)
+	e.getCode = func(ctx context.Context, a common.Address) ([]byte, error) { return []byte{0x60, 0x80}, nil }
+
+	workload, err := e.GenerateWorkload()
+	if err != nil {
+		t.Fatalf("failed to generate workload: %s", err.Error())
+	}
+
+	// Each contract is called with its own functions, in proportion to its ratio
+	selectors := map[common.Address]string{
+		token:   hex.EncodeToString(crypto.Keccak256([]byte("set(uint256)"))[:4]),
+		counter: hex.EncodeToString(crypto.Keccak256([]byte("inc()"))[:4]),
+	}
+	calls := make(map[common.Address]int)
+	for _, txs := range workload[0][0] {
+		for _, txBytes := range txs {
+			var tx ethtypes.Transaction
+			if err := tx.UnmarshalJSON(txBytes); err != nil {
+				t.Fatalf("failed to decode transaction: %s", err.Error())
+			}
+			if tx.To() == nil || hex.EncodeToString(tx.Data()[:4]) != selectors[*tx.To()] {
+				t.Errorf("expected a call of a contract with its function, got %x to %v", tx.Data(), tx.To())
+				continue
+			}
+			calls[*tx.To()]++
+		}
+	}
+
+	total := calls[token] + calls[counter]
+	if total == 0 || calls[token] != total*75/100 || calls[counter] != total*25/100 {
+		t.Errorf("expected 75%% of the calls to the token and 25%% to the counter, got %d and %d", calls[token], calls[counter])
+	}
+}
@@ -1,25 +1,25 @@
 package workloadgenerators
 
 import (
-	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"diablo-benchmark/blockchains/types"
 	"diablo-benchmark/core/configs"
 	"diablo-benchmark/core/configs/parsers"
-	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/compiler"
-	"github.com/ethereum/go-ethereum/core/types"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"go.uber.org/zap"
@@ -27,23 +27,34 @@ import (
 
 // EthereumWorkloadGenerator is the workload generator implementation for the Ethereum blockchain
 type EthereumWorkloadGenerator struct {
-	ActiveConn        *ethclient.Client    // Active connection to a blockchain node for information
-	SuggestedGasPrice *big.Int             // Suggested gas price on the network
-	BenchConfig       *configs.BenchConfig // Benchmark configuration for workload intervals / type
-	ChainConfig       *configs.ChainConfig // Chain configuration to get number of transactions to make
-	Nonces            map[string]uint64    // Nonce of the known accounts
-	ChainID           *big.Int             // ChainID for transactions, provided through the ethereum API
-	KnownAccounts     []configs.ChainKey   // Known accounds, public:private key pair
-	CompiledContract  *compiler.Contract   // Compiled contract bytecode for the contract used in complex workloads
+	ActiveConn        *ethclient.Client              // Active connection to a blockchain node for information
+	SuggestedGasPrice *big.Int                       // Suggested gas price on the network
+	BenchConfig       *configs.BenchConfig           // Benchmark configuration for workload intervals / type
+	ChainConfig       *configs.ChainConfig           // Chain configuration to get number of transactions to make
+	Nonces            map[string]uint64              // Nonce of the known accounts
+	ChainID           *big.Int                       // ChainID for transactions, provided through the ethereum API
+	KnownAccounts     []configs.ChainKey             // Known accounds, public:private key pair
+	CompiledContract  *compiler.Contract             // Compiled contract bytecode for the contract used in complex workloads
+	contractABIs      map[*compiler.Contract]abi.ABI // ABI of the compiled contracts, to encode the calls
+	signTx            ethereumSignFunc               // Signs the transactions, nil to sign them for the chain ID
+	nonceKeys         map[string]string              // Key of the nonce of each private key, the address derived from it
+	sendTx            ethereumSendFunc               // Sends the deployment transactions, nil to send them to the active connection
+	getCode           ethereumCodeFunc               // Gets the code of the deployed contracts, nil to get it from the active connection
 	GenericWorkloadGenerator
 }
 
-const (
-	// number of bits in a big.Word
-	wordBits = 32 << (uint64(^big.Word(0)) >> 63)
-	// number of bytes in a big.Word
-	wordBytes = wordBits / 8
-)
+func init() {
+	RegisterWorkloadGenerator("ethereum", func() WorkloadGenerator { return &EthereumWorkloadGenerator{} })
+}
+
+// ethereumSignFunc signs a transaction with the private key
+type ethereumSignFunc func(tx *ethtypes.Transaction, priv *ecdsa.PrivateKey) (*ethtypes.Transaction, error)
+
+// ethereumSendFunc sends a signed transaction to the chain
+type ethereumSendFunc func(ctx context.Context, tx *ethtypes.Transaction) error
+
+// ethereumCodeFunc gets the code deployed at the address
+type ethereumCodeFunc func(ctx context.Context, address common.Address) ([]byte, error)
 
 // NewGenerator returns a new instance of the generator
 func (e *EthereumWorkloadGenerator) NewGenerator(chainConfig *configs.ChainConfig, benchConfig *configs.BenchConfig) WorkloadGenerator {
@@ -75,16 +86,19 @@ func (e *EthereumWorkloadGenerator) BlockchainSetup() error {
 // InitParams sets initial aspects such as the suggested gas price and sets up a small connection to get information from the blockchain.
 func (e *EthereumWorkloadGenerator) InitParams() error {
 
-	// Connect to the blockchain
-	c, err := ethclient.Dial(fmt.Sprintf("ws://%s", e.ChainConfig.Nodes[0]))
+	// Connect to the blockchain, unless the connection was already made
+	if e.ActiveConn == nil {
+		c, err := ethclient.Dial(fmt.Sprintf("ws://%s", e.ChainConfig.Nodes[0]))
 
-	if err != nil {
-		return err
-	}
+		if err != nil {
+			return err
+		}
 
-	e.ActiveConn = c
+		e.ActiveConn = c
+	}
 
 	// Get the suggested gas price from the network using a client connected
+	var err error
 	e.SuggestedGasPrice, err = e.ActiveConn.SuggestGasPrice(context.Background())
 
 	if err != nil {
@@ -133,20 +147,29 @@ func (e *EthereumWorkloadGenerator) CreateAccount() (interface{}, error) {
 
 // DeployContract deploys the contract and returns the address
 func (e *EthereumWorkloadGenerator) DeployContract(fromPivKey []byte, contractPath string) (string, error) {
-	tx, err := e.CreateContractDeployTX(fromPivKey, contractPath)
+	info := e.BenchConfig.ContractInfo
+	info.Path = contractPath
+
+	return e.deployContract(fromPivKey, info)
+}
+
+// deployContract deploys the contract of the configuration and returns the
+// address
+func (e *EthereumWorkloadGenerator) deployContract(fromPivKey []byte, info configs.ContractInfo) (string, error) {
+	tx, err := e.createContractDeployTX(fromPivKey, info)
 	if err != nil {
 		return "", err
 	}
 
 	// Convert back to the transaction type
-	var parsedTx types.Transaction
+	var parsedTx ethtypes.Transaction
 	err = json.Unmarshal(tx, &parsedTx)
 	if err != nil {
 		return "", err
 	}
 
 	// Deploy the transaction
-	err = e.ActiveConn.SendTransaction(context.Background(), &parsedTx)
+	err = e.send(context.Background(), &parsedTx)
 	if err != nil {
 		return "", err
 	}
@@ -170,433 +193,135 @@ func (e *EthereumWorkloadGenerator) DeployContract(fromPivKey []byte, contractPa
 	}
 }
 
-// CreateContractDeployTX creates a transaction to deploy the smart contract
-func (e *EthereumWorkloadGenerator) CreateContractDeployTX(fromPrivKey []byte, contractPath string) ([]byte, error) {
+// setupContract deploys the contract of the workload, or attaches to the
+// contract at the address of the configuration, and returns its address.
+func (e *EthereumWorkloadGenerator) setupContract(info configs.ContractInfo) (string, error) {
+	if info.Address == "" {
+		return e.deployContract(e.KnownAccounts[0].PrivateKey, info)
+	}
 
-	// Generate the relevant account information from the private key
-	priv, err := crypto.HexToECDSA(hex.EncodeToString(fromPrivKey))
+	contract, err := loadDeployedContract(info)
 	if err != nil {
-		return []byte{}, err
+		return "", err
 	}
 
-	addrFrom := crypto.PubkeyToAddress(priv.PublicKey)
-
-	// Check for the existence of the contract
-	if _, err := os.Stat(contractPath); err == nil {
-		// Path exists, compile the contract and prepare the transaction
-		// TODO: check the 'solc' string
-		contracts, err := compiler.CompileSolidity("", contractPath)
-		if err != nil {
-			return []byte{}, err
-		}
-		if len(contracts) == 0 {
-			return nil, fmt.Errorf("no contracts to compile")
-		}
-
-		// TODO handle case where number of contracts is greater than one
-		var contract *compiler.Contract
-
-		if e.BenchConfig.ContractInfo.Name != "" {
-			for k, v := range contracts {
-				s := strings.Split(k, ":")
-				if s[len(s)-1] == e.BenchConfig.ContractInfo.Name {
-					contract = v
-					break
-				}
-			}
-
-			if contract == nil {
-				zap.L().Error(fmt.Sprintf("Failed to find contract %v in %v", e.BenchConfig.ContractInfo.Name, contracts))
-				return nil, fmt.Errorf("failed to find contract in compiled")
-			}
-		} else {
-			for k, v := range contracts {
-				zap.L().Warn("Name not provided, compiling first contract",
-					zap.String("contract", k),
-				)
-				contract = v
-				break
-			}
-		}
+	// Check the contract is deployed on this chain
+	code, err := e.codeAt(context.Background(), common.HexToAddress(info.Address))
+	if err != nil {
+		return "", err
+	}
+	if len(code) == 0 {
+		return "", fmt.Errorf("no contract deployed at %s", info.Address)
+	}
 
-		zap.L().Info("Deploying Contract",
-			zap.String("contract", e.BenchConfig.ContractInfo.Name),
-			zap.String("path", e.BenchConfig.ContractInfo.Path),
-		)
+	zap.L().Info("Attaching to deployed contract",
+		zap.String("contract", info.Name),
+		zap.String("address", info.Address),
+	)
 
-		bytecodeBytes, err := hex.DecodeString(contract.Code[2:])
+	e.CompiledContract = contract
 
-		if err != nil {
-			return []byte{}, err
-		}
+	return info.Address, nil
+}
 
-		// TODO maybe estimate gas rather than have an upper bound
-		gasLimit := uint64(2000000)
-
-		zap.L().Debug("tx params",
-			zap.String("from", addrFrom.String()),
-			zap.Uint64("Nonce", e.Nonces[strings.ToLower(addrFrom.String())]),
-			zap.Uint64("gaslimit", gasLimit),
-		)
-		tx := types.NewContractCreation(
-			e.Nonces[strings.ToLower(addrFrom.String())],
-			big.NewInt(0),
-			gasLimit,
-			e.SuggestedGasPrice,
-			bytecodeBytes,
-		)
-		signedTx, err := types.SignTx(tx, types.NewEIP155Signer(e.ChainID), priv)
-
-		// Update nonce
-		e.Nonces[strings.ToLower(addrFrom.String())]++
-		e.CompiledContract = contract
-
-		return signedTx.MarshalJSON()
-
-	} else if os.IsNotExist(err) {
-		// Path doesn't exist - return an error
-		return []byte{}, fmt.Errorf("contract does not exist: %s", contractPath)
-	} else {
-		// Corner case, it's another error - so we should handle it
-		// like an error state
-		return []byte{}, err
+// codeAt gets the code deployed at the address with the code function of the
+// chain, by default from the active connection.
+func (e *EthereumWorkloadGenerator) codeAt(ctx context.Context, address common.Address) ([]byte, error) {
+	if e.getCode != nil {
+		return e.getCode(ctx, address)
 	}
 
-	return []byte{}, errors.New("failed to create deploy tx")
+	return e.ActiveConn.CodeAt(ctx, address, nil)
 }
 
-// ReadBits encodes the absolute value of bigint as big-endian bytes. Callers must ensure
-// that buf has enough space. If buf is too short the result will be incomplete.
-// This function is taken from: https://github.com/ethereum/go-ethereum/blob/master/common/math/big.go
-func readBits(bigint *big.Int, buf []byte) {
-	i := len(buf)
-	for _, d := range bigint.Bits() {
-		for j := 0; j < wordBytes && i > 0; j++ {
-			i--
-			buf[i] = byte(d)
-			d >>= 8
-		}
+// sign signs the transaction with the signing function of the chain, by
+// default for the chain ID of the network.
+func (e *EthereumWorkloadGenerator) sign(tx *ethtypes.Transaction, priv *ecdsa.PrivateKey) (*ethtypes.Transaction, error) {
+	if e.signTx != nil {
+		return e.signTx(tx, priv)
 	}
+
+	return ethtypes.SignTx(tx, ethtypes.NewEIP155Signer(e.ChainID), priv)
 }
 
-// Converts the uint256 into padded bytes
-func convertU256(i *big.Int) []byte {
-	if i.BitLen()/8 >= 32 {
-		return i.Bytes()
+// send sends the signed transaction with the send function of the chain, by
+// default to the active connection.
+func (e *EthereumWorkloadGenerator) send(ctx context.Context, tx *ethtypes.Transaction) error {
+	if e.sendTx != nil {
+		return e.sendTx(ctx, tx)
 	}
 
-	ret := make([]byte, 32)
-	readBits(i, ret)
-	return ret
+	return e.ActiveConn.SendTransaction(ctx, tx)
 }
 
-// getCallDataBytes will return the ABI encoded bytes for the variable, or an error
-// if it cannot be converted
-func (e *EthereumWorkloadGenerator) getCallDataBytes(paramType string, val string) ([]byte, error) {
-
-	payloadBytes := make([]byte, 0)
-
-	switch paramType {
-	// uints
-	case "uint8":
-		// uint 8 = 1 byte
-		// padding = 31 bytes
-		num, err := strconv.ParseUint(val, 10, 8)
-		if err != nil {
-			return nil, fmt.Errorf("failed to convert contract arg %s into %s", val, paramType)
-		}
-		padding := make([]byte, 31)
-		payloadBytes = append(payloadBytes, padding...)
-		payloadBytes = append(payloadBytes, uint8(num))
-		break
-	case "uint32":
-		// uint 32 = 4 bytes
-		// padding = 28 bytes
-		num, err := strconv.ParseUint(val, 10, 32)
-		if err != nil {
-			return nil, fmt.Errorf("failed to convert contract arg %s into %s", val, paramType)
-		}
-		padding := make([]byte, 28)
-		payloadBytes = append(payloadBytes, padding...)
-		numBytes := make([]byte, 4)
-		binary.BigEndian.PutUint32(numBytes, uint32(num))
-		payloadBytes = append(payloadBytes, numBytes...)
-		break
-	case "uint64":
-		// uint 64 = 8 bytes
-		// padding = 24 bytes
-		num, err := strconv.ParseUint(val, 10, 64)
-		if err != nil {
-			return nil, fmt.Errorf("failed to convert contract arg %s into %s", val, paramType)
-		}
-		padding := make([]byte, 24)
-		payloadBytes = append(payloadBytes, padding...)
-		numBytes := make([]byte, 8)
-		binary.BigEndian.PutUint64(numBytes, num)
-		payloadBytes = append(payloadBytes, numBytes...)
-		break
-	case "uint256", "uint":
-		// uint 256 = 64 bytes
-		//  padding = 0
-		num, ok := big.NewInt(0).SetString(val, 10)
-		if !ok {
-			return nil, fmt.Errorf("failed to convert contract arg %s into %s", val, paramType)
-		}
-		payloadBytes = append(payloadBytes, convertU256(num)...)
-		break
-	// ints
-	case "int8":
-		// int 8 = 1 byte
-		// padding = 31 bytes
-		num, err := strconv.ParseInt(val, 10, 8)
-		if err != nil {
-			return nil, fmt.Errorf("failed to convert contract arg %s into %s", val, paramType)
-		}
-		padding := make([]byte, 31)
-		payloadBytes = append(payloadBytes, padding...)
-		buf := new(bytes.Buffer)
-		err = binary.Write(buf, binary.BigEndian, int8(num))
-		if err != nil {
-			return nil, err
-		}
-		payloadBytes = append(payloadBytes, buf.Bytes()...)
-		break
-	case "int32":
-		// int 32 = 4 bytes
-		// padding = 28 bytes
-		num, err := strconv.ParseInt(val, 10, 32)
-		if err != nil {
-			return nil, fmt.Errorf("failed to convert contract arg %s into %s", val, paramType)
-		}
-		padding := make([]byte, 28)
-		payloadBytes = append(payloadBytes, padding...)
-		buf := new(bytes.Buffer)
-		err = binary.Write(buf, binary.BigEndian, int32(num))
-		if err != nil {
-			return nil, err
-		}
-		payloadBytes = append(payloadBytes, buf.Bytes()...)
-		break
-	case "int64":
-		// int 32 = 4 bytes
-		// padding = 28 bytes
-		num, err := strconv.ParseInt(val, 10, 64)
-		if err != nil {
-			return nil, fmt.Errorf("failed to convert contract arg %s into %s", val, paramType)
-		}
-		padding := make([]byte, 24)
-		payloadBytes = append(payloadBytes, padding...)
-		buf := new(bytes.Buffer)
-		err = binary.Write(buf, binary.BigEndian, num)
-		if err != nil {
-			return nil, err
-		}
-		payloadBytes = append(payloadBytes, buf.Bytes()...)
-		break
-	case "int256", "int":
-		num, ok := big.NewInt(0).SetString(val, 10)
-		if !ok {
-			return nil, fmt.Errorf("failed to convert contract arg %s into %s", val, paramType)
-		}
-		payloadBytes = append(payloadBytes, num.Bytes()...)
-		break
-	// bool
-	case "bool":
-		// Bool is just a padded uint8 of value 0 or 1
-		var bVal uint8
-		if val == "true" {
-			bVal = 1
-		} else if val == "false" {
-			bVal = 0
-		} else {
-			return nil, fmt.Errorf("failed to convert contract arg %s into %s", val, paramType)
-		}
-		padding := make([]byte, 31)
-		payloadBytes = append(payloadBytes, padding...)
-		payloadBytes = append(payloadBytes, bVal)
-		break
-	// address
-	case "address":
-		// uint160
-		// get the address
-		addr := common.HexToAddress(val)
-		// padding - address bytes should be 20bytes long.
-		padding := make([]byte, 12)
-		payloadBytes = append(payloadBytes, padding...)
-		payloadBytes = append(payloadBytes, addr.Bytes()...)
-		break
-	// bytes
-	case "bytes24":
-		// TODO this needs improvement!
-		s := []byte(val)
-		padding := make([]byte, 32-len(s))
-		payloadBytes = append(payloadBytes, s...)
-		payloadBytes = append(payloadBytes, padding...)
-		break
-	case "bytes32":
-		// TODO this needs improvement!
-		s := []byte(val)
-		payloadBytes = append(payloadBytes, s...)
-		break
-
-	// DYNAMIC TYPES ARE HARD :(
-	case "string", "bytes":
-		// todo this needs to be checked!
-		s := []byte(val)
-		// 2 get the length of the bytes
-		slen := uint32(len(s))
-		// make a uint and pad that bigendian
-		spadding := make([]byte, 28)
-		payloadBytes = append(payloadBytes, spadding...)
-		numBytes := make([]byte, 4)
-		binary.BigEndian.PutUint32(numBytes, uint32(slen))
-		payloadBytes = append(payloadBytes, numBytes...)
-		// 3 - add the padded string
-		padding := make([]byte, (32 - (len(s) % 32)))
-		payloadBytes = append(payloadBytes, s...)
-		payloadBytes = append(payloadBytes, padding...)
-		break
-	// Default
-	default:
-		return nil, fmt.Errorf("invalid arg type: %s", paramType)
-	}
+// CreateContractDeployTX creates a transaction to deploy the smart contract
+func (e *EthereumWorkloadGenerator) CreateContractDeployTX(fromPrivKey []byte, contractPath string) ([]byte, error) {
+	info := e.BenchConfig.ContractInfo
+	info.Path = contractPath
 
-	return payloadBytes, nil
+	return e.createContractDeployTX(fromPrivKey, info)
 }
 
-// CreateInteractionTX forms a transaction that invokes a smart contract
-func (e *EthereumWorkloadGenerator) CreateInteractionTX(fromPrivKey []byte, contractAddress string, functionName string, contractParams []configs.ContractParam, value string) ([]byte, error) {
-	// Check that the contract has been compiled, if nto - then it's difficult to get the hashes from the ABI.
-	if e.CompiledContract == nil {
-		return nil, fmt.Errorf("contract does not exist in known generator")
-	}
+// createContractDeployTX creates a transaction to deploy the contract of the
+// configuration, with the params of its constructor
+func (e *EthereumWorkloadGenerator) createContractDeployTX(fromPrivKey []byte, info configs.ContractInfo) ([]byte, error) {
 
-	// If there are empty params, warn - just because this isn't super common
-	if len(contractParams) < 1 {
-		// empty
-		zap.L().Warn(fmt.Sprintf("empty contract params for %s", functionName))
+	// Generate the relevant account information from the private key
+	priv, err := crypto.HexToECDSA(hex.EncodeToString(fromPrivKey))
+	if err != nil {
+		return []byte{}, err
 	}
 
-	// next - get the function hash
-	var funcHash string
+	addrFrom := crypto.PubkeyToAddress(priv.PublicKey)
 
-	// If we are targeting the fallback function, or, just sending ether - we can ignore the
-	// function name.
-	if functionName == "fallback" || functionName == "receive" || functionName == "()" {
-		funcHash = ""
-	} else {
-		val, ok := e.CompiledContract.Hashes[functionName]
-		if !ok {
-			zap.L().Debug("Failed to find function",
-				zap.String("ABI", fmt.Sprintf("%v", e.CompiledContract.Hashes)),
-				zap.String("Function", fmt.Sprintf("%v", functionName)))
-			return nil, fmt.Errorf("contract does not contain function: %s", functionName)
-		}
-		funcHash = val
-	}
-	// Now we need to parse the arguments to get them into the correct padding
-	payloadBytes, err := hex.DecodeString(funcHash)
+	// Compile the contract or read its artifacts, and prepare the transaction
+	contract, err := loadContract(info)
 	if err != nil {
-		return nil, err
+		return []byte{}, err
 	}
 
-	// Then go through and convert the params
-	// Types taken from: https://solidity.readthedocs.io/en/develop/abi-spec.html#types
-	// NOTE: need to pad to 32 bytes
+	zap.L().Info("Deploying Contract",
+		zap.String("contract", info.Name),
+		zap.String("path", info.Path),
+	)
 
-	// NOTE#2: Dynamic Types require points to show where each type begin and ends
-	// look at "abi.encode" for JS
-	isDynamic := false
-	for _, v := range contractParams {
-		if v.Type == "string" || v.Type == "bytes" {
-			isDynamic = true
-			break
-		}
+	bytecodeBytes, err := deployData(contract, info.Params)
+	if err != nil {
+		return []byte{}, fmt.Errorf("failed to encode the deployment of %s: %w", info.Path, err)
 	}
 
-	// If it's dynamic - then we need to have to space things out :\
-	// encoding = location in calldata
-	// e.g. func(string, uint)
-	//      = location_of_string, uint, stringlen, stringdata
+	// TODO maybe estimate gas rather than have an upper bound
+	gasLimit := uint64(2000000)
 
-	// e.g. 2 func(string, uint, string)
-	//      = location_of_string1, uint, location_of_string2, stringlen, stringdata, stringlen, stringdata
-	//
-	// length (pad to 32 bytes)
-	// data (pad to nearest 32 bytes)
-
-	if !isDynamic {
-		for _, v := range contractParams {
-			encB, err := e.getCallDataBytes(v.Type, v.Value)
-			if err != nil {
-				return nil, err
-			}
-			payloadBytes = append(payloadBytes, encB...)
-		}
-	} else {
-		zap.L().Debug("Contract call contains dynamic values - wizard time")
-
-		// 1 get all the encoded values
-		var nonDynArr [][]byte
-		var dynArr [][]byte
-		totalNonDynLength := 0
-		for _, v := range contractParams {
-			encB, err := e.getCallDataBytes(v.Type, v.Value)
-			if err != nil {
-				return nil, err
-			}
-			zap.L().Debug("Bytes",
-				zap.String("Type", v.Type),
-				zap.String("Val", v.Value),
-				zap.String("Bytes", fmt.Sprintf("%x", encB)),
-			)
-			// if it's dynamic - add a 32byte placeholder
-			// and append to the dynamic data
-			if v.Type == "string" || v.Type == "bytes" {
-				nonDynArr = append(nonDynArr, []byte{})
-				dynArr = append(dynArr, encB)
-				totalNonDynLength += 32
-			} else {
-				nonDynArr = append(nonDynArr, encB)
-				totalNonDynLength += len(encB)
-			}
-		}
+	zap.L().Debug("tx params",
+		zap.String("from", addrFrom.String()),
+		zap.Uint64("Nonce", e.Nonces[strings.ToLower(addrFrom.String())]),
+		zap.Uint64("gaslimit", gasLimit),
+	)
+	tx := ethtypes.NewContractCreation(
+		e.Nonces[strings.ToLower(addrFrom.String())],
+		big.NewInt(0),
+		gasLimit,
+		e.SuggestedGasPrice,
+		bytecodeBytes,
+	)
+	signedTx, err := e.sign(tx, priv)
+	if err != nil {
+		return []byte{}, err
+	}
 
-		// 2 work out positioning
-		fullBytes := make([]byte, 0)
-		allDynBytes := make([]byte, 0)
-		currentOffset := totalNonDynLength
-		dynIndex := 0
-		for _, v := range nonDynArr {
-			// if it has 0 length, it is dynamic so we work out based
-			// on the offset
-			if len(v) == 0 {
-
-				// Set the offset
-				offsetBytes := make([]byte, 28)
-				numBytes := make([]byte, 4)
-				binary.BigEndian.PutUint32(numBytes, uint32(currentOffset))
-				fullBytes = append(fullBytes, offsetBytes...)
-				fullBytes = append(fullBytes, numBytes...)
-
-				// Update the offset
-				currentOffset += len(dynArr[dynIndex])
-
-				// Append all the bytes to the end
-				allDynBytes = append(allDynBytes, dynArr[dynIndex]...)
-			} else {
-				fullBytes = append(fullBytes, v...)
-			}
-		}
+	// Update nonce
+	e.Nonces[strings.ToLower(addrFrom.String())]++
+	e.CompiledContract = contract
 
-		// 3 fill in the final parts
-		payloadBytes = append(payloadBytes, fullBytes...)
-		payloadBytes = append(payloadBytes, allDynBytes...)
-	}
+	return signedTx.MarshalJSON()
+}
 
-	// Assume that the payload bytes have been correctly formed at this point?
-	if len(payloadBytes) < 1 {
-		zap.L().Warn(fmt.Sprintf("no payload generated, sending transaction with 0 data bytes"))
+// CreateInteractionTX forms a transaction that invokes a smart contract
+func (e *EthereumWorkloadGenerator) CreateInteractionTX(fromPrivKey []byte, contractAddress string, functionName string, contractParams []configs.ContractParam, value string) ([]byte, error) {
+	payloadBytes, err := e.encodeCallData(functionName, contractParams)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create the signed transaction
@@ -618,6 +343,60 @@ func (e *EthereumWorkloadGenerator) CreateInteractionTX(fromPrivKey []byte, cont
 	return tx, nil
 }
 
+// CreateReadTX forms the call of a read function of the contract. Reads are
+// not signed, the client interface performs them with a secure read.
+func (e *EthereumWorkloadGenerator) CreateReadTX(contractAddress string, functionName string, contractParams []configs.ContractParam) ([]byte, error) {
+	payloadBytes, err := e.encodeCallData(functionName, contractParams)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&types.EthereumRead{
+		SecureRead: true,
+		To:         contractAddress,
+		Data:       "0x" + hex.EncodeToString(payloadBytes),
+	})
+}
+
+// encodeCallData encodes the function selector and the arguments of a contract
+// call with the ABI of the compiled contract. The params must match the types
+// of the function, the errors say which param is wrong.
+func (e *EthereumWorkloadGenerator) encodeCallData(functionName string, contractParams []configs.ContractParam) ([]byte, error) {
+	// Check that the contract has been compiled, if not - then there is no ABI to encode the call.
+	if e.CompiledContract == nil {
+		return nil, fmt.Errorf("contract does not exist in known generator")
+	}
+
+	// If we are targeting the fallback function, or, just sending ether - there is no call data.
+	if functionName == "fallback" || functionName == "receive" || functionName == "()" {
+		if len(contractParams) > 0 {
+			return nil, fmt.Errorf("%s function takes no params", functionName)
+		}
+		return []byte{}, nil
+	}
+
+	// The ABI is parsed once per compiled contract
+	contractABI, ok := e.contractABIs[e.CompiledContract]
+	if !ok {
+		parsed, err := parseContractABI(e.CompiledContract)
+		if err != nil {
+			return nil, err
+		}
+		if e.contractABIs == nil {
+			e.contractABIs = make(map[*compiler.Contract]abi.ABI)
+		}
+		e.contractABIs[e.CompiledContract] = parsed
+		contractABI = parsed
+	}
+
+	method, err := findMethod(contractABI, functionName, contractParams)
+	if err != nil {
+		return nil, err
+	}
+
+	return packCall(method, contractParams)
+}
+
 // CreateSignedTransaction forms a signed transaction and returns bytes to be sent by the 'SendRawTransaction' call.
 func (e *EthereumWorkloadGenerator) CreateSignedTransaction(fromPrivKey []byte, toAddress string, value *big.Int, data []byte) ([]byte, error) {
 
@@ -644,8 +423,8 @@ func (e *EthereumWorkloadGenerator) CreateSignedTransaction(fromPrivKey []byte,
 	)
 
 	// Make and sign the transaction
-	tx := types.NewTransaction(e.Nonces[strings.ToLower(addrFrom.String())], toConverted, value, gasLimit, e.SuggestedGasPrice, data)
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(e.ChainID), priv)
+	tx := ethtypes.NewTransaction(e.Nonces[strings.ToLower(addrFrom.String())], toConverted, value, gasLimit, e.SuggestedGasPrice, data)
+	signedTx, err := e.sign(tx, priv)
 	if err != nil {
 		return []byte{}, nil
 	}
@@ -657,14 +436,23 @@ func (e *EthereumWorkloadGenerator) CreateSignedTransaction(fromPrivKey []byte,
 	return signedTx.MarshalJSON()
 }
 
-// generateSimpleWorkload generates a simple transaction value transfer workload
-// returns: Workload ([secondary][threads][time][tx]) -> [][][][]byte
-func (e *EthereumWorkloadGenerator) generateSimpleWorkload() (Workload, error) {
-
-	// get the known accounts
-	var totalWorkload Workload
+// ethereumPartition is the part of the Ethereum workload generated by a
+// secondary. The private keys of the accounts are not part of it, the
+// secondary finds them in the keys of its chain configuration.
+type ethereumPartition struct {
+	Secondary         int                  `json:"secondary"`                   // ID of the secondary
+	Seed              int64                `json:"seed"`                        // Seed of the benchmark
+	FirstTx           int                  `json:"firstTx"`                     // Index of the first transaction of the secondary among all the workers
+	GasPrice          *big.Int             `json:"gasPrice"`                    // Gas price of the transactions
+	ChainID           *big.Int             `json:"chainID"`                     // Chain ID the transactions are signed for
+	Contracts         []string             `json:"contracts,omitempty"`         // Addresses of the deployed contracts
+	CompiledContracts []*compiler.Contract `json:"compiledContracts,omitempty"` // Compiled contracts, to encode the calls
+	Accounts          [][]string           `json:"accounts"`                    // Addresses of the accounts of each worker
+	Nonces            map[string]uint64    `json:"nonces"`                      // Nonces of the accounts before the first transaction of the secondary
+}
 
-	// 1. Set up the accounts into buckets for each
+// accountDistribution sets up the known accounts into buckets for each worker
+func (e *EthereumWorkloadGenerator) accountDistribution() [][]*configs.ChainKey {
 	accountDistribution := make([][]*configs.ChainKey, e.BenchConfig.Secondaries*e.BenchConfig.Threads)
 
 	accountCount := 0
@@ -682,192 +470,298 @@ func (e *EthereumWorkloadGenerator) generateSimpleWorkload() (Workload, error) {
 		accountCount++
 	}
 
-	// 2. Generate the transactions
-	txID := 0
-	accountBatch := 0
-	for secondaryID := 0; secondaryID < e.BenchConfig.Secondaries; secondaryID++ {
-		// secondaryWorkload = [thread][interval][tx=[]byte]
-		// [][][][]byte
-		secondaryWorkload := make(SecondaryWorkload, 0)
-		for thread := 0; thread < e.BenchConfig.Threads; thread++ {
-			// Thread workload = list of transactions in intervals
-			// [interval][tx] = [][][]byte
-			threadWorkload := make(WorkerThreadWorkload, 0)
-			// for each thread, generate the intervals of transactions.
-			zap.L().Debug("Info",
-				zap.Int("secondary", secondaryID),
-				zap.Int("thread", thread),
-				zap.Int("len", len(accountDistribution)))
-			accountsChoices := accountDistribution[accountBatch]
-			for interval, txnum := range e.TPSIntervals {
-				// Debug print for each interval to monitor correctness.
-				zap.L().Debug("Making workload ",
-					zap.Int("secondary", secondaryID),
-					zap.Int("thread", thread),
-					zap.Int("interval", interval),
-					zap.Int("value", txnum))
-
-				// Time interval = list of transactions
-				// [tx] = [][]byte
-				intervalWorkload := make([][]byte, 0)
-				for txIt := 0; txIt < txnum; txIt++ {
+	return accountDistribution
+}
 
-					// Initial assumption: there's as many accounts as transactions
-					// TODO allow for more intricate transaction generation, such as A->B, A->C, etc.
-					txVal, ok := big.NewInt(0).SetString("1000000", 10)
-					if !ok {
-						return nil, errors.New("failed to set TX value")
-					}
+// functionSignature returns the signature of the contract function, as found
+// in the hashes of the compiled contract: function(type,type,type)
+func functionSignature(function *configs.ContractFunction) string {
+	if len(function.Params) == 0 {
+		return function.Name
+	}
 
-					// accFrom := secondaryID + thread + (secondaryID * e.BenchConfig.Threads)
-					accFrom := accountsChoices[txID%len(accountsChoices)]
-					accTo := accountsChoices[(txID+1)%len(accountsChoices)]
+	var functionParamSigs []string
+	for _, paramVal := range function.Params {
+		functionParamSigs = append(functionParamSigs, paramVal.Type)
+	}
 
-					tx, txerr := e.CreateSignedTransaction(
-						accFrom.PrivateKey,
-						accTo.Address,
-						txVal,
-						[]byte{},
-					)
+	return fmt.Sprintf("%s(%s)", function.Name, strings.Join(functionParamSigs[:], ","))
+}
 
-					if txerr != nil {
-						return nil, txerr
-					}
+// nonceKey returns the key of the nonce of the account, the address derived
+// from its private key as used when signing.
+func (e *EthereumWorkloadGenerator) nonceKey(account *configs.ChainKey) (string, error) {
+	if key, ok := e.nonceKeys[string(account.PrivateKey)]; ok {
+		return key, nil
+	}
 
-					intervalWorkload = append(intervalWorkload, tx)
-					txID++
-				}
-				threadWorkload = append(threadWorkload, intervalWorkload)
+	priv, err := crypto.HexToECDSA(hex.EncodeToString(account.PrivateKey))
+	if err != nil {
+		return "", err
+	}
+
+	if e.nonceKeys == nil {
+		e.nonceKeys = make(map[string]string)
+	}
+	key := strings.ToLower(crypto.PubkeyToAddress(priv.PublicKey).String())
+	e.nonceKeys[string(account.PrivateKey)] = key
+
+	return key, nil
+}
+
+// partitionAccounts finds the known accounts of the workers of the partition
+func (e *EthereumWorkloadGenerator) partitionAccounts(p *ethereumPartition) ([][]*configs.ChainKey, error) {
+	known := make(map[string]*configs.ChainKey, len(e.KnownAccounts))
+	for i := range e.KnownAccounts {
+		address := strings.ToLower(e.KnownAccounts[i].Address)
+		if _, ok := known[address]; !ok {
+			known[address] = &e.KnownAccounts[i]
+		}
+	}
+
+	accounts := make([][]*configs.ChainKey, 0, len(p.Accounts))
+	for _, addresses := range p.Accounts {
+		if len(addresses) == 0 {
+			return nil, errors.New("no accounts for a worker of the partition")
+		}
+
+		workerAccounts := make([]*configs.ChainKey, 0, len(addresses))
+		for _, address := range addresses {
+			account, ok := known[strings.ToLower(address)]
+			if !ok {
+				return nil, fmt.Errorf("account %s of the partition is not in the keys of the chain configuration", address)
 			}
-			secondaryWorkload = append(secondaryWorkload, threadWorkload)
-			accountBatch++
+			workerAccounts = append(workerAccounts, account)
 		}
-		totalWorkload = append(totalWorkload, secondaryWorkload)
+		accounts = append(accounts, workerAccounts)
 	}
 
-	return totalWorkload, nil
+	return accounts, nil
 }
 
-// generateContractWorkload generates the workload for smart contract integration (or deployment)
-// NOTE: Future implementations can have a separation to test both
-// smart contract deployment and interaction in the same benchmark
-// This can simulate a very realistic blockchain trace to replay existing chains?
-func (e *EthereumWorkloadGenerator) generateContractWorkload() (Workload, error) {
-	// Get the number of transactions to be created
-	numberOfTransactions, err := parsers.GetTotalNumberOfTransactions(e.BenchConfig)
+// functionMix returns the mix of the contract functions of the workload
+func (e *EthereumWorkloadGenerator) functionMix(seed int64) (*functionMix, error) {
+	return newFunctionMix(e.BenchConfig.ContractInfos(), e.BenchConfig.TxInfo.Mix, seed, e.BenchConfig.Secondaries*e.BenchConfig.Threads, txPerWorker(e.TPSIntervals))
+}
+
+// walkPartition calls visit for each transaction of the partition, in the
+// order of the workload, with the worker, the interval and the account sending
+// it. Value transfers are sent to the account to, contract calls call the
+// function of the contract chosen by the mix, which records the calls.
+func (e *EthereumWorkloadGenerator) walkPartition(p *ethereumPartition, mix *functionMix, visit func(worker int, interval int, from *configs.ChainKey, to *configs.ChainKey, contract int, function *configs.ContractFunction) error) error {
+	accounts, err := e.partitionAccounts(p)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// Deploy the contract
-	contractAddr, err := e.DeployContract(e.KnownAccounts[0].PrivateKey, e.BenchConfig.ContractInfo.Path)
+	if len(p.Contracts) > 0 && len(p.Contracts) != len(mix.contracts) {
+		return fmt.Errorf("partition has %d contracts, the configuration %d", len(p.Contracts), len(mix.contracts))
+	}
 
-	if err != nil {
-		return nil, err
+	txID := p.FirstTx
+	for worker, accountsChoices := range accounts {
+		txCount := 0
+		var calls []int
+		if len(p.Contracts) > 0 {
+			calls = mix.workerCalls(p.Secondary*e.BenchConfig.Threads + worker)
+		}
+		for interval, txnum := range e.TPSIntervals {
+			for txIt := 0; txIt < txnum; txIt++ {
+				accFrom := accountsChoices[txID%len(accountsChoices)]
+
+				if len(p.Contracts) == 0 {
+					// Initial assumption: there's as many accounts as transactions
+					// TODO allow for more intricate transaction generation, such as A->B, A->C, etc.
+					accTo := accountsChoices[(txID+1)%len(accountsChoices)]
+					err = visit(worker, interval, accFrom, accTo, 0, nil)
+				} else {
+					contract, function := mix.call(calls[txCount])
+					err = visit(worker, interval, accFrom, nil, contract, function)
+					mix.record(calls[txCount])
+				}
+
+				if err != nil {
+					return err
+				}
+
+				txCount++
+				txID++
+			}
+		}
 	}
 
-	// List of functions to create for each thread
-	// TODO this needs some tuning!
-	// This is a list of [id] pointing to each function
-	// It will occur K times in the list, which is representative of the
-	// ratio.
-	functionsToCreatePerThread := make([]int, numberOfTransactions)
+	return nil
+}
 
-	for idx, funcInfo := range e.BenchConfig.ContractInfo.Functions {
-		// add index to functionsToCreate
-		funcRatio := (funcInfo.Ratio / 100) * numberOfTransactions
+// Partitions deploys the contract of contract workloads, and distributes the
+// accounts to the workers of each secondary. The nonces of each secondary
+// start after the transactions of the previous secondaries, they are worked
+// out without creating the transactions.
+func (e *EthereumWorkloadGenerator) Partitions() ([][]byte, error) {
+	switch e.BenchConfig.TxInfo.TxType {
+	case configs.TxTypeSimple, configs.TxTypeContract:
+	case configs.TxTypePremade:
+		return nil, ErrNotPartitioned
+	default:
+		return nil, errors.New("unknown transaction type in config for workload generation")
+	}
+
+	if len(e.KnownAccounts) == 0 {
+		return nil, errors.New("no accounts available for the workload")
+	}
 
-		for i := 0; i < funcRatio; i++ {
-			functionsToCreatePerThread = append(functionsToCreatePerThread, idx)
+	var contractAddrs []string
+	var compiledContracts []*compiler.Contract
+	if e.BenchConfig.TxInfo.TxType == configs.TxTypeContract {
+		// Deploy the contracts, or attach to them
+		for _, info := range e.BenchConfig.ContractInfos() {
+			contractAddr, err := e.setupContract(info)
+			if err != nil {
+				return nil, err
+			}
+			contractAddrs = append(contractAddrs, contractAddr)
+			compiledContracts = append(compiledContracts, e.CompiledContract)
 		}
 	}
 
-	// 1. Set up the accounts into buckets for each
-	accountDistribution := make([][]*configs.ChainKey, e.BenchConfig.Secondaries*e.BenchConfig.Threads)
+	var mix *functionMix
+	if len(contractAddrs) > 0 {
+		var err error
+		if mix, err = e.functionMix(e.BenchConfig.Seed); err != nil {
+			return nil, err
+		}
+	}
 
-	accountCount := 0
-	for {
+	accountDistribution := e.accountDistribution()
+	txPerSecondary := e.BenchConfig.Threads * txPerWorker(e.TPSIntervals)
 
-		// exit condition - each thread has an assigned account, and we've run out of accounts.
-		if accountCount >= len(e.KnownAccounts) && accountCount >= len(accountDistribution) {
-			break
+	partitions := make([][]byte, 0, e.BenchConfig.Secondaries)
+	for secondaryID := 0; secondaryID < e.BenchConfig.Secondaries; secondaryID++ {
+		p := &ethereumPartition{
+			Secondary:         secondaryID,
+			Seed:              e.BenchConfig.Seed,
+			FirstTx:           secondaryID * txPerSecondary,
+			GasPrice:          e.SuggestedGasPrice,
+			ChainID:           e.ChainID,
+			Contracts:         contractAddrs,
+			CompiledContracts: compiledContracts,
+			Nonces:            make(map[string]uint64),
+		}
+
+		for _, accountsChoices := range accountDistribution[secondaryID*e.BenchConfig.Threads : (secondaryID+1)*e.BenchConfig.Threads] {
+			addresses := make([]string, 0, len(accountsChoices))
+			for _, account := range accountsChoices {
+				key, err := e.nonceKey(account)
+				if err != nil {
+					return nil, err
+				}
+				p.Nonces[key] = e.Nonces[key]
+				addresses = append(addresses, account.Address)
+			}
+			p.Accounts = append(p.Accounts, addresses)
 		}
 
-		currentAccount := accountCount % len(e.KnownAccounts)
-		currentDist := accountCount % len(accountDistribution)
+		// The reads are not signed, the other transactions use the next nonce
+		err := e.walkPartition(p, mix, func(worker int, interval int, from *configs.ChainKey, to *configs.ChainKey, contract int, function *configs.ContractFunction) error {
+			if function != nil && function.Type == "read" {
+				return nil
+			}
 
-		accountDistribution[currentDist] = append(accountDistribution[currentDist], &e.KnownAccounts[currentAccount])
+			key, err := e.nonceKey(from)
+			if err != nil {
+				return err
+			}
+			e.Nonces[key]++
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
 
-		accountCount++
+		b, err := json.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, b)
 	}
 
-	// Shuffle the function interactions
-	// TODO check this carefully - we may have workloads with dependent transactions in future - maybe add this as a flag in config?
-	// ShuffleFunctionCalls(functionsToCreatePerThread)
+	// The nonces are worked out with the calls of every secondary
+	if mix != nil {
+		if err := mix.verify(); err != nil {
+			return nil, err
+		}
+		e.Mix = mix.report()
+	}
 
-	// Now generate the workload as usual
-	var totalWorkload Workload
-	txIndex := 0
-	accountBatch := 0
-	for secondaryID := 0; secondaryID < e.BenchConfig.Secondaries; secondaryID++ {
-		secondaryWorkload := make(SecondaryWorkload, 0)
-		for threadID := 0; threadID < e.BenchConfig.Threads; threadID++ {
-			threadWorkload := make(WorkerThreadWorkload, 0)
-			txCount := 0
-
-			accountsChoices := accountDistribution[accountBatch]
-
-			// 			for _, numTx := range e.BenchConfig.TxInfo.Intervals {
-			for _, numTx := range e.TPSIntervals {
-				intervalWorkload := make([][]byte, 0)
-
-				for i := 0; i < numTx; i++ {
-					// function to create
-					accFrom := accountsChoices[txIndex%len(accountsChoices)]
-					funcToCreate := e.BenchConfig.ContractInfo.Functions[functionsToCreatePerThread[txCount]]
-					zap.L().Debug(fmt.Sprintf("tx %d for func %s", txCount, funcToCreate.Name),
-						zap.Int("secondary", secondaryID),
-						zap.Int("thread", threadID))
-					var functionParamSigs []string
-					var functionFinal string
-					if len(funcToCreate.Params) > 0 {
-
-						for _, paramVal := range funcToCreate.Params {
-							functionParamSigs = append(functionParamSigs, paramVal.Type)
-						}
+	return partitions, nil
+}
 
-						functionFinal = fmt.Sprintf("%s(%s)", funcToCreate.Name, strings.Join(functionParamSigs[:], ","))
-					} else {
-						functionFinal = funcToCreate.Name
-					}
+// GenerateSecondaryWorkload generates and signs the value transfers or the
+// contract calls of the workers of the secondary.
+// returns: SecondaryWorkload ([threads][time][tx]) -> [][][]byte
+func (e *EthereumWorkloadGenerator) GenerateSecondaryWorkload(partition []byte) (SecondaryWorkload, error) {
+	var p ethereumPartition
+	if err := json.Unmarshal(partition, &p); err != nil {
+		return nil, fmt.Errorf("invalid ethereum partition: %w", err)
+	}
 
-					tx, txerr := e.CreateInteractionTX(
-						accFrom.PrivateKey,
-						contractAddr,
-						functionFinal,
-						funcToCreate.Params,
-						funcToCreate.PayValue,
-					)
+	// The secondaries use the keys of their chain configuration
+	if len(e.KnownAccounts) == 0 {
+		e.KnownAccounts = e.ChainConfig.Keys
+	}
 
-					if txerr != nil {
-						return nil, txerr
-					}
+	e.SuggestedGasPrice = p.GasPrice
+	e.ChainID = p.ChainID
+	if e.Nonces == nil {
+		e.Nonces = make(map[string]uint64, len(p.Nonces))
+	}
+	for key, nonce := range p.Nonces {
+		e.Nonces[key] = nonce
+	}
 
-					intervalWorkload = append(intervalWorkload, tx)
-					txCount++
-					txIndex++
-				}
+	secondaryWorkload := make(SecondaryWorkload, len(p.Accounts))
+	for worker := range secondaryWorkload {
+		secondaryWorkload[worker] = make(WorkerThreadWorkload, len(e.TPSIntervals))
+		for interval, txnum := range e.TPSIntervals {
+			secondaryWorkload[worker][interval] = make([][]byte, 0, txnum)
+		}
+	}
 
-				threadWorkload = append(threadWorkload, intervalWorkload)
-			}
-			accountBatch++
-			secondaryWorkload = append(secondaryWorkload, threadWorkload)
+	var mix *functionMix
+	if len(p.Contracts) > 0 {
+		var err error
+		if mix, err = e.functionMix(p.Seed); err != nil {
+			return nil, err
+		}
+	}
+
+	txVal := big.NewInt(1000000)
+	err := e.walkPartition(&p, mix, func(worker int, interval int, from *configs.ChainKey, to *configs.ChainKey, contract int, function *configs.ContractFunction) error {
+		var tx []byte
+		var err error
+		if function != nil {
+			e.CompiledContract = p.CompiledContracts[contract]
+		}
+		switch {
+		case function == nil:
+			tx, err = e.CreateSignedTransaction(from.PrivateKey, to.Address, txVal, []byte{})
+		case function.Type == "read":
+			tx, err = e.CreateReadTX(p.Contracts[contract], functionSignature(function), function.Params)
+		default:
+			tx, err = e.CreateInteractionTX(from.PrivateKey, p.Contracts[contract], functionSignature(function), function.Params, function.PayValue)
+		}
+		if err != nil {
+			return err
 		}
-		totalWorkload = append(totalWorkload, secondaryWorkload)
+
+		secondaryWorkload[worker][interval] = append(secondaryWorkload[worker][interval], tx)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Get workload ready
-	return totalWorkload, nil
+	return secondaryWorkload, nil
 }
 
 // generatePremadeWorkload generates the workload for the "premade" json file that
@@ -875,10 +769,10 @@ func (e *EthereumWorkloadGenerator) generateContractWorkload() (Workload, error)
 func (e *EthereumWorkloadGenerator) generatePremadeWorkload() (Workload, error) {
 	// 1 deploy the contract if it is a contract workload, get the address
 	var contractAddr string
-	if len(e.BenchConfig.ContractInfo.Path) > 0 && len(e.BenchConfig.ContractInfo.Name) > 0 {
-		// Deploy the contract
+	if (len(e.BenchConfig.ContractInfo.Path) > 0 && len(e.BenchConfig.ContractInfo.Name) > 0) || len(e.BenchConfig.ContractInfo.Address) > 0 {
+		// Deploy the contract, or attach to it
 		var err error
-		contractAddr, err = e.DeployContract(e.KnownAccounts[0].PrivateKey, e.BenchConfig.ContractInfo.Path)
+		contractAddr, err = e.setupContract(e.BenchConfig.ContractInfo)
 
 		if err != nil {
 			return nil, err
@@ -973,13 +867,17 @@ func (e *EthereumWorkloadGenerator) generatePremadeWorkload() (Workload, error)
 
 							functionFinal := fmt.Sprintf("%s(%s)", txInfo.Function, strings.Join(functionParamSigs[:], ","))
 
-							finalTx, err = e.CreateInteractionTX(
-								fromAccount.PrivateKey,
-								toAccount,
-								functionFinal,
-								txParams,
-								txInfo.Value,
-							)
+							if txInfo.TxType == "read" {
+								finalTx, err = e.CreateReadTX(toAccount, functionFinal, txParams)
+							} else {
+								finalTx, err = e.CreateInteractionTX(
+									fromAccount.PrivateKey,
+									toAccount,
+									functionFinal,
+									txParams,
+									txInfo.Value,
+								)
+							}
 						}
 
 					}
@@ -1032,14 +930,9 @@ func (e *EthereumWorkloadGenerator) GenerateWorkload() (Workload, error) {
 		zap.L().Warn("Not enough accounts, will experience fails due to sending nonce at incorrect times.")
 	}
 
-	switch e.BenchConfig.TxInfo.TxType {
-	case configs.TxTypeSimple:
-		return e.generateSimpleWorkload()
-	case configs.TxTypeContract:
-		return e.generateContractWorkload()
-	case configs.TxTypePremade:
+	if e.BenchConfig.TxInfo.TxType == configs.TxTypePremade {
 		return e.generatePremadeWorkload()
-	default:
-		return nil, errors.New("unknown transaction type in config for workload generation")
 	}
+
+	return generatePartitioned(e)
 }
@@ -0,0 +1,213 @@
+package workloadgenerators
+
+import (
+	"context"
+	"diablo-benchmark/core/configs"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testEthereumGenerator returns a generator set up as after InitParams, with
+// the chain ID, the gas price and the nonces of the keys.
+func testEthereumGenerator(chainConfig *configs.ChainConfig, benchConfig *configs.BenchConfig) *EthereumWorkloadGenerator {
+	e := (&EthereumWorkloadGenerator{}).NewGenerator(chainConfig, benchConfig).(*EthereumWorkloadGenerator)
+	_ = e.BlockchainSetup()
+	e.SuggestedGasPrice = big.NewInt(1000)
+	e.ChainID = big.NewInt(5)
+	e.Nonces = make(map[string]uint64)
+	for i, key := range e.KnownAccounts {
+		e.Nonces[strings.ToLower(key.Address)] = uint64(10 * i)
+	}
+	e.SetThreadIntervals(GetIntervalPerThread(benchConfig.TxInfo.Intervals, benchConfig.Secondaries, benchConfig.Threads))
+
+	return e
+}
+
+// checkSecondaryGeneration checks that the secondaries, with only the
+// configurations and their partition, generate the workload of the primary.
+// Each call of newPrimary returns a new generator of the primary, the one
+// generating the workload is returned with it.
+func checkSecondaryGeneration(t *testing.T, chainConfig *configs.ChainConfig, benchConfig *configs.BenchConfig, newPrimary func() *EthereumWorkloadGenerator) (Workload, *EthereumWorkloadGenerator) {
+	primary := newPrimary()
+	workload, err := primary.GenerateWorkload()
+	if err != nil {
+		t.Fatalf("failed to generate workload: %s", err.Error())
+	}
+
+	partitioned := newPrimary()
+	partitions, err := partitioned.Partitions()
+	if err != nil {
+		t.Fatalf("failed to partition workload: %s", err.Error())
+	}
+
+	if len(partitions) != len(workload) {
+		t.Fatalf("expected %d partitions, got %d", len(workload), len(partitions))
+	}
+
+	for i, partition := range partitions {
+		g := (&EthereumWorkloadGenerator{}).NewGenerator(chainConfig, benchConfig).(*EthereumWorkloadGenerator)
+		g.SetThreadIntervals(GetIntervalPerThread(benchConfig.TxInfo.Intervals, benchConfig.Secondaries, benchConfig.Threads))
+
+		secondaryWorkload, err := g.GenerateSecondaryWorkload(partition)
+		if err != nil {
+			t.Fatalf("secondary %d failed to generate its workload: %s", i, err.Error())
+		}
+
+		if !reflect.DeepEqual(secondaryWorkload, SecondaryWorkload(workload[i])) {
+			t.Errorf("workload generated by secondary %d differs from the workload of the primary", i)
+		}
+	}
+
+	if !reflect.DeepEqual(partitioned.FunctionMix(), primary.FunctionMix()) {
+		t.Errorf("expected the function mix %+v of the workload, got %+v", primary.FunctionMix(), partitioned.FunctionMix())
+	}
+
+	return workload, primary
+}
+
+func TestEthereumSecondaryGeneration(t *testing.T) {
+	// Fewer accounts than workers, the workers share the nonces of accounts
+	chainConfig := &configs.ChainConfig{Name: "ethereum"}
+	for i := 0; i < 3; i++ {
+		priv, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatalf("failed to generate key: %s", err.Error())
+		}
+		chainConfig.Keys = append(chainConfig.Keys, configs.ChainKey{
+			PrivateKey: crypto.FromECDSA(priv),
+			Address:    crypto.PubkeyToAddress(priv.PublicKey).String(),
+		})
+	}
+
+	benchConfig := &configs.BenchConfig{
+		Secondaries: 2,
+		Threads:     2,
+		Seed:        42,
+		TxInfo: configs.BenchInfo{
+			TxType:    configs.TxTypeSimple,
+			Intervals: configs.TPSIntervals{0: 8, 1: 12},
+		},
+	}
+
+	workload, _ := checkSecondaryGeneration(t, chainConfig, benchConfig, func() *EthereumWorkloadGenerator {
+		return testEthereumGenerator(chainConfig, benchConfig)
+	})
+
+	// The nonces of each account follow each other across the secondaries
+	signer := ethtypes.NewEIP155Signer(big.NewInt(5))
+	nonces := make(map[string][]uint64)
+	for _, secondaryWorkload := range workload {
+		for _, intervals := range secondaryWorkload {
+			for _, txs := range intervals {
+				for _, txBytes := range txs {
+					var tx ethtypes.Transaction
+					if err := tx.UnmarshalJSON(txBytes); err != nil {
+						t.Fatalf("failed to decode transaction: %s", err.Error())
+					}
+					from, err := ethtypes.Sender(signer, &tx)
+					if err != nil {
+						t.Fatalf("failed to recover sender: %s", err.Error())
+					}
+					nonces[strings.ToLower(from.String())] = append(nonces[strings.ToLower(from.String())], tx.Nonce())
+				}
+			}
+		}
+	}
+
+	for i, key := range chainConfig.Keys {
+		address := strings.ToLower(key.Address)
+		used := make(map[uint64]bool)
+		for _, nonce := range nonces[address] {
+			if used[nonce] {
+				t.Errorf("nonce %d of account %s used twice", nonce, hex.EncodeToString(key.PrivateKey[:4]))
+			}
+			used[nonce] = true
+		}
+		for nonce := uint64(10 * i); nonce < uint64(10*i+len(nonces[address])); nonce++ {
+			if !used[nonce] {
+				t.Errorf("nonce %d of account %d skipped", nonce, i)
+			}
+		}
+	}
+}
+
+func TestEthereumSecondaryGenerationContracts(t *testing.T) {
+	dir := writeContractFiles(t, map[string]string{
+		"Token.abi": testConstructorABI,
+		"Counter.abi": `[
+			{"type": "function", "name": "inc", "inputs": [], "outputs": []},
+			{"type": "function", "name": "get", "inputs": [], "outputs": [{"name": "", "type": "uint256"}]}
+		]`,
+	})
+	defer os.RemoveAll(dir)
+
+	chainConfig := &configs.ChainConfig{Name: "ethereum"}
+	for i := 0; i < 2; i++ {
+		priv, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatalf("failed to generate key: %s", err.Error())
+		}
+		chainConfig.Keys = append(chainConfig.Keys, configs.ChainKey{
+			PrivateKey: crypto.FromECDSA(priv),
+			Address:    crypto.PubkeyToAddress(priv.PublicKey).String(),
+		})
+	}
+
+	// The contracts are attached, the secondaries get them from their
+	// partition without any connection to the chain
+	benchConfig := &configs.BenchConfig{
+		Secondaries: 2,
+		Threads:     2,
+		Seed:        42,
+		TxInfo: configs.BenchInfo{
+			TxType:    configs.TxTypeContract,
+			Mix:       configs.FunctionMixShuffled,
+			Intervals: configs.TPSIntervals{0: 8, 1: 12},
+		},
+		Contracts: []configs.ContractInfo{
+			{
+				Ratio:   60,
+				ABI:     filepath.Join(dir, "Token.abi"),
+				Address: "0x00000000000000000000000000000000000000aa",
+				Functions: []configs.ContractFunction{
+					{Name: "set", Ratio: 100, Params: []configs.ContractParam{{Type: "uint256", Value: "1"}}},
+				},
+			},
+			{
+				Ratio:   40,
+				ABI:     filepath.Join(dir, "Counter.abi"),
+				Address: "0x00000000000000000000000000000000000000bb",
+				Functions: []configs.ContractFunction{
+					{Name: "inc", Ratio: 50},
+					{Name: "get", Type: "read", Ratio: 50},
+				},
+			},
+		},
+	}
+
+	_, primary := checkSecondaryGeneration(t, chainConfig, benchConfig, func() *EthereumWorkloadGenerator {
+		e := testEthereumGenerator(chainConfig, benchConfig)
+		e.getCode = func(ctx context.Context, a common.Address) ([]byte, error) { return []byte{0x60, 0x80}, nil }
+		return e
+	})
+
+	// All the functions are called, the reads as well as the signed calls
+	mix := primary.FunctionMix()
+	if len(mix) != 3 {
+		t.Fatalf("expected the mix of the 3 functions, got %+v", mix)
+	}
+	for _, m := range mix {
+		if m.Generated == 0 || m.Generated != m.Expected {
+			t.Errorf("expected the calls of %s.%s to follow the mix, got %+v", m.Contract, m.Function, m)
+		}
+	}
+}
@@ -21,6 +21,10 @@ type FabricWorkloadGenerator struct {
 	GenericWorkloadGenerator
 }
 
+func init() {
+	RegisterWorkloadGenerator("fabric", func() WorkloadGenerator { return &FabricWorkloadGenerator{} })
+}
+
 //NewGenerator returns a new instance of the generator
 func (f FabricWorkloadGenerator) NewGenerator(chainConfig *configs.ChainConfig, benchConfig *configs.BenchConfig) WorkloadGenerator {
 	return &FabricWorkloadGenerator{
@@ -65,8 +69,15 @@ func (f FabricWorkloadGenerator) CreateContractDeployTX(fromPrivKey []byte, cont
 
 //CreateInteractionTX main method to create transaction bytes for the workload
 func (f FabricWorkloadGenerator) CreateInteractionTX(fromPrivKey []byte, functionType string, functionName string, contractParams []configs.ContractParam, value string) ([]byte, error) {
+	return f.createChaincodeTX("", functionType, functionName, contractParams)
+}
+
+//createChaincodeTX creates the transaction invoking the function of the chaincode,
+// the chaincode of the chain configuration if it is empty
+func (f FabricWorkloadGenerator) createChaincodeTX(chaincode string, functionType string, functionName string, contractParams []configs.ContractParam) ([]byte, error) {
 
 	var tx types.FabricTX
+	tx.Contract = chaincode
 	tx.FunctionType = functionType // "read" or "write" to indicate query or submit
 	tx.FunctionName = functionName
 
@@ -100,11 +111,19 @@ func (f FabricWorkloadGenerator) CreateSignedTransaction(fromPrivKey []byte, toA
 }
 
 //generateTestWorkload generates a test workload given the test benchmark config and the blockchain config files
+// The functions of the chaincodes are called in proportion to their ratio, following the mix of the benchmark.
 // returns: Workload ([secondary][threads][time][tx]) -> [][][][]byte
-func (f FabricWorkloadGenerator) generateTestWorkload() (Workload, error) {
+func (f *FabricWorkloadGenerator) generateTestWorkload() (Workload, error) {
 
 	var totalWorkload Workload
 
+	// The chaincode and the function of each transaction of the threads
+	contracts := f.BenchConfig.ContractInfos()
+	mix, err := newFunctionMix(contracts, f.BenchConfig.TxInfo.Mix, f.BenchConfig.Seed, f.BenchConfig.Secondaries*f.BenchConfig.Threads, txPerWorker(f.TPSIntervals))
+	if err != nil {
+		return nil, err
+	}
+
 	// 1. Generate the transactions
 	txID := uint64(0)
 	accountBatch := 0
@@ -120,6 +139,8 @@ func (f FabricWorkloadGenerator) generateTestWorkload() (Workload, error) {
 			zap.L().Debug("Info",
 				zap.Int("secondary", secondaryID),
 				zap.Int("thread", thread))
+			txCount := 0
+			calls := mix.workerCalls(secondaryID*f.BenchConfig.Threads + thread)
 			for interval, txnum := range f.TPSIntervals {
 				// Debug print for each interval to monitor correctness.
 				zap.L().Debug("Making workload ",
@@ -142,27 +163,30 @@ func (f FabricWorkloadGenerator) generateTestWorkload() (Workload, error) {
 						Value: id,
 					})
 
-					//function "CreateAsset" and its arguments
-					functionToInvoke := f.BenchConfig.ContractInfo.Functions[0]
+					//function of the chaincode called by this transaction, e.g. "CreateAsset" and its arguments
+					c, functionToInvoke := mix.call(calls[txCount])
+					mix.record(calls[txCount])
 
 					// transactions are of the form  (assetID, color, size, owner, price)
-					otherParams := functionToInvoke.Params
+					otherParams := append([]configs.ContractParam{}, functionToInvoke.Params...)
 
 					// modifying assetID to get a unique transaction
-					otherParams[0].Value = strconv.FormatUint(txID, 10)
+					if len(otherParams) > 0 {
+						otherParams[0].Value = strconv.FormatUint(txID, 10)
+					}
 					params = append(params, otherParams...)
 
-					functionType := f.BenchConfig.ContractInfo.Functions[0].Type //function type gives us whether it a submit or read type transaction
+					functionType := functionToInvoke.Type //function type gives us whether it a submit or read type transaction
 					functionName := functionToInvoke.Name
 
-					// The nil parameter is the key, which is not useful in Fabric
-					tx, txerr := f.CreateInteractionTX(nil, functionType, functionName, params, "")
+					tx, txerr := f.createChaincodeTX(contracts[c].Name, functionType, functionName, params)
 
 					if txerr != nil {
 						return nil, txerr
 					}
 
 					intervalWorkload = append(intervalWorkload, tx)
+					txCount++
 					txID++
 				}
 				threadWorkload = append(threadWorkload, intervalWorkload)
@@ -173,6 +197,11 @@ func (f FabricWorkloadGenerator) generateTestWorkload() (Workload, error) {
 		totalWorkload = append(totalWorkload, secondaryWorkload)
 	}
 
+	if err := mix.verify(); err != nil {
+		return nil, err
+	}
+	f.Mix = mix.report()
+
 	return totalWorkload, nil
 
 }
@@ -489,7 +518,7 @@ func (f FabricWorkloadGenerator) generateContentionWorkload() (Workload, error)
 
 //GenerateWorkload generates a workload given the benchmark config and the blockchain config files
 // returns: Workload ([secondary][threads][time][tx]) -> [][][][]byte
-func (f FabricWorkloadGenerator) GenerateWorkload() (Workload, error) {
+func (f *FabricWorkloadGenerator) GenerateWorkload() (Workload, error) {
 
 	// 1/ work out the total number of secondaries.
 	numberOfWorkers := f.BenchConfig.Secondaries * f.BenchConfig.Threads
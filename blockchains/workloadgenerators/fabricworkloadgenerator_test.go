@@ -0,0 +1,91 @@
+package workloadgenerators
+
+import (
+	"diablo-benchmark/blockchains/types"
+	"diablo-benchmark/core/configs"
+	"encoding/json"
+	"testing"
+)
+
+func TestFabricMultipleChaincodes(t *testing.T) {
+	chainConfig := &configs.ChainConfig{Name: "fabric"}
+	benchConfig := &configs.BenchConfig{
+		Secondaries: 1,
+		Threads:     2,
+		TxInfo: configs.BenchInfo{
+			TxType:    configs.TxTypeTest,
+			Intervals: configs.TPSIntervals{0: 20, 1: 20},
+		},
+		Contracts: []configs.ContractInfo{
+			{
+				Name:  "basic",
+				Ratio: 60,
+				Functions: []configs.ContractFunction{
+					{Name: "CreateAsset", Type: "write", Ratio: 50, Params: []configs.ContractParam{{Type: "string", Value: "asset"}, {Type: "color", Value: "blue"}}},
+					{Name: "ReadAsset", Type: "read", Ratio: 50, Params: []configs.ContractParam{{Type: "string", Value: "asset"}}},
+				},
+			},
+			{
+				Name:  "token",
+				Ratio: 40,
+				Functions: []configs.ContractFunction{
+					{Name: "Transfer", Type: "write", Ratio: 100, Params: []configs.ContractParam{{Type: "string", Value: "to"}}},
+				},
+			},
+		},
+	}
+
+	f := (&FabricWorkloadGenerator{}).NewGenerator(chainConfig, benchConfig).(*FabricWorkloadGenerator)
+	f.SetThreadIntervals(GetIntervalPerThread(benchConfig.TxInfo.Intervals, benchConfig.Secondaries, benchConfig.Threads))
+
+	workload, err := f.GenerateWorkload()
+	if err != nil {
+		t.Fatalf("failed to generate workload: %s", err.Error())
+	}
+
+	for thread, intervals := range workload[0] {
+		calls := make(map[string]int)
+		previous := ""
+		run := 0
+		for _, txs := range intervals {
+			for _, txBytes := range txs {
+				var tx types.FabricTX
+				if err := json.Unmarshal(txBytes, &tx); err != nil {
+					t.Fatalf("failed to decode transaction: %s", err.Error())
+				}
+				calls[tx.Contract+"."+tx.FunctionName]++
+
+				// The chaincodes are interleaved
+				if tx.Contract == previous {
+					run++
+				} else {
+					previous, run = tx.Contract, 1
+				}
+				if run > 2 {
+					t.Errorf("thread %d calls %s %d times in a row", thread, tx.Contract, run)
+				}
+			}
+		}
+
+		total := txPerWorker(f.TPSIntervals)
+		expected := map[string]int{
+			"basic.CreateAsset": total * 30 / 100,
+			"basic.ReadAsset":   total * 30 / 100,
+			"token.Transfer":    total * 40 / 100,
+		}
+		for call, n := range expected {
+			if calls[call] != n {
+				t.Errorf("thread %d: expected %d calls of %s, got %d", thread, n, call, calls[call])
+			}
+		}
+	}
+
+	for _, m := range f.FunctionMix() {
+		if m.Generated != m.Expected || m.Generated == 0 {
+			t.Errorf("expected the reported calls of %s.%s to match the mix, got %+v", m.Contract, m.Function, m)
+		}
+	}
+	if len(f.FunctionMix()) != 3 {
+		t.Errorf("expected the mix of the 3 functions, got %+v", f.FunctionMix())
+	}
+}
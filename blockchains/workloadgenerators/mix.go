@@ -0,0 +1,237 @@
+package workloadgenerators
+
+import (
+	"diablo-benchmark/core/configs"
+	"diablo-benchmark/core/results"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+// functionCall is a function of one of the contracts of the workload
+type functionCall struct {
+	Contract int // Index of the contract in the configuration
+	Function int // Index of the function in the functions of the contract
+}
+
+// functionMix decides the function called by each transaction of each worker.
+// The share of a function is the ratio of its contract times its ratio in the
+// contract. Unless the functions are picked at random, each function is
+// called exactly its share of all the transactions of all the secondaries,
+// and each worker calls it its share of the worker's transactions, give or
+// take one call.
+type functionMix struct {
+	contracts   []configs.ContractInfo // Contracts of the workload
+	calls       []functionCall         // Functions of all the contracts
+	shares      []float64              // Share of the transactions of each call, they sum to 1
+	expected    []int                  // Number of transactions of each call over all the workers
+	generated   []int                  // Number of transactions of each call generated, to verify the mix
+	mode        configs.FunctionMix    // How the calls of each worker are ordered
+	seed        int64                  // Seed of the benchmark
+	workers     int                    // Number of workers of all the secondaries
+	txPerWorker int                    // Number of transactions of each worker
+}
+
+// newFunctionMix returns the mix of the functions of the contracts for the
+// workers of all the secondaries. Ratios that are all zero give equal shares.
+func newFunctionMix(contracts []configs.ContractInfo, mode configs.FunctionMix, seed int64, workers int, txPerWorker int) (*functionMix, error) {
+	if mode == "" {
+		mode = configs.FunctionMixInterleaved
+	}
+
+	contractWeights := make([]int, 0, len(contracts))
+	for _, contract := range contracts {
+		contractWeights = append(contractWeights, contract.Ratio)
+	}
+	contractShares := normalizeRatios(contractWeights)
+
+	m := &functionMix{
+		contracts:   contracts,
+		mode:        mode,
+		seed:        seed,
+		workers:     workers,
+		txPerWorker: txPerWorker,
+	}
+	for c, contract := range contracts {
+		if len(contract.Functions) == 0 {
+			return nil, fmt.Errorf("no functions for contract %q", contract.Label())
+		}
+
+		functionWeights := make([]int, 0, len(contract.Functions))
+		for _, function := range contract.Functions {
+			functionWeights = append(functionWeights, function.Ratio)
+		}
+		for f, share := range normalizeRatios(functionWeights) {
+			m.calls = append(m.calls, functionCall{Contract: c, Function: f})
+			m.shares = append(m.shares, contractShares[c]*share)
+		}
+	}
+
+	m.expected = largestRemainder(m.shares, workers*txPerWorker)
+	m.generated = make([]int, len(m.calls))
+
+	return m, nil
+}
+
+// normalizeRatios returns the share of each ratio in their sum, the shares
+// are equal if all the ratios are zero.
+func normalizeRatios(ratios []int) []float64 {
+	total := 0
+	for _, ratio := range ratios {
+		total += ratio
+	}
+
+	shares := make([]float64, len(ratios))
+	for i, ratio := range ratios {
+		if total == 0 {
+			shares[i] = 1 / float64(len(ratios))
+		} else {
+			shares[i] = float64(ratio) / float64(total)
+		}
+	}
+
+	return shares
+}
+
+// largestRemainder splits n between the shares: each gets the integer part of
+// its share of n, the rest goes to the largest fractional parts.
+func largestRemainder(shares []float64, n int) []int {
+	counts := make([]int, len(shares))
+	remainders := make([]float64, len(shares))
+	left := n
+	for i, share := range shares {
+		exact := share * float64(n)
+		counts[i] = int(math.Floor(exact))
+		remainders[i] = exact - float64(counts[i])
+		left -= counts[i]
+	}
+
+	order := make([]int, len(shares))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for i := 0; i < left && len(order) > 0; i++ {
+		counts[order[i%len(order)]]++
+	}
+
+	return counts
+}
+
+// call returns the contract and the function of the call
+func (m *functionMix) call(call int) (int, *configs.ContractFunction) {
+	c := m.calls[call]
+	return c.Contract, &m.contracts[c.Contract].Functions[c.Function]
+}
+
+// workerCounts returns the number of transactions of each call for the worker.
+// The transactions of all the workers are dealt in turn to each worker, the
+// transactions of the first call first.
+func (m *functionMix) workerCounts(worker int) []int {
+	// Number of the first n transactions dealt to the worker
+	dealt := func(n int) int {
+		return (n + m.workers - 1 - worker) / m.workers
+	}
+
+	counts := make([]int, len(m.expected))
+	start := 0
+	for i, expected := range m.expected {
+		counts[i] = dealt(start+expected) - dealt(start)
+		start += expected
+	}
+
+	return counts
+}
+
+// workerCalls returns the call of each transaction of the worker, the index of
+// the worker among the workers of all the secondaries.
+func (m *functionMix) workerCalls(worker int) []int {
+	switch m.mode {
+	case configs.FunctionMixRandom:
+		randomness := rand.New(rand.NewSource(m.seed + int64(worker)))
+		calls := make([]int, 0, m.txPerWorker)
+		for len(calls) < m.txPerWorker {
+			calls = append(calls, m.randomCall(randomness))
+		}
+		return calls
+	case configs.FunctionMixShuffled:
+		calls := interleave(m.workerCounts(worker), m.txPerWorker)
+		ShuffleFunctionCalls(calls, m.seed+int64(worker))
+		return calls
+	default:
+		return interleave(m.workerCounts(worker), m.txPerWorker)
+	}
+}
+
+// randomCall picks a call in proportion to the shares
+func (m *functionMix) randomCall(randomness *rand.Rand) int {
+	r := randomness.Float64()
+	for i, share := range m.shares {
+		if r < share {
+			return i
+		}
+		r -= share
+	}
+
+	return len(m.shares) - 1
+}
+
+// record counts a generated transaction of the call
+func (m *functionMix) record(call int) {
+	m.generated[call]++
+}
+
+// verify checks that the generated transactions follow the mix. Each call
+// must have exactly its number of transactions, random picks only warn when
+// they stray from the shares.
+func (m *functionMix) verify() error {
+	total := 0
+	for _, generated := range m.generated {
+		total += generated
+	}
+
+	for i, generated := range m.generated {
+		contract, function := m.call(i)
+		name := m.contracts[contract].Label() + "." + function.Name
+		if m.mode != configs.FunctionMixRandom {
+			if generated != m.expected[i] {
+				return fmt.Errorf("function mix: %d calls of %s, expected %d", generated, name, m.expected[i])
+			}
+			continue
+		}
+
+		// Four standard deviations of the binomial distribution
+		mean := m.shares[i] * float64(total)
+		tolerance := 4*math.Sqrt(mean*(1-m.shares[i])) + 1
+		if math.Abs(float64(generated)-mean) > tolerance {
+			zap.L().Warn("random function mix strays from the ratios",
+				zap.String("function", name),
+				zap.Int("generated", generated),
+				zap.Float64("expected", mean))
+		}
+	}
+
+	return nil
+}
+
+// report returns the mix of the functions for the results
+func (m *functionMix) report() []results.FunctionMix {
+	report := make([]results.FunctionMix, 0, len(m.calls))
+	for i := range m.calls {
+		contract, function := m.call(i)
+		report = append(report, results.FunctionMix{
+			Contract:  m.contracts[contract].Label(),
+			Function:  function.Name,
+			Ratio:     m.shares[i] * 100,
+			Expected:  uint(m.expected[i]),
+			Generated: uint(m.generated[i]),
+		})
+	}
+
+	return report
+}
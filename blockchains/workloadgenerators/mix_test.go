@@ -0,0 +1,159 @@
+package workloadgenerators
+
+import (
+	"diablo-benchmark/core/configs"
+	"reflect"
+	"testing"
+)
+
+// testContracts are two contracts whose functions get 20%, 20%, 27% and 33%
+// of the transactions
+var testContracts = []configs.ContractInfo{
+	{
+		Name:  "token",
+		Ratio: 40,
+		Functions: []configs.ContractFunction{
+			{Name: "transfer", Ratio: 50},
+			{Name: "balanceOf", Ratio: 50},
+		},
+	},
+	{
+		Name:  "store",
+		Ratio: 60,
+		Functions: []configs.ContractFunction{
+			{Name: "get", Ratio: 45},
+			{Name: "set", Ratio: 55},
+		},
+	},
+}
+
+func TestFunctionMixCounts(t *testing.T) {
+	// 3 secondaries of 2 threads, the ratios below 100 used to give no calls
+	contracts := []configs.ContractInfo{{
+		Name: "counter",
+		Functions: []configs.ContractFunction{
+			{Name: "inc", Ratio: 33},
+			{Name: "dec", Ratio: 33},
+			{Name: "get", Ratio: 34},
+		},
+	}}
+
+	for _, mode := range []configs.FunctionMix{configs.FunctionMixInterleaved, configs.FunctionMixShuffled} {
+		mix, err := newFunctionMix(contracts, mode, 42, 6, 17)
+		if err != nil {
+			t.Fatalf("failed to create the mix: %s", err.Error())
+		}
+
+		if !reflect.DeepEqual(mix.expected, []int{34, 33, 35}) {
+			t.Errorf("expected the calls [34 33 35] out of 102, got %v", mix.expected)
+		}
+
+		for worker := 0; worker < 6; worker++ {
+			calls := mix.workerCalls(worker)
+			if len(calls) != 17 {
+				t.Fatalf("expected 17 calls for worker %d, got %d", worker, len(calls))
+			}
+
+			counts := make([]int, len(mix.calls))
+			for _, call := range calls {
+				counts[call]++
+				mix.record(call)
+			}
+			for call, n := range counts {
+				if n < 5 || n > 6 {
+					t.Errorf("%s worker %d: expected 5 or 6 calls of %d, got %d", mode, worker, call, n)
+				}
+			}
+			if !reflect.DeepEqual(counts, mix.workerCounts(worker)) {
+				t.Errorf("%s worker %d: expected the calls %v, got %v", mode, worker, mix.workerCounts(worker), counts)
+			}
+		}
+
+		if err := mix.verify(); err != nil {
+			t.Errorf("%s: %s", mode, err.Error())
+		}
+	}
+}
+
+func TestFunctionMixContracts(t *testing.T) {
+	mix, err := newFunctionMix(testContracts, "", 0, 4, 25)
+	if err != nil {
+		t.Fatalf("failed to create the mix: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(mix.expected, []int{20, 20, 27, 33}) {
+		t.Errorf("expected the calls [20 20 27 33], got %v", mix.expected)
+	}
+
+	for worker := 0; worker < 4; worker++ {
+		for _, call := range mix.workerCalls(worker) {
+			mix.record(call)
+		}
+	}
+	if err := mix.verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	report := mix.report()
+	if len(report) != 4 || report[3].Contract != "store" || report[3].Function != "set" || report[3].Generated != 33 {
+		t.Errorf("expected the report of the 4 functions, got %+v", report)
+	}
+
+	// A mix that is not followed fails to verify
+	mix.record(0)
+	if err := mix.verify(); err == nil {
+		t.Errorf("expected an extra call to fail the verification")
+	}
+
+	if _, err := newFunctionMix([]configs.ContractInfo{{Name: "empty"}}, "", 0, 1, 1); err == nil {
+		t.Errorf("expected a contract without functions to fail")
+	}
+
+	// A contract only given by its path is named by it
+	mix, err = newFunctionMix([]configs.ContractInfo{{Path: "store.sol", Functions: []configs.ContractFunction{{Name: "get"}}}}, "", 0, 1, 1)
+	if err != nil {
+		t.Fatalf("failed to create the mix: %s", err.Error())
+	}
+	if report := mix.report(); report[0].Contract != "store.sol" {
+		t.Errorf("expected the contract to be named by its path, got %+v", report[0])
+	}
+}
+
+func TestFunctionMixSeed(t *testing.T) {
+	for _, mode := range []configs.FunctionMix{configs.FunctionMixShuffled, configs.FunctionMixRandom} {
+		mix, err := newFunctionMix(testContracts, mode, 7, 2, 200)
+		if err != nil {
+			t.Fatalf("failed to create the mix: %s", err.Error())
+		}
+		other, err := newFunctionMix(testContracts, mode, 8, 2, 200)
+		if err != nil {
+			t.Fatalf("failed to create the mix: %s", err.Error())
+		}
+
+		if !reflect.DeepEqual(mix.workerCalls(1), mix.workerCalls(1)) {
+			t.Errorf("%s: expected the same calls with the same seed", mode)
+		}
+		if reflect.DeepEqual(mix.workerCalls(1), other.workerCalls(1)) {
+			t.Errorf("%s: expected other calls with another seed", mode)
+		}
+		if reflect.DeepEqual(mix.workerCalls(0), mix.workerCalls(1)) {
+			t.Errorf("%s: expected the workers to call in another order", mode)
+		}
+
+		for worker := 0; worker < 2; worker++ {
+			for _, call := range mix.workerCalls(worker) {
+				mix.record(call)
+			}
+		}
+		if err := mix.verify(); err != nil {
+			t.Errorf("%s: %s", mode, err.Error())
+		}
+
+		// Random picks follow the shares within a few standard deviations
+		for call, generated := range mix.generated {
+			if expected := mix.expected[call]; generated < expected-30 || generated > expected+30 {
+				t.Errorf("%s: expected about %d calls of %d, got %d", mode, expected, call, generated)
+			}
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package workloadgenerators
+
+import (
+	"crypto/rand"
+	"diablo-benchmark/blockchains/types"
+	"diablo-benchmark/core/configs"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// MockWorkloadGenerator is the workload generator implementation for the
+// in-memory mock blockchain. It creates simple JSON transactions that the mock
+// client interface submits to its simulated ledger.
+type MockWorkloadGenerator struct {
+	BenchConfig   *configs.BenchConfig // Benchmark configuration for workload intervals / type
+	ChainConfig   *configs.ChainConfig // Chain configuration to get number of transactions to make
+	KnownAccounts []string             // Addresses of the accounts used in the workload
+	nextTxID      uint64               // ID given to the next created transaction
+	GenericWorkloadGenerator
+}
+
+func init() {
+	RegisterWorkloadGenerator("mock", func() WorkloadGenerator { return &MockWorkloadGenerator{} })
+}
+
+// NewGenerator returns a new instance of the generator
+func (m *MockWorkloadGenerator) NewGenerator(chainConfig *configs.ChainConfig, benchConfig *configs.BenchConfig) WorkloadGenerator {
+	return &MockWorkloadGenerator{BenchConfig: benchConfig, ChainConfig: chainConfig}
+}
+
+// BlockchainSetup uses the keys of the chain configuration as accounts, or
+// creates one account per worker if none are provided. The simulated ledger
+// itself is started by the client interfaces.
+func (m *MockWorkloadGenerator) BlockchainSetup() error {
+	if len(m.ChainConfig.Keys) > 0 {
+		for _, k := range m.ChainConfig.Keys {
+			m.KnownAccounts = append(m.KnownAccounts, k.Address)
+		}
+		return nil
+	}
+
+	for i := 0; i < m.BenchConfig.Secondaries*m.BenchConfig.Threads; i++ {
+		m.KnownAccounts = append(m.KnownAccounts, fmt.Sprintf("0x%040x", i+1))
+	}
+
+	return nil
+}
+
+// InitParams has nothing to initialise for the mock chain
+func (m *MockWorkloadGenerator) InitParams() error {
+	return nil
+}
+
+// CreateAccount creates a random account address
+func (m *MockWorkloadGenerator) CreateAccount() (interface{}, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	return "0x" + hex.EncodeToString(b), nil
+}
+
+// DeployContract returns the address of the contract. The mock chain does
+// not execute contracts, so the deployment is not sent anywhere. Accounts of
+// the mock chain are plain addresses, they are passed in place of the keys.
+func (m *MockWorkloadGenerator) DeployContract(fromPrivKey []byte, contractPath string) (string, error) {
+	id := m.nextTxID
+	if _, err := m.CreateContractDeployTX(fromPrivKey, contractPath); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("0x%040x", id), nil
+}
+
+// CreateContractDeployTX creates the transaction deploying the contract
+func (m *MockWorkloadGenerator) CreateContractDeployTX(fromPrivKey []byte, contractPath string) ([]byte, error) {
+	return m.marshalTx(types.MockTX{
+		From:         string(fromPrivKey),
+		FunctionName: "constructor",
+		FunctionType: "write",
+		Args:         []string{contractPath},
+	})
+}
+
+// CreateInteractionTX creates a transaction calling the function of the contract
+func (m *MockWorkloadGenerator) CreateInteractionTX(fromPrivKey []byte, contractAddress string, functionName string, contractParams []configs.ContractParam, value string) ([]byte, error) {
+	args := make([]string, 0, len(contractParams))
+	for _, p := range contractParams {
+		args = append(args, p.Value)
+	}
+
+	functionType := "write"
+	for _, f := range m.BenchConfig.ContractInfo.Functions {
+		if f.Name == functionName && f.Type != "" {
+			functionType = f.Type
+			break
+		}
+	}
+
+	return m.marshalTx(types.MockTX{
+		From:         string(fromPrivKey),
+		To:           contractAddress,
+		Value:        value,
+		FunctionName: functionName,
+		FunctionType: functionType,
+		Args:         args,
+	})
+}
+
+// CreateSignedTransaction creates a value transfer, "signing" is not simulated
+func (m *MockWorkloadGenerator) CreateSignedTransaction(fromPrivKey []byte, toAddress string, value *big.Int, data []byte) ([]byte, error) {
+	v := "0"
+	if value != nil {
+		v = value.String()
+	}
+
+	return m.marshalTx(types.MockTX{
+		From:  string(fromPrivKey),
+		To:    toAddress,
+		Value: v,
+	})
+}
+
+// marshalTx assigns the next ID to the transaction and encodes it
+func (m *MockWorkloadGenerator) marshalTx(tx types.MockTX) ([]byte, error) {
+	tx.ID = m.nextTxID
+	m.nextTxID++
+
+	return json.Marshal(&tx)
+}
+
+// functionMix returns the mix of the functions of the contract
+func (m *MockWorkloadGenerator) functionMix(seed int64) (*functionMix, error) {
+	return newFunctionMix([]configs.ContractInfo{m.BenchConfig.ContractInfo}, m.BenchConfig.TxInfo.Mix, seed, m.BenchConfig.Secondaries*m.BenchConfig.Threads, txPerWorker(m.TPSIntervals))
+}
+
+// mockPartition is the part of the mock workload generated by a secondary
+type mockPartition struct {
+	Secondary int      `json:"secondary"`          // ID of the secondary
+	Seed      int64    `json:"seed"`               // Seed of the benchmark
+	FirstTxID uint64   `json:"firstTxID"`          // ID of the first transaction of the secondary
+	Accounts  []string `json:"accounts"`           // Addresses of the accounts used in the workload
+	Contract  string   `json:"contract,omitempty"` // Address of the contract called
+}
+
+// Partitions deploys the contract if needed and gives each secondary the IDs
+// of its transactions, which follow the ones of the previous secondaries.
+func (m *MockWorkloadGenerator) Partitions() ([][]byte, error) {
+	if len(m.KnownAccounts) == 0 {
+		return nil, errors.New("no accounts available for the mock workload")
+	}
+
+	var contractAddr string
+	switch m.BenchConfig.TxInfo.TxType {
+	case configs.TxTypeSimple:
+	case configs.TxTypeContract, configs.TxTypeTest:
+		if len(m.BenchConfig.ContractInfo.Functions) == 0 {
+			return nil, errors.New("no contract functions defined for the mock workload")
+		}
+
+		// A deployed contract is called at its address
+		if contractAddr = m.BenchConfig.ContractInfo.Address; contractAddr != "" {
+			break
+		}
+
+		var err error
+		contractAddr, err = m.DeployContract([]byte(m.KnownAccounts[0]), m.BenchConfig.ContractInfo.Path)
+		if err != nil {
+			return nil, err
+		}
+	case configs.TxTypePremade:
+		return nil, ErrNotPartitioned
+	default:
+		return nil, errors.New("unknown transaction type in config for workload generation")
+	}
+
+	// The secondaries call the functions of the same mix
+	if contractAddr != "" {
+		mix, err := m.functionMix(m.BenchConfig.Seed)
+		if err != nil {
+			return nil, err
+		}
+		for worker := 0; worker < mix.workers; worker++ {
+			for _, call := range mix.workerCalls(worker) {
+				mix.record(call)
+			}
+		}
+		if err := mix.verify(); err != nil {
+			return nil, err
+		}
+		m.Mix = mix.report()
+	}
+
+	txPerSecondary := uint64(m.BenchConfig.Threads * txPerWorker(m.TPSIntervals))
+
+	partitions := make([][]byte, 0, m.BenchConfig.Secondaries)
+	for secondaryID := 0; secondaryID < m.BenchConfig.Secondaries; secondaryID++ {
+		b, err := json.Marshal(&mockPartition{
+			Secondary: secondaryID,
+			Seed:      m.BenchConfig.Seed,
+			FirstTxID: m.nextTxID + uint64(secondaryID)*txPerSecondary,
+			Accounts:  m.KnownAccounts,
+			Contract:  contractAddr,
+		})
+		if err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, b)
+	}
+
+	m.nextTxID += uint64(m.BenchConfig.Secondaries) * txPerSecondary
+
+	return partitions, nil
+}
+
+// GenerateSecondaryWorkload generates the value transfers or the contract
+// calls of the workers of the secondary.
+func (m *MockWorkloadGenerator) GenerateSecondaryWorkload(partition []byte) (SecondaryWorkload, error) {
+	var p mockPartition
+	if err := json.Unmarshal(partition, &p); err != nil {
+		return nil, fmt.Errorf("invalid mock partition: %w", err)
+	}
+
+	if len(p.Accounts) == 0 {
+		return nil, errors.New("no accounts available for the mock workload")
+	}
+
+	m.KnownAccounts = p.Accounts
+	m.nextTxID = p.FirstTxID
+
+	if p.Contract == "" {
+		return m.generateSecondaryWorkload(p.Secondary, func(worker int, txIndex int) ([]byte, error) {
+			from := m.KnownAccounts[worker%len(m.KnownAccounts)]
+			to := m.KnownAccounts[(worker+1)%len(m.KnownAccounts)]
+			return m.CreateSignedTransaction([]byte(from), to, big.NewInt(1), nil)
+		})
+	}
+
+	mix, err := m.functionMix(p.Seed)
+	if err != nil {
+		return nil, err
+	}
+	calls := make(map[int][]int)
+	return m.generateSecondaryWorkload(p.Secondary, func(worker int, txIndex int) ([]byte, error) {
+		if _, ok := calls[worker]; !ok {
+			calls[worker] = mix.workerCalls(worker)
+		}
+		_, f := mix.call(calls[worker][txIndex])
+		from := m.KnownAccounts[worker%len(m.KnownAccounts)]
+		return m.CreateInteractionTX([]byte(from), p.Contract, f.Name, f.Params, f.PayValue)
+	})
+}
+
+// generateSecondaryWorkload builds the workload of the secondary, calling
+// createTx for every transaction with the index of the worker among all the
+// secondaries and the index of the transaction for that worker.
+// returns: SecondaryWorkload ([threads][time][tx]) -> [][][]byte
+func (m *MockWorkloadGenerator) generateSecondaryWorkload(secondaryID int, createTx func(worker int, txIndex int) ([]byte, error)) (SecondaryWorkload, error) {
+	secondaryWorkload := make(SecondaryWorkload, 0, m.BenchConfig.Threads)
+	for thread := 0; thread < m.BenchConfig.Threads; thread++ {
+		worker := secondaryID*m.BenchConfig.Threads + thread
+		threadWorkload := make(WorkerThreadWorkload, 0, len(m.TPSIntervals))
+		txIndex := 0
+		for interval, txnum := range m.TPSIntervals {
+			zap.L().Debug("Making workload ",
+				zap.Int("secondary", secondaryID),
+				zap.Int("thread", thread),
+				zap.Int("interval", interval),
+				zap.Int("value", txnum))
+
+			intervalWorkload := make([][]byte, 0, txnum)
+			for txIt := 0; txIt < txnum; txIt++ {
+				tx, err := createTx(worker, txIndex)
+				if err != nil {
+					return nil, err
+				}
+				intervalWorkload = append(intervalWorkload, tx)
+				txIndex++
+			}
+			threadWorkload = append(threadWorkload, intervalWorkload)
+		}
+		secondaryWorkload = append(secondaryWorkload, threadWorkload)
+	}
+
+	return secondaryWorkload, nil
+}
+
+// generatePremadeWorkload generates the transactions of the premade workload
+func (m *MockWorkloadGenerator) generatePremadeWorkload() (Workload, error) {
+	var fullWorkload Workload
+
+	for _, secondaryWorkload := range m.BenchConfig.TxInfo.PremadeInfo {
+		secondaryTransactions := make(SecondaryWorkload, 0)
+		for _, threadWorkload := range secondaryWorkload {
+			threadTransactions := make(WorkerThreadWorkload, 0)
+			for _, intervalWorkload := range threadWorkload {
+				intervalTransactions := make([][]byte, 0)
+				for _, txInfo := range intervalWorkload {
+					args := make([]string, 0, len(txInfo.DataParams))
+					for _, p := range txInfo.DataParams {
+						args = append(args, p.Value)
+					}
+
+					id, err := strconv.ParseUint(txInfo.ID, 10, 64)
+					if err != nil {
+						id = m.nextTxID
+					}
+					m.nextTxID++
+
+					b, err := json.Marshal(&types.MockTX{
+						ID:           id,
+						From:         txInfo.From,
+						To:           txInfo.To,
+						Value:        txInfo.Value,
+						FunctionName: txInfo.Function,
+						FunctionType: txInfo.TxType,
+						Args:         args,
+					})
+					if err != nil {
+						return nil, err
+					}
+
+					intervalTransactions = append(intervalTransactions, b)
+				}
+				threadTransactions = append(threadTransactions, intervalTransactions)
+			}
+			secondaryTransactions = append(secondaryTransactions, threadTransactions)
+		}
+		fullWorkload = append(fullWorkload, secondaryTransactions)
+	}
+
+	return fullWorkload, nil
+}
+
+// GenerateWorkload creates the workload of the benchmark for all clients
+func (m *MockWorkloadGenerator) GenerateWorkload() (Workload, error) {
+	zap.L().Info(
+		"Generating workload",
+		zap.String("workloadType", string(m.BenchConfig.TxInfo.TxType)),
+		zap.Int("threadsTotal", m.BenchConfig.Secondaries*m.BenchConfig.Threads),
+	)
+
+	if m.BenchConfig.TxInfo.TxType == configs.TxTypePremade {
+		return m.generatePremadeWorkload()
+	}
+
+	return generatePartitioned(m)
+}
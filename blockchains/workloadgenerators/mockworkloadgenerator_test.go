@@ -0,0 +1,74 @@
+package workloadgenerators
+
+import (
+	"diablo-benchmark/blockchains/types"
+	"diablo-benchmark/core/configs"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMockSecondaryGeneration(t *testing.T) {
+	chainConfig := &configs.ChainConfig{Name: "mock"}
+	benchConfig := &configs.BenchConfig{
+		Secondaries: 3,
+		Threads:     2,
+		TxInfo: configs.BenchInfo{
+			TxType:    configs.TxTypeTest,
+			Intervals: configs.TPSIntervals{0: 12, 1: 6},
+		},
+		ContractInfo: configs.ContractInfo{
+			Functions: []configs.ContractFunction{
+				{Name: "get", Type: "read", Ratio: 30},
+				{Name: "set", Type: "write", Ratio: 70, Params: []configs.ContractParam{{Type: "uint64", Value: "7"}}},
+			},
+		},
+	}
+
+	newGenerator := func() *MockWorkloadGenerator {
+		m := (&MockWorkloadGenerator{}).NewGenerator(chainConfig, benchConfig).(*MockWorkloadGenerator)
+		_ = m.BlockchainSetup()
+		m.SetThreadIntervals(GetIntervalPerThread(benchConfig.TxInfo.Intervals, benchConfig.Secondaries, benchConfig.Threads))
+		return m
+	}
+
+	workload, err := newGenerator().GenerateWorkload()
+	if err != nil {
+		t.Fatalf("failed to generate workload: %s", err.Error())
+	}
+
+	partitions, err := newGenerator().Partitions()
+	if err != nil {
+		t.Fatalf("failed to partition workload: %s", err.Error())
+	}
+
+	ids := make(map[uint64]bool)
+	for i, partition := range partitions {
+		g := (&MockWorkloadGenerator{}).NewGenerator(chainConfig, benchConfig).(*MockWorkloadGenerator)
+		g.SetThreadIntervals(GetIntervalPerThread(benchConfig.TxInfo.Intervals, benchConfig.Secondaries, benchConfig.Threads))
+
+		secondaryWorkload, err := g.GenerateSecondaryWorkload(partition)
+		if err != nil {
+			t.Fatalf("secondary %d failed to generate its workload: %s", i, err.Error())
+		}
+
+		if !reflect.DeepEqual(secondaryWorkload, SecondaryWorkload(workload[i])) {
+			t.Errorf("workload generated by secondary %d differs from the workload of the primary", i)
+		}
+
+		for _, intervals := range secondaryWorkload {
+			for _, txs := range intervals {
+				for _, txBytes := range txs {
+					var tx types.MockTX
+					if err := json.Unmarshal(txBytes, &tx); err != nil {
+						t.Fatalf("failed to decode transaction: %s", err.Error())
+					}
+					if ids[tx.ID] {
+						t.Errorf("transaction ID %d used twice", tx.ID)
+					}
+					ids[tx.ID] = true
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package workloadgenerators
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"diablo-benchmark/core/configs"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// quorumPrivateV is added to the "v" value of the signature of the private
+// transactions, Quorum marks them with a "v" of 37 or 38 instead of 27 or 28.
+const quorumPrivateV = 10
+
+// tesseraTimeout bounds the time to store a private payload in Tessera
+const tesseraTimeout = 10 * time.Second
+
+// QuorumWorkloadGenerator is the workload generator implementation for
+// Quorum. It creates the same workloads as the Ethereum generator. If
+// "privateFor" is given in the chain configuration, all the transactions are
+// private: their payload is stored in the Tessera node of the sender and
+// replaced by its hash, and they are signed as Quorum private transactions.
+type QuorumWorkloadGenerator struct {
+	PrivateFor []string     // Tessera public keys of the recipients of the private transactions
+	TesseraURL string       // Third party API of the Tessera node storing the private payloads
+	rpcClient  *rpc.Client  // RPC connection of the active connection, to send the private transactions
+	httpClient *http.Client // Client of the Tessera API
+	privacyErr error        // Error in the private transactions configuration, returned by InitParams
+	EthereumWorkloadGenerator
+}
+
+func init() {
+	RegisterWorkloadGenerator("quorum", func() WorkloadGenerator { return &QuorumWorkloadGenerator{} })
+}
+
+// NewGenerator returns a new instance of the generator
+func (q *QuorumWorkloadGenerator) NewGenerator(chainConfig *configs.ChainConfig, benchConfig *configs.BenchConfig) WorkloadGenerator {
+	g := &QuorumWorkloadGenerator{
+		EthereumWorkloadGenerator: EthereumWorkloadGenerator{BenchConfig: benchConfig, ChainConfig: chainConfig},
+		httpClient:                &http.Client{Timeout: tesseraTimeout},
+	}
+
+	// A wrong configuration must not send the private transactions in public
+	privateFor, tesseraURL, err := QuorumPrivacyConfig(chainConfig)
+	if err != nil {
+		g.privacyErr = fmt.Errorf("private transactions: %w", err)
+		return g
+	}
+
+	if len(privateFor) > 0 {
+		g.PrivateFor = privateFor
+		g.TesseraURL = strings.TrimSuffix(tesseraURL, "/")
+		g.signTx = g.signPrivateTx
+		g.sendTx = g.sendPrivateTx
+	}
+
+	return g
+}
+
+// InitParams connects to the first node and initialises the parameters like
+// the Ethereum generator. The RPC connection is kept to send private
+// transactions.
+func (q *QuorumWorkloadGenerator) InitParams() error {
+	if q.privacyErr != nil {
+		return q.privacyErr
+	}
+
+	r, err := rpc.Dial(fmt.Sprintf("ws://%s", q.ChainConfig.Nodes[0]))
+	if err != nil {
+		return err
+	}
+
+	q.rpcClient = r
+	q.ActiveConn = ethclient.NewClient(r)
+
+	return q.EthereumWorkloadGenerator.InitParams()
+}
+
+// Partitions partitions the workload like the Ethereum generator. Private
+// transactions are generated by the primary, their payloads are stored in
+// the Tessera node while signing.
+func (q *QuorumWorkloadGenerator) Partitions() ([][]byte, error) {
+	if len(q.PrivateFor) > 0 {
+		return nil, fmt.Errorf("private transactions: %w", ErrNotPartitioned)
+	}
+
+	return q.EthereumWorkloadGenerator.Partitions()
+}
+
+// GenerateSecondaryWorkload generates the workload of the secondary like the
+// Ethereum generator, it refuses to generate private transactions, and any
+// transaction if their configuration is invalid.
+func (q *QuorumWorkloadGenerator) GenerateSecondaryWorkload(partition []byte) (SecondaryWorkload, error) {
+	if q.privacyErr != nil {
+		return nil, q.privacyErr
+	}
+	if len(q.PrivateFor) > 0 {
+		return nil, fmt.Errorf("private transactions: %w", ErrNotPartitioned)
+	}
+
+	return q.EthereumWorkloadGenerator.GenerateSecondaryWorkload(partition)
+}
+
+// QuorumPrivacyConfig reads "privateFor" and "tessera" from the extra section
+// of the chain configuration, for the generator and the client interface.
+func QuorumPrivacyConfig(chainConfig *configs.ChainConfig) ([]string, string, error) {
+	if len(chainConfig.Extra) == 0 {
+		return nil, "", nil
+	}
+
+	extra, ok := chainConfig.Extra[0].(map[string]interface{})
+	if !ok {
+		return nil, "", errors.New("chain config: extra must be a map of parameters")
+	}
+
+	var privateFor []string
+	if v, ok := extra["privateFor"]; ok {
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("chain config: privateFor must be a list, got %v", v)
+		}
+		for _, k := range list {
+			s, ok := k.(string)
+			if !ok {
+				return nil, "", fmt.Errorf("chain config: privateFor must contain public keys, got %v", k)
+			}
+			privateFor = append(privateFor, s)
+		}
+	}
+
+	tesseraURL, _ := extra["tessera"].(string)
+	if len(privateFor) > 0 && tesseraURL == "" {
+		return nil, "", errors.New("chain config: tessera is required to send private transactions")
+	}
+
+	return privateFor, tesseraURL, nil
+}
+
+// IsQuorumPrivateTx returns true if the transaction is signed as a Quorum private transaction
+func IsQuorumPrivateTx(tx *ethtypes.Transaction) bool {
+	v, _, _ := tx.RawSignatureValues()
+	return v.Uint64() == 27+quorumPrivateV || v.Uint64() == 28+quorumPrivateV
+}
+
+// storeRaw stores the payload in Tessera and returns its hash
+func (q *QuorumWorkloadGenerator) storeRaw(payload []byte) ([]byte, error) {
+	body, err := json.Marshal(map[string]string{
+		"payload": base64.StdEncoding.EncodeToString(payload),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := q.httpClient.Post(q.TesseraURL+"/storeraw", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tessera storeraw failed: %s", resp.Status)
+	}
+
+	var stored struct {
+		Key string `json:"key"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&stored); err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(stored.Key)
+}
+
+// signPrivateTx replaces the payload of the transaction by its hash in
+// Tessera and signs it as a private transaction. Quorum requires private
+// transactions to be signed without replay protection, with the "v" value
+// shifted to mark them private.
+func (q *QuorumWorkloadGenerator) signPrivateTx(tx *ethtypes.Transaction, priv *ecdsa.PrivateKey) (*ethtypes.Transaction, error) {
+	hash, err := q.storeRaw(tx.Data())
+	if err != nil {
+		return nil, err
+	}
+
+	var privateTx *ethtypes.Transaction
+	if tx.To() == nil {
+		privateTx = ethtypes.NewContractCreation(tx.Nonce(), tx.Value(), tx.Gas(), tx.GasPrice(), hash)
+	} else {
+		privateTx = ethtypes.NewTransaction(tx.Nonce(), *tx.To(), tx.Value(), tx.Gas(), tx.GasPrice(), hash)
+	}
+
+	signedTx, err := ethtypes.SignTx(privateTx, ethtypes.HomesteadSigner{}, priv)
+	if err != nil {
+		return nil, err
+	}
+
+	// The transaction fields are private, change "v" through its JSON encoding
+	txJSON, err := signedTx.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(txJSON, &fields); err != nil {
+		return nil, err
+	}
+
+	v, _, _ := signedTx.RawSignatureValues()
+	fields["v"] = hexutil.EncodeBig(new(big.Int).Add(v, big.NewInt(quorumPrivateV)))
+	delete(fields, "hash")
+
+	if txJSON, err = json.Marshal(fields); err != nil {
+		return nil, err
+	}
+
+	var marked ethtypes.Transaction
+	if err := marked.UnmarshalJSON(txJSON); err != nil {
+		return nil, err
+	}
+
+	return &marked, nil
+}
+
+// sendPrivateTx sends the private deployment transactions
+func (q *QuorumWorkloadGenerator) sendPrivateTx(ctx context.Context, tx *ethtypes.Transaction) error {
+	return SendQuorumPrivateTx(ctx, q.rpcClient, tx, q.PrivateFor)
+}
+
+// SendQuorumPrivateTx sends the signed private transaction to the node, which
+// shares its payload with the given recipients.
+func SendQuorumPrivateTx(ctx context.Context, c *rpc.Client, tx *ethtypes.Transaction, privateFor []string) error {
+	data, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return err
+	}
+
+	var hash string
+	return c.CallContext(ctx, &hash, "eth_sendRawPrivateTransaction",
+		hexutil.Encode(data), map[string]interface{}{"privateFor": privateFor})
+}
@@ -0,0 +1,46 @@
+package workloadgenerators
+
+import (
+	"diablo-benchmark/core/configs"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestQuorumPrivacyConfigErrors(t *testing.T) {
+	tests := []struct {
+		extra    interface{}
+		expected string
+	}{
+		{map[string]interface{}{"privateFor": "key"}, "privateFor must be a list"},
+		{map[string]interface{}{"privateFor": []interface{}{1}}, "privateFor must contain public keys"},
+		{map[string]interface{}{"privateFor": []interface{}{"key"}}, "tessera is required"},
+		{"privateFor", "extra must be a map"},
+	}
+
+	benchConfig := &configs.BenchConfig{Secondaries: 1, Threads: 1}
+	for _, test := range tests {
+		chainConfig := &configs.ChainConfig{Name: "quorum", Nodes: []string{"127.0.0.1:1"}, Extra: []interface{}{test.extra}}
+		q := (&QuorumWorkloadGenerator{}).NewGenerator(chainConfig, benchConfig).(*QuorumWorkloadGenerator)
+
+		// The run fails before connecting to the node, instead of sending public transactions
+		if err := q.InitParams(); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("expected the configuration %v to fail with %q, got %v", test.extra, test.expected, err)
+		}
+		if _, err := q.GenerateSecondaryWorkload(nil); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("expected the secondaries to refuse the configuration %v, got %v", test.extra, err)
+		}
+		if q.signTx != nil || q.sendTx != nil {
+			t.Errorf("expected no private signing with the configuration %v", test.extra)
+		}
+	}
+
+	chainConfig := &configs.ChainConfig{Name: "quorum", Extra: []interface{}{map[string]interface{}{"privateFor": []interface{}{"key"}, "tessera": "http://tessera:9080/"}}}
+	q := (&QuorumWorkloadGenerator{}).NewGenerator(chainConfig, benchConfig).(*QuorumWorkloadGenerator)
+	if q.privacyErr != nil || len(q.PrivateFor) != 1 || q.TesseraURL != "http://tessera:9080" {
+		t.Errorf("expected the private transactions to be configured, got %v %v %s", q.privacyErr, q.PrivateFor, q.TesseraURL)
+	}
+	if _, err := q.GenerateSecondaryWorkload(nil); !errors.Is(err, ErrNotPartitioned) {
+		t.Errorf("expected private transactions not to be partitioned, got %v", err)
+	}
+}
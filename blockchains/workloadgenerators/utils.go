@@ -5,37 +5,143 @@ import (
 	"errors"
 	"math"
 	"math/rand"
-	"time"
+	"sort"
+	"sync"
 
 	"go.uber.org/zap"
 )
 
+// generators maps the name of the chains to the constructor of their workload generator
+var (
+	generators     = make(map[string]func() WorkloadGenerator)
+	generatorsLock sync.RWMutex
+)
+
+// RegisterWorkloadGenerator makes the workload generator of a chain available
+// under the given name, the name of the chain in the chain configuration. It
+// is called by the chains when their package is initialised, registering the
+// same name twice or a nil constructor panics.
+func RegisterWorkloadGenerator(name string, newGenerator func() WorkloadGenerator) {
+	generatorsLock.Lock()
+	defer generatorsLock.Unlock()
+
+	if newGenerator == nil {
+		panic("workloadgenerators: nil constructor for workload generator " + name)
+	}
+
+	if _, ok := generators[name]; ok {
+		panic("workloadgenerators: workload generator registered twice for " + name)
+	}
+
+	generators[name] = newGenerator
+}
+
+// WorkloadGenerators returns the sorted names of the registered workload generators
+func WorkloadGenerators() []string {
+	generatorsLock.RLock()
+	defer generatorsLock.RUnlock()
+
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
 // GetWorkloadGenerator matches the workload generator with the name provided of the chain in the configuration
 // If there is no match, there is an error returned. This is defined in the
 // chain configuration.
 func GetWorkloadGenerator(config *configs.ChainConfig) (WorkloadGenerator, error) {
-	var wg WorkloadGenerator
-
-	switch config.Name {
-	case "ethereum":
-		// Return the ethereum workload generator
-		// TODO get the type of the ethereum workload generator
-		wg = &EthereumWorkloadGenerator{}
-	case "fabric":
-		wg = &FabricWorkloadGenerator{}
-	default:
+	generatorsLock.RLock()
+	newGenerator, ok := generators[config.Name]
+	generatorsLock.RUnlock()
+
+	if !ok {
 		zap.L().Warn("unknown chain defined in config",
 			zap.String("chain_name", config.Name))
 		return nil, errors.New("unknown chain when parsing config")
 	}
 
-	return wg, nil
+	return newGenerator(), nil
+}
+
+// generatePartitioned generates the workload of all the secondaries from their
+// partitions, as each secondary would. The partitioned generators generate
+// their workload this way on the primary too, so that both are identical.
+func generatePartitioned(g PartitionedGenerator) (Workload, error) {
+	partitions, err := g.Partitions()
+	if err != nil {
+		return nil, err
+	}
+
+	workload := make(Workload, 0, len(partitions))
+	for _, partition := range partitions {
+		secondaryWorkload, err := g.GenerateSecondaryWorkload(partition)
+		if err != nil {
+			return nil, err
+		}
+		workload = append(workload, secondaryWorkload)
+	}
+
+	return workload, nil
+}
+
+// txPerWorker returns the number of transactions of each worker for the intervals
+func txPerWorker(intervals []int) int {
+	total := 0
+	for _, v := range intervals {
+		total += v
+	}
+
+	return total
+}
+
+// interleave returns n indexes of the weights, each index appears in
+// proportion to its weight and as evenly as possible between the others.
+// Without weights, the indexes appear equally.
+func interleave(weights []int, n int) []int {
+	if len(weights) == 0 {
+		return nil
+	}
+
+	total := 0
+	for _, weight := range weights {
+		total += weight
+	}
+	if total == 0 {
+		weights = make([]int, len(weights))
+		for i := range weights {
+			weights[i] = 1
+		}
+		total = len(weights)
+	}
+
+	// Smooth weighted round robin: the index with the largest credit is
+	// taken and pays back the total of the weights.
+	sequence := make([]int, 0, n)
+	credits := make([]int, len(weights))
+	for len(sequence) < n {
+		next := 0
+		for i, weight := range weights {
+			credits[i] += weight
+			if credits[i] > credits[next] {
+				next = i
+			}
+		}
+		credits[next] -= total
+		sequence = append(sequence, next)
+	}
+
+	return sequence
 }
 
-// ShuffleFunctionCalls shuffles the function calls to interleave execution
-func ShuffleFunctionCalls(functionsToGet []int) {
+// ShuffleFunctionCalls shuffles the function calls to interleave execution,
+// the same seed gives the same order
+func ShuffleFunctionCalls(functionsToGet []int, seed int64) {
 	// start with a source of randomness
-	randomness := rand.New(rand.NewSource(time.Now().Unix()))
+	randomness := rand.New(rand.NewSource(seed))
 
 	// In-place shuffle
 	for len(functionsToGet) > 0 {
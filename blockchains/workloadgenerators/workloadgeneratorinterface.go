@@ -9,6 +9,8 @@ package workloadgenerators
 
 import (
 	"diablo-benchmark/core/configs"
+	"diablo-benchmark/core/results"
+	"errors"
 	"math/big"
 )
 
@@ -25,6 +27,7 @@ type WorkerThreadWorkload [][][]byte
 // standard across all generators
 type GenericWorkloadGenerator struct {
 	TPSIntervals []int
+	Mix          []results.FunctionMix // Mix of the contract functions of the generated workload
 }
 
 // SetThreadIntervals sets the TPS intervals to be made for each thread
@@ -33,6 +36,12 @@ func (g *GenericWorkloadGenerator) SetThreadIntervals(intervals []int) {
 	g.TPSIntervals = intervals
 }
 
+// FunctionMix returns the number of calls of each contract function in the
+// generated workload, empty if it does not call contracts.
+func (g *GenericWorkloadGenerator) FunctionMix() []results.FunctionMix {
+	return g.Mix
+}
+
 // WorkloadGenerator provides the interface and basic functionality to generate a workload given the configurations.
 // The workload generator handles the creation of the transactions and additionally sets
 // up the blockchain and starts the blockchain nodes.
@@ -72,3 +81,34 @@ type WorkloadGenerator interface {
 	// SetThreadIntervals sets the number of transactions per thread to create for each interval
 	SetThreadIntervals(interval []int)
 }
+
+// ErrNotPartitioned is returned by Partitions when the workload of the
+// benchmark cannot be generated by the secondaries, e.g. premade workloads.
+// The primary then generates the workload.
+var ErrNotPartitioned = errors.New("workload cannot be generated by the secondaries")
+
+// PartitionedGenerator is a workload generator whose secondaries can generate
+// their own part of the workload. The primary only computes the partition of
+// each secondary: the accounts of its workers, their starting nonces, etc.
+// Each secondary then generates and signs its transactions from its partition,
+// the workload is byte-identical to the one of GenerateWorkload.
+type PartitionedGenerator interface {
+	WorkloadGenerator
+
+	// Partitions returns the encoded partition of each secondary, once the
+	// blockchain is set up and the thread intervals are set. Contracts are
+	// deployed by the primary, their address is part of the partitions.
+	Partitions() ([][]byte, error)
+
+	// GenerateSecondaryWorkload generates the workload of a secondary from its
+	// partition, it only needs the configurations and the thread intervals.
+	GenerateSecondaryWorkload(partition []byte) (SecondaryWorkload, error)
+}
+
+// MixReporter is a workload generator that reports the mix of the contract
+// functions of its workload, to be shown in the results.
+type MixReporter interface {
+	// FunctionMix returns the configured share and the number of generated
+	// calls of each contract function, once the workload is generated.
+	FunctionMix() []results.FunctionMix
+}
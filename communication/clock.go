@@ -0,0 +1,88 @@
+package communication
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// clockSamples is the number of clock exchanges with each secondary, the one
+// with the shortest round trip gives the most accurate offset.
+const clockSamples = 8
+
+// startLead is the time given to the secondaries to receive MsgRun before the
+// start of the benchmark, in addition to the longest round trip.
+const startLead = time.Second
+
+// ClockSync is the clock of a secondary measured by the primary
+type ClockSync struct {
+	Offset time.Duration // Clock of the secondary minus the clock of the primary
+	RTT    time.Duration // Round trip time of the exchange used for the offset
+}
+
+// EncodeClock encodes the time for the reply to MsgClock
+func EncodeClock(t time.Time) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(t.UnixNano()))
+	return data
+}
+
+// measureClock estimates the offset of the clock of the secondary with a few
+// MsgClock exchanges, assuming the reply is read half way through each round
+// trip like NTP.
+func (s *PrimaryServer) measureClock(secondary net.Conn) (ClockSync, error) {
+	var best ClockSync
+	for i := 0; i < clockSamples; i++ {
+		sent := time.Now()
+		reply, err := s.exchange(MsgClock, nil, secondary)
+		received := time.Now()
+		if err != nil {
+			return ClockSync{}, err
+		}
+
+		if len(reply) != 8 {
+			return ClockSync{}, &SecondaryErrorReply{
+				Info: secondary.RemoteAddr().String(),
+				Err:  fmt.Errorf("invalid clock reply of %d bytes", len(reply)),
+			}
+		}
+
+		rtt := received.Sub(sent)
+		remote := time.Unix(0, int64(binary.BigEndian.Uint64(reply)))
+		if i == 0 || rtt < best.RTT {
+			best = ClockSync{
+				Offset: remote.Sub(sent.Add(rtt / 2)),
+				RTT:    rtt,
+			}
+		}
+	}
+
+	return best, nil
+}
+
+// startTime returns the time at which the secondaries start the benchmark, on
+// the clock of the primary, leaving them the time to receive MsgRun.
+func (s *PrimaryServer) startTime() time.Time {
+	var maxRTT time.Duration
+	for _, c := range s.Clocks {
+		if c.RTT > maxRTT {
+			maxRTT = c.RTT
+		}
+	}
+
+	return time.Now().Add(startLead + maxRTT)
+}
+
+// ClockOffsets returns the clock offset and round trip time measured for each
+// secondary, by secondary ID like the results.
+func (s *PrimaryServer) ClockOffsets() ([]time.Duration, []time.Duration) {
+	offsets := make([]time.Duration, len(s.Clocks))
+	rtts := make([]time.Duration, len(s.Clocks))
+	for i, c := range s.Clocks {
+		offsets[i] = c.Offset
+		rtts[i] = c.RTT
+	}
+
+	return offsets, rtts
+}
@@ -0,0 +1,62 @@
+package communication
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClockSync(t *testing.T) {
+	primary, secondary := net.Pipe()
+	defer primary.Close()
+	defer secondary.Close()
+
+	// The clock of the secondary is 5 seconds ahead
+	const offset = 5 * time.Second
+	runCh := make(chan RunMessage, 1)
+	go func() {
+		c := &ConnClient{Conn: secondary}
+		for {
+			cmd, err := ReadFrame(secondary)
+			if err != nil {
+				return
+			}
+
+			switch cmd.Type {
+			case MsgClock:
+				c.SendDataOK(EncodeClock(time.Now().Add(offset)))
+			case MsgRun:
+				var run RunMessage
+				_ = json.Unmarshal(cmd.Payload, &run)
+				runCh <- run
+				c.ReplyOK()
+			default:
+				c.ReplyOK()
+			}
+		}
+	}()
+
+	s := &PrimaryServer{Secondaries: []net.Conn{primary}}
+	clock, err := s.measureClock(primary)
+	if err != nil {
+		t.Fatalf("failed to measure clock: %s", err.Error())
+	}
+
+	if diff := clock.Offset - offset; diff < -10*time.Millisecond || diff > 10*time.Millisecond {
+		t.Errorf("expected an offset of %s, got %s", offset, clock.Offset)
+	}
+	s.Clocks = []ClockSync{clock}
+
+	before := time.Now()
+	if errs := s.RunBenchmark(context.Background()); errs != nil {
+		t.Fatalf("failed to run: %v", errs)
+	}
+
+	// The start is given on the clock of the secondary, after the lead time
+	start := time.Unix(0, (<-runCh).StartTime).Add(-clock.Offset)
+	if start.Before(before.Add(startLead)) || start.After(time.Now().Add(startLead+clock.RTT)) {
+		t.Errorf("expected a start %s after %s, got %s", startLead, before, start)
+	}
+}
@@ -0,0 +1,112 @@
+package communication
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ProtocolVersion is the version of the protocol between the primary and the
+// secondaries. It must be increased on every incompatible change of the
+// messages or their payload, the header layout stays the same in all versions.
+const ProtocolVersion uint8 = 8
+
+// frameMagic starts every frame, it tells a diablo peer from anything else
+// connecting to the port.
+var frameMagic = []byte("DBLO")
+
+// frameHeaderLength is the length of the header of a frame:
+// bytes 0-3 : magic
+// byte  4   : protocol version
+// byte  5   : message type
+// bytes 6-13: payload length (big endian uint64)
+const frameHeaderLength = 14
+
+// maxPayloadLength bounds the payload of a frame, so that a corrupted or
+// hostile length cannot exhaust the memory of the host. Workload chunks hold
+// a single interval of a worker and stay well below it.
+const maxPayloadLength uint64 = 64 << 20
+
+// payloadReadSize is the most memory reserved for a payload before its bytes
+// arrive, the buffer grows as the payload is read.
+const payloadReadSize = 64 << 10
+
+// ErrBadMagic is returned when the peer does not speak the diablo protocol
+var ErrBadMagic = errors.New("invalid frame header: peer is not a diablo primary or secondary")
+
+// VersionError is returned when the peer runs a different protocol version.
+// The frame is still read entirely, so an error reply can be reported.
+type VersionError struct {
+	Remote uint8 // Protocol version of the peer
+}
+
+// Error message for the version mismatch
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("protocol version mismatch: peer uses version %d, this build uses version %d", e.Remote, ProtocolVersion)
+}
+
+// Frame is a message with its length-prefixed payload
+type Frame struct {
+	Type    MessageType // Type of the message
+	Payload []byte      // Payload of the message, can be empty
+}
+
+// WriteFrame writes the message and its payload in a single frame
+func WriteFrame(w io.Writer, t MessageType, payload []byte) error {
+	frame := make([]byte, frameHeaderLength, frameHeaderLength+len(payload))
+	copy(frame, frameMagic)
+	frame[4] = ProtocolVersion
+	frame[5] = byte(t)
+	binary.BigEndian.PutUint64(frame[6:], uint64(len(payload)))
+	frame = append(frame, payload...)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+// ReadFrame reads the next frame. If the peer uses another protocol version,
+// the frame is returned with a *VersionError.
+func ReadFrame(r io.Reader) (Frame, error) {
+	return ReadFrameLimit(r, maxPayloadLength)
+}
+
+// ReadFrameLimit reads the next frame, its payload can be up to limit bytes.
+// The payload is read incrementally, the memory is only used as the bytes
+// arrive and not on the length announced by the header.
+func ReadFrameLimit(r io.Reader, limit uint64) (Frame, error) {
+	header := make([]byte, frameHeaderLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return Frame{}, err
+	}
+
+	if !bytes.Equal(header[:4], frameMagic) {
+		return Frame{}, ErrBadMagic
+	}
+
+	length := binary.BigEndian.Uint64(header[6:])
+	if length > limit {
+		return Frame{}, fmt.Errorf("frame payload of %d bytes exceeds the maximum of %d bytes", length, limit)
+	}
+
+	initial := length
+	if initial > payloadReadSize {
+		initial = payloadReadSize
+	}
+	payload := bytes.NewBuffer(make([]byte, 0, initial))
+	if _, err := io.CopyN(payload, r, int64(length)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Frame{}, err
+	}
+
+	frame := Frame{Type: MessageType(header[5]), Payload: payload.Bytes()}
+
+	if header[4] != ProtocolVersion {
+		return frame, &VersionError{Remote: header[4]}
+	}
+
+	return frame, nil
+}
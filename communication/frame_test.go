@@ -0,0 +1,146 @@
+package communication
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// rawFrame encodes a frame with the given protocol version
+func rawFrame(version uint8, t MessageType, payload []byte) []byte {
+	frame := append([]byte(nil), frameMagic...)
+	frame = append(frame, version, byte(t))
+	length := make([]byte, 8)
+	binary.BigEndian.PutUint64(length, uint64(len(payload)))
+	frame = append(frame, length...)
+	return append(frame, payload...)
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	payload := bytes.Repeat([]byte{0xab}, 100000)
+
+	if err := WriteFrame(&buf, MsgWorkload, payload); err != nil {
+		t.Fatalf("failed to write frame: %s", err.Error())
+	}
+	if err := WriteFrame(&buf, MsgRun, nil); err != nil {
+		t.Fatalf("failed to write frame: %s", err.Error())
+	}
+
+	frame, err := ReadFrame(&buf)
+	if err != nil {
+		t.Fatalf("failed to read frame: %s", err.Error())
+	}
+	if frame.Type != MsgWorkload || !bytes.Equal(frame.Payload, payload) {
+		t.Errorf("expected the workload frame of %d bytes, got %s of %d bytes", len(payload), frame.Type, len(frame.Payload))
+	}
+
+	frame, err = ReadFrame(&buf)
+	if err != nil {
+		t.Fatalf("failed to read frame: %s", err.Error())
+	}
+	if frame.Type != MsgRun || len(frame.Payload) != 0 {
+		t.Errorf("expected an empty run frame, got %s of %d bytes", frame.Type, len(frame.Payload))
+	}
+}
+
+func TestFrameInvalidHeader(t *testing.T) {
+	if _, err := ReadFrame(bytes.NewReader([]byte("GET / HTTP/1.1\r\n\r\n"))); err != ErrBadMagic {
+		t.Errorf("expected ErrBadMagic, got %v", err)
+	}
+
+	frame, err := ReadFrame(bytes.NewReader(rawFrame(ProtocolVersion+1, MsgErr, []byte("boom"))))
+	versionErr, ok := err.(*VersionError)
+	if !ok {
+		t.Fatalf("expected a version error, got %v", err)
+	}
+	if versionErr.Remote != ProtocolVersion+1 || string(frame.Payload) != "boom" {
+		t.Errorf("expected the frame of version %d, got version %d and payload %q", ProtocolVersion+1, versionErr.Remote, frame.Payload)
+	}
+}
+
+func TestFrameLength(t *testing.T) {
+	header := func(length uint64) []byte {
+		frame := rawFrame(ProtocolVersion, MsgOk, nil)
+		binary.BigEndian.PutUint64(frame[6:], length)
+		return frame
+	}
+
+	if _, err := ReadFrame(bytes.NewReader(header(maxPayloadLength + 1))); err == nil {
+		t.Errorf("expected a payload over the maximum to be rejected")
+	}
+
+	// A large announced payload that never arrives only fails the read
+	truncated := append(header(maxPayloadLength), []byte("short")...)
+	if _, err := ReadFrame(bytes.NewReader(truncated)); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected a truncated payload to fail, got %v", err)
+	}
+
+	// The results can go over the maximum of the other messages
+	payload := bytes.Repeat([]byte{0x01}, 100)
+	if _, err := ReadFrameLimit(bytes.NewReader(rawFrame(ProtocolVersion, MsgOk, payload)), 99); err == nil {
+		t.Errorf("expected a payload over the limit to be rejected")
+	}
+	frame, err := ReadFrameLimit(bytes.NewReader(rawFrame(ProtocolVersion, MsgOk, payload)), 100)
+	if err != nil || !bytes.Equal(frame.Payload, payload) {
+		t.Errorf("expected the payload within the limit, got %d bytes (%v)", len(frame.Payload), err)
+	}
+}
+
+func TestPrepareErrorReply(t *testing.T) {
+	primary, secondary := net.Pipe()
+	defer primary.Close()
+	defer secondary.Close()
+
+	longError := strings.Repeat("chain config: invalid node address ", 100)
+	go func() {
+		c := &ConnClient{Conn: secondary}
+		cmd, err := c.ReadCommand()
+		if err != nil {
+			return
+		}
+
+		if cmd.Type != MsgPrepare || cmd.Prepare.SecondaryID != 300 || cmd.Prepare.Threads != 4 {
+			c.ReplyERR("unexpected prepare message")
+			return
+		}
+		c.ReplyPrepare(errors.New(longError))
+	}()
+
+	s := &PrimaryServer{}
+	payload, _ := json.Marshal(PrepareMessage{SecondaryID: 300, Threads: 4})
+	err := s.SendAndWaitOKSync(MsgPrepare, payload, primary)
+
+	reply, ok := err.(*SecondaryErrorReply)
+	if !ok {
+		t.Fatalf("expected an error reply, got %v", err)
+	}
+	if reply.Err.Error() != longError {
+		t.Errorf("expected the full error text of %d bytes, got %q", len(longError), reply.Err.Error())
+	}
+}
+
+func TestVersionMismatchAtPrepare(t *testing.T) {
+	primary, secondary := net.Pipe()
+	defer primary.Close()
+	defer secondary.Close()
+
+	// A secondary from another build rejects the prepare message
+	go func() {
+		if _, err := ReadFrame(secondary); err != nil {
+			return
+		}
+		_, _ = secondary.Write(rawFrame(ProtocolVersion+1, MsgErr, []byte("protocol version mismatch")))
+	}()
+
+	s := &PrimaryServer{}
+	err := s.SendAndWaitOKSync(MsgPrepare, nil, primary)
+	if err == nil || !strings.Contains(err.Error(), "protocol version mismatch") {
+		t.Errorf("expected a version mismatch error, got %v", err)
+	}
+}
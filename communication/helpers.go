@@ -1,22 +1,170 @@
 package communication
 
 import (
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
 	"diablo-benchmark/blockchains/workloadgenerators"
-	"encoding/json"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
 )
 
-// EncodeWorkload Helper function to standardise the way that workloads and information is encoded/decoded through
-// communication
-func EncodeWorkload(workload workloadgenerators.SecondaryWorkload) ([]byte, error) {
-	return json.Marshal(workload)
+// Compression of the workload chunks, set by the primary in the workload header
+const (
+	CompressionNone    = "none"    // Chunks are sent as encoded
+	CompressionDeflate = "deflate" // Chunks are compressed with deflate
+)
+
+// workloadChunkHeaderLength is the length of the header of an encoded chunk:
+// bytes 0-3 : index of the chunk
+// bytes 4-7 : worker
+// bytes 8-11: interval
+const workloadChunkHeaderLength = 12
+
+// WorkloadChunk is the part of the workload of a secondary sent in one
+// message: the transactions of one worker in one interval.
+type WorkloadChunk struct {
+	Index    uint32   // Position of the chunk in the transfer
+	Worker   uint32   // Worker sending the transactions
+	Interval uint32   // Interval of the transactions
+	Txs      [][]byte // Encoded transactions
 }
 
-// DecodeWorkload Helper function to standardise the way workloads and information is decoded through
+// ValidCompression returns true if the compression is known
+func ValidCompression(compression string) bool {
+	return compression == CompressionNone || compression == CompressionDeflate
+}
+
+// WorkloadChunks splits the workload of a secondary in chunks, worker by
+// worker and interval by interval. The chunks share the transactions of the
+// workload.
+func WorkloadChunks(workload workloadgenerators.SecondaryWorkload) []WorkloadChunk {
+	var chunks []WorkloadChunk
+	for worker, intervals := range workload {
+		for interval, txs := range intervals {
+			chunks = append(chunks, WorkloadChunk{
+				Index:    uint32(len(chunks)),
+				Worker:   uint32(worker),
+				Interval: uint32(interval),
+				Txs:      txs,
+			})
+		}
+	}
+
+	return chunks
+}
+
+// EncodeWorkloadChunk Helper function to standardise the way that workloads are encoded through
+// communication. The transactions are written as raw bytes prefixed by their
+// length, and compressed if requested.
+func EncodeWorkloadChunk(chunk WorkloadChunk, compression string) ([]byte, error) {
+	var body bytes.Buffer
+	varint := make([]byte, binary.MaxVarintLen64)
+
+	body.Write(varint[:binary.PutUvarint(varint, uint64(len(chunk.Txs)))])
+	for _, tx := range chunk.Txs {
+		body.Write(varint[:binary.PutUvarint(varint, uint64(len(tx)))])
+		body.Write(tx)
+	}
+
+	data := make([]byte, workloadChunkHeaderLength, workloadChunkHeaderLength+body.Len())
+	binary.BigEndian.PutUint32(data[0:], chunk.Index)
+	binary.BigEndian.PutUint32(data[4:], chunk.Worker)
+	binary.BigEndian.PutUint32(data[8:], chunk.Interval)
+
+	switch compression {
+	case CompressionNone, "":
+		return append(data, body.Bytes()...), nil
+	case CompressionDeflate:
+		compressed := bytes.NewBuffer(data)
+		w, err := flate.NewWriter(compressed, flate.BestSpeed)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(body.Bytes()); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return compressed.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown workload compression %s", compression)
+	}
+}
+
+// DecodeWorkloadChunk Helper function to standardise the way workloads are decoded through
 // communication.
-func DecodeWorkload(data []byte) (workloadgenerators.SecondaryWorkload, error) {
-	var decodedWorkload workloadgenerators.SecondaryWorkload
+func DecodeWorkloadChunk(data []byte, compression string) (WorkloadChunk, error) {
+	if len(data) < workloadChunkHeaderLength {
+		return WorkloadChunk{}, errors.New("workload chunk too short")
+	}
+
+	chunk := WorkloadChunk{
+		Index:    binary.BigEndian.Uint32(data[0:]),
+		Worker:   binary.BigEndian.Uint32(data[4:]),
+		Interval: binary.BigEndian.Uint32(data[8:]),
+	}
+
+	body := data[workloadChunkHeaderLength:]
+	switch compression {
+	case CompressionNone, "":
+	case CompressionDeflate:
+		r := flate.NewReader(bytes.NewReader(body))
+		decompressed, err := ioutil.ReadAll(r)
+		if err != nil {
+			return WorkloadChunk{}, fmt.Errorf("workload chunk %d: %s", chunk.Index, err.Error())
+		}
+		body = decompressed
+	default:
+		return WorkloadChunk{}, fmt.Errorf("unknown workload compression %s", compression)
+	}
+
+	count, n := binary.Uvarint(body)
+	if n <= 0 || count > uint64(len(body)) {
+		return WorkloadChunk{}, fmt.Errorf("workload chunk %d: invalid number of transactions", chunk.Index)
+	}
+	body = body[n:]
+
+	chunk.Txs = make([][]byte, count)
+	for i := range chunk.Txs {
+		length, n := binary.Uvarint(body)
+		if n <= 0 || length > uint64(len(body)-n) {
+			return WorkloadChunk{}, fmt.Errorf("workload chunk %d: transaction %d truncated", chunk.Index, i)
+		}
+		chunk.Txs[i] = body[n : n+int(length)]
+		body = body[n+int(length):]
+	}
+
+	if len(body) != 0 {
+		return WorkloadChunk{}, fmt.Errorf("workload chunk %d: %d trailing bytes", chunk.Index, len(body))
+	}
+
+	return chunk, nil
+}
+
+// WorkloadDigest returns the SHA-256 digest of the workload of a secondary,
+// and its number of transactions. The same workload has the same digest
+// whether it was generated by the primary or by the secondary.
+func WorkloadDigest(workload workloadgenerators.SecondaryWorkload) (string, int) {
+	h := sha256.New()
+	varint := make([]byte, binary.MaxVarintLen64)
+	transactions := 0
 
-	err := json.Unmarshal(data, &decodedWorkload)
+	for _, intervals := range workload {
+		h.Write(varint[:binary.PutUvarint(varint, uint64(len(intervals)))])
+		for _, txs := range intervals {
+			h.Write(varint[:binary.PutUvarint(varint, uint64(len(txs)))])
+			for _, tx := range txs {
+				h.Write(varint[:binary.PutUvarint(varint, uint64(len(tx)))])
+				h.Write(tx)
+			}
+			transactions += len(txs)
+		}
+	}
 
-	return decodedWorkload, err
+	return hex.EncodeToString(h.Sum(nil)), transactions
 }
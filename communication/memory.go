@@ -0,0 +1,373 @@
+package communication
+
+import (
+	"context"
+	"diablo-benchmark/blockchains/workloadgenerators"
+	"diablo-benchmark/core/configs"
+	"diablo-benchmark/core/results"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// errMemoryClosed is returned once the memory transport of a secondary is closed
+var errMemoryClosed = errors.New("memory transport closed")
+
+// memoryReply is the reply of a secondary in the same process to a command
+type memoryReply struct {
+	generated GenerateReply     // Digest of the workload, for MsgGenerate
+	results   []results.Results // Results of the workers, for MsgResults
+	err       error             // Error of the command, nil if it succeeded
+}
+
+// MemoryTransport is the transport of the primary to the secondaries in the
+// same process. The commands and the replies are passed over channels as they
+// are, there is no encoding, clock or reconnection.
+type MemoryTransport struct {
+	AbortFailureRate float64                // Fraction of failed transactions above which the run is aborted, 0 never aborts
+	abortReason      string                 // Why the last run was aborted, empty if it was not
+	telemetry        *results.LiveTelemetry // Progress of the secondaries during the run
+	secondaries      []*MemorySecondary     // Transport of each secondary, by ID
+}
+
+// MemorySecondary is the transport of a secondary to the primary in the same
+// process, it is given by MemoryTransport.
+type MemorySecondary struct {
+	commands  chan Command           // Commands of the primary
+	replies   chan memoryReply       // Replies to the commands
+	telemetry chan results.Telemetry // Latest progress of the run, dropped if not read
+	closed    chan struct{}          // Closed with the transport
+	closeOnce sync.Once
+}
+
+// NewMemoryTransport returns the transport of the primary to the given number
+// of secondaries in the same process.
+func NewMemoryTransport(secondaries int) *MemoryTransport {
+	t := &MemoryTransport{}
+	for i := 0; i < secondaries; i++ {
+		t.secondaries = append(t.secondaries, &MemorySecondary{
+			commands:  make(chan Command),
+			replies:   make(chan memoryReply),
+			telemetry: make(chan results.Telemetry, 1),
+			closed:    make(chan struct{}),
+		})
+	}
+
+	return t
+}
+
+// Secondary returns the transport of the secondary to the primary
+func (t *MemoryTransport) Secondary(i int) *MemorySecondary {
+	return t.secondaries[i]
+}
+
+// exchange sends the command to the secondary and waits for its reply. The
+// telemetry sent in the meantime is given to onTelemetry, or ignored if it is
+// nil.
+func (t *MemoryTransport) exchange(secondary int, cmd Command, onTelemetry func(results.Telemetry)) (memoryReply, error) {
+	s := t.secondaries[secondary]
+	select {
+	case s.commands <- cmd:
+	case <-s.closed:
+		return memoryReply{}, fmt.Errorf("secondary %d: %w", secondary, errMemoryClosed)
+	}
+
+	for {
+		select {
+		case reply := <-s.replies:
+			if reply.err != nil {
+				return reply, fmt.Errorf("secondary %d: %w", secondary, reply.err)
+			}
+			return reply, nil
+		case progress := <-s.telemetry:
+			if onTelemetry != nil {
+				onTelemetry(progress)
+			}
+		case <-s.closed:
+			return memoryReply{}, fmt.Errorf("secondary %d: %w", secondary, errMemoryClosed)
+		}
+	}
+}
+
+// HandleSecondaries sends true on the channel, the secondaries are created
+// with the transport.
+func (t *MemoryTransport) HandleSecondaries(readyChannel chan bool) {
+	readyChannel <- true
+}
+
+// NumSecondaries returns the number of secondaries
+func (t *MemoryTransport) NumSecondaries() int {
+	return len(t.secondaries)
+}
+
+// PrepareBenchmarkSecondaries assigns the secondaries their ID and number of
+// workers. Their clock is the clock of the primary.
+func (t *MemoryTransport) PrepareBenchmarkSecondaries(numThreads uint32) SecondaryReplyErrors {
+	var errorList SecondaryReplyErrors
+	for i := range t.secondaries {
+		cmd := Command{Type: MsgPrepare, Prepare: PrepareMessage{SecondaryID: uint32(i), Threads: numThreads}}
+		if _, err := t.exchange(i, cmd, nil); err != nil {
+			errorList = append(errorList, err.Error())
+		}
+	}
+
+	return errorList
+}
+
+// SendWorkload gives each secondary its workload
+func (t *MemoryTransport) SendWorkload(workloads workloadgenerators.Workload) SecondaryReplyErrors {
+	var errorList SecondaryReplyErrors
+	for i := range t.secondaries {
+		if _, err := t.exchange(i, Command{Type: MsgWorkload, Workload: workloads[i]}, nil); err != nil {
+			errorList = append(errorList, err.Error())
+			continue
+		}
+
+		digest, transactions := WorkloadDigest(workloads[i])
+		zap.L().Info("Workload sent",
+			zap.Int("secondary", i),
+			zap.Int("transactions", transactions),
+			zap.String("digest", digest))
+	}
+
+	return errorList
+}
+
+// SendPartitions has each secondary generate its workload from its partition,
+// the secondaries generate their workloads in parallel.
+func (t *MemoryTransport) SendPartitions(bench *configs.BenchConfig, partitions [][]byte) SecondaryReplyErrors {
+	if len(partitions) != len(t.secondaries) {
+		return SecondaryReplyErrors{fmt.Sprintf("%d partitions for %d secondaries", len(partitions), len(t.secondaries))}
+	}
+
+	errs := make([]error, len(partitions))
+	var wg sync.WaitGroup
+	for i := range partitions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			cmd := Command{Type: MsgGenerate, Generate: GenerateMessage{Bench: bench, Partition: partitions[i]}}
+			reply, err := t.exchange(i, cmd, nil)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			zap.L().Info("Workload generated",
+				zap.Int("secondary", i),
+				zap.Int("transactions", reply.generated.Transactions),
+				zap.String("digest", reply.generated.Digest))
+		}(i)
+	}
+	wg.Wait()
+
+	var errorList SecondaryReplyErrors
+	for _, err := range errs {
+		if err != nil {
+			errorList = append(errorList, err.Error())
+		}
+	}
+
+	return errorList
+}
+
+// RunBenchmark runs the benchmark on all secondaries and returns once all of
+// them are done. The run is aborted like over TCP, when the context is
+// cancelled or the failure rate is too high.
+func (t *MemoryTransport) RunBenchmark(ctx context.Context) SecondaryReplyErrors {
+	zap.L().Info("\n------------\nStarting Benchmark\n------------\n")
+
+	// All the secondaries start at the same time, on the clock of the primary
+	start := time.Now().Add(startLead)
+	zap.L().Info("Benchmark start",
+		zap.Time("start", start))
+
+	t.telemetry = results.NewLiveTelemetry(len(t.secondaries))
+	errCh := make(chan error, len(t.secondaries))
+	for i := range t.secondaries {
+		go func(i int) {
+			onTelemetry := func(progress results.Telemetry) { t.telemetry.Update(i, progress) }
+			_, err := t.exchange(i, Command{Type: MsgRun, Run: RunMessage{StartTime: start.UnixNano()}}, onTelemetry)
+			errCh <- err
+		}(i)
+	}
+
+	// Show the progress until all the secondaries are done
+	view := time.NewTicker(telemetryViewInterval)
+	defer view.Stop()
+
+	t.abortReason = ""
+	interrupted := ctx.Done()
+	var errorList SecondaryReplyErrors
+	for numberDone := 0; numberDone < len(t.secondaries); {
+		select {
+		case err := <-errCh:
+			numberDone++
+			if err != nil {
+				errorList = append(errorList, err.Error())
+			}
+		case <-view.C:
+			zap.L().Info(t.telemetry.View())
+			if t.abortReason == "" {
+				if reason := failureRateExceeded(t.AbortFailureRate, t.telemetry.Total()); reason != "" {
+					t.abort(reason)
+				}
+			}
+		case <-interrupted:
+			interrupted = nil
+			if t.abortReason == "" {
+				t.abort("benchmark interrupted")
+			}
+		}
+	}
+
+	return errorList
+}
+
+// abort tells all the secondaries to stop the running benchmark, they then
+// reply to MsgRun.
+func (t *MemoryTransport) abort(reason string) {
+	zap.L().Error("Aborting the benchmark",
+		zap.String("reason", reason))
+	t.abortReason = reason
+
+	for _, s := range t.secondaries {
+		select {
+		case s.commands <- Command{Type: MsgAbort}:
+		case <-s.closed:
+		}
+	}
+}
+
+// AbortReason returns why the last run was aborted, empty if it was not
+func (t *MemoryTransport) AbortReason() string {
+	return t.abortReason
+}
+
+// GetResults returns the results of the workers of each secondary, by
+// secondary ID. The results of the secondaries failing to reply are empty.
+func (t *MemoryTransport) GetResults() ([][]results.Results, SecondaryReplyErrors) {
+	allResults := make([][]results.Results, len(t.secondaries))
+	var errorList SecondaryReplyErrors
+	for i := range t.secondaries {
+		reply, err := t.exchange(i, Command{Type: MsgResults}, nil)
+		if err != nil {
+			errorList = append(errorList, err.Error())
+			continue
+		}
+
+		allResults[i] = reply.results
+	}
+
+	return allResults, errorList
+}
+
+// ClockOffsets returns no offset and no round trip time for each secondary,
+// they share the clock of the primary.
+func (t *MemoryTransport) ClockOffsets() ([]time.Duration, []time.Duration) {
+	return make([]time.Duration, len(t.secondaries)), make([]time.Duration, len(t.secondaries))
+}
+
+// SecondaryHealth returns no secondary, they cannot lose their connection
+func (t *MemoryTransport) SecondaryHealth() (degraded []int, lost []int) {
+	return nil, nil
+}
+
+// SendFin tells the secondaries the benchmark is finished
+func (t *MemoryTransport) SendFin() {
+	for i := range t.secondaries {
+		_, _ = t.exchange(i, Command{Type: MsgFin}, nil)
+	}
+}
+
+// CloseSecondaries closes the transports of the secondaries, the commands
+// they wait for then fail.
+func (t *MemoryTransport) CloseSecondaries() {
+	for _, s := range t.secondaries {
+		s.CloseConn()
+	}
+}
+
+// Close does nothing, the secondaries are not accepted
+func (t *MemoryTransport) Close() {}
+
+// ReadCommand waits for the next command of the primary
+func (s *MemorySecondary) ReadCommand() (Command, error) {
+	select {
+	case cmd := <-s.commands:
+		return cmd, nil
+	case <-s.closed:
+		return Command{}, errMemoryClosed
+	}
+}
+
+// reply gives the reply to the primary waiting for it
+func (s *MemorySecondary) reply(reply memoryReply) {
+	select {
+	case s.replies <- reply:
+	case <-s.closed:
+	}
+}
+
+// ReplyPrepare replies to MsgPrepare
+func (s *MemorySecondary) ReplyPrepare(err error) {
+	s.reply(memoryReply{err: err})
+}
+
+// ReplyWorkload replies to MsgWorkload
+func (s *MemorySecondary) ReplyWorkload(err error) {
+	s.reply(memoryReply{err: err})
+}
+
+// ReplyGenerate replies to MsgGenerate with the digest of the workload
+func (s *MemorySecondary) ReplyGenerate(reply GenerateReply, err error) {
+	s.reply(memoryReply{generated: reply, err: err})
+}
+
+// ReplyRun replies to MsgRun once the run is over
+func (s *MemorySecondary) ReplyRun(err error) {
+	s.reply(memoryReply{err: err})
+}
+
+// ReplyResults replies to MsgResults with the results of the workers
+func (s *MemorySecondary) ReplyResults(res []results.Results, err error) {
+	s.reply(memoryReply{results: res, err: err})
+}
+
+// ReplyFin replies to MsgFin
+func (s *MemorySecondary) ReplyFin() {
+	s.reply(memoryReply{})
+}
+
+// ReplyERR replies the error to the command
+func (s *MemorySecondary) ReplyERR(msg string) {
+	s.reply(memoryReply{err: errors.New(msg)})
+}
+
+// SendTelemetry gives the progress of the run to the primary, it replaces
+// the progress the primary did not read yet.
+func (s *MemorySecondary) SendTelemetry(t results.Telemetry) {
+	select {
+	case <-s.telemetry:
+	default:
+	}
+
+	select {
+	case s.telemetry <- t:
+	default:
+	}
+}
+
+// Reconnect fails, the transport in memory is never lost
+func (s *MemorySecondary) Reconnect(secondaryID int) (SecondaryTransport, error) {
+	return nil, errNoReconnect
+}
+
+// CloseConn closes the transport, for the primary and the secondary
+func (s *MemorySecondary) CloseConn() {
+	s.closeOnce.Do(func() { close(s.closed) })
+}
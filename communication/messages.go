@@ -6,13 +6,127 @@
 // to the secondary and then run the secondary processes.
 package communication
 
+import (
+	"diablo-benchmark/core/configs"
+	"fmt"
+)
+
+// MessageType is the type of a message, it identifies the command sent by the
+// primary or the reply of the secondary.
+type MessageType uint8
+
 // Communication Messages
-var (
-	MsgPrepare  = []byte("\x01") // Initialise the connection
-	MsgWorkload = []byte("\x02") // Workload message
-	MsgRun      = []byte("\x03") // Start the benchmark
-	MsgResults  = []byte("\x04") // Return the result request
-	MsgFin      = []byte("\x05") // Finish and close the connection.
-	MsgOk       = []byte("\x99") // Everything is OK
-	MsgErr      = []byte("\x98") // There was an error on the client
+const (
+	MsgPrepare  MessageType = 0x01 // Initialise the connection, payload is a PrepareMessage
+	MsgWorkload MessageType = 0x02 // Start or resume the workload transfer, payload is a WorkloadHeader
+	MsgRun      MessageType = 0x03 // Start the benchmark, payload is a RunMessage
+	MsgResults  MessageType = 0x04 // Return the result request
+	MsgFin      MessageType = 0x05 // Finish and close the connection.
+	MsgOk       MessageType = 0x99 // Everything is OK, payload is the data of the reply if any
+	MsgErr      MessageType = 0x98 // There was an error on the client, payload is the error text
+
+	MsgWorkloadChunk MessageType = 0x06 // Part of the workload, payload is an encoded WorkloadChunk, not replied to
+	MsgWorkloadEnd   MessageType = 0x07 // End of the workload transfer, replied with a WorkloadProgress
+	MsgClock         MessageType = 0x08 // Read the clock of the secondary, replied with its time in unix nanoseconds
+	MsgTelemetry     MessageType = 0x09 // Progress of the secondary during the run, payload is a results.Telemetry, not replied to
+	MsgAbort         MessageType = 0x0a // Stop the running benchmark, the secondary then replies to MsgRun, not replied to
+	MsgReconnect     MessageType = 0x0b // First message of a secondary reconnecting, payload is a ReconnectMessage
+	MsgGenerate      MessageType = 0x0c // Generate the workload of the secondary, payload is a GenerateMessage
+	MsgAuth          MessageType = 0x0d // Prove the token before taking the place of a secondary, payload is an AuthMessage
 )
+
+// String returns the name of the message type
+func (t MessageType) String() string {
+	switch t {
+	case MsgPrepare:
+		return "PREPARE"
+	case MsgWorkload:
+		return "WORKLOAD"
+	case MsgRun:
+		return "RUN"
+	case MsgResults:
+		return "RESULTS"
+	case MsgFin:
+		return "FIN"
+	case MsgWorkloadChunk:
+		return "WORKLOAD_CHUNK"
+	case MsgWorkloadEnd:
+		return "WORKLOAD_END"
+	case MsgClock:
+		return "CLOCK"
+	case MsgTelemetry:
+		return "TELEMETRY"
+	case MsgAbort:
+		return "ABORT"
+	case MsgReconnect:
+		return "RECONNECT"
+	case MsgGenerate:
+		return "GENERATE"
+	case MsgAuth:
+		return "AUTH"
+	case MsgOk:
+		return "OK"
+	case MsgErr:
+		return "ERR"
+	default:
+		return fmt.Sprintf("UNKNOWN(0x%02x)", uint8(t))
+	}
+}
+
+// PrepareMessage is the payload of MsgPrepare, it assigns the secondary its
+// ID and number of workers.
+type PrepareMessage struct {
+	SecondaryID uint32 `json:"secondaryID"` // ID of the secondary
+	Threads     uint32 `json:"threads"`     // Number of workers of the secondary
+}
+
+// AuthMessage is the payload of MsgAuth, sent by a primary with a token to a
+// new connection before it takes the place of a secondary.
+type AuthMessage struct {
+	Nonce      string `json:"nonce"`      // Random nonce, new for every connection
+	Connection uint32 `json:"connection"` // Number of the connection on the primary
+}
+
+// AuthReply is the payload of the reply to MsgAuth
+type AuthReply struct {
+	Proof string `json:"proof"` // TokenProof of the nonce and the connection
+}
+
+// WorkloadHeader is the payload of MsgWorkload. It describes the workload of
+// the secondary sent in the following MsgWorkloadChunk messages.
+type WorkloadHeader struct {
+	ID          string   `json:"id"`          // Identifies the workload, a transfer of the same workload resumes
+	Intervals   []uint32 `json:"intervals"`   // Number of intervals of each worker
+	Chunks      uint32   `json:"chunks"`      // Number of chunks of the workload
+	Compression string   `json:"compression"` // Compression of the chunks
+}
+
+// WorkloadProgress is the reply of the secondary to MsgWorkload and
+// MsgWorkloadEnd with the number of chunks it has.
+type WorkloadProgress struct {
+	Received uint32 `json:"received"` // Number of chunks received, the transfer resumes after them
+}
+
+// GenerateMessage is the payload of MsgGenerate, the secondary generates its
+// workload from the benchmark configuration and its partition.
+type GenerateMessage struct {
+	Bench     *configs.BenchConfig `json:"bench"`     // Benchmark configuration of the primary
+	Partition []byte               `json:"partition"` // Partition of the workload generator for the secondary
+}
+
+// GenerateReply is the reply of the secondary to MsgGenerate
+type GenerateReply struct {
+	Transactions int    `json:"transactions"` // Number of transactions generated
+	Digest       string `json:"digest"`       // WorkloadDigest of the workload generated
+}
+
+// RunMessage is the payload of MsgRun
+type RunMessage struct {
+	StartTime int64 `json:"startTime"` // Time to start the benchmark on the clock of the secondary (unix ns)
+}
+
+// ReconnectMessage is the payload of MsgReconnect, sent by a secondary that
+// lost its connection to take its place again.
+type ReconnectMessage struct {
+	SecondaryID uint32 `json:"secondaryID"` // ID given to the secondary by MsgPrepare
+}
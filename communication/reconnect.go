@@ -0,0 +1,257 @@
+package communication
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ReconnectTimeout is how long the primary waits for a secondary to reconnect
+// after losing its connection, and how long the secondary tries to.
+const ReconnectTimeout = 30 * time.Second
+
+// reconnectRetry is the interval between the connection attempts of a secondary
+const reconnectRetry = time.Second
+
+// reconnectHandshakeTimeout bounds the wait for the first message of a new connection
+const reconnectHandshakeTimeout = 5 * time.Second
+
+// handleReconnection reads the ID of the secondary reconnecting and gives the
+// connection to the exchange waiting for it. The secondary must prove it has
+// the token again, and is only accepted while the primary waits for it.
+func (s *PrimaryServer) handleReconnection(c net.Conn, connection uint32) {
+	_ = c.SetReadDeadline(time.Now().Add(reconnectHandshakeTimeout))
+	frame, err := ReadFrameLimit(c, maxAuthLength)
+	_ = c.SetReadDeadline(time.Time{})
+
+	if err == nil && frame.Type != MsgReconnect {
+		err = fmt.Errorf("expected %s from a new connection, got %s", MsgReconnect, frame.Type)
+	}
+
+	var msg ReconnectMessage
+	if err == nil {
+		err = json.Unmarshal(frame.Payload, &msg)
+	}
+
+	if err == nil && int(msg.SecondaryID) >= len(s.reconnects) {
+		err = fmt.Errorf("unknown secondary %d", msg.SecondaryID)
+	}
+
+	if err == nil && !s.isWaiting(int(msg.SecondaryID)) {
+		err = fmt.Errorf("secondary %d is not expected to reconnect", msg.SecondaryID)
+	}
+
+	if err == nil {
+		err = s.authenticate(c, connection)
+	}
+
+	if err != nil {
+		zap.L().Warn("Rejected connection",
+			zap.String("addr", c.RemoteAddr().String()),
+			zap.Error(err))
+		_ = WriteFrame(c, MsgErr, []byte(err.Error()))
+		_ = c.Close()
+		return
+	}
+
+	// Only the latest connection of the secondary is kept, and only while
+	// the primary waits for it
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+	if !s.waiting[msg.SecondaryID] {
+		_ = WriteFrame(c, MsgErr, []byte(fmt.Sprintf("secondary %d is not expected to reconnect", msg.SecondaryID)))
+		_ = c.Close()
+		return
+	}
+
+	if err := WriteFrame(c, MsgOk, nil); err != nil {
+		_ = c.Close()
+		return
+	}
+
+	zap.L().Info("Secondary reconnected",
+		zap.Uint32("secondary", msg.SecondaryID),
+		zap.String("addr", c.RemoteAddr().String()))
+
+	select {
+	case old := <-s.reconnects[msg.SecondaryID]:
+		_ = old.Close()
+	default:
+	}
+	s.reconnects[msg.SecondaryID] <- c
+}
+
+// isWaiting returns true if the primary waits for the secondary to reconnect
+func (s *PrimaryServer) isWaiting(secondary int) bool {
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+	return s.waiting[secondary]
+}
+
+// conn returns the current connection to the secondary
+func (s *PrimaryServer) conn(secondary int) net.Conn {
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+	return s.Secondaries[secondary]
+}
+
+// reconnect waits for the secondary to reconnect after the communication
+// failure and returns its new connection, the secondary is then degraded.
+// Errors replied by the secondary are returned as they are.
+func (s *PrimaryServer) reconnect(secondary int, cause error) (net.Conn, error) {
+	if _, ok := cause.(*SecondaryCommError); !ok || secondary >= len(s.reconnects) {
+		return nil, cause
+	}
+
+	timeout := s.ReconnectTimeout
+	if timeout == 0 {
+		timeout = ReconnectTimeout
+	}
+
+	zap.L().Warn("Lost connection to secondary, waiting for it to reconnect",
+		zap.Int("secondary", secondary),
+		zap.Error(cause),
+		zap.Duration("timeout", timeout))
+
+	// Make sure the secondary notices the failure
+	s.connLock.Lock()
+	_ = s.Secondaries[secondary].Close()
+	s.waiting[secondary] = true
+	s.connLock.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case c := <-s.reconnects[secondary]:
+		s.connLock.Lock()
+		s.Secondaries[secondary] = c
+		s.degraded[secondary] = true
+		s.waiting[secondary] = false
+		s.connLock.Unlock()
+		return c, nil
+	case <-timer.C:
+		// A connection queued since the timeout is not used
+		s.connLock.Lock()
+		s.waiting[secondary] = false
+		select {
+		case c := <-s.reconnects[secondary]:
+			_ = c.Close()
+		default:
+		}
+		s.connLock.Unlock()
+		return nil, fmt.Errorf("secondary %d did not reconnect within %s: %w", secondary, timeout, cause)
+	}
+}
+
+// markLost records that the results of the secondary cannot be collected
+func (s *PrimaryServer) markLost(secondary int) {
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+	if secondary < len(s.lost) {
+		s.lost[secondary] = true
+	}
+}
+
+// isLost returns true if the secondary did not reconnect in time
+func (s *PrimaryServer) isLost(secondary int) bool {
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+	return secondary < len(s.lost) && s.lost[secondary]
+}
+
+// SecondaryHealth returns the secondaries that reconnected during the
+// benchmark, and the ones whose results could not be collected.
+func (s *PrimaryServer) SecondaryHealth() (degraded []int, lost []int) {
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+
+	for i := range s.degraded {
+		if s.degraded[i] {
+			degraded = append(degraded, i)
+		}
+		if s.lost[i] {
+			lost = append(lost, i)
+		}
+	}
+
+	return degraded, lost
+}
+
+// ReconnectSecondary connects the secondary to the primary again after its
+// connection was lost, and takes its place with its ID. The token proves the
+// secondary may take it, if the primary has one.
+func ReconnectSecondary(dial Dialer, msg ReconnectMessage, token string) (*ConnClient, error) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(ReconnectTimeout)
+	for {
+		c, err := reconnectOnce(dial, payload, token)
+		if err == nil {
+			return c, nil
+		}
+
+		if time.Now().Add(reconnectRetry).After(deadline) {
+			return nil, fmt.Errorf("failed to reconnect to the primary within %s: %w", ReconnectTimeout, err)
+		}
+
+		zap.L().Warn("Failed to reconnect to the primary, retrying",
+			zap.Error(err))
+		time.Sleep(reconnectRetry)
+	}
+}
+
+// reconnectOnce dials the primary, sends the reconnection message and proves
+// the token if the primary asks for it
+func reconnectOnce(dial Dialer, payload []byte, token string) (*ConnClient, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := WriteFrame(conn, MsgReconnect, payload); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(reconnectHandshakeTimeout))
+	reply, err := ReadFrame(conn)
+	if err == nil && reply.Type == MsgAuth {
+		reply, err = proveToken(conn, reply.Payload, token)
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	if err == nil && reply.Type != MsgOk {
+		err = fmt.Errorf("primary rejected the reconnection: %s", string(reply.Payload))
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return &ConnClient{Conn: conn, dial: dial, token: token}, nil
+}
+
+// proveToken replies to the MsgAuth of the primary and returns its answer
+func proveToken(conn net.Conn, payload []byte, token string) (Frame, error) {
+	var auth AuthMessage
+	if err := json.Unmarshal(payload, &auth); err != nil {
+		return Frame{}, err
+	}
+
+	proof, err := json.Marshal(AuthReply{Proof: TokenProof(token, auth.Nonce, auth.Connection)})
+	if err != nil {
+		return Frame{}, err
+	}
+
+	if err := WriteFrame(conn, MsgOk, proof); err != nil {
+		return Frame{}, err
+	}
+
+	return ReadFrame(conn)
+}
@@ -0,0 +1,225 @@
+package communication
+
+import (
+	"context"
+	"diablo-benchmark/core/results"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// connectOne starts a primary on a local port and connects one secondary
+func connectOne(t *testing.T) (*PrimaryServer, *ConnClient) {
+	s, err := SetupPrimaryTCP("127.0.0.1:0", 1, Security{})
+	if err != nil {
+		t.Fatalf("failed to start primary: %s", err.Error())
+	}
+
+	ready := make(chan bool, 1)
+	go s.HandleSecondaries(ready)
+
+	c, err := SetupSecondaryTCP(s.Listener.Addr().String(), Security{})
+	if err != nil {
+		t.Fatalf("failed to connect secondary: %s", err.Error())
+	}
+
+	if !<-ready {
+		t.Fatalf("expected the secondary to be connected")
+	}
+
+	return s, c
+}
+
+// closePrimary closes the connections and the listener of the primary
+func closePrimary(s *PrimaryServer) {
+	s.CloseSecondaries()
+	s.Close()
+}
+
+// dropAndReconnect reads the command expected, closes the connection and
+// reconnects as the secondary 0.
+func dropAndReconnect(s *PrimaryServer, c *ConnClient, expected MessageType) *ConnClient {
+	if cmd, err := c.ReadCommand(); err != nil || cmd.Type != expected {
+		return nil
+	}
+	c.CloseConn()
+
+	c, err := ReconnectSecondary(TCPDialer(s.Listener.Addr().String(), Security{}), ReconnectMessage{}, "")
+	if err != nil {
+		return nil
+	}
+
+	return c
+}
+
+func TestReconnectDuringRun(t *testing.T) {
+	s, c := connectOne(t)
+	defer closePrimary(s)
+
+	go func() {
+		if c := dropAndReconnect(s, c, MsgRun); c != nil {
+			// The run completes while disconnected
+			c.ReplyOK()
+		}
+	}()
+
+	if errs := s.RunBenchmark(context.Background()); len(errs) != 0 {
+		t.Fatalf("expected the reply after the reconnection, got %v", errs)
+	}
+
+	if degraded, lost := s.SecondaryHealth(); !reflect.DeepEqual(degraded, []int{0}) || len(lost) != 0 {
+		t.Errorf("expected secondary 0 to be degraded, got %v (lost %v)", degraded, lost)
+	}
+}
+
+func TestReconnectDeliversResults(t *testing.T) {
+	s, c := connectOne(t)
+	defer closePrimary(s)
+
+	go func() {
+		c := dropAndReconnect(s, c, MsgResults)
+		if c == nil {
+			return
+		}
+
+		// The results are asked again on the new connection
+		if cmd, err := c.ReadCommand(); err != nil || cmd.Type != MsgResults {
+			return
+		}
+		data, _ := json.Marshal([]results.Results{{Success: 3}})
+		c.SendDataOK(data)
+	}()
+
+	res, errs := s.GetResults()
+	if len(errs) != 0 {
+		t.Fatalf("expected the results after the reconnection, got %v", errs)
+	}
+
+	if len(res) != 1 || len(res[0]) != 1 || res[0][0].Success != 3 {
+		t.Errorf("expected the results of the secondary, got %v", res)
+	}
+
+	if degraded, _ := s.SecondaryHealth(); !reflect.DeepEqual(degraded, []int{0}) {
+		t.Errorf("expected secondary 0 to be degraded, got %v", degraded)
+	}
+}
+
+func TestReconnectTimeout(t *testing.T) {
+	s, c := connectOne(t)
+	defer closePrimary(s)
+	s.ReconnectTimeout = 200 * time.Millisecond
+
+	go func() {
+		if cmd, err := c.ReadCommand(); err == nil && cmd.Type == MsgResults {
+			c.CloseConn()
+		}
+	}()
+
+	res, errs := s.GetResults()
+	if len(errs) != 1 || len(res) != 1 || len(res[0]) != 0 {
+		t.Fatalf("expected the secondary to be lost, got %v (results %v)", errs, res)
+	}
+
+	if degraded, lost := s.SecondaryHealth(); len(degraded) != 0 || !reflect.DeepEqual(lost, []int{0}) {
+		t.Errorf("expected secondary 0 to be lost, got %v (degraded %v)", lost, degraded)
+	}
+}
+
+func TestReconnectTimeoutDuringRun(t *testing.T) {
+	s, c := connectOne(t)
+	defer closePrimary(s)
+	s.ReconnectTimeout = 200 * time.Millisecond
+
+	go func() {
+		if cmd, err := c.ReadCommand(); err == nil && cmd.Type == MsgRun {
+			c.CloseConn()
+		}
+	}()
+
+	if errs := s.RunBenchmark(context.Background()); len(errs) != 1 {
+		t.Fatalf("expected the run to fail, got %v", errs)
+	}
+	if _, lost := s.SecondaryHealth(); !reflect.DeepEqual(lost, []int{0}) {
+		t.Errorf("expected secondary 0 to be lost, got %v", lost)
+	}
+
+	// The results of the lost secondary are not waited for again
+	start := time.Now()
+	res, errs := s.GetResults()
+	if len(errs) != 1 || len(res) != 1 || len(res[0]) != 0 {
+		t.Errorf("expected no results of the lost secondary, got %v (errors %v)", res, errs)
+	}
+	if time.Since(start) >= s.ReconnectTimeout {
+		t.Errorf("expected the lost secondary to be skipped, took %s", time.Since(start))
+	}
+}
+
+func TestReconnectUnknownSecondary(t *testing.T) {
+	s, _ := connectOne(t)
+	defer closePrimary(s)
+
+	payload, _ := json.Marshal(ReconnectMessage{SecondaryID: 4})
+	if _, err := reconnectOnce(TCPDialer(s.Listener.Addr().String(), Security{}), payload, ""); err == nil {
+		t.Errorf("expected the reconnection of an unknown secondary to be rejected")
+	}
+}
+
+func TestReconnectNotWaiting(t *testing.T) {
+	s, _ := connectOne(t)
+	defer closePrimary(s)
+
+	// The primary did not lose the connection of the secondary
+	payload, _ := json.Marshal(ReconnectMessage{SecondaryID: 0})
+	if _, err := reconnectOnce(TCPDialer(s.Listener.Addr().String(), Security{}), payload, ""); err == nil {
+		t.Errorf("expected a reconnection the primary does not wait for to be rejected")
+	}
+}
+
+func TestReconnectToken(t *testing.T) {
+	s, err := SetupPrimaryTCP("127.0.0.1:0", 1, Security{Token: "secret"})
+	if err != nil {
+		t.Fatalf("failed to start primary: %s", err.Error())
+	}
+	defer closePrimary(s)
+	s.ReconnectTimeout = 5 * time.Second
+
+	ready := make(chan bool, 1)
+	go s.HandleSecondaries(ready)
+
+	dialer := TCPDialer(s.Listener.Addr().String(), Security{})
+	c, err := ConnectSecondary(dialer, "")
+	if err != nil {
+		t.Fatalf("failed to connect secondary: %s", err.Error())
+	}
+	answerAuth(c.Conn, func(auth AuthMessage) string {
+		return TokenProof("secret", auth.Nonce, auth.Connection)
+	})
+	if !<-ready {
+		t.Fatalf("expected the secondary to be connected")
+	}
+
+	go func() {
+		if cmd, err := c.ReadCommand(); err != nil || cmd.Type != MsgRun {
+			return
+		}
+		c.CloseConn()
+		for !s.isWaiting(0) {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		// Another token cannot take the place of the secondary
+		payload, _ := json.Marshal(ReconnectMessage{SecondaryID: 0})
+		if _, err := reconnectOnce(dialer, payload, "guess"); err == nil {
+			t.Errorf("expected the reconnection with another token to be rejected")
+		}
+
+		if c, err := ReconnectSecondary(dialer, ReconnectMessage{SecondaryID: 0}, "secret"); err == nil {
+			c.ReplyOK()
+		}
+	}()
+
+	if errs := s.RunBenchmark(context.Background()); len(errs) != 0 {
+		t.Fatalf("expected the reply after the reconnection, got %v", errs)
+	}
+}
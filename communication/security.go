@@ -0,0 +1,224 @@
+package communication
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+)
+
+// handshakeTimeout bounds the TLS handshake of a new connection
+const handshakeTimeout = 10 * time.Second
+
+// nonceSize is the number of random bytes of the nonce sent in MsgAuth
+const nonceSize = 32
+
+// maxAuthLength bounds the messages read from a peer before it is authenticated
+const maxAuthLength uint64 = 4 << 10
+
+// Security configures the authentication of the connections between the
+// primary and the secondaries. Both are optional, without them any host
+// reaching the primary can join the benchmark.
+type Security struct {
+	TLS   *tls.Config // Mutual TLS of the connections, nil for plain TCP
+	Token string      // Token shared by the primary and the secondaries, empty for none
+}
+
+// LoadSecurity loads the certificate and key used for mutual TLS, if given,
+// and keeps the token. The certificate file is also the authority trusted to
+// verify the peer: the primary and secondaries use the same self-signed
+// certificate, or certificates signed by the authority appended to the file.
+func LoadSecurity(certFile string, keyFile string, token string) (Security, error) {
+	security := Security{Token: token}
+
+	if certFile == "" && keyFile == "" {
+		return security, nil
+	}
+
+	if certFile == "" || keyFile == "" {
+		return security, errors.New("both the TLS certificate and key are required")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return security, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	pemCerts, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return security, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemCerts) {
+		return security, fmt.Errorf("no certificate found in %s", certFile)
+	}
+
+	security.TLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAnyClientCert,
+		// The secondaries connect by address, so the peer is verified against
+		// the trusted certificates without its host name
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: verifyPeer(pool),
+		MinVersion:            tls.VersionTLS12,
+	}
+
+	return security, nil
+}
+
+// verifyPeer returns the verification of the certificate chain of the peer
+// against the trusted certificates.
+func verifyPeer(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("no certificate from the peer")
+		}
+
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs[i] = cert
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		return err
+	}
+}
+
+// dial connects to the primary, with TLS if configured
+func dial(addr string, security Security, timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if security.TLS == nil {
+		return conn, nil
+	}
+
+	tlsConn := tls.Client(conn, security.TLS)
+	if err := handshake(tlsConn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// handshake completes the TLS handshake of the connection, so that the
+// connections failing it are not counted as secondaries. Other connections
+// are left as they are.
+func handshake(c net.Conn) error {
+	tlsConn, ok := c.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+
+	_ = tlsConn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer func() { _ = tlsConn.SetDeadline(time.Time{}) }()
+
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("TLS handshake failed: %w", err)
+	}
+
+	return nil
+}
+
+// newNonce returns a random nonce for the secondary to prove it has the token
+func newNonce() (string, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(nonce), nil
+}
+
+// TokenProof returns the proof that the secondary has the token, the HMAC of
+// the nonce and the number of the connection given by the primary with the
+// token. The token itself is never sent, and a proof is only valid for the
+// connection it was asked on.
+func TokenProof(token string, nonce string, connection uint32) string {
+	id := make([]byte, 4)
+	binary.BigEndian.PutUint32(id, connection)
+
+	mac := hmac.New(sha256.New, []byte(token))
+	_, _ = mac.Write([]byte(nonce))
+	_, _ = mac.Write(id)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validProof returns whether the proof was computed with the token
+func validProof(token string, nonce string, connection uint32, proof string) bool {
+	expected, _ := hex.DecodeString(TokenProof(token, nonce, connection))
+	given, err := hex.DecodeString(proof)
+	return err == nil && hmac.Equal(expected, given)
+}
+
+// authenticate checks that the peer of a new connection has the token before
+// it takes the place of a secondary: the primary sends MsgAuth with a fresh
+// nonce and the number of the connection, and the secondary replies with the
+// proof of both. Any peer is accepted if the primary has no token.
+func (s *PrimaryServer) authenticate(c net.Conn, connection uint32) error {
+	if s.Token == "" {
+		return nil
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(AuthMessage{Nonce: nonce, Connection: connection})
+	if err != nil {
+		return err
+	}
+
+	_ = c.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer func() { _ = c.SetDeadline(time.Time{}) }()
+
+	if err := WriteFrame(c, MsgAuth, payload); err != nil {
+		return err
+	}
+
+	reply, err := ReadFrameLimit(c, maxAuthLength)
+	if err != nil {
+		return err
+	}
+	if reply.Type != MsgOk {
+		return fmt.Errorf("expected the proof of the token, got %s", reply.Type)
+	}
+
+	var auth AuthReply
+	if err := json.Unmarshal(reply.Payload, &auth); err != nil {
+		return err
+	}
+
+	if !validProof(s.Token, nonce, connection, auth.Proof) {
+		return fmt.Errorf("connection %d failed to authenticate: invalid token", connection)
+	}
+
+	return nil
+}
@@ -0,0 +1,235 @@
+package communication
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// selfSigned writes a new self-signed certificate and its key in the
+// directory, and returns the security using them.
+func selfSigned(t *testing.T, dir string, name string) Security {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err.Error())
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to encode key: %s", err.Error())
+	}
+
+	certFile := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+".key")
+	_ = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	_ = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+
+	security, err := LoadSecurity(certFile, keyFile, "")
+	if err != nil {
+		t.Fatalf("failed to load security: %s", err.Error())
+	}
+
+	return security
+}
+
+func TestTLSRejectsUnknownCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diablo-tls")
+	if err != nil {
+		t.Fatalf("failed to create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	trusted := selfSigned(t, dir, "trusted")
+	other := selfSigned(t, dir, "other")
+
+	s, err := SetupPrimaryTCP("127.0.0.1:0", 1, trusted)
+	if err != nil {
+		t.Fatalf("failed to start primary: %s", err.Error())
+	}
+	defer closePrimary(s)
+
+	ready := make(chan bool, 1)
+	go s.HandleSecondaries(ready)
+
+	addr := s.Listener.Addr().String()
+	if _, err := SetupSecondaryTCP(addr, other); err == nil {
+		t.Errorf("expected the secondary with another certificate to be rejected")
+	}
+	// The plain connection fails the handshake of the primary
+	if plain, err := SetupSecondaryTCP(addr, Security{}); err == nil {
+		_ = WriteFrame(plain.Conn, MsgOk, nil)
+		plain.CloseConn()
+	}
+
+	c, err := SetupSecondaryTCP(addr, trusted)
+	if err != nil {
+		t.Fatalf("expected the secondary with the certificate to connect: %s", err.Error())
+	}
+	defer c.CloseConn()
+
+	if !<-ready || len(s.Secondaries) != 1 {
+		t.Errorf("expected one secondary, got %d", len(s.Secondaries))
+	}
+}
+
+// answerAuth reads the MsgAuth of the primary and replies with the proof
+// computed by prove from the nonce and the connection
+func answerAuth(c net.Conn, prove func(AuthMessage) string) {
+	frame, err := ReadFrame(c)
+	if err != nil || frame.Type != MsgAuth {
+		return
+	}
+
+	var auth AuthMessage
+	_ = json.Unmarshal(frame.Payload, &auth)
+	payload, _ := json.Marshal(AuthReply{Proof: prove(auth)})
+	_ = WriteFrame(c, MsgOk, payload)
+}
+
+func TestTokenProof(t *testing.T) {
+	s := &PrimaryServer{Token: "secret"}
+
+	// authenticate runs the exchange with a secondary answering with prove
+	authenticate := func(prove func(AuthMessage) string) error {
+		primary, secondary := net.Pipe()
+		defer primary.Close()
+		defer secondary.Close()
+
+		go answerAuth(secondary, prove)
+		return s.authenticate(primary, 3)
+	}
+
+	for _, tc := range []struct {
+		token string
+		valid bool
+	}{
+		{"secret", true},
+		{"guess", false},
+		{"", false},
+	} {
+		err := authenticate(func(auth AuthMessage) string {
+			return TokenProof(tc.token, auth.Nonce, auth.Connection)
+		})
+		if (err == nil) != tc.valid {
+			t.Errorf("token %q: expected valid %t, got %v", tc.token, tc.valid, err)
+		}
+	}
+
+	// A proof is bound to the connection and the nonce of its exchange
+	var previous AuthMessage
+	if err := authenticate(func(auth AuthMessage) string {
+		previous = auth
+		return TokenProof("secret", auth.Nonce, 4)
+	}); err == nil {
+		t.Errorf("expected the proof of another connection to be rejected")
+	}
+	if err := authenticate(func(auth AuthMessage) string {
+		return TokenProof("secret", previous.Nonce, previous.Connection)
+	}); err == nil {
+		t.Errorf("expected the proof of a previous nonce to be rejected")
+	}
+}
+
+func TestTokenRejectsBeforeSlot(t *testing.T) {
+	s, err := SetupPrimaryTCP("127.0.0.1:0", 1, Security{Token: "secret"})
+	if err != nil {
+		t.Fatalf("failed to start primary: %s", err.Error())
+	}
+	defer closePrimary(s)
+
+	ready := make(chan bool, 1)
+	go s.HandleSecondaries(ready)
+
+	addr := s.Listener.Addr().String()
+
+	// Connections without the token do not take the place of the secondary
+	for _, token := range []string{"", "guess"} {
+		c, err := SetupSecondaryTCP(addr, Security{})
+		if err != nil {
+			t.Fatalf("failed to connect: %s", err.Error())
+		}
+		answerAuth(c.Conn, func(auth AuthMessage) string {
+			return TokenProof(token, auth.Nonce, auth.Connection)
+		})
+		if reply, err := ReadFrame(c.Conn); err != nil || reply.Type != MsgErr {
+			t.Errorf("token %q: expected the connection to be rejected, got %s (%v)", token, reply.Type, err)
+		}
+		c.CloseConn()
+	}
+
+	c, err := SetupSecondaryTCP(addr, Security{})
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err.Error())
+	}
+	defer c.CloseConn()
+	answerAuth(c.Conn, func(auth AuthMessage) string {
+		return TokenProof("secret", auth.Nonce, auth.Connection)
+	})
+
+	if !<-ready || len(s.Secondaries) != 1 {
+		t.Errorf("expected the secondary with the token to connect, got %d secondaries", len(s.Secondaries))
+	}
+}
+
+func TestStalledConnection(t *testing.T) {
+	s, err := SetupPrimaryTCP("127.0.0.1:0", 1, Security{Token: "secret"})
+	if err != nil {
+		t.Fatalf("failed to start primary: %s", err.Error())
+	}
+	defer closePrimary(s)
+
+	ready := make(chan bool, 1)
+	go s.HandleSecondaries(ready)
+
+	addr := s.Listener.Addr().String()
+
+	// A connection never answering MsgAuth does not hold back the secondary
+	stalled, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err.Error())
+	}
+	defer stalled.Close()
+
+	c, err := SetupSecondaryTCP(addr, Security{})
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err.Error())
+	}
+	defer c.CloseConn()
+	answerAuth(c.Conn, func(auth AuthMessage) string {
+		return TokenProof("secret", auth.Nonce, auth.Connection)
+	})
+
+	select {
+	case ok := <-ready:
+		if !ok || len(s.Secondaries) != 1 {
+			t.Errorf("expected the secondary with the token to connect, got %d secondaries", len(s.Secondaries))
+		}
+	case <-time.After(handshakeTimeout / 2):
+		t.Errorf("expected the secondary to connect while the other connection stalls")
+	}
+}
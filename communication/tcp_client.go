@@ -1,55 +1,102 @@
 package communication
 
 import (
-	"encoding/binary"
-	"io"
+	"diablo-benchmark/core/results"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
 )
 
 // ConnClient provides an active connection from the secondary to
 // the primary. The main action of the connection is to receive commands and to
-// reply with OK or errors and results.
+// reply with OK or errors and results. It answers the token, the clock and the
+// chunks of the workload itself, and decodes the other commands.
 type ConnClient struct {
-	Conn net.Conn // Active connection to the primary
+	Conn      net.Conn          // Active connection to the primary
+	dial      Dialer            // Connects to the primary again, nil if it cannot
+	token     string            // Token shared with the primary, empty for none
+	workload  *WorkloadReceiver // Workload being received, kept across reconnections
+	progress  WorkloadProgress  // Progress of the last workload received, the reply to it
+	writeLock sync.Mutex        // Keeps the replies and the telemetry whole
 }
 
-// READLENGTH is the amount to read on first read
-// byte 0 : cmd
-// byte 1 : aux / len
-// byte 2-9 will be the uint64
-// 3 byte (16 bit number to represent size to read of payload)
-const READLENGTH int = 9
+// ErrRejected is returned when the primary rejects the secondary, e.g. for a
+// wrong token. The secondary does not try to reconnect.
+var ErrRejected = errors.New("primary rejected the connection")
 
-// MAXREAD is the maximum value to read in a single read.
-// If the size we need to read is greater, then we need to read split amounts
-// and iterate through the reading.
-const MAXREAD uint64 = 8192
+// errNoReconnect is returned by Reconnect when the client has no dialer
+var errNoReconnect = errors.New("the connection to the primary cannot be reopened")
 
-// SetupSecondaryTCP connects to the master TCP address and return the connected client
-func SetupSecondaryTCP(addr string) (*ConnClient, error) {
+// connectTimeout bounds the connection to the primary
+const connectTimeout = 30 * time.Second
+
+// Dialer connects a secondary to the primary
+type Dialer func() (net.Conn, error)
+
+// TCPDialer returns the dialer connecting to the primary TCP address, with
+// TLS if the security configures it.
+func TCPDialer(addr string, security Security) Dialer {
+	return func() (net.Conn, error) {
+		return dial(addr, security, connectTimeout)
+	}
+}
+
+// SetupSecondaryTCP connects to the master TCP address and return the connected client.
+// The connection uses TLS and proves the token if the security configures them.
+func SetupSecondaryTCP(addr string, security Security) (*ConnClient, error) {
+	return ConnectSecondary(TCPDialer(addr, security), security.Token)
+}
+
+// ConnectSecondary connects to the primary with the dialer and returns the
+// connected client, proving the token if the primary asks for it. The client
+// reconnects with the same dialer.
+func ConnectSecondary(dial Dialer, token string) (*ConnClient, error) {
 	// Dial the address, return the error if we cannot
-	conn, err := net.Dial("tcp", addr)
+	conn, err := dial()
 
 	if err != nil {
 		return nil, err
 	}
 
 	zap.L().Debug("Connection OK",
-		zap.String("ADDR", addr),
+		zap.String("ADDR", conn.RemoteAddr().String()),
 	)
 
-	return &ConnClient{Conn: conn}, nil
+	return &ConnClient{Conn: conn, dial: dial, token: token}, nil
+}
+
+// Reconnect connects to the primary again with the dialer of the client and
+// takes the place of the secondary. The new client keeps the workload being
+// received, so that its transfer resumes.
+func (c *ConnClient) Reconnect(secondaryID int) (SecondaryTransport, error) {
+	if c.dial == nil {
+		return nil, errNoReconnect
+	}
+
+	n, err := ReconnectSecondary(c.dial, ReconnectMessage{SecondaryID: uint32(secondaryID)}, c.token)
+	if err != nil {
+		return nil, err
+	}
+
+	n.workload = c.workload
+	return n, nil
 }
 
 //////////////////////////
 // Writing Response
 //////////////////////////
 
-// ReplyOK replies with an OK, just an ACK to say we got the message and all is well
-func (c *ConnClient) ReplyOK() {
-	_, err := c.Conn.Write(MsgOk)
+// reply writes the reply frame to the primary, closing the connection on failure
+func (c *ConnClient) reply(t MessageType, payload []byte) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	err := WriteFrame(c.Conn, t, payload)
 	if err != nil {
 		zap.L().Error("Error sending reply to master",
 			zap.Error(err),
@@ -57,122 +104,216 @@ func (c *ConnClient) ReplyOK() {
 		_ = c.Conn.Close()
 		return
 	}
+}
 
+// ReplyOK replies with an OK, just an ACK to say we got the message and all is well
+func (c *ConnClient) ReplyOK() {
+	c.reply(MsgOk, nil)
 	zap.L().Debug("OK sent to master")
 }
 
-// ReplyERR replies with an error: We tried the command, but something went wrong
+// ReplyERR replies with an error: We tried the command, but something went wrong.
+// The primary receives the whole message.
 func (c *ConnClient) ReplyERR(msg string) {
-	errmsg := append(MsgErr, []byte(msg)...)
-	_, err := c.Conn.Write(errmsg)
-	if err != nil {
-		zap.L().Error("Error sending reply to master",
-			zap.Error(err),
-		)
-		_ = c.Conn.Close()
-		return
-	}
+	c.reply(MsgErr, []byte(msg))
 	zap.L().Debug("Error state sent to master")
 }
 
 // SendDataOK will send OK + DATA to the Primary
 func (c *ConnClient) SendDataOK(data []byte) {
-	// msg OK
-	payload := MsgOk
+	zap.L().Debug("Sending data to primary",
+		zap.Int("dataLen", len(data)))
 
-	// Length = uint64
-	dataLen := make([]byte, 8)
-	binary.BigEndian.PutUint64(dataLen, uint64(len(data)))
+	c.reply(MsgOk, data)
+}
 
-	payload = append(payload, dataLen...)
-	payload = append(payload, data...)
+// replyJSON replies OK with the JSON encoding of the value
+func (c *ConnClient) replyJSON(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		c.ReplyERR(err.Error())
+		return
+	}
 
-	zap.L().Debug("Sending data to primary",
-		zap.Int("dataLen", len(data)))
+	c.SendDataOK(data)
+}
+
+// replyErr replies OK if err is nil, the error otherwise
+func (c *ConnClient) replyErr(err error) {
+	if err != nil {
+		c.ReplyERR(err.Error())
+		return
+	}
+
+	c.ReplyOK()
+}
 
-	_, err := c.Conn.Write(payload)
+// ReplyPrepare replies to MsgPrepare
+func (c *ConnClient) ReplyPrepare(err error) {
+	c.replyErr(err)
+}
 
+// ReplyWorkload replies to the workload, with the chunks received if it was
+// accepted.
+func (c *ConnClient) ReplyWorkload(err error) {
 	if err != nil {
-		zap.L().Error("Error sending reply to master",
-			zap.Error(err),
-		)
-		_ = c.Conn.Close()
+		c.ReplyERR(err.Error())
 		return
 	}
+
+	c.replyJSON(c.progress)
 }
 
-//////////////////////////
-// Reading
-//////////////////////////
+// ReplyGenerate replies to MsgGenerate with the digest of the workload
+func (c *ConnClient) ReplyGenerate(reply GenerateReply, err error) {
+	if err != nil {
+		c.ReplyERR(err.Error())
+		return
+	}
+
+	c.replyJSON(reply)
+}
 
-// InitialRead performs a first read, always reads 4 bytes long
-// gets command, length to read or aux value
-func (c *ConnClient) InitialRead() ([]byte, error) {
-	buf := make([]byte, READLENGTH)
+// ReplyRun replies to MsgRun once the run is over
+func (c *ConnClient) ReplyRun(err error) {
+	c.replyErr(err)
+}
 
-	zap.L().Debug("Performing Initial Read")
-	n, err := c.Conn.Read(buf)
+// ReplyResults replies to MsgResults with the results of the workers
+func (c *ConnClient) ReplyResults(res []results.Results, err error) {
 	if err != nil {
-		return nil, err
+		c.ReplyERR(err.Error())
+		return
 	}
 
-	return buf[:n], nil
+	c.replyJSON(res)
 }
 
-// ReadSplit reads information over a number of "reads".
-// This should happen if the size of the object to read is larger than the
-// size of a full read in go.
-func (c *ConnClient) ReadSplit(totalSize uint64) ([]byte, error) {
-	fullData := make([]byte, 0)
-	readLen := 0
+// ReplyFin replies to MsgFin
+func (c *ConnClient) ReplyFin() {
+	c.ReplyOK()
+}
 
-	zap.L().Debug("Asked to read",
-		zap.Uint64("Size", totalSize))
+//////////////////////////
+// Reading
+//////////////////////////
 
-	// Loop through, iteratively reading until the EOF is reached.
+// ReadCommand reads the next command from the primary and decodes it. The
+// token, the clock and the chunks of the workload are answered here, the
+// workload is returned once all its chunks are received. Invalid commands
+// are replied with an error and skipped.
+// If the primary uses another protocol version, the command is returned with
+// a *VersionError so that the secondary can reply with the mismatch.
+func (c *ConnClient) ReadCommand() (Command, error) {
 	for {
-		buffer := make([]byte, 1024)
-		numRead, err := c.Conn.Read(buffer)
+		zap.L().Debug("Reading command")
+		frame, err := ReadFrame(c.Conn)
 		if err != nil {
-			if err != io.EOF {
-				return nil, err
-			}
-			break
+			return Command{Type: frame.Type}, err
 		}
 
-		fullData = append(fullData, buffer[:numRead]...)
-		readLen += numRead
-
-		if uint64(readLen) >= totalSize {
-			break
+		cmd, ok, err := c.decode(frame)
+		if err != nil {
+			return Command{Type: frame.Type}, err
+		}
+		if ok {
+			return cmd, nil
 		}
 	}
-
-	return fullData, nil
 }
 
-// ReadSize read to the given size
-func (c *ConnClient) ReadSize(size uint64) ([]byte, error) {
+// decode decodes the frame into a command for the secondary, ok is false if
+// the frame was handled here.
+func (c *ConnClient) decode(frame Frame) (cmd Command, ok bool, err error) {
+	zap.L().Debug("Received Command Message",
+		zap.Stringer("CMD", frame.Type),
+		zap.Int("length", len(frame.Payload)),
+	)
 
-	if size > MAXREAD {
-		// split read
-		return c.ReadSplit(size)
-	}
-	buf := make([]byte, size)
+	cmd.Type = frame.Type
+	switch frame.Type {
+	case MsgAuth:
+		// The primary checks the token before taking this secondary
+		var auth AuthMessage
+		if err := json.Unmarshal(frame.Payload, &auth); err != nil {
+			c.ReplyERR(fmt.Sprintf("invalid auth message: %s", err.Error()))
+			return cmd, false, nil
+		}
+		c.replyJSON(AuthReply{Proof: TokenProof(c.token, auth.Nonce, auth.Connection)})
+		return cmd, false, nil
+	case MsgErr:
+		return cmd, false, fmt.Errorf("%w: %s", ErrRejected, string(frame.Payload))
+	case MsgClock:
+		// Reply the current time to measure the clock offset
+		c.SendDataOK(EncodeClock(time.Now()))
+		return cmd, false, nil
+	case MsgPrepare:
+		if err := json.Unmarshal(frame.Payload, &cmd.Prepare); err != nil {
+			c.ReplyERR(fmt.Sprintf("invalid prepare message: %s", err.Error()))
+			return cmd, false, nil
+		}
+	case MsgWorkload:
+		var header WorkloadHeader
+		if err := json.Unmarshal(frame.Payload, &header); err != nil {
+			c.ReplyERR(fmt.Sprintf("invalid workload header: %s", err.Error()))
+			return cmd, false, nil
+		}
 
-	zap.L().Debug("Reading Size",
-		zap.Uint64("size", size),
-	)
+		zap.L().Debug("Workload header",
+			zap.Uint32("chunks", header.Chunks),
+			zap.String("compression", header.Compression))
 
-	n, err := c.Conn.Read(buf)
-	if err != nil {
-		return nil, err
+		progress, err := c.receiver().Start(header)
+		if err != nil {
+			c.ReplyERR(err.Error())
+			return cmd, false, nil
+		}
+		c.replyJSON(progress)
+		return cmd, false, nil
+	case MsgWorkloadChunk:
+		// The chunks are streamed, errors are replied at the end
+		c.receiver().Add(frame.Payload)
+		return cmd, false, nil
+	case MsgWorkloadEnd:
+		workload, progress, err := c.receiver().Finish()
+		if err != nil {
+			zap.L().Warn("failed to receive workload",
+				zap.String("err", err.Error()),
+				zap.Uint32("received", progress.Received))
+			c.ReplyERR(err.Error())
+			return cmd, false, nil
+		}
+
+		c.progress = progress
+		cmd.Type = MsgWorkload
+		cmd.Workload = workload
+	case MsgGenerate:
+		if err := json.Unmarshal(frame.Payload, &cmd.Generate); err != nil || cmd.Generate.Bench == nil {
+			c.ReplyERR("invalid generate message")
+			return cmd, false, nil
+		}
+	case MsgRun:
+		if err := json.Unmarshal(frame.Payload, &cmd.Run); err != nil {
+			c.ReplyERR(fmt.Sprintf("invalid run message: %s", err.Error()))
+			return cmd, false, nil
+		}
+	case MsgResults, MsgFin, MsgAbort:
+	default:
+		// Return that there was no matching command
+		c.ReplyERR(fmt.Sprintf("no matching command %s", frame.Type))
+		return cmd, false, nil
 	}
 
-	zap.L().Debug("Read bytes",
-		zap.Int("number", n))
+	return cmd, true, nil
+}
+
+// receiver returns the receiver of the workload, created on first use
+func (c *ConnClient) receiver() *WorkloadReceiver {
+	if c.workload == nil {
+		c.workload = &WorkloadReceiver{}
+	}
 
-	return buf[:n], nil
+	return c.workload
 }
 
 // CloseConn closes the connection to the primary server
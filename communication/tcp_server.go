@@ -1,14 +1,18 @@
 package communication
 
 import (
-	"bytes"
+	"context"
+	"crypto/tls"
 	"diablo-benchmark/blockchains/workloadgenerators"
+	"diablo-benchmark/core/configs"
 	"diablo-benchmark/core/results"
-	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net"
+	"strconv"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -16,16 +20,31 @@ import (
 // PrimaryServer provides the listening server to communicate with the secondaries
 // as well as a connection to the active secondaries.
 type PrimaryServer struct {
-	Listener            net.Listener // TCP listener listening for incoming secondaries
-	Secondaries         []net.Conn   // Any connected secondaries so that they can communicate with the Primary
-	ExpectedSecondaries int          // The number of expected secondaries to connect
+	Listener            net.Listener           // TCP listener listening for incoming secondaries
+	Secondaries         []net.Conn             // Any connected secondaries so that they can communicate with the Primary
+	ExpectedSecondaries int                    // The number of expected secondaries to connect
+	WorkloadCompression string                 // Compression of the workload chunks, CompressionNone or CompressionDeflate
+	Clocks              []ClockSync            // Clock of each secondary measured during the prepare
+	AbortFailureRate    float64                // Fraction of failed transactions above which the run is aborted, 0 never aborts
+	abortReason         string                 // Why the last run was aborted, empty if it was not
+	Telemetry           *results.LiveTelemetry // Progress of the secondaries during the run
+	ReconnectTimeout    time.Duration          // How long to wait for a secondary to reconnect, ReconnectTimeout if 0
+	Token               string                 // Token the secondaries must prove they have, empty for none
+	workloadID          string                 // Identifies the workload sent, to resume its transfer
+	connLock            sync.Mutex             // Protects the connections replaced when secondaries reconnect
+	reconnects          []chan net.Conn        // New connection of each secondary reconnecting
+	waiting             []bool                 // Whether the primary waits for each secondary to reconnect
+	degraded            []bool                 // Whether each secondary reconnected during the benchmark
+	lost                []bool                 // Whether the results of each secondary could not be collected
 }
 
 // SecondaryReplyErrors stores the errors returned by the secondaries to be printed out
 type SecondaryReplyErrors []string
 
 // SetupPrimaryTCP generates a new "Listener" by creating the TCP server.
-func SetupPrimaryTCP(addr string, expectedSecondaries int) (*PrimaryServer, error) {
+// The connections use TLS and the secondaries prove they have the token if
+// the security configures them.
+func SetupPrimaryTCP(addr string, expectedSecondaries int, security Security) (*PrimaryServer, error) {
 	listener, err := net.Listen("tcp", addr)
 
 	// If we can't make a listener, we
@@ -34,153 +53,261 @@ func SetupPrimaryTCP(addr string, expectedSecondaries int) (*PrimaryServer, erro
 		return nil, err
 	}
 
+	if security.TLS != nil {
+		listener = tls.NewListener(listener, security.TLS)
+	}
+
 	zap.L().Info("Server Started",
 		zap.String("Addr", addr),
-		zap.Int("Expected Secondaries", expectedSecondaries))
+		zap.Int("Expected Secondaries", expectedSecondaries),
+		zap.Bool("TLS", security.TLS != nil),
+		zap.Bool("Token", security.Token != ""))
+
+	return NewPrimaryServer(listener, expectedSecondaries, security.Token), nil
+}
 
-	return &PrimaryServer{Listener: listener, ExpectedSecondaries: expectedSecondaries}, nil
+// NewPrimaryServer returns the server of the primary accepting the secondaries
+// on the listener.
+func NewPrimaryServer(listener net.Listener, expectedSecondaries int, token string) *PrimaryServer {
+	return &PrimaryServer{Listener: listener, ExpectedSecondaries: expectedSecondaries, Token: token}
 }
 
 // HandleSecondaries starts a listener that will run in a thread to
-// handle any secondary connections.
+// handle any secondary connections. It sends true on the channel once all
+// the secondaries are connected, or false if the listener is closed before.
+// The connections are authenticated concurrently, so that a connection
+// stalling its handshake does not hold back the secondaries behind it.
 func (s *PrimaryServer) HandleSecondaries(readyChannel chan bool) {
+	authenticated := make(chan net.Conn)
+	failed := make(chan error, 1)
+	connected := make(chan struct{})
+	go s.acceptConnections(authenticated, failed, connected)
 
-	for {
-		c, err := s.Listener.Accept()
-
-		if err != nil {
+	for len(s.Secondaries) < s.ExpectedSecondaries {
+		select {
+		case c := <-authenticated:
+			// The secondary takes the ID of its place in the connections
+			s.Secondaries = append(s.Secondaries, c)
+
+			zap.L().Info(fmt.Sprintf("Secondary %d / %d connected", len(s.Secondaries), s.ExpectedSecondaries),
+				zap.Int("ID", len(s.Secondaries)-1),
+				zap.String("Addr:", c.RemoteAddr().String()))
+		case err := <-failed:
 			// Log the error here
 			zap.L().Error("Error from listen",
 				zap.Error(err))
+			close(connected)
+			readyChannel <- false
+			return
 		}
+	}
 
-		s.Secondaries = append(s.Secondaries, c)
+	s.reconnects = make([]chan net.Conn, len(s.Secondaries))
+	for i := range s.reconnects {
+		s.reconnects[i] = make(chan net.Conn, 1)
+	}
+	s.waiting = make([]bool, len(s.Secondaries))
+	s.degraded = make([]bool, len(s.Secondaries))
+	s.lost = make([]bool, len(s.Secondaries))
 
-		zap.L().Info(fmt.Sprintf("Secondary %d / %d connected", len(s.Secondaries), s.ExpectedSecondaries),
-			zap.String("Addr:", c.RemoteAddr().String()))
+	// The secondaries losing their connection take their place again
+	close(connected)
+	readyChannel <- true
+}
+
+// acceptConnections accepts the connections until the listener is closed,
+// each one numbered and handled in its own goroutine. Until connected is
+// closed, the authenticated connections are sent on authenticated to take
+// the place of a secondary, afterwards they are reconnections.
+func (s *PrimaryServer) acceptConnections(authenticated chan<- net.Conn, failed chan<- error, connected <-chan struct{}) {
+	for connection := uint32(0); ; connection++ {
+		c, err := s.Listener.Accept()
+		if err != nil {
+			select {
+			case <-connected:
+				zap.L().Debug("Stopped accepting reconnections",
+					zap.Error(err))
+			default:
+				failed <- err
+			}
+			return
+		}
 
-		if len(s.Secondaries) == s.ExpectedSecondaries {
-			readyChannel <- true
-			break
+		select {
+		case <-connected:
+			go s.handleReconnection(c, connection)
+		default:
+			go s.admit(c, connection, authenticated, connected)
 		}
 	}
 }
 
-// sendAndWaitOKAsync is used to send and wait for the OK byte to be
-// received. This takes a channel and replies on the channel once OK or err is received.
-func (s *PrimaryServer) sendAndWaitOKAsync(data []byte, secondary net.Conn, doneCh chan int, errCh chan error) {
-	if _, err := secondary.Write(data); err != nil {
-		// TODO: Log that we can't communicate with secondary
-		errCh <- err
-		doneCh <- 1
+// admit authenticates a new connection and sends it on authenticated to take
+// the place of a secondary, unless all of them connected in the meantime.
+func (s *PrimaryServer) admit(c net.Conn, connection uint32, authenticated chan<- net.Conn, connected <-chan struct{}) {
+	// Connections failing the TLS handshake do not take the place of a secondary
+	if err := handshake(c); err != nil {
+		zap.L().Warn("Rejected connection",
+			zap.String("addr", c.RemoteAddr().String()),
+			zap.Error(err))
+		_ = c.Close()
+		return
 	}
 
-	reply := make([]byte, 1)
-
-	n, err := secondary.Read(reply)
-
-	if err != nil {
-		errCh <- err
-		doneCh <- 1
+	// Neither do the connections failing to prove the token
+	if err := s.authenticate(c, connection); err != nil {
+		zap.L().Warn("Rejected connection",
+			zap.String("addr", c.RemoteAddr().String()),
+			zap.Error(err))
+		_ = WriteFrame(c, MsgErr, []byte(err.Error()))
+		_ = c.Close()
+		return
 	}
 
-	zap.L().Debug(fmt.Sprintf("GOT REPLY FROM %s\n", secondary.RemoteAddr().String()))
+	select {
+	case authenticated <- c:
+	case <-connected:
+		_ = WriteFrame(c, MsgErr, []byte("all the secondaries are already connected"))
+		_ = c.Close()
+	}
+}
 
-	// If we got an error reply - it means
-	// something failed on the secondary machine
-	if bytes.Equal(MsgErr, reply[:n]) {
-		// TODO: Add a "get X bytes for the error reason"
-		errCh <- fmt.Errorf("failed to communicate with secondary %s", secondary.RemoteAddr().String())
-		doneCh <- 1
+// exchange sends the message to a secondary and waits for its reply. It returns
+// the payload of an OK reply, the error text of an error reply as a
+// SecondaryErrorReply, and communication failures as a SecondaryCommError.
+func (s *PrimaryServer) exchange(t MessageType, payload []byte, secondary net.Conn) ([]byte, error) {
+	if err := s.send(t, payload, secondary); err != nil {
+		return nil, err
 	}
 
-	doneCh <- 0
-	return
+	return s.readReply(t, secondary, nil)
 }
 
-// SendAndWaitOKSync send a message to a secondary and wait for the okay without
-// the use of a channel (synchronous sending).
-func (s *PrimaryServer) SendAndWaitOKSync(data []byte, secondary net.Conn) error {
+// send writes the message to a secondary without waiting for a reply
+func (s *PrimaryServer) send(t MessageType, payload []byte, secondary net.Conn) error {
+	zap.L().Debug("Send",
+		zap.Stringer("cmd", t),
+		zap.Int("len", len(payload)))
 
-	zap.L().Debug("SendSync",
-		zap.Int("len", len(data)))
-
-	if _, err := secondary.Write(data); err != nil {
-		// TODO: Log that we can't communicate with secondary
+	if err := WriteFrame(secondary, t, payload); err != nil {
 		return &SecondaryCommError{
 			SecondaryInfo: secondary.RemoteAddr().String(),
 			Err:           err,
 		}
 	}
 
-	reply := make([]byte, 1024)
-
-	n, err := secondary.Read(reply)
+	return nil
+}
 
-	if err != nil {
-		// TODO: Log secondary got an error
-		return &SecondaryCommError{
-			SecondaryInfo: secondary.RemoteAddr().String(),
-			Err:           err,
+// readReply waits for the reply of the secondary to the message. The
+// telemetry sent by the secondary in the meantime is given to onTelemetry,
+// or ignored if it is nil.
+func (s *PrimaryServer) readReply(t MessageType, secondary net.Conn, onTelemetry func(results.Telemetry)) ([]byte, error) {
+	for {
+		reply, err := ReadFrame(secondary)
+		if versionErr, ok := err.(*VersionError); ok && reply.Type == MsgErr {
+			// The secondary rejected the message, report why along with the versions
+			return nil, &SecondaryErrorReply{
+				Info: secondary.RemoteAddr().String(),
+				Err:  fmt.Errorf("%s (%s)", string(reply.Payload), versionErr.Error()),
+			}
+		}
+		if err != nil {
+			return nil, &SecondaryCommError{
+				SecondaryInfo: secondary.RemoteAddr().String(),
+				Err:           err,
+			}
 		}
-	}
 
-	zap.L().Debug(fmt.Sprintf("GOT REPLY FROM %s\n", secondary.RemoteAddr().String()))
+		if reply.Type == MsgTelemetry {
+			var telemetry results.Telemetry
+			if err := json.Unmarshal(reply.Payload, &telemetry); err != nil {
+				zap.L().Warn("invalid telemetry from secondary",
+					zap.String("secondary", secondary.RemoteAddr().String()),
+					zap.Error(err))
+				continue
+			}
+			if onTelemetry != nil {
+				onTelemetry(telemetry)
+			}
+			continue
+		}
 
-	// If we got an error reply - it means
-	// something failed on the secondary machine
-	if reply[0] == MsgErr[0] {
-		// TODO: Add a "get X bytes for the error reason"
-		return &SecondaryErrorReply{
-			Info: secondary.RemoteAddr().String(),
-			Err:  fmt.Errorf("error from secondary %s", string(reply[1:n])),
+		zap.L().Debug(fmt.Sprintf("GOT REPLY FROM %s", secondary.RemoteAddr().String()),
+			zap.Stringer("reply", reply.Type),
+			zap.Int("len", len(reply.Payload)))
+
+		switch reply.Type {
+		case MsgOk:
+			return reply.Payload, nil
+		case MsgErr:
+			// Something failed on the secondary machine
+			return nil, &SecondaryErrorReply{
+				Info: secondary.RemoteAddr().String(),
+				Err:  errors.New(string(reply.Payload)),
+			}
+		default:
+			return nil, &SecondaryCommError{
+				SecondaryInfo: secondary.RemoteAddr().String(),
+				Err:           fmt.Errorf("unexpected reply %s to %s", reply.Type, t),
+			}
 		}
 	}
-
-	return nil
 }
 
-// sendAndWaitData sends a message to a secondary and waits for the OK and data, or errors
-func (s *PrimaryServer) sendAndWaitData(data []byte, secondary net.Conn) ([]results.Results, error) {
-	if _, err := secondary.Write(data); err != nil {
-		// TODO log that we can't communicate with secondary
-		return nil, &SecondaryCommError{
-			SecondaryInfo: secondary.RemoteAddr().String(),
-			Err:           err,
+// sendAndWaitOKAsync is used to send and wait for the OK to be received.
+// This takes a channel and replies on the channel once OK or err is received,
+// it is pushed to once. The telemetry received until the reply is given to
+// onTelemetry. If the connection is lost, the reply is waited for on the
+// connection of the secondary reconnecting, a secondary that does not
+// reconnect is lost.
+func (s *PrimaryServer) sendAndWaitOKAsync(t MessageType, payload []byte, secondary int, onTelemetry func(results.Telemetry), doneCh chan int, errCh chan error) {
+	c := s.conn(secondary)
+	sent := false
+	for {
+		var err error
+		if !sent {
+			err = s.send(t, payload, c)
+			sent = err == nil
 		}
-	}
-
-	// Read the reply AND response error (if it's an error, 1024 is to
-	// encapsulate any error string passed with the data).
-	initialReply := make([]byte, 1024)
-
-	n, err := secondary.Read(initialReply)
 
-	zap.L().Debug("Got secondary reply from RES message")
+		if err == nil {
+			if _, err = s.readReply(t, c, onTelemetry); err == nil {
+				doneCh <- 0
+				return
+			}
+		}
 
-	if err != nil {
-		// TODO: Log secondary got an error
-		return nil, &SecondaryCommError{
-			SecondaryInfo: secondary.RemoteAddr().String(),
-			Err:           err,
+		if c, err = s.reconnect(secondary, err); err != nil {
+			if _, ok := err.(*SecondaryErrorReply); !ok {
+				s.markLost(secondary)
+			}
+			errCh <- err
+			doneCh <- 1
+			return
 		}
 	}
+}
 
-	// If we got an error reply - it means
-	// something failed on the secondary machine
-	if initialReply[0] == MsgErr[0] {
-		// TODO: Add a "get X bytes for the error reason"
-		return nil, &SecondaryErrorReply{
-			Info: secondary.RemoteAddr().String(),
-			Err:  fmt.Errorf("error from secondary %s", string(initialReply[1:n])),
-		}
+// SendAndWaitOKSync send a message to a secondary and wait for the okay without
+// the use of a channel (synchronous sending).
+func (s *PrimaryServer) SendAndWaitOKSync(t MessageType, payload []byte, secondary net.Conn) error {
+	_, err := s.exchange(t, payload, secondary)
+	return err
+}
+
+// sendAndWaitData sends a message to a secondary and waits for the OK and data, or errors
+func (s *PrimaryServer) sendAndWaitData(t MessageType, secondary net.Conn) ([]results.Results, error) {
+	data, err := s.exchange(t, nil, secondary)
+	if err != nil {
+		return nil, err
 	}
 
-	// Now we have to read through the data until we end.
-	// Get the length
-	dataLen := binary.BigEndian.Uint64(initialReply[1:9])
+	zap.L().Debug("Read secondary reply",
+		zap.String("secondary", secondary.RemoteAddr().String()),
+		zap.Int("numbytes", len(data)))
 
-	if dataLen == 0 {
+	if len(data) == 0 {
 		return []results.Results{{
 			AverageLatency: 0,
 			Throughput:     0,
@@ -188,40 +315,8 @@ func (s *PrimaryServer) sendAndWaitData(data []byte, secondary net.Conn) ([]resu
 		}}, nil
 	}
 
-	fullReply := initialReply[9:n]
-	zap.L().Debug("Reply from client",
-		zap.Uint64("length", dataLen),
-		zap.ByteString("reply", fullReply),
-	)
-	buffer := make([]byte, 1024)
-	readLen := n - 9
-
-	for {
-		if uint64(readLen) >= dataLen {
-			break
-		}
-		n, err := secondary.Read(buffer)
-		zap.L().Debug(fmt.Sprintf("Secondary %s read %d, total %d", secondary.RemoteAddr().String(), n, readLen))
-		if err != nil {
-			if err != io.EOF {
-				return nil, &SecondaryCommError{
-					SecondaryInfo: secondary.RemoteAddr().String(),
-					Err:           err,
-				}
-			}
-			break
-		}
-
-		fullReply = append(fullReply, buffer[:n]...)
-		readLen += n
-	}
-
-	zap.L().Debug("Read secondary reply",
-		zap.String("secondary", secondary.RemoteAddr().String()),
-		zap.Int("numbytes", readLen))
-
 	var res []results.Results
-	err = json.Unmarshal(fullReply[:dataLen], &res)
+	err = json.Unmarshal(data, &res)
 
 	if err != nil {
 		zap.L().Error("failed to unmarshal bytes of result reply from secondary",
@@ -231,25 +326,40 @@ func (s *PrimaryServer) sendAndWaitData(data []byte, secondary net.Conn) ([]resu
 	return res, nil
 }
 
-// PrepareBenchmarkSecondaries sends the prepare message to the secondaires
+// PrepareBenchmarkSecondaries sends the prepare message to the secondaires.
+// It is the first message of the connection, a secondary running another
+// version of the protocol replies with an error. The clock of each secondary
+// is then measured to synchronise their start.
 func (s *PrimaryServer) PrepareBenchmarkSecondaries(numThreads uint32) SecondaryReplyErrors {
 
 	var errorList []string
-
-	threadBytes := make([]byte, 4)
-	binary.BigEndian.PutUint32(threadBytes, numThreads)
+	s.Clocks = make([]ClockSync, len(s.Secondaries))
 
 	for i, c := range s.Secondaries {
-		secondaryID := make([]byte, 4)
-		binary.BigEndian.PutUint32(secondaryID, uint32(i))
-		payload := append(MsgPrepare, secondaryID...)
-		payload = append(payload, threadBytes...)
-		err := s.SendAndWaitOKSync(payload, c)
+		payload, err := json.Marshal(PrepareMessage{SecondaryID: uint32(i), Threads: numThreads})
+		if err != nil {
+			errorList = append(errorList, err.Error())
+			continue
+		}
+
+		err = s.SendAndWaitOKSync(MsgPrepare, payload, c)
 		if err != nil {
 			zap.L().Warn("Got an error from secondary",
 				zap.String("secondary", c.RemoteAddr().String()))
 			errorList = append(errorList, err.Error())
+			continue
+		}
+
+		s.Clocks[i], err = s.measureClock(c)
+		if err != nil {
+			errorList = append(errorList, err.Error())
+			continue
 		}
+
+		zap.L().Info("Secondary clock",
+			zap.String("secondary", c.RemoteAddr().String()),
+			zap.Duration("offset", s.Clocks[i].Offset),
+			zap.Duration("rtt", s.Clocks[i].RTT))
 	}
 
 	if len(errorList) == 0 {
@@ -259,70 +369,165 @@ func (s *PrimaryServer) PrepareBenchmarkSecondaries(numThreads uint32) Secondary
 	return errorList
 }
 
-// SendWorkload sends the workload to all secondaries. Encodes the workload in the
-// chosen encoding in helpers.go and will send off the bytes to be read and processed
-// by the secondary.
+// SendWorkload sends the workload to all secondaries. The workload of each
+// secondary is streamed in chunks encoded in helpers.go, and a transfer
+// started again resumes after the chunks the secondary already has.
 func (s *PrimaryServer) SendWorkload(workloads workloadgenerators.Workload) SecondaryReplyErrors {
-	var errorList []error
+	var errorList SecondaryReplyErrors
+
+	if s.workloadID == "" {
+		s.workloadID = strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
 
 	for i, c := range s.Secondaries {
-		data := MsgWorkload
+		err := s.streamWorkload(s.workloadID, workloads[i], c)
+		if err != nil {
+			// The transfer resumes on the new connection
+			if c, err = s.reconnect(i, err); err == nil {
+				err = s.streamWorkload(s.workloadID, workloads[i], c)
+			}
+		}
 
-		payload, err := EncodeWorkload(workloads[i])
 		if err != nil {
-			errorList = append(errorList, err)
+			errorList = append(errorList, err.Error())
 			continue
 		}
 
-		// format: cmd, len, payload
-		payloadLen := uint64(len(payload))
-		payloadLenBytes := make([]byte, 8)
-		binary.BigEndian.PutUint64(payloadLenBytes, payloadLen)
+		digest, transactions := WorkloadDigest(workloads[i])
+		zap.L().Info("Workload sent",
+			zap.Int("secondary", i),
+			zap.Int("transactions", transactions),
+			zap.String("digest", digest))
+	}
 
-		data = append(data, payloadLenBytes...)
-		data = append(data, payload...)
+	if len(errorList) == 0 {
+		return nil
+	}
 
-		zap.L().Debug("Sending data",
-			zap.Uint64("length", payloadLen))
+	return errorList
+}
+
+// SendPartitions has each secondary generate its workload from its partition,
+// the secondaries generate their workloads in parallel. The digest of each
+// workload is logged, it is the same as the digest of the workload generated
+// on the primary.
+func (s *PrimaryServer) SendPartitions(bench *configs.BenchConfig, partitions [][]byte) SecondaryReplyErrors {
+	if len(partitions) != len(s.Secondaries) {
+		return SecondaryReplyErrors{fmt.Sprintf("%d partitions for %d secondaries", len(partitions), len(s.Secondaries))}
+	}
+
+	errs := make([]error, len(partitions))
+	var wg sync.WaitGroup
+	for i := range partitions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.generate(i, bench, partitions[i])
+		}(i)
+	}
+	wg.Wait()
 
-		err = s.SendAndWaitOKSync(data, c)
+	var errorList SecondaryReplyErrors
+	for _, err := range errs {
 		if err != nil {
-			errorList = append(errorList, err)
+			errorList = append(errorList, err.Error())
 		}
 	}
 
+	return errorList
+}
+
+// generate has the secondary generate its workload, again after reconnecting
+// if the connection is lost.
+func (s *PrimaryServer) generate(secondary int, bench *configs.BenchConfig, partition []byte) error {
+	payload, err := json.Marshal(GenerateMessage{Bench: bench, Partition: partition})
+	if err != nil {
+		return err
+	}
+
+	c := s.conn(secondary)
+	reply, err := s.exchange(MsgGenerate, payload, c)
+	if err != nil {
+		if c, err = s.reconnect(secondary, err); err == nil {
+			reply, err = s.exchange(MsgGenerate, payload, c)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	var generated GenerateReply
+	if err := json.Unmarshal(reply, &generated); err != nil {
+		return err
+	}
+
+	zap.L().Info("Workload generated",
+		zap.Int("secondary", secondary),
+		zap.Int("transactions", generated.Transactions),
+		zap.String("digest", generated.Digest))
+
 	return nil
 }
 
 // RunBenchmark sends the message to all secondaries to run the benchmark.
-func (s *PrimaryServer) RunBenchmark() SecondaryReplyErrors {
+// The run is aborted on all the secondaries when the context is cancelled or
+// the failure rate is too high, AbortReason then tells why. The secondaries
+// still reply, so that their partial results can be collected.
+func (s *PrimaryServer) RunBenchmark(ctx context.Context) SecondaryReplyErrors {
 	zap.L().Info("\n------------\nStarting Benchmark\n------------\n")
 
-	var errorList SecondaryReplyErrors
-
 	// Channels for goroutine comms
 	okCh := make(chan int, len(s.Secondaries))
 	errCh := make(chan error, len(s.Secondaries))
 
-	for _, c := range s.Secondaries {
-		go s.sendAndWaitOKAsync(MsgRun, c, okCh, errCh)
+	// All the secondaries start at the same time, given on their own clock
+	start := s.startTime()
+	zap.L().Info("Benchmark start",
+		zap.Time("start", start))
+
+	s.Telemetry = results.NewLiveTelemetry(len(s.Secondaries))
+	for i := range s.Secondaries {
+		var offset time.Duration
+		if i < len(s.Clocks) {
+			offset = s.Clocks[i].Offset
+		}
+
+		payload, err := json.Marshal(RunMessage{StartTime: start.Add(offset).UnixNano()})
+		if err != nil {
+			errCh <- err
+			okCh <- 1
+			continue
+		}
+
+		secondary := i
+		onTelemetry := func(t results.Telemetry) { s.Telemetry.Update(secondary, t) }
+		go s.sendAndWaitOKAsync(MsgRun, payload, i, onTelemetry, okCh, errCh)
 	}
 
+	// Show the progress until all the secondaries are done
+	view := time.NewTicker(telemetryViewInterval)
+	defer view.Stop()
+
+	s.abortReason = ""
+	interrupted := ctx.Done()
 	numberDone := 0
 	numberOfErrors := 0
-	for {
+	for numberDone < len(s.Secondaries) {
 		select {
 		case secondaryDone := <-okCh:
-			// TODO change this
 			zap.L().Debug("Secondary Done")
 			numberDone++
 			numberOfErrors += secondaryDone
-			if numberDone == len(s.Secondaries) {
-				break
+		case <-view.C:
+			zap.L().Info(s.Telemetry.View())
+			if s.abortReason == "" {
+				s.checkFailureRate(s.Telemetry.Total())
+			}
+		case <-interrupted:
+			interrupted = nil
+			if s.abortReason == "" {
+				s.abort("benchmark interrupted")
 			}
-		}
-		if numberDone == len(s.Secondaries) {
-			break
 		}
 	}
 
@@ -349,40 +554,67 @@ func (s *PrimaryServer) RunBenchmark() SecondaryReplyErrors {
 		return nil
 	}
 
-	return errorList
+	return errList
 }
 
 // GetResults calls the secondaries to return the results.
-// Will return the list of results as well as any errors that had been encountered
+// Will return the list of results as well as any errors that had been encountered.
+// The secondaries keep their results, so a secondary losing its connection
+// returns them once it reconnects. The others are reported by SecondaryHealth,
+// along with the ones lost during the run which are not asked again, and their
+// results are left empty so that the results are indexed by secondary ID.
 func (s *PrimaryServer) GetResults() ([][]results.Results, SecondaryReplyErrors) {
-	var allResults [][]results.Results
+	// One slot per secondary, left empty for the lost ones, so that the
+	// results keep the IDs of the secondaries
+	allResults := make([][]results.Results, len(s.Secondaries))
 	var errs SecondaryReplyErrors
 
-	for _, c := range s.Secondaries {
+	for i, c := range s.Secondaries {
+		if s.isLost(i) {
+			errs = append(errs, fmt.Sprintf("secondary %d was lost during the benchmark", i))
+			continue
+		}
+
 		// Send the RES command, wait for the results to come back
 		secondaryRes, err := s.sendAndWaitData(MsgResults, c)
+		if err != nil {
+			if c, err = s.reconnect(i, err); err == nil {
+				secondaryRes, err = s.sendAndWaitData(MsgResults, c)
+			}
+		}
 
 		if err != nil {
+			s.markLost(i)
 			errs = append(errs, err.Error())
 			continue
 		}
 
 		zap.L().Debug(fmt.Sprintf("Got %d results from secondary", len(secondaryRes)))
 
-		allResults = append(allResults, secondaryRes)
+		allResults[i] = secondaryRes
 	}
 
-	zap.L().Debug(fmt.Sprintf("%d Results returned", len(allResults)))
+	zap.L().Debug(fmt.Sprintf("%d Results returned", len(allResults)-len(errs)))
 	return allResults, errs
 }
 
 // SendFin sends the final GOODBYE message and then close the connection to the secondaries
 func (s *PrimaryServer) SendFin() {
 	for _, c := range s.Secondaries {
-		_ = s.SendAndWaitOKSync(MsgFin, c)
+		_ = s.SendAndWaitOKSync(MsgFin, nil, c)
 	}
 }
 
+// NumSecondaries returns the number of secondaries connected
+func (s *PrimaryServer) NumSecondaries() int {
+	return len(s.Secondaries)
+}
+
+// AbortReason returns why the last run was aborted, empty if it was not
+func (s *PrimaryServer) AbortReason() string {
+	return s.abortReason
+}
+
 // CloseAll close all connections and threads
 func (s *PrimaryServer) CloseAll() {
 	s.CloseSecondaries()
@@ -391,6 +623,9 @@ func (s *PrimaryServer) CloseAll() {
 
 // CloseSecondaries closes the secondary connections
 func (s *PrimaryServer) CloseSecondaries() {
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+
 	for i, c := range s.Secondaries {
 		zap.L().Debug(fmt.Sprintf("Closing Secondary %d @ %s", i, c.RemoteAddr().String()))
 		_ = c.Close()
@@ -0,0 +1,78 @@
+package communication
+
+import (
+	"diablo-benchmark/core/results"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TelemetryInterval is the interval at which the secondaries send their
+// progress during the run.
+const TelemetryInterval = time.Second
+
+// telemetryViewInterval is the interval at which the primary shows the
+// progress of the secondaries and checks their failure rate.
+const telemetryViewInterval = 2 * time.Second
+
+// abortMinTransactions is the number of completed transactions before the
+// failure rate is checked, so that the first failures do not abort the run.
+const abortMinTransactions = 100
+
+// SendTelemetry sends the progress of the secondary during the run, the
+// primary does not reply to it.
+func (c *ConnClient) SendTelemetry(t results.Telemetry) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		zap.L().Warn("failed to encode telemetry",
+			zap.Error(err))
+		return
+	}
+
+	c.reply(MsgTelemetry, data)
+}
+
+// checkFailureRate aborts the run on all the secondaries if the failure rate
+// is above the configured threshold.
+func (s *PrimaryServer) checkFailureRate(total results.Telemetry) {
+	if reason := failureRateExceeded(s.AbortFailureRate, total); reason != "" {
+		s.abort(reason)
+	}
+}
+
+// failureRateExceeded returns why the run is aborted if the failure rate is
+// above the threshold, empty otherwise.
+func failureRateExceeded(threshold float64, total results.Telemetry) string {
+	if threshold <= 0 || total.Committed+total.Failed < abortMinTransactions {
+		return ""
+	}
+
+	rate := total.FailureRate()
+	if rate <= threshold {
+		return ""
+	}
+
+	zap.L().Warn("failure rate above the threshold",
+		zap.Uint64("committed", total.Committed),
+		zap.Uint64("failed", total.Failed))
+	return fmt.Sprintf("failure rate %.2f%% above the threshold of %.2f%%", 100*rate, 100*threshold)
+}
+
+// abort tells all the secondaries to stop the running benchmark, they then
+// reply to MsgRun.
+func (s *PrimaryServer) abort(reason string) {
+	zap.L().Error("Aborting the benchmark",
+		zap.String("reason", reason))
+	s.abortReason = reason
+
+	for i := range s.Secondaries {
+		c := s.conn(i)
+		if err := s.send(MsgAbort, nil, c); err != nil {
+			zap.L().Warn("failed to abort secondary",
+				zap.String("secondary", c.RemoteAddr().String()),
+				zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package communication
+
+import (
+	"context"
+	"diablo-benchmark/core/results"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runUntilAbort runs like a secondary failing most of its transactions: it
+// sends telemetry until the primary aborts the run, and returns whether the
+// abort was received.
+func runUntilAbort(conn net.Conn) bool {
+	c := &ConnClient{Conn: conn}
+	if cmd, err := c.ReadCommand(); err != nil || cmd.Type != MsgRun {
+		return false
+	}
+
+	aborted := make(chan bool, 1)
+	go func() {
+		cmd, err := c.ReadCommand()
+		aborted <- err == nil && cmd.Type == MsgAbort
+	}()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	sent := uint64(0)
+	for {
+		select {
+		case ok := <-aborted:
+			c.ReplyOK()
+			return ok
+		case <-ticker.C:
+			sent += 100
+			c.SendTelemetry(results.Telemetry{Sent: sent, Committed: sent / 2, Failed: sent / 2})
+		}
+	}
+}
+
+func TestTelemetryAbort(t *testing.T) {
+	primary, secondary := net.Pipe()
+	defer primary.Close()
+	defer secondary.Close()
+
+	done := make(chan bool)
+	go func() { done <- runUntilAbort(secondary) }()
+
+	s := &PrimaryServer{Secondaries: []net.Conn{primary}, AbortFailureRate: 0.2}
+	errs := s.RunBenchmark(context.Background())
+
+	if !<-done {
+		t.Fatalf("expected the secondary to be aborted")
+	}
+
+	if len(errs) != 0 || !strings.Contains(s.AbortReason(), "failure rate") {
+		t.Errorf("expected the abort to be reported, got %q (errors: %v)", s.AbortReason(), errs)
+	}
+
+	if total := s.Telemetry.Total(); total.Sent == 0 || total.FailureRate() != 0.5 {
+		t.Errorf("expected the telemetry of the secondary, got %s", total)
+	}
+}
+
+func TestTelemetryIgnoredOutsideRun(t *testing.T) {
+	primary, secondary := net.Pipe()
+	defer primary.Close()
+	defer secondary.Close()
+
+	go func() {
+		c := &ConnClient{Conn: secondary}
+		_, _ = ReadFrame(secondary)
+		c.SendTelemetry(results.Telemetry{Sent: 1})
+		data, _ := json.Marshal(WorkloadProgress{Received: 3})
+		c.SendDataOK(data)
+	}()
+
+	s := &PrimaryServer{}
+	reply, err := s.exchange(MsgWorkloadEnd, nil, primary)
+	if err != nil {
+		t.Fatalf("failed to exchange: %s", err.Error())
+	}
+
+	var progress WorkloadProgress
+	if err := json.Unmarshal(reply, &progress); err != nil || progress.Received != 3 {
+		t.Errorf("expected the reply after the telemetry, got %q", reply)
+	}
+}
+
+func TestRunInterrupted(t *testing.T) {
+	primary, secondary := net.Pipe()
+	defer primary.Close()
+	defer secondary.Close()
+
+	done := make(chan bool)
+	go func() { done <- runUntilAbort(secondary) }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	s := &PrimaryServer{Secondaries: []net.Conn{primary}}
+	if errs := s.RunBenchmark(ctx); len(errs) != 0 {
+		t.Fatalf("expected the secondary to reply after the abort, got %v", errs)
+	}
+
+	if !<-done || s.AbortReason() != "benchmark interrupted" {
+		t.Errorf("expected the run to be interrupted, got %q", s.AbortReason())
+	}
+}
@@ -0,0 +1,119 @@
+package communication
+
+import (
+	"context"
+	"diablo-benchmark/blockchains/workloadgenerators"
+	"diablo-benchmark/core/configs"
+	"diablo-benchmark/core/results"
+	"time"
+)
+
+// PrimaryTransport is the command set the primary sends to the secondaries,
+// in the order of the benchmark. PrimaryServer implements it over TCP and
+// MemoryTransport over channels, other transports can be plugged in the
+// primary without changing its flow.
+type PrimaryTransport interface {
+	// HandleSecondaries waits for the secondaries, it sends true on the
+	// channel once all of them are connected and false if it failed.
+	HandleSecondaries(readyChannel chan bool)
+
+	// NumSecondaries returns the number of secondaries connected
+	NumSecondaries() int
+
+	// PrepareBenchmarkSecondaries assigns the secondaries their ID and
+	// number of workers, and measures their clock.
+	PrepareBenchmarkSecondaries(numThreads uint32) SecondaryReplyErrors
+
+	// SendWorkload sends each secondary its workload
+	SendWorkload(workloads workloadgenerators.Workload) SecondaryReplyErrors
+
+	// SendPartitions has each secondary generate its workload from the
+	// benchmark configuration and its partition, in place of SendWorkload.
+	SendPartitions(bench *configs.BenchConfig, partitions [][]byte) SecondaryReplyErrors
+
+	// RunBenchmark runs the benchmark on all secondaries and returns once
+	// all of them are done. Cancelling the context aborts the run.
+	RunBenchmark(ctx context.Context) SecondaryReplyErrors
+
+	// AbortReason returns why the last run was aborted, empty if it was not
+	AbortReason() string
+
+	// GetResults returns the results of the workers of each secondary, by
+	// secondary ID. The results of the lost secondaries are empty.
+	GetResults() ([][]results.Results, SecondaryReplyErrors)
+
+	// ClockOffsets returns the clock offset and round trip time of each
+	// secondary, by secondary ID.
+	ClockOffsets() ([]time.Duration, []time.Duration)
+
+	// SecondaryHealth returns the secondaries that reconnected during the
+	// benchmark, and the ones whose results could not be collected.
+	SecondaryHealth() (degraded []int, lost []int)
+
+	// SendFin tells the secondaries the benchmark is finished
+	SendFin()
+
+	// CloseSecondaries closes the connections to the secondaries
+	CloseSecondaries()
+
+	// Close stops accepting secondaries
+	Close()
+}
+
+// Command is a command of the primary, decoded by the transport of the
+// secondary. The field of its type is set.
+type Command struct {
+	Type     MessageType                          // MsgPrepare, MsgWorkload, MsgGenerate, MsgRun, MsgResults, MsgAbort or MsgFin
+	Prepare  PrepareMessage                       // ID and number of workers of the secondary, for MsgPrepare
+	Workload workloadgenerators.SecondaryWorkload // Whole workload of the secondary, for MsgWorkload
+	Generate GenerateMessage                      // Configuration and partition to generate, for MsgGenerate
+	Run      RunMessage                           // Start of the run, for MsgRun
+}
+
+// SecondaryTransport is the connection of a secondary to the primary. The
+// secondary reads the commands and replies to each of them with the reply of
+// its type, the transport answers the rest of the protocol itself. ConnClient
+// implements it over a net.Conn and MemorySecondary over channels.
+type SecondaryTransport interface {
+	// ReadCommand waits for the next command of the primary
+	ReadCommand() (Command, error)
+
+	// ReplyPrepare replies to MsgPrepare, with the error if it failed
+	ReplyPrepare(err error)
+
+	// ReplyWorkload replies to MsgWorkload once the workload is parsed
+	ReplyWorkload(err error)
+
+	// ReplyGenerate replies to MsgGenerate with the digest of the workload
+	ReplyGenerate(reply GenerateReply, err error)
+
+	// ReplyRun replies to MsgRun once the run is over
+	ReplyRun(err error)
+
+	// ReplyResults replies to MsgResults with the results of the workers
+	ReplyResults(res []results.Results, err error)
+
+	// ReplyFin replies to MsgFin
+	ReplyFin()
+
+	// ReplyERR replies the error to the command, e.g. another protocol version
+	ReplyERR(msg string)
+
+	// SendTelemetry sends the progress of the run, it is not a reply
+	SendTelemetry(t results.Telemetry)
+
+	// Reconnect connects to the primary again once the connection is lost,
+	// and returns the transport taking the place of this secondary.
+	Reconnect(secondaryID int) (SecondaryTransport, error)
+
+	// CloseConn closes the connection to the primary
+	CloseConn()
+}
+
+// Check that the transports implement the interfaces
+var (
+	_ PrimaryTransport   = (*PrimaryServer)(nil)
+	_ PrimaryTransport   = (*MemoryTransport)(nil)
+	_ SecondaryTransport = (*ConnClient)(nil)
+	_ SecondaryTransport = (*MemorySecondary)(nil)
+)
@@ -0,0 +1,183 @@
+package communication
+
+import (
+	"diablo-benchmark/blockchains/workloadgenerators"
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"go.uber.org/zap"
+)
+
+// workloadProgressSteps is the number of progress logs of a workload transfer
+const workloadProgressSteps = 10
+
+// WorkloadReceiver assembles the workload of the secondary from its chunks.
+// It keeps the chunks received when the transfer stops, so that a new
+// transfer of the same workload resumes after them.
+type WorkloadReceiver struct {
+	header   WorkloadHeader                       // Header of the workload being received
+	workload workloadgenerators.SecondaryWorkload // Workload assembled from the chunks
+	received uint32                               // Number of chunks received, in order
+	err      error                                // First error of the transfer, replied at the end
+}
+
+// Start starts the transfer of the workload described by the header. If the
+// chunks of the same workload were already received, the transfer resumes.
+func (r *WorkloadReceiver) Start(header WorkloadHeader) (WorkloadProgress, error) {
+	if !ValidCompression(header.Compression) {
+		return WorkloadProgress{}, fmt.Errorf("unknown workload compression %s", header.Compression)
+	}
+
+	r.err = nil
+	if r.workload != nil && header.ID == r.header.ID {
+		zap.L().Info("Resuming workload transfer",
+			zap.Uint32("received", r.received),
+			zap.Uint32("chunks", header.Chunks))
+		return WorkloadProgress{Received: r.received}, nil
+	}
+
+	r.header = header
+	r.received = 0
+	r.workload = make(workloadgenerators.SecondaryWorkload, len(header.Intervals))
+	for worker, intervals := range header.Intervals {
+		r.workload[worker] = make(workloadgenerators.WorkerThreadWorkload, intervals)
+	}
+
+	return WorkloadProgress{}, nil
+}
+
+// Add decodes the chunk and adds it to the workload. Chunks already received
+// are ignored. Errors are kept and returned by Finish, since chunks are not
+// replied to.
+func (r *WorkloadReceiver) Add(data []byte) {
+	if r.err != nil {
+		return
+	}
+
+	if r.workload == nil {
+		r.err = fmt.Errorf("workload chunk received before %s", MsgWorkload)
+		return
+	}
+
+	chunk, err := DecodeWorkloadChunk(data, r.header.Compression)
+	if err != nil {
+		r.err = err
+		return
+	}
+
+	switch {
+	case chunk.Index < r.received:
+		return
+	case chunk.Index > r.received:
+		r.err = fmt.Errorf("workload chunk %d received, expected chunk %d", chunk.Index, r.received)
+		return
+	case int(chunk.Worker) >= len(r.workload) || int(chunk.Interval) >= len(r.workload[chunk.Worker]):
+		r.err = fmt.Errorf("workload chunk %d out of the workload (worker %d, interval %d)", chunk.Index, chunk.Worker, chunk.Interval)
+		return
+	}
+
+	r.workload[chunk.Worker][chunk.Interval] = chunk.Txs
+	r.received++
+}
+
+// Finish returns the workload once all its chunks are received
+func (r *WorkloadReceiver) Finish() (workloadgenerators.SecondaryWorkload, WorkloadProgress, error) {
+	progress := WorkloadProgress{Received: r.received}
+	if r.err != nil {
+		return nil, progress, r.err
+	}
+
+	if r.workload == nil || r.received != r.header.Chunks {
+		return nil, progress, fmt.Errorf("incomplete workload: %d of %d chunks received", r.received, r.header.Chunks)
+	}
+
+	return r.workload, progress, nil
+}
+
+// streamWorkload sends the workload of the secondary chunk by chunk. The
+// secondary replies to the header with the chunks it already has, and the
+// transfer resumes after them.
+func (s *PrimaryServer) streamWorkload(workloadID string, workload workloadgenerators.SecondaryWorkload, secondary net.Conn) error {
+	compression := s.WorkloadCompression
+	if compression == "" {
+		compression = CompressionNone
+	}
+
+	chunks := WorkloadChunks(workload)
+	header := WorkloadHeader{
+		ID:          workloadID,
+		Intervals:   make([]uint32, len(workload)),
+		Chunks:      uint32(len(chunks)),
+		Compression: compression,
+	}
+	for worker, intervals := range workload {
+		header.Intervals[worker] = uint32(len(intervals))
+	}
+
+	headerBytes, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	reply, err := s.exchange(MsgWorkload, headerBytes, secondary)
+	if err != nil {
+		return err
+	}
+
+	var progress WorkloadProgress
+	if err := json.Unmarshal(reply, &progress); err != nil {
+		return err
+	}
+
+	if progress.Received > 0 {
+		zap.L().Info("Resuming workload transfer",
+			zap.String("secondary", secondary.RemoteAddr().String()),
+			zap.Uint32("received", progress.Received),
+			zap.Int("chunks", len(chunks)))
+	}
+
+	sentBytes := 0
+	nextLog := 1
+	for i := int(progress.Received); i < len(chunks); i++ {
+		data, err := EncodeWorkloadChunk(chunks[i], compression)
+		if err != nil {
+			return err
+		}
+
+		if err := WriteFrame(secondary, MsgWorkloadChunk, data); err != nil {
+			return &SecondaryCommError{
+				SecondaryInfo: secondary.RemoteAddr().String(),
+				Err:           err,
+			}
+		}
+		sentBytes += len(data)
+
+		if (i+1)*workloadProgressSteps >= nextLog*len(chunks) {
+			zap.L().Info("Workload transfer",
+				zap.String("secondary", secondary.RemoteAddr().String()),
+				zap.Int("chunks", i+1),
+				zap.Int("total", len(chunks)),
+				zap.Int("bytes", sentBytes))
+			nextLog = (i+1)*workloadProgressSteps/len(chunks) + 1
+		}
+	}
+
+	reply, err = s.exchange(MsgWorkloadEnd, nil, secondary)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(reply, &progress); err != nil {
+		return err
+	}
+
+	if int(progress.Received) != len(chunks) {
+		return &SecondaryErrorReply{
+			Info: secondary.RemoteAddr().String(),
+			Err:  fmt.Errorf("secondary received %d of %d workload chunks", progress.Received, len(chunks)),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,212 @@
+package communication
+
+import (
+	"bytes"
+	"diablo-benchmark/blockchains/workloadgenerators"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// testWorkload returns a workload of 3 workers with 4 intervals of signed-like transactions
+func testWorkload() workloadgenerators.SecondaryWorkload {
+	workload := make(workloadgenerators.SecondaryWorkload, 3)
+	for worker := range workload {
+		workload[worker] = make(workloadgenerators.WorkerThreadWorkload, 4)
+		for interval := range workload[worker] {
+			for tx := 0; tx < 5+interval; tx++ {
+				workload[worker][interval] = append(workload[worker][interval],
+					[]byte(fmt.Sprintf(`{"nonce":"0x%x","input":"0xa9059cbb%064x"}`, tx, worker*100+interval)))
+			}
+		}
+	}
+
+	return workload
+}
+
+// serveWorkload receives workload transfers like a secondary until the end of
+// a transfer, and returns the number of chunks received
+func serveWorkload(conn net.Conn, r *WorkloadReceiver) int {
+	c := &ConnClient{Conn: conn}
+	received := 0
+
+	for {
+		cmd, err := ReadFrame(conn)
+		if err != nil {
+			return received
+		}
+
+		switch cmd.Type {
+		case MsgWorkload:
+			var header WorkloadHeader
+			_ = json.Unmarshal(cmd.Payload, &header)
+			progress, err := r.Start(header)
+			if err != nil {
+				c.ReplyERR(err.Error())
+				continue
+			}
+			data, _ := json.Marshal(progress)
+			c.SendDataOK(data)
+		case MsgWorkloadChunk:
+			r.Add(cmd.Payload)
+			received++
+		case MsgWorkloadEnd:
+			_, progress, err := r.Finish()
+			if err != nil {
+				c.ReplyERR(err.Error())
+				return received
+			}
+			data, _ := json.Marshal(progress)
+			c.SendDataOK(data)
+			return received
+		}
+	}
+}
+
+func TestWorkloadChunkEncoding(t *testing.T) {
+	chunk := WorkloadChunks(testWorkload())[6]
+
+	for _, compression := range []string{CompressionNone, CompressionDeflate} {
+		data, err := EncodeWorkloadChunk(chunk, compression)
+		if err != nil {
+			t.Fatalf("%s: failed to encode chunk: %s", compression, err.Error())
+		}
+
+		decoded, err := DecodeWorkloadChunk(data, compression)
+		if err != nil {
+			t.Fatalf("%s: failed to decode chunk: %s", compression, err.Error())
+		}
+
+		if !reflect.DeepEqual(decoded, chunk) {
+			t.Errorf("%s: expected %+v, got %+v", compression, chunk, decoded)
+		}
+	}
+
+	data, _ := EncodeWorkloadChunk(chunk, CompressionNone)
+	if _, err := DecodeWorkloadChunk(data[:len(data)-1], CompressionNone); err == nil {
+		t.Errorf("expected an error for a truncated chunk")
+	}
+}
+
+func TestStreamWorkload(t *testing.T) {
+	for _, compression := range []string{CompressionNone, CompressionDeflate} {
+		primary, secondary := net.Pipe()
+		workload := testWorkload()
+
+		r := &WorkloadReceiver{}
+		done := make(chan int)
+		go func() { done <- serveWorkload(secondary, r) }()
+
+		s := &PrimaryServer{WorkloadCompression: compression}
+		if err := s.streamWorkload("run", workload, primary); err != nil {
+			t.Fatalf("%s: failed to stream workload: %s", compression, err.Error())
+		}
+
+		if received := <-done; received != 12 {
+			t.Errorf("%s: expected 12 chunks, got %d", compression, received)
+		}
+
+		got, _, err := r.Finish()
+		if err != nil {
+			t.Fatalf("%s: failed to assemble workload: %s", compression, err.Error())
+		}
+
+		for worker := range workload {
+			for interval := range workload[worker] {
+				if !reflect.DeepEqual(got[worker][interval], workload[worker][interval]) {
+					t.Errorf("%s: worker %d interval %d differs", compression, worker, interval)
+				}
+			}
+		}
+
+		primary.Close()
+		secondary.Close()
+	}
+}
+
+func TestWorkloadCommand(t *testing.T) {
+	primary, secondary := net.Pipe()
+	defer primary.Close()
+	defer secondary.Close()
+	workload := testWorkload()
+
+	// The client assembles the chunks and returns the whole workload
+	got := make(chan Command, 1)
+	go func() {
+		c := &ConnClient{Conn: secondary}
+		cmd, err := c.ReadCommand()
+		if err != nil {
+			close(got)
+			return
+		}
+		got <- cmd
+		c.ReplyWorkload(nil)
+	}()
+
+	s := &PrimaryServer{}
+	if err := s.streamWorkload("run", workload, primary); err != nil {
+		t.Fatalf("failed to stream workload: %s", err.Error())
+	}
+
+	cmd := <-got
+	if cmd.Type != MsgWorkload || !reflect.DeepEqual(cmd.Workload, workload) {
+		t.Errorf("expected the workload command, got %s", cmd.Type)
+	}
+}
+
+func TestStreamWorkloadResume(t *testing.T) {
+	workload := testWorkload()
+	chunks := WorkloadChunks(workload)
+
+	// A first transfer stopped after 5 chunks
+	r := &WorkloadReceiver{}
+	header := WorkloadHeader{ID: "run", Intervals: []uint32{4, 4, 4}, Chunks: uint32(len(chunks)), Compression: CompressionNone}
+	if _, err := r.Start(header); err != nil {
+		t.Fatalf("failed to start transfer: %s", err.Error())
+	}
+	for _, chunk := range chunks[:5] {
+		data, _ := EncodeWorkloadChunk(chunk, CompressionNone)
+		r.Add(data)
+	}
+
+	primary, secondary := net.Pipe()
+	defer primary.Close()
+	defer secondary.Close()
+
+	done := make(chan int)
+	go func() { done <- serveWorkload(secondary, r) }()
+
+	s := &PrimaryServer{}
+	if err := s.streamWorkload("run", workload, primary); err != nil {
+		t.Fatalf("failed to resume workload: %s", err.Error())
+	}
+
+	if received := <-done; received != len(chunks)-5 {
+		t.Errorf("expected the %d remaining chunks, got %d", len(chunks)-5, received)
+	}
+
+	got, _, err := r.Finish()
+	if err != nil {
+		t.Fatalf("failed to assemble workload: %s", err.Error())
+	}
+	if !bytes.Equal(got[2][3][7], workload[2][3][7]) {
+		t.Errorf("expected the last transaction %s, got %s", workload[2][3][7], got[2][3][7])
+	}
+}
+
+func TestStreamWorkloadMissingChunk(t *testing.T) {
+	chunks := WorkloadChunks(testWorkload())
+
+	r := &WorkloadReceiver{}
+	_, _ = r.Start(WorkloadHeader{ID: "run", Intervals: []uint32{4, 4, 4}, Chunks: uint32(len(chunks)), Compression: CompressionNone})
+	for _, i := range []int{0, 2} {
+		data, _ := EncodeWorkloadChunk(chunks[i], CompressionNone)
+		r.Add(data)
+	}
+
+	if _, progress, err := r.Finish(); err == nil || progress.Received != 1 {
+		t.Errorf("expected an error after 1 chunk, got %v after %d", err, progress.Received)
+	}
+}
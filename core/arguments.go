@@ -1,6 +1,7 @@
 package core
 
 import (
+	"diablo-benchmark/communication"
 	"flag"
 	"os"
 
@@ -12,8 +13,10 @@ import (
 type Arguments struct {
 	PrimaryCommand   *flag.FlagSet  // Commands related to the primary
 	SecondaryCommand *flag.FlagSet  // Commands related to the secondarys
+	LocalCommand     *flag.FlagSet  // Commands related to the local mode
 	PrimaryArgs      *PrimaryArgs   // Primary arguments
 	SecondaryArgs    *SecondaryArgs // Secondary arguments
+	LocalArgs        *LocalArgs     // Local mode arguments
 }
 
 // PrimaryArgs contains the command-line arguments for the primary
@@ -23,6 +26,10 @@ type PrimaryArgs struct {
 	ListenAddr      string        // host:port that it should run on
 	LogLevel        zapcore.Level // log level
 	Timeout         int           // benchmark timeout
+	Compression     string        // compression of the workload sent to the secondaries
+	TLSCert         string        // certificate for mutual TLS with the secondaries
+	TLSKey          string        // key of the TLS certificate
+	Token           string        // token the secondaries must have to join
 }
 
 // SecondaryArgs provides command-line arguments for secondary
@@ -32,6 +39,19 @@ type SecondaryArgs struct {
 	PrimaryAddr     string        // Address of the primary (can also be in secondary config)
 	LogLevel        zapcore.Level // log level
 	Timeout         int           // benchmark timeout
+	TLSCert         string        // certificate for mutual TLS with the primary
+	TLSKey          string        // key of the TLS certificate
+	Token           string        // token shared with the primary
+}
+
+// LocalArgs contains the command-line arguments for the local mode, running
+// the primary and the secondaries in one process
+type LocalArgs struct {
+	BenchConfigPath string        // Path to the configurations
+	ChainConfigPath string        // Path to the chain configuration
+	LogLevel        zapcore.Level // log level
+	Timeout         int           // benchmark timeout
+	ResultsDir      string        // directory the results are written to
 }
 
 // DefineArguments sets the arguments that will be used for the subcommands
@@ -39,9 +59,11 @@ func DefineArguments() *Arguments {
 
 	primaryCommand := flag.NewFlagSet("primary", flag.ExitOnError)
 	secondaryCommand := flag.NewFlagSet("secondary", flag.ExitOnError)
+	localCommand := flag.NewFlagSet("local", flag.ExitOnError)
 
 	primaryArgs := PrimaryArgs{}
 	secondaryArgs := SecondaryArgs{}
+	localArgs := LocalArgs{}
 
 	// General arguments
 	// --config
@@ -50,18 +72,32 @@ func DefineArguments() *Arguments {
 	primaryCommand.StringVar(&primaryArgs.BenchConfigPath, "c", "", "-c /path/to/config")
 	secondaryCommand.StringVar(&secondaryArgs.BenchConfigPath, "config", "", "--config=/path/to/config (required)")
 	secondaryCommand.StringVar(&secondaryArgs.BenchConfigPath, "c", "", "-c /path/to/config")
+	localCommand.StringVar(&localArgs.BenchConfigPath, "config", "", "--config=/path/to/config (required)")
+	localCommand.StringVar(&localArgs.BenchConfigPath, "c", "", "-c /path/to/config")
 
 	//--timeout
 	primaryCommand.IntVar(&primaryArgs.Timeout, "t", 0, "-t <timeout>")
 	primaryCommand.IntVar(&primaryArgs.Timeout, "timeout", 0, "--timeout=<timeout>")
 	secondaryCommand.IntVar(&secondaryArgs.Timeout, "t", 0, "-t <timeout>")
 	secondaryCommand.IntVar(&secondaryArgs.Timeout, "timeout", 0, "--timeout=<timeout>")
+	localCommand.IntVar(&localArgs.Timeout, "t", 0, "-t <timeout>")
+	localCommand.IntVar(&localArgs.Timeout, "timeout", 0, "--timeout=<timeout>")
 
 	// --level
 	primaryArgs.LogLevel = zapcore.InfoLevel
 	primaryCommand.Var(&primaryArgs.LogLevel, "level", "--level INFO|WARN|DEBUG|ERROR")
 	secondaryArgs.LogLevel = zapcore.InfoLevel
 	secondaryCommand.Var(&secondaryArgs.LogLevel, "level", "--level INFO|WARN|DEBUG|ERROR")
+	localArgs.LogLevel = zapcore.InfoLevel
+	localCommand.Var(&localArgs.LogLevel, "level", "--level INFO|WARN|DEBUG|ERROR")
+
+	// --tls-cert, --tls-key, --token
+	primaryCommand.StringVar(&primaryArgs.TLSCert, "tls-cert", "", "--tls-cert=/path/to/cert.pem (also the trusted certificate of the secondaries)")
+	primaryCommand.StringVar(&primaryArgs.TLSKey, "tls-key", "", "--tls-key=/path/to/key.pem")
+	primaryCommand.StringVar(&primaryArgs.Token, "token", "", "--token=<secret shared with the secondaries>")
+	secondaryCommand.StringVar(&secondaryArgs.TLSCert, "tls-cert", "", "--tls-cert=/path/to/cert.pem (also the trusted certificate of the primary)")
+	secondaryCommand.StringVar(&secondaryArgs.TLSKey, "tls-key", "", "--tls-key=/path/to/key.pem")
+	secondaryCommand.StringVar(&secondaryArgs.Token, "token", "", "--token=<secret shared with the primary>")
 
 	// Primary Arguments
 	primaryCommand.StringVar(&primaryArgs.ListenAddr, "addr", "", "--addr=addr (e.g. --addr=\"0.0.0.0:8323\")")
@@ -70,6 +106,8 @@ func DefineArguments() *Arguments {
 	primaryCommand.StringVar(&primaryArgs.ChainConfigPath, "chain-config", "", "--chain-config=/path/to/chain/yml (required)")
 	primaryCommand.StringVar(&primaryArgs.ChainConfigPath, "cc", "", "-cc /path/to/chain/yml")
 
+	primaryCommand.StringVar(&primaryArgs.Compression, "compression", communication.CompressionNone, "--compression=none|deflate")
+
 	// Secondary Arguments
 	secondaryCommand.StringVar(&secondaryArgs.PrimaryAddr, "primary", "", "--primary=<ipaddr>:<port>")
 	secondaryCommand.StringVar(&secondaryArgs.PrimaryAddr, "m", "", "-m <ipaddress>:<port>")
@@ -77,12 +115,20 @@ func DefineArguments() *Arguments {
 	secondaryCommand.StringVar(&secondaryArgs.ChainConfigPath, "chain-config", "", "--chain-config=/path/to/chain/yml (required)")
 	secondaryCommand.StringVar(&secondaryArgs.ChainConfigPath, "cc", "", "-cc /path/to/chain/yml")
 
+	// Local Arguments
+	localCommand.StringVar(&localArgs.ChainConfigPath, "chain-config", "", "--chain-config=/path/to/chain/yml (required)")
+	localCommand.StringVar(&localArgs.ChainConfigPath, "cc", "", "-cc /path/to/chain/yml")
+
+	localCommand.StringVar(&localArgs.ResultsDir, "results", "results", "--results=/path/to/results/dir")
+
 	// Return all the arguments
 	return &Arguments{
 		PrimaryCommand:   primaryCommand,   // The primary command FlagSet
 		SecondaryCommand: secondaryCommand, // The secondary command FlagSet
+		LocalCommand:     localCommand,     // The local command FlagSet
 		PrimaryArgs:      &primaryArgs,     // The primary argument list, contains config and other args
 		SecondaryArgs:    &secondaryArgs,   // The secondary argument list, contains config and other args
+		LocalArgs:        &localArgs,       // The local argument list, contains config and other args
 	}
 }
 
@@ -97,6 +143,22 @@ func (pa *PrimaryArgs) CheckArgs() {
 		zap.L().Error("chain configuration not provided")
 		os.Exit(1)
 	}
+
+	if !communication.ValidCompression(pa.Compression) {
+		zap.L().Error("unknown workload compression",
+			zap.String("compression", pa.Compression))
+		os.Exit(1)
+	}
+
+	checkTLSArgs(pa.TLSCert, pa.TLSKey)
+}
+
+// checkTLSArgs checks that the TLS certificate and key are given together
+func checkTLSArgs(cert string, key string) {
+	if (cert == "") != (key == "") {
+		zap.L().Error("both --tls-cert and --tls-key are required for TLS")
+		os.Exit(1)
+	}
 }
 
 // SecondaryArgs validates that the secondary arguments are correct
@@ -116,4 +178,19 @@ func (sa *SecondaryArgs) SecondaryArgs() {
 		zap.L().Error("no chain config provided")
 		os.Exit(1)
 	}
+
+	checkTLSArgs(sa.TLSCert, sa.TLSKey)
+}
+
+// CheckArgs checks that the local arguments conform to specified requirements
+func (la *LocalArgs) CheckArgs() {
+	if la.BenchConfigPath == "" {
+		zap.L().Error("benchmark config not provided")
+		os.Exit(1)
+	}
+
+	if la.ChainConfigPath == "" {
+		zap.L().Error("chain configuration not provided")
+		os.Exit(1)
+	}
 }
@@ -4,25 +4,47 @@
 // to the generation of the workload.
 package configs
 
-import "diablo-benchmark/core/workload"
+import (
+	"diablo-benchmark/core/workload"
+	"path/filepath"
+	"strings"
+)
 
 // BenchConfig provides the main benchmark configuration structure, all information about the specified workload
 type BenchConfig struct {
-	Name         string       `yaml:"name"` // Name of the benchmark.
-	Path         string       // The location of this benchmark file (to be used in result printing)
-	Description  string       `yaml:"description,omitempty"` // Description of what it is.
-	Threads      int          `yaml:"threads"`               // Number of threads per secondary expected.
-	Secondaries  int          `yaml:"secondaries"`           // Number of secondary machines.
-	Timeout      int          `yaml:"timeout"`               // Timeout for the benchmark after sending
-	TxInfo       BenchInfo    `yaml:"bench,flow"`            // Benchmark transaction information.
-	ContractInfo ContractInfo `yaml:"contract,omitempty"`    // Contract Information
+	Name             string         `yaml:"name"` // Name of the benchmark.
+	Path             string         // The location of this benchmark file (to be used in result printing)
+	Description      string         `yaml:"description,omitempty"`      // Description of what it is.
+	Threads          int            `yaml:"threads"`                    // Number of threads per secondary expected.
+	Secondaries      int            `yaml:"secondaries"`                // Number of secondary machines.
+	Timeout          int            `yaml:"timeout"`                    // Timeout for the benchmark after sending
+	AbortFailureRate float64        `yaml:"abortFailureRate,omitempty"` // Fraction of failed transactions above which the run is aborted, 0 never aborts.
+	Generation       Generation     `yaml:"generation,omitempty"`       // Where the workload is generated (primary, secondary), default is primary.
+	Seed             int64          `yaml:"seed,omitempty"`             // Seed of the random choices of the workload, the same seed generates the same workload.
+	TxInfo           BenchInfo      `yaml:"bench,flow"`                 // Benchmark transaction information.
+	ContractInfo     ContractInfo   `yaml:"contract,omitempty"`         // Contract Information
+	Contracts        []ContractInfo `yaml:"contracts,omitempty"`        // Contracts of the workload, in place of contract to call several of them.
+}
+
+// ContractInfos returns the contracts of the workload, the contracts given as
+// a list or the contract.
+func (c *BenchConfig) ContractInfos() []ContractInfo {
+	if len(c.Contracts) > 0 {
+		return c.Contracts
+	}
+
+	return []ContractInfo{c.ContractInfo}
 }
 
 // BenchInfo provides specific information about transaction type and intervals
 type BenchInfo struct {
-	TxType      BenchTransactionType              `yaml:"type"`               // Type of the transactions (simple, contract).
-	DataPath    string                            `yaml:"datapath,omitempty"` // Data path of the transactions
-	Intervals   TPSIntervals                      `yaml:"txs"`                // Transactions.
+	TxType      BenchTransactionType              `yaml:"type"`                  // Type of the transactions (simple, contract).
+	DataPath    string                            `yaml:"datapath,omitempty"`    // Data path of the transactions
+	Intervals   TPSIntervals                      `yaml:"txs"`                   // Transactions.
+	Mode        LoadMode                          `yaml:"mode,omitempty"`        // How the transactions are sent (open, closed), default is open.
+	Outstanding int                               `yaml:"outstanding,omitempty"` // Transactions in flight per worker in closed loop.
+	Pacing      Pacing                            `yaml:"pacing,omitempty"`      // How the transactions are spread in an interval (even, poisson, burst), default is even.
+	Mix         FunctionMix                       `yaml:"mix,omitempty"`         // How the contract functions are mixed (interleaved, shuffled, random), default is interleaved.
 	PremadeInfo workload.PremadeBenchmarkWorkload // Premade workload (if exists)
 }
 
@@ -36,14 +58,44 @@ type ContractParam struct {
 type ContractFunction struct {
 	Name     string          `yaml:"name"`        // The name identifier for the function e.g. storeVal(uint32)
 	Type     string          `yaml:"ftype"`       // Function type: "read", "write", "deploy" (note: deploy is for the constructor).
-	Ratio    int             `yaml:"ratio"`       // Percentage of the workload that this function calls take.
+	Ratio    int             `yaml:"ratio"`       // Percentage of the calls of the contract that this function takes, the functions share them equally if all are 0.
 	PayValue string          `yaml:"value"`       // Monetary value to send to the contract with this transaction, default is 0.
 	Params   []ContractParam `yaml:"params,flow"` // Parameters of the function
 }
 
 // ContractInfo defining the path and functions that would be called.
 type ContractInfo struct {
-	Path      string             `yaml:"path"`           // Path of the contract file to be deployed (e.g. Solidity File).
-	Name      string             `yaml:"name"`           // The contract name (required for multiple deployed contracts)
-	Functions []ContractFunction `yaml:"functions,flow"` // Functions that should be called.
+	Ratio     int                `yaml:"ratio,omitempty"`       // Percentage of the workload calling this contract, with several contracts.
+	Path      string             `yaml:"path"`                  // Path of the contract to be deployed: Solidity file, Hardhat/Truffle JSON artifact or .bin bytecode.
+	Name      string             `yaml:"name"`                  // The contract name (required for multiple deployed contracts)
+	ABI       string             `yaml:"abi,omitempty"`         // ABI file of the .bin bytecode or of the contract at the address, next to the .bin bytecode with the .abi extension by default.
+	Address   string             `yaml:"address,omitempty"`     // Address of a deployed contract to call instead of deploying it.
+	Params    []ContractParam    `yaml:"params,flow,omitempty"` // Parameters of the constructor.
+	Functions []ContractFunction `yaml:"functions,flow"`        // Functions that should be called.
+}
+
+// ABIPath returns the path of the ABI of the contract, given with the .bin
+// bytecode or the address. It is empty if the ABI comes with the contract.
+func (ci ContractInfo) ABIPath() string {
+	if ci.ABI != "" {
+		return ci.ABI
+	}
+	if filepath.Ext(ci.Path) == ".bin" {
+		return strings.TrimSuffix(ci.Path, ".bin") + ".abi"
+	}
+
+	return ""
+}
+
+// Label names the contract in the errors and the results, by its name, its
+// address or its path.
+func (ci ContractInfo) Label() string {
+	switch {
+	case ci.Name != "":
+		return ci.Name
+	case ci.Address != "":
+		return ci.Address
+	default:
+		return ci.Path
+	}
 }
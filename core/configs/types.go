@@ -26,6 +26,62 @@ const (
 	TxTypeContention = "contention"
 )
 
+// LoadMode is the way the workers send the transactions of the workload
+type LoadMode string
+
+const (
+	// LoadModeOpen sends the transactions of each interval every second,
+	// whether or not the previous transactions are committed. This is the
+	// default.
+	LoadModeOpen LoadMode = "open"
+	// LoadModeClosed keeps a fixed number of transactions in flight per
+	// worker, the next transaction is sent once one is committed or failed.
+	LoadModeClosed LoadMode = "closed"
+)
+
+// Pacing is how the transactions of an interval are spread over the second
+type Pacing string
+
+const (
+	// PacingEven sends the transactions of an interval at regular times over
+	// the second. This is the default.
+	PacingEven Pacing = "even"
+	// PacingPoisson sends the transactions of an interval at the arrival times
+	// of a Poisson process, uniformly distributed over the second.
+	PacingPoisson Pacing = "poisson"
+	// PacingBurst sends all the transactions of an interval at the start of the second
+	PacingBurst Pacing = "burst"
+)
+
+// Generation is where the workload is generated
+type Generation string
+
+const (
+	// GenerationPrimary generates the whole workload on the primary, which
+	// sends each secondary its transactions. This is the default.
+	GenerationPrimary Generation = "primary"
+	// GenerationSecondary has each secondary generate its part of the
+	// workload from the partition given by the primary.
+	GenerationSecondary Generation = "secondary"
+)
+
+// FunctionMix is how the calls of the contract functions are mixed in the
+// transactions of each worker
+type FunctionMix string
+
+const (
+	// FunctionMixInterleaved calls each function its exact share of the
+	// transactions, as evenly as possible between the calls of the others.
+	// This is the default.
+	FunctionMixInterleaved FunctionMix = "interleaved"
+	// FunctionMixShuffled calls each function its exact share of the
+	// transactions, in an order shuffled with the seed of the benchmark.
+	FunctionMixShuffled FunctionMix = "shuffled"
+	// FunctionMixRandom picks the function of each transaction at random,
+	// weighted by the ratios, with the seed of the benchmark.
+	FunctionMixRandom FunctionMix = "random"
+)
+
 // DefaultTimeout is the default timeout for the benchmark if not provided
 // or overwritten by the args
 const DefaultTimeout int = 20
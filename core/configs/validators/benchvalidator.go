@@ -12,6 +12,7 @@ import (
 	"os"
 	"reflect"
 
+	"github.com/ethereum/go-ethereum/common"
 	"go.uber.org/zap"
 )
 
@@ -40,38 +41,69 @@ func ValidateBenchConfig(c *configs.BenchConfig) (bool, error) {
 		return false, fmt.Errorf("number of threads must be minimum 1")
 	}
 
+	// The failure rate is a fraction of the transactions
+	if c.AbortFailureRate < 0 || c.AbortFailureRate > 1 {
+		return false, fmt.Errorf("[%s] abort failure rate %v must be between 0 and 1 (\"abortFailureRate\")", c.Name, c.AbortFailureRate)
+	}
+
 	// Contract Checks!
-	if c.TxInfo.TxType == configs.TxTypeContract {
-		// Check if it's empty
-		if reflect.DeepEqual(configs.ContractInfo{}, c.ContractInfo) {
-			return false, fmt.Errorf("[%s] empty contract info for contract workload in", c.Name)
-		}
+	if len(c.Contracts) > 0 && !reflect.DeepEqual(configs.ContractInfo{}, c.ContractInfo) {
+		return false, fmt.Errorf("[%s] both contract and contracts given, only one of them can be", c.Name)
+	}
 
-		// Check that the contract exists.
-		if c.ContractInfo.Path == "" {
-			return false, fmt.Errorf("[%s] empty path for contract in config", c.Name)
-		}
+	// Check if it's empty
+	if c.TxInfo.TxType == configs.TxTypeContract && len(c.Contracts) == 0 && reflect.DeepEqual(configs.ContractInfo{}, c.ContractInfo) {
+		return false, fmt.Errorf("[%s] empty contract info for contract workload in", c.Name)
+	}
 
-		info, err := os.Stat(c.ContractInfo.Path)
-		if err != nil {
+	ratios := 0
+	for _, contract := range c.ContractInfos() {
+		if err := validateContract(c, contract); err != nil {
 			return false, err
 		}
+		ratios += contract.Ratio
+	}
 
-		// If it is a directory - then error
-		if info.IsDir() {
-			return false, fmt.Errorf("[%s] contract path (%s) is a directory", c.Name, c.ContractInfo.Path)
-		}
+	// The contracts share the workload
+	if len(c.Contracts) > 1 && ratios != 100 {
+		return false, fmt.Errorf("[%s] ratios of the contracts add up to %d, not 100", c.Name, ratios)
+	}
 
-		// Check that the functions aren't empty.
-		if len(c.ContractInfo.Functions) == 0 {
-			return false, fmt.Errorf("[%s] no functions provided for contract", c.Name)
-		}
-	} else if c.TxInfo.TxType == configs.TxTypePremade {
+	if c.TxInfo.TxType == configs.TxTypePremade {
 		if len(c.TxInfo.DataPath) == 0 {
 			return false, fmt.Errorf("[%s] data path not provided for premade benchmark (missing \"datapath\")", c.Name)
 		}
 	}
 
+	// Closed loop needs transactions in flight.
+	switch c.TxInfo.Mode {
+	case "", configs.LoadModeOpen:
+	case configs.LoadModeClosed:
+		if c.TxInfo.Outstanding < 1 {
+			return false, fmt.Errorf("[%s] closed loop requires at least 1 outstanding transaction (\"outstanding\")", c.Name)
+		}
+	default:
+		return false, fmt.Errorf("[%s] unknown load mode %q (open, closed)", c.Name, c.TxInfo.Mode)
+	}
+
+	switch c.TxInfo.Pacing {
+	case "", configs.PacingEven, configs.PacingPoisson, configs.PacingBurst:
+	default:
+		return false, fmt.Errorf("[%s] unknown pacing %q (even, poisson, burst)", c.Name, c.TxInfo.Pacing)
+	}
+
+	switch c.TxInfo.Mix {
+	case "", configs.FunctionMixInterleaved, configs.FunctionMixShuffled, configs.FunctionMixRandom:
+	default:
+		return false, fmt.Errorf("[%s] unknown function mix %q (interleaved, shuffled, random)", c.Name, c.TxInfo.Mix)
+	}
+
+	switch c.Generation {
+	case "", configs.GenerationPrimary, configs.GenerationSecondary:
+	default:
+		return false, fmt.Errorf("[%s] unknown generation %q (primary, secondary)", c.Name, c.Generation)
+	}
+
 	// Intervals cannot be empty.
 	if len(c.TxInfo.Intervals) == 0 {
 		return false, errors.New("no tps intervals provided")
@@ -93,3 +125,66 @@ func ValidateBenchConfig(c *configs.BenchConfig) (bool, error) {
 
 	return true, nil
 }
+
+// validateContract validates a contract of the benchmark, its path is only
+// needed for contract workloads.
+func validateContract(c *configs.BenchConfig, contract configs.ContractInfo) error {
+	if contract.Address != "" && !common.IsHexAddress(contract.Address) {
+		return fmt.Errorf("[%s] contract address %q is not an address", c.Name, contract.Address)
+	}
+
+	if contract.Ratio < 0 {
+		return fmt.Errorf("[%s] ratio %d of contract %q cannot be negative", c.Name, contract.Ratio, contract.Label())
+	}
+
+	// The functions share the calls of the contract, equally if no ratio is given
+	ratios := 0
+	for _, function := range contract.Functions {
+		if function.Ratio < 0 {
+			return fmt.Errorf("[%s] ratio %d of function %q cannot be negative", c.Name, function.Ratio, function.Name)
+		}
+		ratios += function.Ratio
+	}
+	if ratios != 0 && ratios != 100 {
+		return fmt.Errorf("[%s] ratios of the functions of contract %q add up to %d, not 100", c.Name, contract.Label(), ratios)
+	}
+
+	if c.TxInfo.TxType != configs.TxTypeContract {
+		return nil
+	}
+
+	// Check that the contract exists, a deployed contract only needs
+	// its ABI.
+	if contract.Path == "" && contract.Address == "" {
+		return fmt.Errorf("[%s] empty path for contract in config", c.Name)
+	}
+	if contract.Path == "" && contract.ABI == "" {
+		return fmt.Errorf("[%s] empty path and ABI for deployed contract in config", c.Name)
+	}
+
+	if contract.Path != "" {
+		info, err := os.Stat(contract.Path)
+		if err != nil {
+			return err
+		}
+
+		// If it is a directory - then error
+		if info.IsDir() {
+			return fmt.Errorf("[%s] contract path (%s) is a directory", c.Name, contract.Path)
+		}
+	}
+
+	// The calls are encoded with the ABI
+	if abiPath := contract.ABIPath(); abiPath != "" {
+		if _, err := os.Stat(abiPath); err != nil {
+			return fmt.Errorf("[%s] ABI of the contract: %w", c.Name, err)
+		}
+	}
+
+	// Check that the functions aren't empty.
+	if len(contract.Functions) == 0 {
+		return fmt.Errorf("[%s] no functions provided for contract", c.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"diablo-benchmark/core/configs"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// scheduledTx is a transaction of the workload with the time it should be sent
+type scheduledTx struct {
+	tx interface{} // Transaction to send
+	at time.Time   // Time to send the transaction, zero to send it immediately
+}
+
+// SendTime is the scheduled and actual send time of a transaction
+type SendTime struct {
+	Scheduled time.Time // Time the transaction was scheduled to be sent, zero if not paced
+	Actual    time.Time // Time the transaction was sent
+}
+
+// Delay returns how late the transaction was sent compared to its schedule
+func (st SendTime) Delay() time.Duration {
+	if st.Scheduled.IsZero() {
+		return 0
+	}
+
+	return st.Actual.Sub(st.Scheduled)
+}
+
+// pacingOffsets returns the offsets from the start of the interval at which
+// the n transactions of a one second interval are sent, in increasing order.
+func pacingOffsets(pacing configs.Pacing, n int, rng *rand.Rand) []time.Duration {
+	offsets := make([]time.Duration, n)
+
+	switch pacing {
+	case configs.PacingBurst:
+		// All at the start of the interval
+	case configs.PacingPoisson:
+		// Given the number of arrivals in the interval, the arrival times of a
+		// Poisson process are uniformly distributed over the interval.
+		for i := range offsets {
+			offsets[i] = time.Duration(rng.Int63n(int64(time.Second)))
+		}
+		sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	default:
+		for i := range offsets {
+			offsets[i] = time.Duration(i) * time.Second / time.Duration(n)
+		}
+	}
+
+	return offsets
+}
@@ -2,12 +2,14 @@
 package handlers
 
 import (
+	"context"
 	"diablo-benchmark/blockchains/clientinterfaces"
 	"diablo-benchmark/blockchains/workloadgenerators"
 	"diablo-benchmark/core/configs"
 	"diablo-benchmark/core/results"
 	"errors"
 	"fmt"
+	"math/rand"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -19,17 +21,26 @@ import (
 // WorkloadHandler is the main handler loop that dispatches the workload into channels and creates routines that will read and send
 type WorkloadHandler struct {
 	numThread            uint32                                 // Number of workers
-	workerThreadChannels []chan interface{}                     // Channels between the threads to have the workload from
+	workerThreadChannels []chan scheduledTx                     // Channels between the threads to have the workload from
 	activeClients        []clientinterfaces.BlockchainInterface // Number of client threads that are run
 	FullWorkload         [][][]interface{}                      // Workload
-	readyChannels        []chan bool                            // channels that signal ready to start
-	wg                   *sync.WaitGroup                        // All threads done
 	numTx                uint64                                 // number of transactions sent
 	numErrors            uint64                                 // Number of errors during workload
 	StartEnd             []time.Time                            // Start and end of the benchmark
 	timeout              int                                    // Timeout to wait for the benchmark
+	loadMode             configs.LoadMode                       // How the transactions are sent, open or closed loop
+	outstanding          int                                    // Transactions in flight per worker in closed loop
+	pacing               configs.Pacing                         // How the transactions are spread in an interval
+	sendTimes            [][]SendTime                           // Scheduled and actual send time of the transactions per worker
+	startAt              time.Time                              // Time to start the benchmark at, zero to start immediately
+	sendLags             []int64                                // Delay of the latest send behind its schedule per worker (ns)
+	interrupted          bool                                   // Whether the last run was cancelled before its end
 }
 
+// closedLoopPoll is the interval at which a worker in closed loop checks the
+// done transactions, in case the client interface does not signal them.
+const closedLoopPoll = 100 * time.Millisecond
+
 // NewWorkloadHandler provides a new workload handler with number of threads and clients
 func NewWorkloadHandler(numThread uint32, clients []clientinterfaces.BlockchainInterface, timeout int) *WorkloadHandler {
 	// Generate the channels to speak to the workers.
@@ -37,7 +48,72 @@ func NewWorkloadHandler(numThread uint32, clients []clientinterfaces.BlockchainI
 		numThread:     numThread,
 		activeClients: clients,
 		timeout:       timeout,
+		loadMode:      configs.LoadModeOpen,
+		pacing:        configs.PacingEven,
+	}
+}
+
+// Interrupted returns whether the last run was cancelled before its end, its
+// results are then partial.
+func (wh *WorkloadHandler) Interrupted() bool {
+	return wh.interrupted
+}
+
+// Telemetry returns the progress of the benchmark, it is safe to call while
+// the benchmark is running.
+func (wh *WorkloadHandler) Telemetry() results.Telemetry {
+	t := results.Telemetry{
+		Sent:   atomic.LoadUint64(&wh.numTx),
+		Failed: atomic.LoadUint64(&wh.numErrors),
+	}
+
+	for _, c := range wh.activeClients {
+		failed := c.GetTxFailed()
+		t.Committed += c.GetTxDone() - failed
+		t.Failed += failed
+	}
+
+	var lag int64
+	for i := range wh.sendLags {
+		if l := atomic.LoadInt64(&wh.sendLags[i]); l > lag {
+			lag = l
+		}
+	}
+	t.SendLag = float64(lag/1000) / 1000
+
+	return t
+}
+
+// SetPacing sets how the transactions of an interval are spread over the second
+func (wh *WorkloadHandler) SetPacing(pacing configs.Pacing) {
+	if pacing == "" {
+		pacing = configs.PacingEven
+	}
+
+	wh.pacing = pacing
+}
+
+// SetStartTime sets the time at which RunBench starts sending, so that all
+// the secondaries start together.
+func (wh *WorkloadHandler) SetStartTime(t time.Time) {
+	wh.startAt = t
+}
+
+// SendTimes returns the scheduled and actual send time of the transactions
+// sent by each worker, in the order they were sent.
+func (wh *WorkloadHandler) SendTimes() [][]SendTime {
+	return wh.sendTimes
+}
+
+// SetLoadMode sets how the workers send the transactions. In closed loop,
+// each worker keeps the outstanding number of transactions in flight.
+func (wh *WorkloadHandler) SetLoadMode(mode configs.LoadMode, outstanding int) {
+	if mode == "" {
+		mode = configs.LoadModeOpen
 	}
+
+	wh.loadMode = mode
+	wh.outstanding = outstanding
 }
 
 // Connect initialises the clients and connects to the nodes
@@ -63,10 +139,9 @@ func (wh *WorkloadHandler) Connect(chainConfig *configs.ChainConfig, ID int) err
 func (wh *WorkloadHandler) ParseWorkloads(rawWorkload workloadgenerators.SecondaryWorkload) error {
 
 	// Set up the workload channels
-	var readyChannels []chan bool
-	var wg sync.WaitGroup
-
+	var workerChannels []chan scheduledTx
 	var fullWorkload [][][]interface{}
+	sendTimes := make([][]SendTime, len(rawWorkload))
 
 	for i, workerWorkload := range rawWorkload {
 		// Should be able to parse the workloads from transactions into bytes
@@ -80,72 +155,79 @@ func (wh *WorkloadHandler) ParseWorkloads(rawWorkload workloadgenerators.Seconda
 			channelSize += len(v)
 		}
 
-		readyChannel := make(chan bool, 0)
-		readyChannels = append(readyChannels, readyChannel)
-
-		workerChannel := make(chan interface{}, channelSize)
-		wg.Add(1)
-		// Make my consumer
-		go wh.runnerConsumer(
-			wh.activeClients[i],
-			workerChannel,
-			&wg,
-		)
-
-		// Start the worker producer
-		go wh.workloadProducer(
-			parsedWorkerWorkload,
-			workerChannel,
-			readyChannel,
-			i,
-		)
-
+		workerChannels = append(workerChannels, make(chan scheduledTx, channelSize))
+		sendTimes[i] = make([]SendTime, 0, channelSize)
 		fullWorkload = append(fullWorkload, parsedWorkerWorkload)
 	}
 
 	wh.FullWorkload = fullWorkload
-	wh.readyChannels = readyChannels
-	wh.wg = &wg
+	wh.workerThreadChannels = workerChannels
+	wh.sendTimes = sendTimes
+	wh.sendLags = make([]int64, len(rawWorkload))
 	return nil
 }
 
-// workloadProducer producer that places transactions into the queue and handles naive rate limiting.
-func (wh *WorkloadHandler) workloadProducer(workload [][]interface{}, workerChan chan interface{}, ready chan bool, id int) {
+// workloadProducer producer that schedules the transactions of each interval
+// over its second, according to the pacing, and places them into the queue.
+// The consumer sends them at their scheduled time. It stops when the context
+// is cancelled.
+func (wh *WorkloadHandler) workloadProducer(ctx context.Context, workload [][]interface{}, workerChan chan scheduledTx, id int) {
 	zap.L().Debug(fmt.Sprintf("producer %d ready", id))
-	<-ready
-	currentIterator := 1
-	for _, v := range workload[0] {
-		workerChan <- v
-	}
+	defer close(workerChan)
 
-	// Set up the timer
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			for _, v := range workload[currentIterator] {
-				workerChan <- v
-			}
-			currentIterator++
-			if currentIterator >= len(workload) {
-				close(workerChan)
+	// In closed loop, the consumer paces the transactions
+	if wh.loadMode == configs.LoadModeClosed {
+		for _, interval := range workload {
+			if ctx.Err() != nil {
 				return
 			}
+			for _, v := range interval {
+				workerChan <- scheduledTx{tx: v}
+			}
 		}
+		return
 	}
 
+	// The intervals are relative to the start of the benchmark
+	start := wh.StartEnd[0]
+	rng := rand.New(rand.NewSource(start.UnixNano() + int64(id)))
+	for i, interval := range workload {
+		if ctx.Err() != nil {
+			return
+		}
+		intervalStart := start.Add(time.Duration(i) * time.Second)
+		offsets := pacingOffsets(wh.pacing, len(interval), rng)
+		for j, v := range interval {
+			workerChan <- scheduledTx{tx: v, at: intervalStart.Add(offsets[j])}
+		}
+	}
 }
 
-// runnerConsumer consumer that runs the workload pulling from the channel
-func (wh *WorkloadHandler) runnerConsumer(blockchainInterface clientinterfaces.BlockchainInterface, workload chan interface{}, wg *sync.WaitGroup) {
+// runnerConsumer consumer that runs the workload pulling from the channel,
+// sending each transaction at its scheduled time until the context is cancelled
+func (wh *WorkloadHandler) runnerConsumer(ctx context.Context, blockchainInterface clientinterfaces.BlockchainInterface, workload chan scheduledTx, sendTimes *[]SendTime, sendLag *int64, wg *sync.WaitGroup) {
 	var errs []error
 	defer wg.Done()
 
 	// Wait for the signal to go
-	for tx := range workload {
-		e := blockchainInterface.SendRawTransaction(tx)
+	for stx := range workload {
+		if wait := time.Until(stx.at); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+
+		now := time.Now()
+		*sendTimes = append(*sendTimes, SendTime{Scheduled: stx.at, Actual: now})
+		atomic.StoreInt64(sendLag, int64(now.Sub(stx.at)))
+		blockchainInterface.SetIntendedSendTime(stx.at)
+		e := blockchainInterface.SendRawTransaction(ctx, stx.tx)
 		if e != nil {
 			zap.L().Debug("Error sending tx",
 				zap.Error(e))
@@ -158,6 +240,60 @@ func (wh *WorkloadHandler) runnerConsumer(blockchainInterface clientinterfaces.B
 	// TODO handle errors
 }
 
+// closedLoopConsumer runs the workload keeping the outstanding number of
+// transactions in flight: the next transaction is sent once a previous one is
+// committed or failed. If no transaction is done within the timeout, the
+// oldest one in flight is considered lost so the worker does not stall.
+func (wh *WorkloadHandler) closedLoopConsumer(ctx context.Context, blockchainInterface clientinterfaces.BlockchainInterface, workload chan scheduledTx, sendTimes *[]SendTime, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	notify := blockchainInterface.TxDoneNotify()
+	poll := time.NewTicker(closedLoopPoll)
+	defer poll.Stop()
+
+	timeout := time.Duration(wh.timeout) * time.Second
+	sent := uint64(0)
+	lost := uint64(0)
+
+	for stx := range workload {
+		if ctx.Err() != nil {
+			return
+		}
+
+		lastDone, lastProgress := blockchainInterface.GetTxDone(), time.Now()
+		for sent >= lastDone+lost+uint64(wh.outstanding) {
+			select {
+			case <-notify:
+			case <-poll.C:
+			case <-ctx.Done():
+				return
+			}
+
+			if done := blockchainInterface.GetTxDone(); done != lastDone {
+				lastDone, lastProgress = done, time.Now()
+			} else if timeout > 0 && time.Since(lastProgress) > timeout {
+				zap.L().Warn("no transaction done within the timeout, sending the next one",
+					zap.Uint64("sent", sent),
+					zap.Uint64("done", done))
+				lost++
+				lastProgress = time.Now()
+			}
+		}
+
+		*sendTimes = append(*sendTimes, SendTime{Actual: time.Now()})
+		e := blockchainInterface.SendRawTransaction(ctx, stx.tx)
+		if e != nil {
+			// The transaction was not sent, it is not in flight
+			zap.L().Debug("Error sending tx",
+				zap.Error(e))
+			atomic.AddUint64(&wh.numErrors, 1)
+		} else {
+			sent++
+		}
+		atomic.AddUint64(&wh.numTx, 1)
+	}
+}
+
 // statusPrinter periodically prints the status of the workload progress
 func (wh *WorkloadHandler) statusPrinter(stopCh chan bool) {
 	timer := time.NewTicker(5 * time.Second)
@@ -168,7 +304,7 @@ func (wh *WorkloadHandler) statusPrinter(stopCh chan bool) {
 			return
 		case <-timer.C:
 			// print
-			zap.L().Info(fmt.Sprintf("SENT: %d tx (%d errors)", wh.numTx, wh.numErrors))
+			zap.L().Info(fmt.Sprintf("PROGRESS: %s", wh.Telemetry()))
 		}
 	}
 }
@@ -182,21 +318,54 @@ func (wh *WorkloadHandler) getTxCheck() uint64 {
 	return fullTx
 }
 
-// RunBench executes the benchmark
-func (wh *WorkloadHandler) RunBench() error {
+// RunBench executes the benchmark. When the context is cancelled, the workers
+// stop sending and it returns without waiting for the transactions in flight,
+// the results are then partial.
+func (wh *WorkloadHandler) RunBench(ctx context.Context) error {
+	wh.interrupted = false
+	if !wh.startAt.IsZero() {
+		if wait := time.Until(wh.startAt); wait > 0 {
+			zap.L().Info("Waiting for the start of the benchmark",
+				zap.Time("start", wh.startAt),
+				zap.Duration("wait", wait))
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				zap.L().Warn("Benchmark cancelled before its start")
+				wh.interrupted = true
+				wh.StartEnd = append(wh.StartEnd, time.Now(), time.Now())
+				return nil
+			}
+		} else {
+			zap.L().Warn("Start time already passed, starting now",
+				zap.Duration("late", -wait))
+		}
+	}
+
 	wh.StartEnd = append(wh.StartEnd, time.Now())
 	stopPrinting := make(chan bool, 0)
 
 	go wh.statusPrinter(stopPrinting)
 
-	for i, ch := range wh.readyChannels {
+	var wg sync.WaitGroup
+	for i, workerChannel := range wh.workerThreadChannels {
 		wh.activeClients[i].Start()
-		ch <- true
+
+		wg.Add(1)
+		if wh.loadMode == configs.LoadModeClosed {
+			go wh.closedLoopConsumer(ctx, wh.activeClients[i], workerChannel, &wh.sendTimes[i], &wg)
+		} else {
+			go wh.runnerConsumer(ctx, wh.activeClients[i], workerChannel, &wh.sendTimes[i], &wh.sendLags[i], &wg)
+		}
+
+		go wh.workloadProducer(ctx, wh.FullWorkload[i], workerChannel, i)
 	}
 
 	// All of the threads have stopped sending, we should wait some time for
 	// confirmations
-	wh.wg.Wait()
+	wg.Wait()
 
 	// Sending finished waiting for timeout
 	// TODO: add configurable timeout that will exit if benchmark not complete
@@ -204,10 +373,13 @@ func (wh *WorkloadHandler) RunBench() error {
 	stopPrinting <- true
 
 	waitingTicker := time.NewTicker(1 * time.Second)
+	defer waitingTicker.Stop()
 	waitCount := 0
 	td := uint64(0)
-	for {
+	for ctx.Err() == nil {
 		select {
+		case <-ctx.Done():
+			continue
 		case <-waitingTicker.C:
 			waitCount++
 			td = wh.getTxCheck()
@@ -217,13 +389,20 @@ func (wh *WorkloadHandler) RunBench() error {
 			)
 			break
 		}
-		if waitCount >= wh.timeout || (td/wh.numTx) == 1 {
+		if waitCount >= wh.timeout || td >= wh.numTx {
 			break
 		}
 	}
 
 	wh.StartEnd = append(wh.StartEnd, time.Now())
 
+	if ctx.Err() != nil {
+		wh.interrupted = true
+		zap.L().Warn("Benchmark cancelled, the results are partial",
+			zap.Uint64("sent", atomic.LoadUint64(&wh.numTx)),
+			zap.Uint64("done", wh.getTxCheck()))
+	}
+
 	zap.L().Info("Benchmark complete:",
 		zap.Time("start", wh.StartEnd[0]),
 		zap.Time("end", wh.StartEnd[1]),
@@ -237,8 +416,18 @@ func (wh *WorkloadHandler) RunBench() error {
 func (wh *WorkloadHandler) HandleCleanup() []results.Results {
 
 	var resList []results.Results
-	for _, c := range wh.activeClients {
-		resList = append(resList, c.Cleanup())
+	for i, c := range wh.activeClients {
+		res := c.Cleanup()
+		if i < len(wh.sendTimes) {
+			res.SendDelays = sendDelays(wh.sendTimes[i])
+		}
+		// Only the histograms are sent to the primary
+		res.Summarise()
+		if len(wh.StartEnd) > 0 {
+			res.StartTime = wh.StartEnd[0].UnixNano()
+		}
+		res.Interrupted = wh.interrupted
+		resList = append(resList, res)
 	}
 
 	zap.L().Debug("Results being returned",
@@ -248,6 +437,19 @@ func (wh *WorkloadHandler) HandleCleanup() []results.Results {
 	return resList
 }
 
+// sendDelays returns the delay in milliseconds between the scheduled and actual
+// send time of the paced transactions
+func sendDelays(sendTimes []SendTime) []float64 {
+	delays := make([]float64, 0, len(sendTimes))
+	for _, st := range sendTimes {
+		if !st.Scheduled.IsZero() {
+			delays = append(delays, float64(st.Delay().Microseconds())/1000)
+		}
+	}
+
+	return delays
+}
+
 // CloseAll closes the clients and the channels
 func (wh *WorkloadHandler) CloseAll() {
 	for _, c := range wh.activeClients {
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"context"
+	"diablo-benchmark/blockchains/clientinterfaces"
+	"diablo-benchmark/blockchains/workloadgenerators"
+	"diablo-benchmark/core/configs"
+	"diablo-benchmark/core/results"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockChainConfig returns a fast, deterministic mock chain configuration
+func mockChainConfig(failureRate float64) *configs.ChainConfig {
+	return &configs.ChainConfig{
+		Name:             "mock",
+		ThroughputWindow: 1,
+		Extra: []interface{}{
+			map[string]interface{}{
+				"blockTime":   50,
+				"blockSize":   1000,
+				"latency":     "uniform",
+				"latencyMin":  5,
+				"latencyMax":  40,
+				"failureRate": failureRate,
+				"seed":        7,
+			},
+		},
+	}
+}
+
+// runMockBench generates a mock workload and runs it through the workload handler
+func runMockBench(t *testing.T, chainConfig *configs.ChainConfig, benchConfig *configs.BenchConfig) []results.Results {
+	return runMockBenchWith(t, chainConfig, benchConfig, nil)
+}
+
+// runMockBenchWith runs the mock benchmark with the clients wrapped by the given function
+func runMockBenchWith(t *testing.T, chainConfig *configs.ChainConfig, benchConfig *configs.BenchConfig, wrap func(clientinterfaces.BlockchainInterface) clientinterfaces.BlockchainInterface) []results.Results {
+	return runMockBenchHandler(context.Background(), t, chainConfig, benchConfig, wrap, nil)
+}
+
+// runMockBenchHandler runs the mock benchmark until the context is cancelled,
+// the handler is given to setup before the run
+func runMockBenchHandler(ctx context.Context, t *testing.T, chainConfig *configs.ChainConfig, benchConfig *configs.BenchConfig, wrap func(clientinterfaces.BlockchainInterface) clientinterfaces.BlockchainInterface, setup func(*WorkloadHandler)) []results.Results {
+	generatorClass, err := workloadgenerators.GetWorkloadGenerator(chainConfig)
+	if err != nil {
+		t.Fatalf("failed to get generator: %s", err.Error())
+	}
+
+	wg := generatorClass.NewGenerator(chainConfig, benchConfig)
+	if err := wg.BlockchainSetup(); err != nil {
+		t.Fatalf("blockchain setup failed: %s", err.Error())
+	}
+	if err := wg.InitParams(); err != nil {
+		t.Fatalf("init params failed: %s", err.Error())
+	}
+
+	wg.SetThreadIntervals(workloadgenerators.GetIntervalPerThread(benchConfig.TxInfo.Intervals, benchConfig.Secondaries, benchConfig.Threads))
+
+	workload, err := wg.GenerateWorkload()
+	if err != nil {
+		t.Fatalf("failed to generate workload: %s", err.Error())
+	}
+
+	var clients []clientinterfaces.BlockchainInterface
+	for i := 0; i < benchConfig.Threads; i++ {
+		bc, err := clientinterfaces.GetBlockchainInterface(chainConfig)
+		if err != nil {
+			t.Fatalf("failed to get interface: %s", err.Error())
+		}
+		if wrap != nil {
+			bc = wrap(bc)
+		}
+		clients = append(clients, bc)
+	}
+
+	wh := NewWorkloadHandler(uint32(benchConfig.Threads), clients, benchConfig.Timeout)
+	wh.SetLoadMode(benchConfig.TxInfo.Mode, benchConfig.TxInfo.Outstanding)
+	if err := wh.Connect(chainConfig, 0); err != nil {
+		t.Fatalf("failed to connect: %s", err.Error())
+	}
+	defer wh.CloseAll()
+
+	if err := wh.ParseWorkloads(workload[0]); err != nil {
+		t.Fatalf("failed to parse workload: %s", err.Error())
+	}
+
+	if setup != nil {
+		setup(wh)
+	}
+
+	if err := wh.RunBench(ctx); err != nil {
+		t.Fatalf("failed to run bench: %s", err.Error())
+	}
+
+	return wh.HandleCleanup()
+}
+
+func TestMockChainEndToEnd(t *testing.T) {
+	benchConfig := &configs.BenchConfig{
+		Name:        "mock",
+		Secondaries: 1,
+		Threads:     2,
+		Timeout:     5,
+		TxInfo: configs.BenchInfo{
+			TxType:    configs.TxTypeSimple,
+			Intervals: configs.TPSIntervals{0: 20, 1: 20},
+		},
+	}
+
+	res := runMockBench(t, mockChainConfig(0), benchConfig)
+
+	if len(res) != 2 {
+		t.Fatalf("expected results for 2 workers, got %d", len(res))
+	}
+
+	aggregated := results.CalculateAggregatedResults([][]results.Results{res})
+	if aggregated.TotalSuccess != 40 {
+		t.Errorf("expected 40 committed transactions, got %d (fails: %d)", aggregated.TotalSuccess, aggregated.TotalFails)
+	}
+
+	if aggregated.TotalFails != 0 {
+		t.Errorf("expected no failures, got %d", aggregated.TotalFails)
+	}
+
+	if aggregated.MaxLatency <= 0 {
+		t.Errorf("expected a positive latency, got %f", aggregated.MaxLatency)
+	}
+
+	for i, r := range res {
+		if r.SendDelayHistogram == nil || r.SendDelayHistogram.Count != 20 {
+			t.Errorf("expected the send delay of 20 transactions for worker %d, got %+v", i, r.SendDelayHistogram)
+		}
+	}
+}
+
+func TestMockChainEmptyWorkload(t *testing.T) {
+	// Workers without transactions finish without waiting for the timeout
+	benchConfig := &configs.BenchConfig{
+		Name:        "mock",
+		Secondaries: 1,
+		Threads:     2,
+		Timeout:     30,
+		TxInfo: configs.BenchInfo{
+			TxType:    configs.TxTypeSimple,
+			Intervals: configs.TPSIntervals{0: 0, 1: 0},
+		},
+	}
+
+	start := time.Now()
+	res := runMockBench(t, mockChainConfig(0), benchConfig)
+
+	if len(res) != 2 {
+		t.Fatalf("expected results for 2 workers, got %d", len(res))
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected the empty workload to finish before the timeout, took %s", elapsed)
+	}
+}
+
+func TestMockChainDeterministicFailures(t *testing.T) {
+	benchConfig := &configs.BenchConfig{
+		Name:        "mock",
+		Secondaries: 1,
+		Threads:     1,
+		Timeout:     5,
+		TxInfo: configs.BenchInfo{
+			TxType:    configs.TxTypeSimple,
+			Intervals: configs.TPSIntervals{0: 50},
+		},
+	}
+
+	first := runMockBench(t, mockChainConfig(0.3), benchConfig)
+	second := runMockBench(t, mockChainConfig(0.3), benchConfig)
+
+	if first[0].Fail == 0 {
+		t.Fatalf("expected some failures with a failure rate of 0.3")
+	}
+
+	if first[0].Fail != second[0].Fail || first[0].Success != second[0].Success {
+		t.Errorf("runs are not deterministic: %d/%d vs %d/%d",
+			first[0].Success, first[0].Fail, second[0].Success, second[0].Fail)
+	}
+}
+
+// inFlightClient records the maximum number of transactions in flight
+type inFlightClient struct {
+	sent        uint64
+	maxInFlight uint64
+	lock        sync.Mutex
+	clientinterfaces.BlockchainInterface
+}
+
+func (c *inFlightClient) SendRawTransaction(ctx context.Context, tx interface{}) error {
+	c.lock.Lock()
+	c.sent++
+	if inFlight := c.sent - c.GetTxDone(); inFlight > c.maxInFlight {
+		c.maxInFlight = inFlight
+	}
+	c.lock.Unlock()
+
+	return c.BlockchainInterface.SendRawTransaction(ctx, tx)
+}
+
+func TestMockChainClosedLoop(t *testing.T) {
+	benchConfig := &configs.BenchConfig{
+		Name:        "mock",
+		Secondaries: 1,
+		Threads:     2,
+		Timeout:     5,
+		TxInfo: configs.BenchInfo{
+			TxType:      configs.TxTypeSimple,
+			Intervals:   configs.TPSIntervals{0: 40},
+			Mode:        configs.LoadModeClosed,
+			Outstanding: 3,
+		},
+	}
+
+	var clients []*inFlightClient
+	res := runMockBenchWith(t, mockChainConfig(0.1), benchConfig, func(bc clientinterfaces.BlockchainInterface) clientinterfaces.BlockchainInterface {
+		c := &inFlightClient{BlockchainInterface: bc}
+		clients = append(clients, c)
+		return c
+	})
+
+	for i, c := range clients {
+		if c.maxInFlight > 3 {
+			t.Errorf("worker %d had %d transactions in flight, expected at most 3", i, c.maxInFlight)
+		}
+	}
+
+	aggregated := results.CalculateAggregatedResults([][]results.Results{res})
+	if total := aggregated.TotalSuccess + aggregated.TotalFails; total != 40 {
+		t.Errorf("expected 40 done transactions, got %d", total)
+	}
+}
+
+// slowClient delays every send, like a client that cannot keep up with the schedule
+type slowClient struct {
+	delay time.Duration
+	clientinterfaces.BlockchainInterface
+}
+
+func (c *slowClient) SendRawTransaction(ctx context.Context, tx interface{}) error {
+	time.Sleep(c.delay)
+	return c.BlockchainInterface.SendRawTransaction(ctx, tx)
+}
+
+func TestMockChainResponseLatency(t *testing.T) {
+	benchConfig := &configs.BenchConfig{
+		Name:        "mock",
+		Secondaries: 1,
+		Threads:     1,
+		Timeout:     5,
+		TxInfo: configs.BenchInfo{
+			TxType:    configs.TxTypeSimple,
+			Intervals: configs.TPSIntervals{0: 20},
+		},
+	}
+
+	// Sending takes twice the interval between transactions, so they are sent
+	// later and later while the chain itself stays fast.
+	res := runMockBenchWith(t, mockChainConfig(0), benchConfig, func(bc clientinterfaces.BlockchainInterface) clientinterfaces.BlockchainInterface {
+		return &slowClient{delay: 100 * time.Millisecond, BlockchainInterface: bc}
+	})
+
+	if res[0].ResponseHistogram.Count != res[0].LatencyHistogram.Count {
+		t.Fatalf("expected a response latency per committed transaction, got %d for %d",
+			res[0].ResponseHistogram.Count, res[0].LatencyHistogram.Count)
+	}
+
+	aggregated := results.CalculateAggregatedResults([][]results.Results{res})
+	if aggregated.MaxLatency > 500 {
+		t.Errorf("expected the service latency to exclude the client delays, got a max of %f ms", aggregated.MaxLatency)
+	}
+
+	if aggregated.MaxResponseLatency < 800 {
+		t.Errorf("expected the response latency to include the client delays, got a max of %f ms", aggregated.MaxResponseLatency)
+	}
+
+	if aggregated.AverageResponseLatency <= aggregated.AverageLatency {
+		t.Errorf("expected the response latency (%f ms) above the service latency (%f ms)",
+			aggregated.AverageResponseLatency, aggregated.AverageLatency)
+	}
+}
+
+func TestStartTime(t *testing.T) {
+	benchConfig := &configs.BenchConfig{
+		Name:        "mock",
+		Secondaries: 1,
+		Threads:     1,
+		Timeout:     5,
+		TxInfo: configs.BenchInfo{
+			TxType:    configs.TxTypeSimple,
+			Intervals: configs.TPSIntervals{0: 10},
+		},
+	}
+
+	start := time.Now().Add(500 * time.Millisecond)
+	var handler *WorkloadHandler
+	res := runMockBenchHandler(context.Background(), t, mockChainConfig(0), benchConfig, nil, func(wh *WorkloadHandler) {
+		wh.SetStartTime(start)
+		handler = wh
+	})
+
+	if got := time.Unix(0, res[0].StartTime); got.Before(start) || got.Sub(start) > 50*time.Millisecond {
+		t.Errorf("expected the benchmark to start at %s, started at %s", start, got)
+	}
+
+	for _, st := range handler.SendTimes()[0] {
+		if st.Scheduled.Before(start) {
+			t.Fatalf("transaction scheduled at %s before the start %s", st.Scheduled, start)
+		}
+	}
+}
+
+func TestAbort(t *testing.T) {
+	benchConfig := &configs.BenchConfig{
+		Name:        "mock",
+		Secondaries: 1,
+		Threads:     2,
+		Timeout:     20,
+		TxInfo: configs.BenchInfo{
+			TxType:    configs.TxTypeSimple,
+			Intervals: configs.TPSIntervals{0: 20, 1: 20, 2: 20, 3: 20, 4: 20, 5: 20},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+
+	var handler *WorkloadHandler
+	start := time.Now()
+	res := runMockBenchHandler(ctx, t, mockChainConfig(0.5), benchConfig, nil, func(wh *WorkloadHandler) {
+		handler = wh
+	})
+
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected the run to stop after the abort, it took %s", elapsed)
+	}
+
+	telemetry := handler.Telemetry()
+	if telemetry.Sent == 0 || telemetry.Sent >= 120 {
+		t.Errorf("expected part of the 120 transactions to be sent, got %d", telemetry.Sent)
+	}
+
+	if telemetry.Failed == 0 || telemetry.Committed+telemetry.Failed > telemetry.Sent {
+		t.Errorf("expected some of the %d transactions sent to fail, got %s", telemetry.Sent, telemetry)
+	}
+
+	if !handler.Interrupted() || !res[0].Interrupted {
+		t.Errorf("expected the partial results to be marked as interrupted")
+	}
+}
+
+func TestPacingOffsets(t *testing.T) {
+	even := pacingOffsets(configs.PacingEven, 4, nil)
+	for i, o := range even {
+		if expected := time.Duration(i) * 250 * time.Millisecond; o != expected {
+			t.Errorf("expected even offset %d at %s, got %s", i, expected, o)
+		}
+	}
+
+	poisson := pacingOffsets(configs.PacingPoisson, 1000, rand.New(rand.NewSource(1)))
+	for i, o := range poisson {
+		if o < 0 || o >= time.Second {
+			t.Fatalf("poisson offset %s out of the interval", o)
+		}
+		if i > 0 && o < poisson[i-1] {
+			t.Fatalf("poisson offsets are not sorted at %d", i)
+		}
+	}
+
+	// The arrivals are spread over the second, not bunched at its start
+	if half := poisson[500]; half < 400*time.Millisecond || half > 600*time.Millisecond {
+		t.Errorf("expected the median poisson offset near 500ms, got %s", half)
+	}
+
+	for _, o := range pacingOffsets(configs.PacingBurst, 10, nil) {
+		if o != 0 {
+			t.Fatalf("expected burst offsets at the start of the interval, got %s", o)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"context"
+	"diablo-benchmark/blockchains/workloadgenerators"
+	"diablo-benchmark/communication"
+	"diablo-benchmark/core/configs"
+	"diablo-benchmark/core/results"
+	"sync"
+)
+
+// RunLocal runs the benchmark with the primary and the secondaries in this
+// process, the secondaries get the commands of the primary over channels. The
+// results are written to the results directory and returned, they are nil if
+// the benchmark did not complete.
+func RunLocal(ctx context.Context, wg workloadgenerators.WorkloadGenerator, bConfig *configs.BenchConfig, cConfig *configs.ChainConfig, resultsDir string) *results.AggregatedResults {
+	transport := communication.NewMemoryTransport(bConfig.Secondaries)
+	transport.AbortFailureRate = bConfig.AbortFailureRate
+
+	p := NewPrimary(transport, wg, bConfig, cConfig)
+	p.ResultsDir = resultsDir
+
+	// The secondaries wait for the commands of the primary
+	var secondaries sync.WaitGroup
+	for i := 0; i < bConfig.Secondaries; i++ {
+		secondaries.Add(1)
+		go func(i int) {
+			defer secondaries.Done()
+			NewSecondaryWithTransport(cConfig, bConfig, transport.Secondary(i)).Run(ctx)
+		}(i)
+	}
+
+	p.Run(ctx)
+
+	// The secondaries still waiting for a command are stopped
+	transport.CloseSecondaries()
+	secondaries.Wait()
+
+	return p.Results
+}
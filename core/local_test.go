@@ -0,0 +1,92 @@
+package core
+
+import (
+	"context"
+	"diablo-benchmark/blockchains/workloadgenerators"
+	"diablo-benchmark/core/configs/parsers"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const localBench = `name: "local"
+description: "two secondaries against the mock chain"
+secondaries: 2
+threads: 2
+timeout: 5
+bench:
+  type: "simple"
+  txs:
+    0: 20
+    2: 20
+`
+
+const localChain = `name: "mock"
+nodes:
+  - 127.0.0.1:0
+extra:
+  - blockTime: 100
+    blockSize: 200
+    latency: "constant"
+    latencyMean: 20
+    failureRate: 0
+    seed: 42
+`
+
+// runLocal runs the benchmark in local mode against the mock chain
+func runLocal(t *testing.T, bench string) {
+	dir, err := ioutil.TempDir("", "diablo-local")
+	if err != nil {
+		t.Fatalf("failed to create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	benchPath := filepath.Join(dir, "bench.yaml")
+	chainPath := filepath.Join(dir, "chain.yaml")
+	_ = ioutil.WriteFile(benchPath, []byte(bench), 0600)
+	_ = ioutil.WriteFile(chainPath, []byte(localChain), 0600)
+
+	bConfig, err := parsers.ParseBenchConfig(benchPath)
+	if err != nil {
+		t.Fatalf("failed to parse bench config: %s", err.Error())
+	}
+	cConfig, err := parsers.ParseChainConfig(chainPath)
+	if err != nil {
+		t.Fatalf("failed to parse chain config: %s", err.Error())
+	}
+
+	generatorClass, err := workloadgenerators.GetWorkloadGenerator(cConfig)
+	if err != nil {
+		t.Fatalf("failed to get workload generator: %s", err.Error())
+	}
+
+	res := RunLocal(context.Background(), generatorClass.NewGenerator(cConfig, bConfig), bConfig, cConfig, filepath.Join(dir, "results"))
+	if res == nil {
+		t.Fatalf("expected the benchmark to complete")
+	}
+
+	if len(res.RawResults) != 2 || res.Aborted {
+		t.Errorf("expected the results of 2 secondaries, got %d (aborted %t)", len(res.RawResults), res.Aborted)
+	}
+
+	var success uint
+	for _, secondary := range res.SecondaryResults {
+		success += secondary.Success
+	}
+	if success == 0 {
+		t.Errorf("expected committed transactions")
+	}
+
+	if files, _ := ioutil.ReadDir(filepath.Join(dir, "results")); len(files) == 0 {
+		t.Errorf("expected the results to be written")
+	}
+}
+
+func TestRunLocal(t *testing.T) {
+	runLocal(t, localBench)
+}
+
+func TestRunLocalSecondaryGeneration(t *testing.T) {
+	runLocal(t, localBench+"generation: \"secondary\"\n")
+}
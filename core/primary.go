@@ -6,10 +6,12 @@
 package core
 
 import (
+	"context"
 	"diablo-benchmark/blockchains/workloadgenerators"
 	"diablo-benchmark/communication"
 	"diablo-benchmark/core/configs"
 	"diablo-benchmark/core/results"
+	"errors"
 	"fmt"
 	"time"
 
@@ -18,27 +20,37 @@ import (
 
 // Primary benchmark server, acts as the orchestrator for the benchmark
 type Primary struct {
-	Server            *communication.PrimaryServer         // TCP server identified with the primary for all secondaries to connect to
+	Server            communication.PrimaryTransport       // Transport to the secondaries, e.g. the TCP server they connect to
 	workloadGenerator workloadgenerators.WorkloadGenerator // Workload generator implementation that will generate the transactions
 	benchmarkConfig   *configs.BenchConfig                 // Benchmark configuration about the workload
 	chainConfig       *configs.ChainConfig                 // Chain configuration containing information about the nodes
+	ResultsDir        string                               // Directory the results are written to
+	Results           *results.AggregatedResults           // Results of the benchmark, nil until they are collected
 }
 
 // InitPrimary initialises the primary server and returns an instance of the primary
 // This will be passed back to the main
-func InitPrimary(listenAddr string, expectedSecondaries int, wg workloadgenerators.WorkloadGenerator, bConfig *configs.BenchConfig, cConfig *configs.ChainConfig) *Primary {
-	s, err := communication.SetupPrimaryTCP(listenAddr, expectedSecondaries)
+func InitPrimary(listenAddr string, expectedSecondaries int, wg workloadgenerators.WorkloadGenerator, bConfig *configs.BenchConfig, cConfig *configs.ChainConfig, security communication.Security, compression string) (*Primary, error) {
+	s, err := communication.SetupPrimaryTCP(listenAddr, expectedSecondaries, security)
 	if err != nil {
-		// TODO remove panic
-		panic(err)
+		return nil, err
 	}
 
+	s.AbortFailureRate = bConfig.AbortFailureRate
+	s.WorkloadCompression = compression
+	return NewPrimary(s, wg, bConfig, cConfig), nil
+}
+
+// NewPrimary returns an instance of the primary running the benchmark over
+// the transport to the secondaries.
+func NewPrimary(transport communication.PrimaryTransport, wg workloadgenerators.WorkloadGenerator, bConfig *configs.BenchConfig, cConfig *configs.ChainConfig) *Primary {
 	// Return a new primary instance with the active communication set up
 	return &Primary{
-		Server:            s,
+		Server:            transport,
 		workloadGenerator: wg,
 		benchmarkConfig:   bConfig,
 		chainConfig:       cConfig,
+		ResultsDir:        "results",
 	}
 }
 
@@ -48,10 +60,50 @@ func (p *Primary) closeAllConns() {
 	p.Server.Close()
 }
 
+// stopped returns whether the context was cancelled before the benchmark
+// started, the secondaries are then told to finish.
+func (p *Primary) stopped(ctx context.Context) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+
+	zap.L().Warn("Interrupted before the benchmark started, finishing the secondaries")
+	p.Server.SendFin()
+	p.closeAllConns()
+	return true
+}
+
+// partitionWorkload returns the partitions of the workload generated by the
+// secondaries, or nil if the primary generates the workload. The primary
+// generates it if the generator cannot partition the workload.
+func (p *Primary) partitionWorkload() ([][]byte, error) {
+	if p.benchmarkConfig.Generation != configs.GenerationSecondary {
+		return nil, nil
+	}
+
+	g, ok := p.workloadGenerator.(workloadgenerators.PartitionedGenerator)
+	if !ok {
+		zap.L().Warn("The workload generator cannot partition the workload, generating it on the primary",
+			zap.String("chain", p.chainConfig.Name))
+		return nil, nil
+	}
+
+	partitions, err := g.Partitions()
+	if errors.Is(err, workloadgenerators.ErrNotPartitioned) {
+		zap.L().Warn("Generating the workload on the primary",
+			zap.Error(err))
+		return nil, nil
+	}
+
+	return partitions, err
+}
+
 // Run provides the main functionality to run
 // Holds the majority of the work
+// Cancelling the context aborts the benchmark, the partial results are still
+// collected and written.
 // TODO: under construction!
-func (p *Primary) Run() {
+func (p *Primary) Run(ctx context.Context) {
 	// First, set up the blockchain
 	err := p.workloadGenerator.BlockchainSetup()
 
@@ -72,7 +124,19 @@ func (p *Primary) Run() {
 	// Get the secondary connections ready
 	secondaryReadyChannel := make(chan bool, 1)
 	go p.Server.HandleSecondaries(secondaryReadyChannel)
-	<-secondaryReadyChannel
+	select {
+	case ready := <-secondaryReadyChannel:
+		if !ready {
+			p.closeAllConns()
+			return
+		}
+	case <-ctx.Done():
+		// Stop accepting, the secondaries connected are told to finish
+		p.Server.Close()
+		<-secondaryReadyChannel
+		p.stopped(ctx)
+		return
+	}
 	close(secondaryReadyChannel)
 
 	// Parse the config files
@@ -90,29 +154,53 @@ func (p *Primary) Run() {
 		return
 	}
 
+	if p.stopped(ctx) {
+		return
+	}
+
 	// Number of secondaries connected
 	zap.L().Info("Benchmark secondaries all connected.",
-		zap.Int("secondaries", len(p.Server.Secondaries)))
+		zap.Int("secondaries", p.Server.NumSecondaries()))
 
 	p.workloadGenerator.SetThreadIntervals(workloadgenerators.GetIntervalPerThread(p.benchmarkConfig.TxInfo.Intervals, p.benchmarkConfig.Secondaries, p.benchmarkConfig.Threads))
 
-	// Step 3: Prepare the workload for the benchmark
-	// TODO: generate workloads
-	workload, err := p.workloadGenerator.GenerateWorkload()
-
+	// Step 3: Prepare the workload for the benchmark, or the partitions the
+	// secondaries generate it from
+	partitions, err := p.partitionWorkload()
 	if err != nil {
-		zap.L().Error("failed to generate workload",
+		zap.L().Error("failed to partition workload",
 			zap.String("error", err.Error()))
 		p.closeAllConns()
 		return
-	} else if workload == nil || len(workload) == 0 {
-		zap.L().Error("failed to produce workload")
-		p.closeAllConns()
+	}
+
+	var workload workloadgenerators.Workload
+	if partitions == nil {
+		// TODO: generate workloads
+		workload, err = p.workloadGenerator.GenerateWorkload()
+
+		if err != nil {
+			zap.L().Error("failed to generate workload",
+				zap.String("error", err.Error()))
+			p.closeAllConns()
+			return
+		} else if workload == nil || len(workload) == 0 {
+			zap.L().Error("failed to produce workload")
+			p.closeAllConns()
+			return
+		}
+	}
+
+	if p.stopped(ctx) {
 		return
 	}
 
 	// Step 4: Distribute benchmark
-	errs = p.Server.SendWorkload(workload)
+	if partitions != nil {
+		errs = p.Server.SendPartitions(p.benchmarkConfig, partitions)
+	} else {
+		errs = p.Server.SendWorkload(workload)
+	}
 	if errs != nil {
 		zap.L().Error("Encountered Error sending workload",
 			zap.String("errs", fmt.Sprintf("%v", errs)),
@@ -121,14 +209,18 @@ func (p *Primary) Run() {
 		return
 	}
 
+	if p.stopped(ctx) {
+		return
+	}
+
 	// Step 5: run the bench
-	errs = p.Server.RunBenchmark()
+	errs = p.Server.RunBenchmark(ctx)
 	if errs != nil {
-		zap.L().Error("Encountered Error sending workload",
+		// The secondaries that did not reconnect are lost, the results of
+		// the others are still collected
+		zap.L().Error("Encountered Error running benchmark",
 			zap.String("errs", fmt.Sprintf("%v", errs)),
 		)
-		p.closeAllConns()
-		return
 	}
 
 	// Wait until everyone is done and give some room for final messages
@@ -138,13 +230,34 @@ func (p *Primary) Run() {
 	// TODO: Need to store the results
 	rawResults, errs := p.Server.GetResults()
 	if errs != nil {
+		// The results of the other secondaries are still reported
 		zap.L().Error("GetResults returned client errors",
 			zap.Strings("errors", errs))
+	}
+	// The lost secondaries keep an empty slot in the results
+	collected := 0
+	for _, secondaryResults := range rawResults {
+		if len(secondaryResults) > 0 {
+			collected++
+		}
+	}
+	if collected == 0 {
+		zap.L().Error("No results collected from the secondaries")
 		p.closeAllConns()
+		return
 	}
 
 	// TODO: @CHRIS
 	aggregatedResults := results.CalculateAggregatedResults(rawResults)
+	aggregatedResults.SetStartSkew(p.Server.ClockOffsets())
+	if reason := p.Server.AbortReason(); reason != "" {
+		aggregatedResults.SetAborted(reason)
+	}
+	aggregatedResults.SetSecondaryHealth(p.Server.SecondaryHealth())
+	if reporter, ok := p.workloadGenerator.(workloadgenerators.MixReporter); ok {
+		aggregatedResults.SetFunctionMix(reporter.FunctionMix())
+	}
+	p.Results = &aggregatedResults
 
 	// Step 7 - store results
 	p.Server.SendFin()
@@ -154,7 +267,7 @@ func (p *Primary) Run() {
 	// Display the results
 	results.Display(aggregatedResults)
 	// Write the results to a file
-	err = results.WriteResultsToFile(p.benchmarkConfig.Path, p.chainConfig.Path, aggregatedResults, "results")
+	err = results.WriteResultsToFile(p.benchmarkConfig.Path, p.chainConfig.Path, aggregatedResults, p.ResultsDir)
 	if err != nil {
 		zap.L().Error("Encountered error when saving results",
 			zap.Error(err))
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"context"
+	"diablo-benchmark/blockchains/workloadgenerators"
+	"diablo-benchmark/communication"
+	"diablo-benchmark/core/configs"
+	"diablo-benchmark/core/configs/parsers"
+	"diablo-benchmark/core/results"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordingTransport is a transport recording the commands of the primary,
+// the secondaries always succeed.
+type recordingTransport struct {
+	commands []string
+	lost     []int // Secondaries failing the run, their results are missing
+}
+
+func (rt *recordingTransport) HandleSecondaries(readyChannel chan bool) {
+	rt.commands = append(rt.commands, "connect")
+	readyChannel <- true
+}
+
+func (rt *recordingTransport) NumSecondaries() int { return 2 }
+
+func (rt *recordingTransport) PrepareBenchmarkSecondaries(numThreads uint32) communication.SecondaryReplyErrors {
+	rt.commands = append(rt.commands, "prepare")
+	return nil
+}
+
+func (rt *recordingTransport) SendWorkload(workloads workloadgenerators.Workload) communication.SecondaryReplyErrors {
+	rt.commands = append(rt.commands, "workload")
+	return nil
+}
+
+func (rt *recordingTransport) SendPartitions(bench *configs.BenchConfig, partitions [][]byte) communication.SecondaryReplyErrors {
+	rt.commands = append(rt.commands, "generate")
+	return nil
+}
+
+func (rt *recordingTransport) RunBenchmark(ctx context.Context) communication.SecondaryReplyErrors {
+	rt.commands = append(rt.commands, "run")
+	var errs communication.SecondaryReplyErrors
+	for _, secondary := range rt.lost {
+		errs = append(errs, fmt.Sprintf("secondary %d did not reconnect", secondary))
+	}
+	return errs
+}
+
+func (rt *recordingTransport) AbortReason() string { return "" }
+
+func (rt *recordingTransport) GetResults() ([][]results.Results, communication.SecondaryReplyErrors) {
+	rt.commands = append(rt.commands, "results")
+	res := results.Results{
+		TxLatencies:       []float64{10, 20},
+		AverageLatency:    15,
+		MedianLatency:     15,
+		Throughput:        2,
+		ThroughputSeconds: []float64{2},
+		Success:           2,
+	}
+	all := [][]results.Results{{res}, {res}}
+	for _, secondary := range rt.lost {
+		all[secondary] = nil
+	}
+	return all, nil
+}
+
+func (rt *recordingTransport) ClockOffsets() ([]time.Duration, []time.Duration) { return nil, nil }
+
+func (rt *recordingTransport) SecondaryHealth() ([]int, []int) { return nil, rt.lost }
+
+func (rt *recordingTransport) SendFin() { rt.commands = append(rt.commands, "fin") }
+
+func (rt *recordingTransport) CloseSecondaries() {}
+
+func (rt *recordingTransport) Close() {}
+
+// runRecorded runs the benchmark over a recording transport and returns the
+// commands of the primary
+func runRecorded(t *testing.T, bench string) ([]string, *Primary) {
+	return runRecordedWith(t, bench, &recordingTransport{})
+}
+
+// runRecordedWith runs the benchmark over the given recording transport
+func runRecordedWith(t *testing.T, bench string, transport *recordingTransport) ([]string, *Primary) {
+	dir, err := ioutil.TempDir("", "diablo-primary")
+	if err != nil {
+		t.Fatalf("failed to create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	benchPath := filepath.Join(dir, "bench.yaml")
+	chainPath := filepath.Join(dir, "chain.yaml")
+	_ = ioutil.WriteFile(benchPath, []byte(bench), 0600)
+	_ = ioutil.WriteFile(chainPath, []byte(localChain), 0600)
+
+	bConfig, err := parsers.ParseBenchConfig(benchPath)
+	if err != nil {
+		t.Fatalf("failed to parse bench config: %s", err.Error())
+	}
+	cConfig, err := parsers.ParseChainConfig(chainPath)
+	if err != nil {
+		t.Fatalf("failed to parse chain config: %s", err.Error())
+	}
+
+	generatorClass, err := workloadgenerators.GetWorkloadGenerator(cConfig)
+	if err != nil {
+		t.Fatalf("failed to get workload generator: %s", err.Error())
+	}
+
+	p := NewPrimary(transport, generatorClass.NewGenerator(cConfig, bConfig), bConfig, cConfig)
+	p.ResultsDir = filepath.Join(dir, "results")
+	p.Run(context.Background())
+
+	return transport.commands, p
+}
+
+func TestPrimaryTransport(t *testing.T) {
+	commands, p := runRecorded(t, localBench)
+
+	expected := []string{"connect", "prepare", "workload", "run", "results", "fin"}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Errorf("expected the commands %v, got %v", expected, commands)
+	}
+
+	if p.Results == nil || len(p.Results.RawResults) != 2 {
+		t.Errorf("expected the results of the transport, got %v", p.Results)
+	}
+}
+
+func TestPrimaryTransportPartitions(t *testing.T) {
+	// The secondaries generate the workload in place of its transfer
+	commands, _ := runRecorded(t, localBench+"generation: \"secondary\"\n")
+
+	expected := []string{"connect", "prepare", "generate", "run", "results", "fin"}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Errorf("expected the commands %v, got %v", expected, commands)
+	}
+}
+
+func TestPrimaryLostSecondary(t *testing.T) {
+	// A secondary failing to reconnect during the run does not discard the
+	// results of the other one
+	commands, p := runRecordedWith(t, localBench, &recordingTransport{lost: []int{1}})
+
+	expected := []string{"connect", "prepare", "workload", "run", "results", "fin"}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Errorf("expected the commands %v, got %v", expected, commands)
+	}
+
+	if p.Results == nil || len(p.Results.RawResults) != 2 || len(p.Results.RawResults[0]) != 1 {
+		t.Fatalf("expected the results of the healthy secondary, got %v", p.Results)
+	}
+	if len(p.Results.RawResults[1]) != 0 || p.Results.SecondaryResults[1].Success != 0 {
+		t.Errorf("expected the slot of the lost secondary to be empty, got %v", p.Results.RawResults[1])
+	}
+	if !reflect.DeepEqual(p.Results.LostSecondaries, []int{1}) {
+		t.Errorf("expected secondary 1 to be lost, got %v", p.Results.LostSecondaries)
+	}
+}
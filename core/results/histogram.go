@@ -0,0 +1,167 @@
+package results
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// histogramSubBuckets is the number of linear buckets in each power of two
+// range of the histogram. The width of a bucket is at most 1/1024 of its
+// value, so the percentiles are within 0.1% of the exact value.
+const histogramSubBuckets = 2048
+
+// histogramUnit is the resolution of the recorded latencies, given in ms
+const histogramUnit = 0.001
+
+// Histogram is a log-linear latency histogram in the style of HdrHistogram.
+// Values are counted in buckets whose width grows with the value, so it stays
+// small whatever the number of transactions. Histograms of the workers are
+// built on the secondaries and merged on the primary, the percentiles are
+// computed over all the transactions.
+type Histogram struct {
+	Count  uint64         `json:"Count"`  // Number of values recorded
+	Min    float64        `json:"Min"`    // Minimum value recorded (ms)
+	Max    float64        `json:"Max"`    // Maximum value recorded (ms)
+	Sum    float64        `json:"Sum"`    // Sum of the values recorded (ms)
+	Counts map[int]uint64 `json:"Counts"` // Number of values per non-empty bucket index
+}
+
+// Percentiles are the latency percentiles reported in the results (ms)
+type Percentiles struct {
+	P50  float64 `json:"P50"`
+	P90  float64 `json:"P90"`
+	P95  float64 `json:"P95"`
+	P99  float64 `json:"P99"`
+	P999 float64 `json:"P99.9"`
+}
+
+// NewHistogram returns an empty histogram
+func NewHistogram() *Histogram {
+	return &Histogram{Counts: make(map[int]uint64)}
+}
+
+// NewHistogramOf returns the histogram of the given latencies (ms)
+func NewHistogramOf(values []float64) *Histogram {
+	h := NewHistogram()
+	for _, v := range values {
+		h.Record(v)
+	}
+
+	return h
+}
+
+// histogramIndex returns the index of the bucket of the value in histogram units
+func histogramIndex(v uint64) int {
+	if v < histogramSubBuckets {
+		return int(v)
+	}
+
+	shift := bits.Len64(v) - bits.Len64(histogramSubBuckets-1)
+	return shift*histogramSubBuckets/2 + int(v>>uint(shift))
+}
+
+// histogramBucketRange returns the lowest and highest value in histogram
+// units of the bucket at the given index
+func histogramBucketRange(index int) (uint64, uint64) {
+	if index < histogramSubBuckets {
+		return uint64(index), uint64(index)
+	}
+
+	shift := index/(histogramSubBuckets/2) - 1
+	sub := uint64(index - shift*histogramSubBuckets/2)
+	return sub << uint(shift), (sub+1)<<uint(shift) - 1
+}
+
+// Record adds the latency (ms) to the histogram, negative values are counted as 0
+func (h *Histogram) Record(v float64) {
+	if v < 0 {
+		v = 0
+	}
+
+	if h.Count == 0 || v < h.Min {
+		h.Min = v
+	}
+	if v > h.Max {
+		h.Max = v
+	}
+
+	h.Count++
+	h.Sum += v
+	h.Counts[histogramIndex(uint64(math.Round(v/histogramUnit)))]++
+}
+
+// Merge adds the values of the other histogram to this one
+func (h *Histogram) Merge(other *Histogram) {
+	if other == nil || other.Count == 0 {
+		return
+	}
+
+	if h.Count == 0 || other.Min < h.Min {
+		h.Min = other.Min
+	}
+	if other.Max > h.Max {
+		h.Max = other.Max
+	}
+
+	h.Count += other.Count
+	h.Sum += other.Sum
+	for index, count := range other.Counts {
+		h.Counts[index] += count
+	}
+}
+
+// Mean returns the average of the values recorded, 0 if there are none
+func (h *Histogram) Mean() float64 {
+	if h.Count == 0 {
+		return 0
+	}
+
+	return h.Sum / float64(h.Count)
+}
+
+// Percentile returns the value below which the given percentage of the
+// values fall, 0 if there are none. The value is the lowest of its bucket,
+// bounded by the minimum and maximum recorded.
+func (h *Histogram) Percentile(q float64) float64 {
+	if h.Count == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(q / 100 * float64(h.Count)))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank >= h.Count {
+		return h.Max
+	}
+
+	indexes := make([]int, 0, len(h.Counts))
+	for index := range h.Counts {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	seen := uint64(0)
+	for _, index := range indexes {
+		seen += h.Counts[index]
+		if seen >= rank {
+			low, _ := histogramBucketRange(index)
+			v := float64(low) * histogramUnit
+			return math.Min(math.Max(v, h.Min), h.Max)
+		}
+	}
+
+	return h.Max
+}
+
+// Percentiles returns the percentiles reported in the results
+func (h *Histogram) Percentiles() Percentiles {
+	return Percentiles{
+		P50:  h.Percentile(50),
+		P90:  h.Percentile(90),
+		P95:  h.Percentile(95),
+		P99:  h.Percentile(99),
+		P999: h.Percentile(99.9),
+	}
+}
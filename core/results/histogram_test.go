@@ -0,0 +1,110 @@
+package results
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// exactPercentile returns the nearest-rank percentile of the sorted values
+func exactPercentile(sorted []float64, q float64) float64 {
+	rank := int(math.Ceil(q / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+func TestHistogramPercentiles(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	values := make([]float64, 100000)
+	for i := range values {
+		// Long tailed latencies between 1ms and a few seconds
+		values[i] = math.Round(math.Exp(rng.NormFloat64()+4)*1000) / 1000
+	}
+
+	h := NewHistogramOf(values)
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	for _, q := range []float64{50, 90, 95, 99, 99.9} {
+		exact := exactPercentile(sorted, q)
+		if got := h.Percentile(q); math.Abs(got-exact) > exact*0.001+0.001 {
+			t.Errorf("p%v: expected %f within 0.1%%, got %f", q, exact, got)
+		}
+	}
+
+	if h.Min != sorted[0] || h.Max != sorted[len(sorted)-1] {
+		t.Errorf("expected min %f and max %f, got %f and %f", sorted[0], sorted[len(sorted)-1], h.Min, h.Max)
+	}
+
+	if h.Percentile(100) != h.Max {
+		t.Errorf("expected p100 to be the maximum %f, got %f", h.Max, h.Percentile(100))
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a := NewHistogramOf([]float64{1, 2, 3, 250})
+	b := NewHistogramOf([]float64{5, 1200})
+	all := NewHistogramOf([]float64{1, 2, 3, 250, 5, 1200})
+
+	merged := NewHistogram()
+	merged.Merge(a)
+	merged.Merge(b)
+	merged.Merge(nil)
+
+	// The histograms travel as JSON from the secondaries
+	encoded, err := json.Marshal(merged)
+	if err != nil {
+		t.Fatalf("failed to encode histogram: %s", err.Error())
+	}
+
+	var decoded Histogram
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("failed to decode histogram: %s", err.Error())
+	}
+
+	if decoded.Count != all.Count || decoded.Min != all.Min || decoded.Max != all.Max || decoded.Sum != all.Sum {
+		t.Errorf("expected %+v, got %+v", all, decoded)
+	}
+
+	if decoded.Percentiles() != all.Percentiles() {
+		t.Errorf("expected the percentiles %+v, got %+v", all.Percentiles(), decoded.Percentiles())
+	}
+
+	if empty := NewHistogram(); empty.Percentile(99) != 0 || empty.Mean() != 0 {
+		t.Errorf("expected 0 for an empty histogram")
+	}
+}
+
+func TestAggregatedPercentiles(t *testing.T) {
+	secondary := func(latencies ...float64) []Results {
+		return []Results{{TxLatencies: latencies, ThroughputSeconds: []float64{1}}}
+	}
+
+	// The median is over all the transactions, not the per-secondary averages
+	aggregated := CalculateAggregatedResults([][]Results{
+		secondary(10, 10, 10, 10, 10),
+		secondary(1000),
+	})
+
+	if aggregated.MedianLatency != 10 {
+		t.Errorf("expected a median of 10ms, got %f", aggregated.MedianLatency)
+	}
+
+	if aggregated.Percentiles.P999 != 1000 {
+		t.Errorf("expected p99.9 of 1000ms, got %f", aggregated.Percentiles.P999)
+	}
+
+	if aggregated.LatencyHistogram.Count != 6 || aggregated.SecondaryResults[1].LatencyHistogram.Count != 1 {
+		t.Errorf("expected the merged histogram of 6 transactions, got %d", aggregated.LatencyHistogram.Count)
+	}
+
+	// Without response latencies, the response latency is the service latency
+	if aggregated.ResponsePercentiles != aggregated.Percentiles {
+		t.Errorf("expected the response percentiles %+v, got %+v", aggregated.Percentiles, aggregated.ResponsePercentiles)
+	}
+}
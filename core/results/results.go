@@ -8,20 +8,52 @@ package results
 
 import (
 	"fmt"
-	"sort"
+	"time"
 
 	"go.uber.org/zap"
 )
 
 // Results is the generic result structure that will be encoded and sent back to the primary and combined
 type Results struct {
-	TxLatencies       []float64 `json:"TxLatencies"`       // Latency of each transaction, can be used in CDF
-	AverageLatency    float64   `json:"AverageLatency"`    // Averaged latency of the transactions
-	MedianLatency     float64   `json:"MedianLatency"`     // Median Latency of the transaction
-	Throughput        float64   `json:"Throughput"`        // Number of transactions per second "committed"
-	ThroughputSeconds []float64 `json:"ThroughputSeconds"` // Number of transactions "committed" over second periods to measure dynamic throughput
+	TxLatencies       []float64 `json:"TxLatencies,omitempty"` // Service latency of each transaction, from its actual send, dropped once summarised by the histogram
+	AverageLatency    float64   `json:"AverageLatency"`        // Averaged latency of the transactions
+	MedianLatency     float64   `json:"MedianLatency"`         // Median Latency of the transaction
+	Throughput        float64   `json:"Throughput"`            // Number of transactions per second "committed"
+	ThroughputSeconds []float64 `json:"ThroughputSeconds"`     // Number of transactions "committed" over second periods to measure dynamic throughput
 	Success           uint      // Number of successful transactions
 	Fail              uint      // Number of failed transactions
+
+	// Response latency, measured from the time each transaction was scheduled
+	// to be sent so that the delays of the client are not omitted
+	ResponseLatencies      []float64 `json:"ResponseLatencies,omitempty"` // Response latency of each transaction, dropped once summarised by the histogram
+	AverageResponseLatency float64   `json:"AverageResponseLatency"`      // Averaged response latency of the transactions
+
+	// Distribution of the latencies, built on the secondaries
+	LatencyHistogram    *Histogram  `json:"LatencyHistogram,omitempty"`  // Histogram of the service latencies
+	ResponseHistogram   *Histogram  `json:"ResponseHistogram,omitempty"` // Histogram of the response latencies
+	Percentiles         Percentiles `json:"Percentiles"`                 // Percentiles of the service latency
+	ResponsePercentiles Percentiles `json:"ResponsePercentiles"`         // Percentiles of the response latency
+
+	// Breakdown of the failed transactions, when reported by the chain
+	Reverted      uint            `json:"Reverted"`                // Number of transactions committed but reverted
+	Dropped       uint            `json:"Dropped"`                 // Number of transactions sent but never committed
+	SendFailed    uint            `json:"SendFailed"`              // Number of transactions that could not be sent
+	GasUsed       uint64          `json:"GasUsed"`                 // Gas used by the committed transactions
+	RevertReasons map[string]uint `json:"RevertReasons,omitempty"` // Number of reverted transactions per reason
+
+	// Latency of the phases of the transactions, when reported by the chain
+	PhaseLatencies  map[string]float64 `json:"PhaseLatencies,omitempty"`  // Average latency of each phase of the committed transactions
+	ValidationCodes map[string]uint    `json:"ValidationCodes,omitempty"` // Number of transactions per validation code
+
+	// Pacing of the sends
+	SendDelays         []float64  `json:"SendDelays,omitempty"`         // Delay between the scheduled and actual send time of each transaction (ms), dropped once summarised
+	SendDelayHistogram *Histogram `json:"SendDelayHistogram,omitempty"` // Histogram of the send delays
+
+	// Start of the benchmark on the clock of the secondary (unix ns)
+	StartTime int64 `json:"StartTime,omitempty"`
+
+	// Whether the run was cancelled before its end, the results are then partial
+	Interrupted bool `json:"Interrupted,omitempty"`
 }
 
 // AggregatedResults returns all the information from all secondaries, and
@@ -32,11 +64,21 @@ type AggregatedResults struct {
 	SecondaryResults []Results   `json:"SecondaryResults"` // Aggregation of results per secondary
 
 	// Latency
-	MinLatency     float64   `json:"MinLatency"`     // Minimum latency across all workers and secondaries
-	AverageLatency float64   `json:"AverageLatency"` // Average latency across all workers and secondaries
-	MaxLatency     float64   `json:"MaxLatency"`     // Maximum Latency across all workers and secondaries
-	MedianLatency  float64   `json:"MedianLatency"`  // Median Latency across all workers and secondaries
-	AllTxLatencies []float64 `json:"AllTxLatencies"` // All Transaction Latencies
+	MinLatency     float64 `json:"MinLatency"`     // Minimum latency across all workers and secondaries
+	AverageLatency float64 `json:"AverageLatency"` // Average latency across all workers and secondaries
+	MaxLatency     float64 `json:"MaxLatency"`     // Maximum Latency across all workers and secondaries
+	MedianLatency  float64 `json:"MedianLatency"`  // Median Latency across all transactions
+
+	// Latency distribution across all transactions
+	LatencyHistogram    *Histogram  `json:"LatencyHistogram"`    // Merged histogram of the service latencies
+	ResponseHistogram   *Histogram  `json:"ResponseHistogram"`   // Merged histogram of the response latencies
+	Percentiles         Percentiles `json:"Percentiles"`         // Percentiles of the service latency
+	ResponsePercentiles Percentiles `json:"ResponsePercentiles"` // Percentiles of the response latency
+
+	// Response latency, corrected for coordinated omission
+	AverageResponseLatency float64 `json:"AverageResponseLatency"` // Average response latency across all workers and secondaries
+	MaxResponseLatency     float64 `json:"MaxResponseLatency"`     // Maximum response latency across all workers and secondaries
+	MedianResponseLatency  float64 `json:"MedianResponseLatency"`  // Median response latency across all transactions
 
 	// Throughput
 	TotalThroughputTimes         []float64   `json:"TotalThroughputOverTime"`              // Total throughput over time per window
@@ -47,23 +89,186 @@ type AggregatedResults struct {
 	AverageThroughput            float64     `json:"AverageThroughput"`                    // Average throughput reached overall
 
 	// Success and Fail
-	TotalSuccess uint `json:"TotalSuccess"` // Total number of successes
-	TotalFails   uint `json:"TotalFails"`   // Total number of fails
+	TotalSuccess    uint            `json:"TotalSuccess"`            // Total number of successes
+	TotalFails      uint            `json:"TotalFails"`              // Total number of fails
+	TotalReverted   uint            `json:"TotalReverted"`           // Total number of reverted transactions
+	TotalDropped    uint            `json:"TotalDropped"`            // Total number of transactions never committed
+	TotalSendFailed uint            `json:"TotalSendFailed"`         // Total number of transactions that could not be sent
+	TotalGasUsed    uint64          `json:"TotalGasUsed"`            // Total gas used by the committed transactions
+	RevertReasons   map[string]uint `json:"RevertReasons,omitempty"` // Number of reverted transactions per reason
+
+	// Phases
+	PhaseLatencies  map[string]float64 `json:"PhaseLatencies,omitempty"`  // Average latency of each phase across all workers
+	ValidationCodes map[string]uint    `json:"ValidationCodes,omitempty"` // Number of transactions per validation code
+
+	// Pacing
+	AverageSendDelay float64 `json:"AverageSendDelay"` // Average delay between the scheduled and actual send times (ms)
+	MaxSendDelay     float64 `json:"MaxSendDelay"`     // Maximum delay between the scheduled and actual send times (ms)
+
+	// Start synchronisation
+	ClockOffsets   []float64 `json:"ClockOffsets,omitempty"`   // Clock offset of each secondary to the primary (ms)
+	RoundTripTimes []float64 `json:"RoundTripTimes,omitempty"` // Round trip time to each secondary when measuring its clock (ms)
+	StartSkew      float64   `json:"StartSkew"`                // Spread of the start times of the secondaries on the clock of the primary (ms)
+
+	// Abort
+	Aborted     bool   `json:"Aborted"`               // Whether the run was aborted, the results are then partial
+	AbortReason string `json:"AbortReason,omitempty"` // Why the run was aborted
+
+	// Connection of the secondaries
+	DegradedSecondaries []int `json:"DegradedSecondaries,omitempty"` // Secondaries that lost their connection and reconnected
+	LostSecondaries     []int `json:"LostSecondaries,omitempty"`     // Secondaries whose results could not be collected
+
+	// Workload
+	FunctionMix []FunctionMix `json:"FunctionMix,omitempty"` // Calls of each contract function in the workload
+}
+
+// FunctionMix is the number of calls of a contract function in the workload
+type FunctionMix struct {
+	Contract  string  `json:"Contract"`  // Name or address of the contract
+	Function  string  `json:"Function"`  // Name of the function
+	Ratio     float64 `json:"Ratio"`     // Configured share of the transactions (%)
+	Expected  uint    `json:"Expected"`  // Number of calls for the configured share
+	Generated uint    `json:"Generated"` // Number of calls generated
+}
+
+// SetAborted marks the results as partial, the run was aborted for the reason given
+func (ar *AggregatedResults) SetAborted(reason string) {
+	ar.Aborted = true
+	ar.AbortReason = reason
+}
+
+// SetSecondaryHealth records the secondaries that reconnected during the
+// benchmark, and the ones missing from the results.
+func (ar *AggregatedResults) SetSecondaryHealth(degraded []int, lost []int) {
+	ar.DegradedSecondaries = degraded
+	ar.LostSecondaries = lost
+}
+
+// SetFunctionMix records the calls of each contract function in the workload
+func (ar *AggregatedResults) SetFunctionMix(mix []FunctionMix) {
+	ar.FunctionMix = mix
+}
+
+// SetStartSkew records the clock offset and round trip time measured for
+// each secondary, and the spread of their start times on the clock of the
+// primary. The secondaries must be indexed by ID, like the raw results.
+func (ar *AggregatedResults) SetStartSkew(offsets []time.Duration, rtts []time.Duration) {
+	ar.ClockOffsets = make([]float64, len(offsets))
+	for i, o := range offsets {
+		ar.ClockOffsets[i] = float64(o.Microseconds()) / 1000
+	}
+
+	ar.RoundTripTimes = make([]float64, len(rtts))
+	for i, rtt := range rtts {
+		ar.RoundTripTimes[i] = float64(rtt.Microseconds()) / 1000
+	}
+
+	if len(offsets) != len(ar.RawResults) {
+		zap.L().Warn("cannot compute the start skew without the results of every secondary")
+		return
+	}
+
+	var first, last int64
+	for i, secondaryResults := range ar.RawResults {
+		if len(secondaryResults) == 0 || secondaryResults[0].StartTime == 0 {
+			continue
+		}
+
+		start := secondaryResults[0].StartTime - offsets[i].Nanoseconds()
+		if first == 0 || start < first {
+			first = start
+		}
+		if start > last {
+			last = start
+		}
+	}
+
+	ar.StartSkew = float64(time.Duration(last-first).Microseconds()) / 1000
+}
+
+// mergePhaseLatencies adds the phase latencies of the worker to the totals,
+// weighted by the number of committed transactions n of the worker.
+func mergePhaseLatencies(totals map[string]float64, weights map[string]float64, phases map[string]float64, n uint64) {
+	for phase, latency := range phases {
+		totals[phase] += latency * float64(n)
+		weights[phase] += float64(n)
+	}
 }
 
-// Return the median of a list
-func getMedian(arr []float64) float64 {
-	arrSorted := arr
-	sort.Float64s(arrSorted)
+// averagePhaseLatencies returns the weighted average of the merged phase latencies
+func averagePhaseLatencies(totals map[string]float64, weights map[string]float64) map[string]float64 {
+	if len(totals) == 0 {
+		return nil
+	}
 
-	midNumber := len(arrSorted) / 2
-	if len(arrSorted)%2 == 0 {
-		return (arrSorted[midNumber-1] + arrSorted[midNumber]) / 2
+	averages := make(map[string]float64)
+	for phase, total := range totals {
+		if weights[phase] > 0 {
+			averages[phase] = total / weights[phase]
+		}
 	}
-	return arrSorted[midNumber]
+
+	return averages
 }
 
-// CalculateAggregatedResults calculates the aggregated results given the set of results from the secondaries
+// workerResponseLatencies returns the response latencies of the worker. If
+// the chain did not report them, the transactions were not scheduled and the
+// response latency is the service latency.
+func workerResponseLatencies(result Results) []float64 {
+	if len(result.ResponseLatencies) == 0 {
+		return result.TxLatencies
+	}
+
+	return result.ResponseLatencies
+}
+
+// workerHistograms returns the histograms of the service and response
+// latencies of the worker, built from its latencies if it has none.
+func workerHistograms(result Results) (*Histogram, *Histogram) {
+	latency := result.LatencyHistogram
+	if latency == nil {
+		latency = NewHistogramOf(result.TxLatencies)
+	}
+
+	response := result.ResponseHistogram
+	if response == nil {
+		response = NewHistogramOf(workerResponseLatencies(result))
+	}
+
+	return latency, response
+}
+
+// workerSendDelays returns the histogram of the send delays of the worker,
+// built from its send delays if it has none.
+func workerSendDelays(result Results) *Histogram {
+	if result.SendDelayHistogram != nil {
+		return result.SendDelayHistogram
+	}
+
+	return NewHistogramOf(result.SendDelays)
+}
+
+// Summarise replaces the latencies and the send delays of every transaction
+// of the worker with their histograms and percentiles, so that the results
+// sent to the primary stay small whatever the number of transactions.
+func (r *Results) Summarise() {
+	r.LatencyHistogram, r.ResponseHistogram = workerHistograms(*r)
+	r.Percentiles = r.LatencyHistogram.Percentiles()
+	r.ResponsePercentiles = r.ResponseHistogram.Percentiles()
+	if len(r.SendDelays) > 0 {
+		r.SendDelayHistogram = workerSendDelays(*r)
+	}
+
+	r.TxLatencies = nil
+	r.ResponseLatencies = nil
+	r.SendDelays = nil
+}
+
+// CalculateAggregatedResults calculates the aggregated results given the set
+// of results from the secondaries, indexed by secondary ID. The latencies are
+// computed from the histograms of the workers, merged per secondary and over
+// all the secondaries. The lost secondaries have no results and keep an empty
+// slot in the results per secondary.
 func CalculateAggregatedResults(secondaryResults [][]Results) AggregatedResults {
 
 	// Check that it's not empty
@@ -81,13 +286,10 @@ func CalculateAggregatedResults(secondaryResults [][]Results) AggregatedResults
 	// Total throughput per secondary per second (throughput over time)
 	var throughputOverTimeSecondary [][]float64
 
-	// Min/Max/Average Latency
-	maxTotalLatency := float64(0)
-	averageTotalLatency := float64(0)
-	minTotalLatency := secondaryResults[0][0].AverageLatency
-
-	var latencyPerSecondary []float64
-	var allTxLatencies []float64
+	// Latency distributions over all transactions
+	latencyHistogram := NewHistogram()
+	responseHistogram := NewHistogram()
+	sendDelayHistogram := NewHistogram()
 
 	// Throughput total
 	maxTotalThroughput := float64(0)
@@ -95,35 +297,65 @@ func CalculateAggregatedResults(secondaryResults [][]Results) AggregatedResults
 
 	totalSuccess := uint(0)
 	totalFails := uint(0)
+	totalReverted := uint(0)
+	totalDropped := uint(0)
+	totalSendFailed := uint(0)
+	totalGasUsed := uint64(0)
+	revertReasons := make(map[string]uint)
+	validationCodes := make(map[string]uint)
+	phaseTotals := make(map[string]float64)
+	phaseWeights := make(map[string]float64)
+	anyInterrupted := false
 
 	// Iterate through the results
 	for secondaryID, secondaryResult := range secondaryResults {
-		txLatencies := make([]float64, 0)
-		averageLatencyPerSecondary := float64(0)
+		if len(secondaryResult) == 0 {
+			ResultsPerSecondary = append(ResultsPerSecondary, Results{})
+			throughputOverTimeSecondary = append(throughputOverTimeSecondary, nil)
+			throughputPerSecondary = append(throughputPerSecondary, 0)
+			continue
+		}
+
+		secondaryLatencyHistogram := NewHistogram()
+		secondaryResponseHistogram := NewHistogram()
 		secondaryThroughputs := make([]float64, 0)
-		latencyEntries := float64(0)
 		avgThroughputPerSecondary := float64(0)
 		// For each worker
 		numSuccess := uint(0)
 		numFails := uint(0)
+		numReverted := uint(0)
+		numDropped := uint(0)
+		numSendFailed := uint(0)
+		gasUsed := uint64(0)
+		interrupted := false
+		secondaryRevertReasons := make(map[string]uint)
+		secondaryValidationCodes := make(map[string]uint)
+		secondaryPhaseTotals := make(map[string]float64)
+		secondaryPhaseWeights := make(map[string]float64)
 		for workerID, workerResult := range secondaryResult {
-			// 1. get the latency average per secondary
-			latencyEntries += float64(len(workerResult.TxLatencies))
+			// 1. merge the latency distributions of the worker
+			workerLatencyHistogram, workerResponseHistogram := workerHistograms(workerResult)
+			secondaryLatencyHistogram.Merge(workerLatencyHistogram)
+			secondaryResponseHistogram.Merge(workerResponseHistogram)
+			sendDelayHistogram.Merge(workerSendDelays(workerResult))
+
 			numSuccess += workerResult.Success
 			numFails += workerResult.Fail
-			for _, v := range workerResult.TxLatencies {
-				averageLatencyPerSecondary += v
-				if minTotalLatency > v && v > 0 {
-					minTotalLatency = v
-				}
-
-				if v > maxTotalLatency {
-					maxTotalLatency = v
-				}
-
-				txLatencies = append(txLatencies, v)
-				allTxLatencies = append(allTxLatencies, v)
+			numReverted += workerResult.Reverted
+			numDropped += workerResult.Dropped
+			numSendFailed += workerResult.SendFailed
+			gasUsed += workerResult.GasUsed
+			interrupted = interrupted || workerResult.Interrupted
+			for reason, count := range workerResult.RevertReasons {
+				secondaryRevertReasons[reason] += count
+				revertReasons[reason] += count
+			}
+			for code, count := range workerResult.ValidationCodes {
+				secondaryValidationCodes[code] += count
+				validationCodes[code] += count
 			}
+			mergePhaseLatencies(secondaryPhaseTotals, secondaryPhaseWeights, workerResult.PhaseLatencies, workerLatencyHistogram.Count)
+			mergePhaseLatencies(phaseTotals, phaseWeights, workerResult.PhaseLatencies, workerLatencyHistogram.Count)
 
 			// 2. Obtain throughputs
 			for timeIndex, v := range workerResult.ThroughputSeconds {
@@ -145,55 +377,55 @@ func CalculateAggregatedResults(secondaryResults [][]Results) AggregatedResults
 			)
 		}
 
-		// fix the average latencies
-		avgLatency := float64(0)
-		for idx := 0; idx < len(txLatencies); idx++ {
-			avgLatency += txLatencies[idx]
-		}
-		if len(txLatencies) > 0 {
-			avgLatency = avgLatency / float64(len(txLatencies))
-		}
-		latencyPerSecondary = append(latencyPerSecondary, avgLatency)
-
-		// Get the median latency by sorting -> getting middle number
-		sortedLatencies := txLatencies
-		sort.Float64s(sortedLatencies)
-		medianLatency := float64(0)
-		if len(txLatencies) > 0 {
-			midNumber := len(txLatencies) / 2
-			if len(txLatencies)%2 == 0 {
-				medianLatency = (txLatencies[midNumber-1] + txLatencies[midNumber]) / 2
-			} else {
-				medianLatency = txLatencies[midNumber]
-			}
-		}
-
-		// Total and averages
-		averageTotalLatency += avgLatency
+		// Totals and averages
 		throughputOverTimeSecondary = append(throughputOverTimeSecondary, secondaryThroughputs)
 		throughputPerSecondary = append(throughputPerSecondary, avgThroughputPerSecondary/float64(len(secondaryResult)))
 
+		secondaryPercentiles := secondaryLatencyHistogram.Percentiles()
 		ResultsPerSecondary = append(ResultsPerSecondary, Results{
-			TxLatencies:       txLatencies,
-			ThroughputSeconds: secondaryThroughputs,
-			Throughput:        avgThroughputPerSecondary / float64(len(secondaryResult)),
-			AverageLatency:    avgLatency,
-			MedianLatency:     medianLatency,
-			Success:           numSuccess,
-			Fail:              numFails,
+			AverageResponseLatency: secondaryResponseHistogram.Mean(),
+			ThroughputSeconds:      secondaryThroughputs,
+			Throughput:             avgThroughputPerSecondary / float64(len(secondaryResult)),
+			AverageLatency:         secondaryLatencyHistogram.Mean(),
+			MedianLatency:          secondaryPercentiles.P50,
+			Success:                numSuccess,
+			Fail:                   numFails,
+			Reverted:               numReverted,
+			Dropped:                numDropped,
+			SendFailed:             numSendFailed,
+			GasUsed:                gasUsed,
+			RevertReasons:          secondaryRevertReasons,
+			PhaseLatencies:         averagePhaseLatencies(secondaryPhaseTotals, secondaryPhaseWeights),
+			ValidationCodes:        secondaryValidationCodes,
+			LatencyHistogram:       secondaryLatencyHistogram,
+			ResponseHistogram:      secondaryResponseHistogram,
+			Percentiles:            secondaryPercentiles,
+			ResponsePercentiles:    secondaryResponseHistogram.Percentiles(),
+			Interrupted:            interrupted,
 		})
+		latencyHistogram.Merge(secondaryLatencyHistogram)
+		responseHistogram.Merge(secondaryResponseHistogram)
 
 		// Update the number of total success and failures
 		totalSuccess += numSuccess
 		totalFails += numFails
+		totalReverted += numReverted
+		totalDropped += numDropped
+		totalSendFailed += numSendFailed
+		totalGasUsed += gasUsed
+		anyInterrupted = anyInterrupted || interrupted
 	}
 
-	// Fix up the average and median latency
-	averageTotalLatency = averageTotalLatency / float64(len(secondaryResults))
-	medianLatencyTotal := getMedian(latencyPerSecondary)
+	// The percentiles are over all transactions
+	percentiles := latencyHistogram.Percentiles()
+	responsePercentiles := responseHistogram.Percentiles()
 
 	// Fix up the overall throughput and average throughput
-	minTotalThroughput := totalThroughputOverTime[0]
+	// An aborted run may not have lasted a full window
+	minTotalThroughput := float64(0)
+	if len(totalThroughputOverTime) > 0 {
+		minTotalThroughput = totalThroughputOverTime[0]
+	}
 	for _, v := range totalThroughputOverTime {
 		if v > maxTotalThroughput {
 			maxTotalThroughput = v
@@ -206,7 +438,9 @@ func CalculateAggregatedResults(secondaryResults [][]Results) AggregatedResults
 		averageTotalThroughput += v
 	}
 
-	averageTotalThroughput = averageTotalThroughput / float64(len(totalThroughputOverTime))
+	if len(totalThroughputOverTime) > 0 {
+		averageTotalThroughput = averageTotalThroughput / float64(len(totalThroughputOverTime))
+	}
 
 	// DEBUG PURPOSES ONLY
 	var avgThroughputAvg float64
@@ -222,12 +456,13 @@ func CalculateAggregatedResults(secondaryResults [][]Results) AggregatedResults
 
 	// Return the absolute mass of results chunked together!
 	return AggregatedResults{
+		Aborted:                      anyInterrupted,
 		RawResults:                   secondaryResults,
 		SecondaryResults:             ResultsPerSecondary,
-		MinLatency:                   minTotalLatency,
-		AverageLatency:               averageTotalLatency,
-		MedianLatency:                medianLatencyTotal,
-		MaxLatency:                   maxTotalLatency,
+		MinLatency:                   latencyHistogram.Min,
+		AverageLatency:               latencyHistogram.Mean(),
+		MedianLatency:                percentiles.P50,
+		MaxLatency:                   latencyHistogram.Max,
 		TotalThroughputTimes:         totalThroughputOverTime,
 		AverageThroughputSecondary:   throughputPerSecondary,
 		TotalThroughputSecondaryTime: throughputOverTimeSecondary,
@@ -236,6 +471,21 @@ func CalculateAggregatedResults(secondaryResults [][]Results) AggregatedResults
 		AverageThroughput:            avgThroughputAvg,
 		TotalSuccess:                 totalSuccess,
 		TotalFails:                   totalFails,
-		AllTxLatencies:               allTxLatencies,
+		TotalReverted:                totalReverted,
+		TotalDropped:                 totalDropped,
+		TotalSendFailed:              totalSendFailed,
+		TotalGasUsed:                 totalGasUsed,
+		RevertReasons:                revertReasons,
+		PhaseLatencies:               averagePhaseLatencies(phaseTotals, phaseWeights),
+		ValidationCodes:              validationCodes,
+		AverageSendDelay:             sendDelayHistogram.Mean(),
+		MaxSendDelay:                 sendDelayHistogram.Max,
+		AverageResponseLatency:       responseHistogram.Mean(),
+		MaxResponseLatency:           responseHistogram.Max,
+		MedianResponseLatency:        responsePercentiles.P50,
+		LatencyHistogram:             latencyHistogram,
+		ResponseHistogram:            responseHistogram,
+		Percentiles:                  percentiles,
+		ResponsePercentiles:          responsePercentiles,
 	}
 }
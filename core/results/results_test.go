@@ -0,0 +1,105 @@
+package results
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStartSkew(t *testing.T) {
+	start := time.Now()
+
+	// The second secondary is 2s ahead and started 3ms later
+	aggregated := CalculateAggregatedResults([][]Results{
+		{{TxLatencies: []float64{10}, ThroughputSeconds: []float64{1}, StartTime: start.UnixNano()}},
+		{{TxLatencies: []float64{10}, ThroughputSeconds: []float64{1}, StartTime: start.Add(2*time.Second + 3*time.Millisecond).UnixNano()}},
+	})
+	aggregated.SetStartSkew([]time.Duration{0, 2 * time.Second}, []time.Duration{time.Millisecond, 2 * time.Millisecond})
+
+	if aggregated.StartSkew != 3 {
+		t.Errorf("expected a start skew of 3ms, got %f", aggregated.StartSkew)
+	}
+
+	if aggregated.ClockOffsets[1] != 2000 || aggregated.RoundTripTimes[1] != 2 {
+		t.Errorf("expected an offset of 2000ms and rtt of 2ms, got %v and %v", aggregated.ClockOffsets, aggregated.RoundTripTimes)
+	}
+}
+
+func TestLostSecondarySlot(t *testing.T) {
+	start := time.Now()
+
+	// Secondary 1 was lost, the others keep their IDs
+	aggregated := CalculateAggregatedResults([][]Results{
+		{{TxLatencies: []float64{10}, ThroughputSeconds: []float64{1}, Success: 1, StartTime: start.UnixNano()}},
+		nil,
+		{{TxLatencies: []float64{30}, ThroughputSeconds: []float64{1}, Success: 1, StartTime: start.Add(time.Second + 4*time.Millisecond).UnixNano()}},
+	})
+	aggregated.SetStartSkew([]time.Duration{0, 0, time.Second}, []time.Duration{0, 0, 0})
+
+	if len(aggregated.SecondaryResults) != 3 || aggregated.SecondaryResults[1].Success != 0 {
+		t.Fatalf("expected an empty slot for the lost secondary, got %+v", aggregated.SecondaryResults)
+	}
+	if aggregated.SecondaryResults[2].AverageLatency != 30 || aggregated.TotalSuccess != 2 {
+		t.Errorf("expected the results of secondary 2 in its slot, got %+v", aggregated.SecondaryResults[2])
+	}
+	if aggregated.StartSkew != 4 {
+		t.Errorf("expected a start skew of 4ms, got %f", aggregated.StartSkew)
+	}
+}
+
+func TestWriteResultsCompact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	worker := Results{
+		TxLatencies:       []float64{10, 20, 30},
+		ResponseLatencies: []float64{11, 21, 31},
+		SendDelays:        []float64{1, 2, 3},
+		ThroughputSeconds: []float64{3},
+	}
+	worker.Summarise()
+	if len(worker.TxLatencies)+len(worker.ResponseLatencies)+len(worker.SendDelays) > 0 {
+		t.Errorf("expected no values per transaction once summarised, got %+v", worker)
+	}
+	aggregated := CalculateAggregatedResults([][]Results{{worker}})
+
+	path := filepath.Join(dir, "results.json")
+	if err := writeResults(path, aggregated); err != nil {
+		t.Fatalf("failed to write the results: %s", err.Error())
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var written AggregatedResults
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatal(err)
+	}
+
+	// The values of every transaction are summarised by histograms
+	for _, r := range append(written.RawResults[0], written.SecondaryResults...) {
+		if len(r.TxLatencies)+len(r.ResponseLatencies)+len(r.SendDelays) > 0 {
+			t.Errorf("expected no values per transaction in the results file, got %+v", r)
+		}
+	}
+	h := written.RawResults[0][0].SendDelayHistogram
+	if h == nil || h.Count != 3 {
+		t.Errorf("expected the histogram of the 3 send delays, got %+v", h)
+	}
+
+	// The statistics are computed from the histograms
+	if written.MinLatency != 10 || written.MaxLatency != 30 || written.AverageLatency != 20 {
+		t.Errorf("expected latencies of 10/20/30ms, got %f/%f/%f", written.MinLatency, written.AverageLatency, written.MaxLatency)
+	}
+	if written.AverageSendDelay != 2 || written.MaxSendDelay != 3 {
+		t.Errorf("expected send delays of 2/3ms, got %f/%f", written.AverageSendDelay, written.MaxSendDelay)
+	}
+}
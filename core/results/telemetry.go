@@ -0,0 +1,90 @@
+package results
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Telemetry is the progress of a secondary during the benchmark, sent
+// periodically to the primary.
+type Telemetry struct {
+	Sent      uint64  `json:"sent"`      // Number of transactions sent, including the failed sends
+	Committed uint64  `json:"committed"` // Number of transactions committed
+	Failed    uint64  `json:"failed"`    // Number of transactions that failed to be sent or committed
+	SendLag   float64 `json:"sendLag"`   // Delay of the latest sends behind their scheduled time (ms)
+}
+
+// FailureRate returns the fraction of the completed transactions that failed
+func (t Telemetry) FailureRate() float64 {
+	if t.Committed+t.Failed == 0 {
+		return 0
+	}
+
+	return float64(t.Failed) / float64(t.Committed+t.Failed)
+}
+
+// String formats the progress for the console
+func (t Telemetry) String() string {
+	return fmt.Sprintf("sent %d, committed %d, failed %d (%.2f%%), lag %.1f ms",
+		t.Sent, t.Committed, t.Failed, 100*t.FailureRate(), t.SendLag)
+}
+
+// LiveTelemetry keeps the latest telemetry of each secondary during the
+// benchmark, to follow its progress on the primary. It is safe for
+// concurrent use.
+type LiveTelemetry struct {
+	lock        sync.Mutex
+	secondaries []Telemetry // Latest telemetry of each secondary
+}
+
+// NewLiveTelemetry returns the live telemetry of the given number of secondaries
+func NewLiveTelemetry(secondaries int) *LiveTelemetry {
+	return &LiveTelemetry{secondaries: make([]Telemetry, secondaries)}
+}
+
+// Update records the latest telemetry of the secondary
+func (lt *LiveTelemetry) Update(secondary int, t Telemetry) {
+	lt.lock.Lock()
+	defer lt.lock.Unlock()
+
+	if secondary >= 0 && secondary < len(lt.secondaries) {
+		lt.secondaries[secondary] = t
+	}
+}
+
+// Total returns the sum of the counts of all secondaries, with the largest
+// send lag.
+func (lt *LiveTelemetry) Total() Telemetry {
+	lt.lock.Lock()
+	defer lt.lock.Unlock()
+
+	var total Telemetry
+	for _, t := range lt.secondaries {
+		total.Sent += t.Sent
+		total.Committed += t.Committed
+		total.Failed += t.Failed
+		if t.SendLag > total.SendLag {
+			total.SendLag = t.SendLag
+		}
+	}
+
+	return total
+}
+
+// View formats the progress of all secondaries for the console, the total
+// followed by one line per secondary.
+func (lt *LiveTelemetry) View() string {
+	total := lt.Total()
+
+	lt.lock.Lock()
+	defer lt.lock.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "LIVE: %s", total)
+	for i, t := range lt.secondaries {
+		fmt.Fprintf(&b, "\n  secondary %d: %s", i, t)
+	}
+
+	return b.String()
+}
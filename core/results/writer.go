@@ -62,7 +62,7 @@ func copyFile(fromPath string, toPath string) error {
 	return err
 }
 
-// writeResults marshals the data into JSON and writes the result as a JSON file
+// writeResults marshals the data into JSON and writes the result as a JSON file.
 func writeResults(path string, data AggregatedResults) error {
 	f, err := json.MarshalIndent(data, "", " ")
 
@@ -105,22 +105,79 @@ func WriteResultsToFile(benchConfig string, chainConfig string, results Aggregat
 	return err
 }
 
+// formatPercentiles formats the percentiles on one line
+func formatPercentiles(p Percentiles) string {
+	return fmt.Sprintf("p50 %.3f | p90 %.3f | p95 %.3f | p99 %.3f | p99.9 %.3f", p.P50, p.P90, p.P95, p.P99, p.P999)
+}
+
 // Display presents the formatting to display the results to stdout.
 // TODO: future - this can be made to show graphs, and present the results in a much nicer way!
 func Display(results AggregatedResults) {
 
 	fmt.Println()
 	fmt.Println("--------------------------")
-	fmt.Println("Benchmark Complete")
+	if results.Aborted {
+		fmt.Println("Benchmark Aborted (partial results)")
+		if results.AbortReason != "" {
+			fmt.Println(results.AbortReason)
+		}
+	} else {
+		fmt.Println("Benchmark Complete")
+	}
+	if len(results.DegradedSecondaries) > 0 {
+		fmt.Println(fmt.Sprintf("Secondaries reconnected during the benchmark: %v", results.DegradedSecondaries))
+	}
+	if len(results.LostSecondaries) > 0 {
+		fmt.Println(fmt.Sprintf("Secondaries missing from the results: %v", results.LostSecondaries))
+	}
 	fmt.Println("--------------------------")
 	fmt.Println("[*] Aggregated Stats")
 	fmt.Println(fmt.Sprintf("\t [-] Throughput [tx/sec]: %.3f [Min: %.3f | Max: %.3f]", results.AverageThroughput, results.MinThroughput, results.MaxThroughput))
 	fmt.Println(fmt.Sprintf("\t [-] Latency        [ms]: %.3f [Min: %+v | Max: %+v]", results.AverageLatency, results.MinLatency, results.MaxLatency))
+	fmt.Println(fmt.Sprintf("\t [-] Response       [ms]: %.3f [Median: %.3f | Max: %+v]", results.AverageResponseLatency, results.MedianResponseLatency, results.MaxResponseLatency))
+	fmt.Println(fmt.Sprintf("\t [-] Latency  pct  [ms]: %s", formatPercentiles(results.Percentiles)))
+	fmt.Println(fmt.Sprintf("\t [-] Response pct  [ms]: %s", formatPercentiles(results.ResponsePercentiles)))
+	fmt.Println(fmt.Sprintf("\t [-] Transactions      : %d success | %d fail", results.TotalSuccess, results.TotalFails))
+	fmt.Println(fmt.Sprintf("\t [-] Send delay     [ms]: %.3f [Max: %.3f]", results.AverageSendDelay, results.MaxSendDelay))
+	if len(results.ClockOffsets) > 0 {
+		fmt.Println(fmt.Sprintf("\t [-] Start skew     [ms]: %.3f", results.StartSkew))
+	}
+	if results.TotalReverted+results.TotalDropped+results.TotalSendFailed > 0 {
+		fmt.Println(fmt.Sprintf("\t [-] Failures          : %d reverted | %d dropped | %d send failed", results.TotalReverted, results.TotalDropped, results.TotalSendFailed))
+	}
+	for reason, count := range results.RevertReasons {
+		if reason == "" {
+			reason = "<no reason>"
+		}
+		fmt.Println(fmt.Sprintf("\t\t [-] Reverted (%d): %s", count, reason))
+	}
+	if len(results.PhaseLatencies) > 0 {
+		fmt.Println(fmt.Sprintf("\t [-] Phases       [ms]: endorse %.3f | order %.3f | commit %.3f",
+			results.PhaseLatencies["endorse"], results.PhaseLatencies["order"], results.PhaseLatencies["commit"]))
+	}
+	for code, count := range results.ValidationCodes {
+		fmt.Println(fmt.Sprintf("\t\t [-] %s: %d", code, count))
+	}
+	if len(results.FunctionMix) > 0 {
+		fmt.Println("\t [-] Function mix      :")
+	}
+	for _, m := range results.FunctionMix {
+		fmt.Println(fmt.Sprintf("\t\t [-] %s.%s: %d calls (%.2f%%, expected %d)", m.Contract, m.Function, m.Generated, m.Ratio, m.Expected))
+	}
 
 	for i, v := range results.SecondaryResults {
+		if i < len(results.RawResults) && len(results.RawResults[i]) == 0 {
+			fmt.Println(fmt.Sprintf("[*] Secondary %d Stats: lost, no results", i))
+			continue
+		}
 		fmt.Println(fmt.Sprintf("[*] Secondary %d Stats", i))
 		fmt.Println(fmt.Sprintf("\t [-] Throughput [tx/sec]: %.3f", v.Throughput))
 		fmt.Println(fmt.Sprintf("\t [-] Latency        [ms]: %.3f", v.AverageLatency))
+		fmt.Println(fmt.Sprintf("\t [-] Response       [ms]: %.3f", v.AverageResponseLatency))
+		fmt.Println(fmt.Sprintf("\t [-] Latency  pct  [ms]: %s", formatPercentiles(v.Percentiles)))
+		if i < len(results.ClockOffsets) {
+			fmt.Println(fmt.Sprintf("\t [-] Clock offset   [ms]: %.3f [RTT: %.3f]", results.ClockOffsets[i], results.RoundTripTimes[i]))
+		}
 	}
 
 	fmt.Println()
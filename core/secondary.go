@@ -1,13 +1,16 @@
 package core
 
 import (
+	"context"
 	"diablo-benchmark/blockchains/clientinterfaces"
+	"diablo-benchmark/blockchains/workloadgenerators"
 	"diablo-benchmark/communication"
 	"diablo-benchmark/core/configs"
 	"diablo-benchmark/core/handlers"
-	"encoding/binary"
-	"encoding/json"
+	"diablo-benchmark/core/results"
+	"errors"
 	"fmt"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -19,161 +22,390 @@ type Secondary struct {
 	ChainConfig     *configs.ChainConfig                 // Chain configuration
 	BenchConfig     *configs.BenchConfig                 // Bench Configuration
 	Blockchain      clientinterfaces.BlockchainInterface // Blockchain Interface
-	PrimaryComms    *communication.ConnClient            // Connection to the primary
+	PrimaryComms    communication.SecondaryTransport     // Connection to the primary
 	WorkloadHandler *handlers.WorkloadHandler            // Workload Handler
+	commands        chan command                         // Commands read from the primary
+	prepared        bool                                 // Whether the primary gave this secondary its ID
+	results         []results.Results                    // Results of the last run, kept until the primary has them
+}
+
+// errPrimaryLost is returned when the primary cannot be reached again
+var errPrimaryLost = errors.New("connection to primary lost")
+
+// command is a command read from the primary, or the error that stopped the reads
+type command struct {
+	cmd communication.Command
+	err error
 }
 
 // NewSecondary creates a new secondary, performs set up for the tcp connection to primary.
-func NewSecondary(chainConfig *configs.ChainConfig, benchConfig *configs.BenchConfig, primaryAddress string) (*Secondary, error) {
+func NewSecondary(chainConfig *configs.ChainConfig, benchConfig *configs.BenchConfig, primaryAddress string, security communication.Security) (*Secondary, error) {
 	// Set up the communication
-	c, err := communication.SetupSecondaryTCP(primaryAddress)
+	c, err := communication.SetupSecondaryTCP(primaryAddress, security)
 	if err != nil {
 		zap.L().Error("failed to connect to primary server")
 		return nil, err
 	}
 
+	return NewSecondaryWithTransport(chainConfig, benchConfig, c), nil
+}
+
+// NewSecondaryWithTransport creates a new secondary receiving the commands of
+// the primary over the transport, which reconnects if it fails.
+func NewSecondaryWithTransport(chainConfig *configs.ChainConfig, benchConfig *configs.BenchConfig, transport communication.SecondaryTransport) *Secondary {
 	// Log and return, ready to go!
 	zap.L().Info("Secondary init")
 	return &Secondary{
 		ChainConfig:  chainConfig,
 		BenchConfig:  benchConfig,
-		PrimaryComms: c,
-	}, nil
+		PrimaryComms: transport,
+	}
 }
 
-// Run is the main loop that performs the receiving of commands and executes relevant actions.
-// This is the main handler loop where all secondary action runs
-func (s *Secondary) Run() {
-	// Main work loop that handles the commands from primary and dispatches
-	// the workload from the benchmark.
-	for {
+// newBlockchainInterfaces creates the client interface of each worker
+func newBlockchainInterfaces(chainConfig *configs.ChainConfig, numThreads uint32) ([]clientinterfaces.BlockchainInterface, error) {
+	var bcis []clientinterfaces.BlockchainInterface
+	for i := uint32(0); i < numThreads; i++ {
+		bc, err := clientinterfaces.GetBlockchainInterface(chainConfig)
+		if err != nil {
+			return nil, err
+		}
+		bcis = append(bcis, bc)
+	}
 
-		cmd, err := s.PrimaryComms.InitialRead()
+	return bcis, nil
+}
 
+// readCommands reads the commands from the primary until the connection
+// fails, so that they can be received while the benchmark is running.
+// The channel is closed after the error.
+func readCommands(c communication.SecondaryTransport, commands chan command) {
+	for {
+		cmd, err := c.ReadCommand()
+		commands <- command{cmd: cmd, err: err}
 		if err != nil {
-			zap.L().Warn("failed to read",
-				zap.String("err", err.Error()))
-
-			s.PrimaryComms.CloseConn()
+			close(commands)
 			return
 		}
+	}
+}
 
-		zap.L().Debug("Received Command Message",
-			zap.Binary("CMD", cmd),
-		)
+// useConn replaces the connection to the primary and reads its commands
+func (s *Secondary) useConn(c communication.SecondaryTransport) {
+	s.PrimaryComms = c
+	s.commands = make(chan command, 1)
+	go readCommands(c, s.commands)
+}
 
-		switch cmd[0] {
-		case communication.MsgPrepare[0]:
-			// Prepare message, did we connect, and are we prepared for work?
-			zap.L().Info("Got command from primary",
-				zap.String("CMD", "PREPARE"))
-			// It should also give us a secondary ID as aux value
-			s.ID = int(cmd[1])
-			s.ID = int(binary.BigEndian.Uint32(cmd[1:5]))
-			numThreads := binary.BigEndian.Uint32(cmd[5:9])
-			// Connect le blockchains
-			var bcis []clientinterfaces.BlockchainInterface
-			for i := uint32(0); i < numThreads; i++ {
-				bc, err := clientinterfaces.GetBlockchainInterface(s.ChainConfig)
-				if err != nil {
-					s.PrimaryComms.ReplyERR(err.Error())
+// reconnect connects to the primary again after losing the connection.
+// Only a secondary with an ID can take its place again.
+func (s *Secondary) reconnect() error {
+	s.PrimaryComms.CloseConn()
+	if !s.prepared {
+		return errPrimaryLost
+	}
+
+	zap.L().Warn("Lost connection to primary, reconnecting")
+	c, err := s.PrimaryComms.Reconnect(s.ID)
+	if err != nil {
+		return err
+	}
+
+	s.useConn(c)
+	return nil
+}
+
+// generateWorkload generates the workload of this secondary from its
+// partition, with the benchmark configuration of the primary.
+func (s *Secondary) generateWorkload(generate communication.GenerateMessage) (workloadgenerators.SecondaryWorkload, error) {
+	generatorClass, err := workloadgenerators.GetWorkloadGenerator(s.ChainConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	bench := generate.Bench
+	g, ok := generatorClass.NewGenerator(s.ChainConfig, bench).(workloadgenerators.PartitionedGenerator)
+	if !ok {
+		return nil, fmt.Errorf("the workload generator of %s cannot generate partitions", s.ChainConfig.Name)
+	}
+
+	g.SetThreadIntervals(workloadgenerators.GetIntervalPerThread(bench.TxInfo.Intervals, bench.Secondaries, bench.Threads))
+	return g.GenerateSecondaryWorkload(generate.Partition)
+}
+
+// runBench runs the benchmark, sending its progress to the primary until it
+// completes, the primary aborts it or the context is cancelled.
+// The run goes on if the connection is lost, while the secondary reconnects.
+// It is stopped with errPrimaryLost if the secondary cannot reconnect.
+func (s *Secondary) runBench(ctx context.Context) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.WorkloadHandler.RunBench(runCtx)
+	}()
+
+	ticker := time.NewTicker(communication.TelemetryInterval)
+	defer ticker.Stop()
+
+	// Set while reconnecting, the commands are not read until then
+	var reconnected chan communication.SecondaryTransport
+	commands := s.commands
+
+	for {
+		select {
+		case err := <-done:
+			if reconnected != nil {
+				// The reply goes to the new connection
+				c := <-reconnected
+				if c == nil {
+					return errPrimaryLost
 				}
-				bcis = append(bcis, bc)
+				s.useConn(c)
 			}
 
-			// Create the workload handler
-			wHandler := handlers.NewWorkloadHandler(
-				numThreads,
-				bcis,
-				s.BenchConfig.Timeout,
-			)
+			// Last progress before the reply
+			s.PrimaryComms.SendTelemetry(s.WorkloadHandler.Telemetry())
+			return err
+		case <-ticker.C:
+			if reconnected == nil {
+				s.PrimaryComms.SendTelemetry(s.WorkloadHandler.Telemetry())
+			}
+		case c := <-reconnected:
+			if c == nil {
+				// The primary is gone, there is no one to run for
+				cancel()
+				<-done
+				return errPrimaryLost
+			}
 
-			s.WorkloadHandler = wHandler
+			reconnected = nil
+			s.useConn(c)
+			commands = s.commands
+		case cmd, ok := <-commands:
+			if !ok || cmd.err != nil {
+				zap.L().Warn("Lost connection to primary during the run, reconnecting")
+				s.PrimaryComms.CloseConn()
+				commands = nil
+				reconnected = make(chan communication.SecondaryTransport, 1)
+				go func(old communication.SecondaryTransport, reconnected chan communication.SecondaryTransport) {
+					c, err := old.Reconnect(s.ID)
+					if err != nil {
+						zap.L().Error("failed to reconnect to primary",
+							zap.Error(err))
+					}
+					reconnected <- c
+				}(s.PrimaryComms, reconnected)
+				continue
+			}
 
-			err := s.WorkloadHandler.Connect(s.ChainConfig, s.ID)
-			if err != nil {
-				s.PrimaryComms.ReplyERR(err.Error())
+			if cmd.cmd.Type == communication.MsgAbort {
+				zap.L().Info("Got command from primary",
+					zap.String("CMD", "ABORT"))
+				cancel()
 				continue
 			}
 
-			zap.L().Debug("Connect and Init of workload handler and client interface OK",
-				zap.Int("ID", s.ID),
-			)
-		case communication.MsgWorkload[0]:
-			zap.L().Info("Got command from primary",
-				zap.String("CMD", "WORKLOAD"))
+			zap.L().Warn("ignoring command received during the run",
+				zap.Stringer("CMD", cmd.cmd.Type))
+		}
+	}
+}
 
-			// Workload length = bytes 1-4
-			workloadLen := binary.BigEndian.Uint64(cmd[1:])
+// prepare connects the workers to the blockchain with the ID and the number
+// of workers given by the primary
+func (s *Secondary) prepare(prepare communication.PrepareMessage) error {
+	s.ID = int(prepare.SecondaryID)
+	numThreads := prepare.Threads
+	// Connect le blockchains
+	bcis, err := newBlockchainInterfaces(s.ChainConfig, numThreads)
+	if err != nil {
+		return err
+	}
 
-			zap.L().Debug("Workload Length",
-				zap.Uint64("length", workloadLen),
-				zap.Binary("raw", cmd[1:]))
+	// Create the workload handler
+	wHandler := handlers.NewWorkloadHandler(
+		numThreads,
+		bcis,
+		s.BenchConfig.Timeout,
+	)
+	wHandler.SetLoadMode(s.BenchConfig.TxInfo.Mode, s.BenchConfig.TxInfo.Outstanding)
+	wHandler.SetPacing(s.BenchConfig.TxInfo.Pacing)
 
-			wl, err := s.PrimaryComms.ReadSize(workloadLen)
+	s.WorkloadHandler = wHandler
 
-			if err != nil {
-				zap.L().Warn("failed to read workload bytes",
-					zap.String("err", err.Error()))
-				s.PrimaryComms.ReplyERR(err.Error())
+	err = s.WorkloadHandler.Connect(s.ChainConfig, s.ID)
+	if err != nil {
+		return err
+	}
+	s.prepared = true
+
+	zap.L().Debug("Connect and Init of workload handler and client interface OK",
+		zap.Int("ID", s.ID),
+	)
+	return nil
+}
+
+// generate generates the workload of this secondary and parses it, and
+// returns its digest for the primary to check.
+func (s *Secondary) generate(generate communication.GenerateMessage) (communication.GenerateReply, error) {
+	workload, err := s.generateWorkload(generate)
+	if err != nil {
+		zap.L().Warn("failed to generate workload",
+			zap.String("err", err.Error()))
+		return communication.GenerateReply{}, err
+	}
+
+	err = s.WorkloadHandler.ParseWorkloads(workload)
+	if err != nil {
+		zap.L().Warn("failed to parse workload",
+			zap.String("err", err.Error()))
+		return communication.GenerateReply{}, err
+	}
+
+	digest, transactions := communication.WorkloadDigest(workload)
+	zap.L().Info("Workload generated",
+		zap.Int("transactions", transactions),
+		zap.String("digest", digest))
+
+	return communication.GenerateReply{Transactions: transactions, Digest: digest}, nil
+}
+
+// Run is the main loop that performs the receiving of commands and executes relevant actions.
+// This is the main handler loop where all secondary action runs.
+// Cancelling the context stops the running benchmark, the secondary then
+// stays until the primary collects the partial results. Otherwise it closes
+// the connection.
+func (s *Secondary) Run(ctx context.Context) {
+	// Main work loop that handles the commands from primary and dispatches
+	// the workload from the benchmark.
+	s.useConn(s.PrimaryComms)
+
+	interrupted := ctx.Done()
+	resultsPending := false
+	for {
+
+		var next command
+		var ok bool
+		select {
+		case next, ok = <-s.commands:
+		case <-interrupted:
+			if resultsPending {
+				zap.L().Warn("Interrupted, waiting for the primary to collect the results")
+				interrupted = nil
 				continue
 			}
 
-			unmarshaledWorkload, err := communication.DecodeWorkload(wl)
+			zap.L().Warn("Interrupted, closing the connection to the primary")
+			s.PrimaryComms.CloseConn()
+			return
+		}
 
-			if err != nil {
-				zap.L().Warn("failed to unmarshal workload",
-					zap.String("err", err.Error()),
-					zap.Uint64("expected_length", workloadLen),
-					zap.Int("length", len(wl)))
-				s.PrimaryComms.ReplyERR(err.Error())
-				continue
+		if !ok {
+			s.PrimaryComms.CloseConn()
+			return
+		}
+		cmd, err := next.cmd, next.err
+
+		if versionErr, ok := err.(*communication.VersionError); ok {
+			// The primary cannot run with this secondary, tell it why
+			zap.L().Error("incompatible primary",
+				zap.Error(versionErr))
+			s.PrimaryComms.ReplyERR(versionErr.Error())
+			s.PrimaryComms.CloseConn()
+			return
+		}
+
+		if errors.Is(err, communication.ErrRejected) {
+			// The primary rejected this secondary, e.g. for a wrong token
+			zap.L().Error("primary rejected the connection",
+				zap.Error(err))
+			s.PrimaryComms.CloseConn()
+			return
+		}
+
+		if err != nil {
+			zap.L().Warn("failed to read",
+				zap.String("err", err.Error()))
+
+			// Buffered results are delivered once reconnected
+			if err := s.reconnect(); err != nil {
+				zap.L().Warn("failed to reconnect",
+					zap.Error(err))
+				return
 			}
+			continue
+		}
 
-			err = s.WorkloadHandler.ParseWorkloads(unmarshaledWorkload)
+		switch cmd.Type {
+		case communication.MsgPrepare:
+			// Prepare message, did we connect, and are we prepared for work?
+			zap.L().Info("Got command from primary",
+				zap.String("CMD", "PREPARE"))
+			// It gives us a secondary ID and the number of workers
+			s.PrimaryComms.ReplyPrepare(s.prepare(cmd.Prepare))
+		case communication.MsgWorkload:
+			zap.L().Info("Got command from primary",
+				zap.String("CMD", "WORKLOAD"))
+
+			err := s.WorkloadHandler.ParseWorkloads(cmd.Workload)
 			if err != nil {
 				zap.L().Warn("failed to parse workload",
 					zap.String("err", err.Error()))
-				s.PrimaryComms.ReplyERR(err.Error())
-				continue
+			} else {
+				zap.L().Debug("Workload received OK",
+					zap.Int("Length", len(cmd.Workload)),
+				)
 			}
 
-			zap.L().Debug("Workload unmarshal OK",
-				zap.Int("Length", len(unmarshaledWorkload)),
-			)
+			s.PrimaryComms.ReplyWorkload(err)
+		case communication.MsgGenerate:
+			zap.L().Info("Got command from primary",
+				zap.String("CMD", "GENERATE"))
 
-		case communication.MsgRun[0]:
+			s.PrimaryComms.ReplyGenerate(s.generate(cmd.Generate))
+		case communication.MsgRun:
 			zap.L().Info("Got command from primary",
 				zap.String("CMD", "RUN"))
-			errs := s.WorkloadHandler.RunBench()
+			if cmd.Run.StartTime > 0 {
+				s.WorkloadHandler.SetStartTime(time.Unix(0, cmd.Run.StartTime))
+			}
+			resultsPending = true
+			s.results = nil
+			errs := s.runBench(ctx)
+			if errs == errPrimaryLost {
+				zap.L().Error("Primary lost during the run")
+				return
+			}
 			if errs != nil {
 				zap.L().Warn("error during bench",
-					zap.Error(err))
-				s.PrimaryComms.ReplyERR(err.Error())
-				continue
+					zap.Error(errs))
 			}
-		case communication.MsgResults[0]:
+			s.PrimaryComms.ReplyRun(errs)
+		case communication.MsgResults:
 			zap.L().Info("Got command from primary",
 				zap.String("CMD", "RESULTS"))
-			res := s.WorkloadHandler.HandleCleanup()
-			resBytes, err := json.Marshal(res)
-			if err != nil {
-				s.PrimaryComms.ReplyERR("failed to convert results to bytes")
+			// The results are kept in case the reply is lost
+			if s.results == nil {
+				s.results = s.WorkloadHandler.HandleCleanup()
 			}
-			fmt.Println(resBytes)
-			s.PrimaryComms.SendDataOK(resBytes)
-		case communication.MsgFin[0]:
+			// The results are the reply
+			s.PrimaryComms.ReplyResults(s.results, nil)
+			resultsPending = false
+		case communication.MsgFin:
 			zap.L().Info("Got command from primary",
 				zap.String("CMD", "FIN"))
-			s.WorkloadHandler.CloseAll()
+			if s.WorkloadHandler != nil {
+				s.WorkloadHandler.CloseAll()
+			}
+			s.PrimaryComms.ReplyFin()
+			s.PrimaryComms.CloseConn()
 			return
-		default:
-			// Return that there was no matching command
-			s.PrimaryComms.ReplyERR("no matching command")
+		case communication.MsgAbort:
+			// The run is already over
 			continue
 		}
-
-		s.PrimaryComms.ReplyOK()
 	}
 
 }
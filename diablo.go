@@ -18,12 +18,17 @@
 package main
 
 import (
+	"context"
+	"diablo-benchmark/blockchains/chains"
 	"diablo-benchmark/blockchains/workloadgenerators"
+	"diablo-benchmark/communication"
 	"diablo-benchmark/core"
 	"diablo-benchmark/core/configs"
 	"diablo-benchmark/core/configs/parsers"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -150,12 +155,19 @@ func runPrimary(primaryArgs *core.PrimaryArgs) {
 
 	wg := generatorClass.NewGenerator(cConfig, bConfig)
 
+	security := loadSecurity(primaryArgs.TLSCert, primaryArgs.TLSKey, primaryArgs.Token)
+
 	// Initialise the TCP server
-	m := core.InitPrimary(primaryArgs.ListenAddr, bConfig.Secondaries, wg, bConfig, cConfig)
+	m, err := core.InitPrimary(primaryArgs.ListenAddr, bConfig.Secondaries, wg, bConfig, cConfig, security, primaryArgs.Compression)
+	if err != nil {
+		zap.L().Error("failed to start the primary server",
+			zap.Error(err))
+		os.Exit(1)
+	}
 
 	// Run the benchmark flow
 	zap.L().Info("Primary ready, running benchmark flow")
-	m.Run()
+	m.Run(interruptContext())
 }
 
 // Run the secondary
@@ -173,23 +185,120 @@ func runSecondary(secondaryArgs *core.SecondaryArgs) {
 	benchConfiguration, err := parsers.ParseBenchConfig(secondaryArgs.BenchConfigPath)
 
 	// Check the timeout with args
-	if secondaryArgs.Timeout == 0 && benchConfiguration.Timeout <= 0 {
+	setTimeout(benchConfiguration, secondaryArgs.Timeout)
+
+	security := loadSecurity(secondaryArgs.TLSCert, secondaryArgs.TLSKey, secondaryArgs.Token)
+
+	secondary, err := core.NewSecondary(chainConfiguration, benchConfiguration, secondaryArgs.PrimaryAddr, security)
+
+	if err != nil {
+		zap.L().Error("Failed to start new secondary",
+			zap.Error(err))
+		os.Exit(1)
+	}
+
+	secondary.Run(interruptContext())
+}
+
+// Sets the timeout of the secondaries, the flag overrides the configuration
+func setTimeout(benchConfiguration *configs.BenchConfig, timeout int) {
+	if timeout == 0 && benchConfiguration.Timeout <= 0 {
 		zap.L().Warn(fmt.Sprintf("Invalid or no timeout provided, defaulting to %d", configs.DefaultTimeout))
 		benchConfiguration.Timeout = configs.DefaultTimeout
-	} else if secondaryArgs.Timeout > 0 {
-		zap.L().Warn(fmt.Sprintf("Overwriting config timeout (%d) with flag %d", benchConfiguration.Timeout, secondaryArgs.Timeout))
-		benchConfiguration.Timeout = secondaryArgs.Timeout
+	} else if timeout > 0 {
+		zap.L().Warn(fmt.Sprintf("Overwriting config timeout (%d) with flag %d", benchConfiguration.Timeout, timeout))
+		benchConfiguration.Timeout = timeout
 	}
+}
 
-	secondary, err := core.NewSecondary(chainConfiguration, benchConfiguration, secondaryArgs.PrimaryAddr)
+// Run the primary and the secondaries in this process
+func runLocal(localArgs *core.LocalArgs) {
+	localArgs.CheckArgs()
 
+	bConfig, err := parsers.ParseBenchConfig(localArgs.BenchConfigPath)
 	if err != nil {
-		zap.L().Error("Failed to start new secondary",
+		zap.L().Error(err.Error())
+		os.Exit(1)
+	}
+
+	cConfig, err := parsers.ParseChainConfig(localArgs.ChainConfigPath)
+	if err != nil {
+		zap.L().Error(err.Error())
+		os.Exit(1)
+	}
+
+	setTimeout(bConfig, localArgs.Timeout)
+
+	generatorClass, err := workloadgenerators.GetWorkloadGenerator(cConfig)
+	if err != nil {
+		zap.L().Error("failed to get workload generators",
+			zap.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	wg := generatorClass.NewGenerator(cConfig, bConfig)
+
+	zap.L().Info("Running the benchmark locally",
+		zap.Int("secondaries", bConfig.Secondaries))
+	if core.RunLocal(interruptContext(), wg, bConfig, cConfig, localArgs.ResultsDir) == nil {
+		os.Exit(1)
+	}
+}
+
+// loadSecurity loads the authentication of the connections between the
+// primary and the secondaries, and exits if it cannot.
+func loadSecurity(certFile string, keyFile string, token string) communication.Security {
+	security, err := communication.LoadSecurity(certFile, keyFile, token)
+	if err != nil {
+		zap.L().Error("failed to load TLS configuration",
 			zap.Error(err))
 		os.Exit(1)
 	}
 
-	secondary.Run()
+	if security.TLS == nil {
+		if security.Token != "" {
+			zap.L().Warn("Token without TLS, the workloads are sent in clear text")
+		} else {
+			zap.L().Warn("No TLS or token, any host reaching the primary can join the benchmark")
+		}
+	}
+
+	return security
+}
+
+// interruptContext returns a context cancelled on SIGINT or SIGTERM, so that the
+// benchmark stops cleanly. A second signal exits immediately.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		zap.L().Warn("Interrupted, stopping the benchmark (interrupt again to exit now)",
+			zap.Stringer("signal", sig))
+		cancel()
+
+		<-signals
+		os.Exit(1)
+	}()
+
+	return ctx
+}
+
+// Lists the chains that can be benchmarked, the name to give in the chain configuration
+func listChains() {
+	fmt.Println("Available chains:")
+	for _, c := range chains.List() {
+		switch {
+		case c.Complete():
+			fmt.Printf("  %s\n", c.Name)
+		case c.HasInterface:
+			fmt.Printf("  %s (no workload generator)\n", c.Name)
+		default:
+			fmt.Printf("  %s (no client interface)\n", c.Name)
+		}
+	}
 }
 
 // Main running function
@@ -198,7 +307,7 @@ func main() {
 
 	if len(os.Args) < 2 {
 		// This is going to be a primary
-		fmt.Fprintf(os.Stderr, "No subcommand given (primary/secondary), exiting!")
+		fmt.Fprintf(os.Stderr, "No subcommand given (primary/secondary/local/chains), exiting!")
 		os.Exit(1)
 	} else {
 		switch os.Args[1] {
@@ -226,6 +335,19 @@ func main() {
 				os.Exit(1)
 			}
 			runSecondary(args.SecondaryArgs)
+
+		case "local":
+			// Print the welcome message
+			printWelcome(true)
+
+			// Parse the arguments
+			args.LocalCommand.Parse(os.Args[2:])
+
+			prepareLogger("local", args.LocalArgs.LogLevel)
+			runLocal(args.LocalArgs)
+
+		case "chains":
+			listChains()
 		}
 	}
 }
@@ -4,6 +4,8 @@ go 1.14
 
 require (
 	github.com/ethereum/go-ethereum v1.9.15
+	github.com/golang/protobuf v1.3.3
+	github.com/hyperledger/fabric-protos-go v0.0.0-20200707132912-fee30f3ccd23
 	github.com/hyperledger/fabric-sdk-go v1.0.0-rc1
 	go.uber.org/zap v1.15.0
 	gopkg.in/yaml.v3 v3.0.0-20200601152816-913338de1bd2
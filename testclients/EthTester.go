@@ -128,7 +128,7 @@ func main() {
 	var parsedTx types.Transaction
 	_ = json.Unmarshal(tx, &parsedTx)
 
-	err = E.SendRawTransaction(&parsedTx)
+	err = E.SendRawTransaction(context.Background(), &parsedTx)
 
 
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"diablo-benchmark/blockchains/clientinterfaces"
 	blockchaintypes "diablo-benchmark/blockchains/types"
 	"diablo-benchmark/blockchains/workloadgenerators"
@@ -66,7 +67,7 @@ func main(){
 
 
 	log.Println("sendRawTransaction via client1 FIRST TIME EXPECTING BUG")
-	err = client1.SendRawTransaction(createAssetTransaction(0,generator))
+	err = client1.SendRawTransaction(context.Background(), createAssetTransaction(0,generator))
 	////err = client2.SendRawTransaction(createAssetTransaction(0,generator))
 //
 	workload,err := generator.GenerateWorkload()
@@ -80,7 +81,7 @@ func main(){
 	}
  	for _,intervals := range parsedWorkload1 {
 		for _, tx := range intervals {
-			client1.SendRawTransaction(tx)
+			client1.SendRawTransaction(context.Background(), tx)
 		}
 	}
 
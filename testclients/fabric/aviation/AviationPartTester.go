@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"diablo-benchmark/blockchains/clientinterfaces"
 	blockchaintypes "diablo-benchmark/blockchains/types"
 	"diablo-benchmark/blockchains/workloadgenerators"
@@ -67,13 +68,13 @@ func main(){
 
 
 	log.Println("sendRawTransaction via client1 FIRST TIME EXPECTING BUG")
-	err = client1.SendRawTransaction(createPartTransaction(0,"Alice",generator))
+	err = client1.SendRawTransaction(context.Background(), createPartTransaction(0,"Alice",generator))
 
 	for i := 1; i < 10; i++ {
 		if i%2 == 0 {
-			client1.SendRawTransaction(createPartTransaction(i,"Alice",generator))
+			client1.SendRawTransaction(context.Background(), createPartTransaction(i,"Alice",generator))
 		}else {
-			client1.SendRawTransaction(createPartTransaction(i,"Bob",generator))
+			client1.SendRawTransaction(context.Background(), createPartTransaction(i,"Bob",generator))
 		}
 
 	}
@@ -93,7 +94,7 @@ func main(){
 //
 	//for _,intervals := range parsedWorkload1 {
 	//	for _, tx := range intervals {
-	//		client1.SendRawTransaction(tx)
+	//		client1.SendRawTransaction(context.Background(), tx)
 	//	}
 	//}
 //